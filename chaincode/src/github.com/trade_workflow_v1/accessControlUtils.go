@@ -57,6 +57,34 @@ func getTxCreatorInfo(stub shim.ChaincodeStubInterface) (string, string, error)
 	return mspid, cert.Issuer.CommonName, nil
 }
 
+// Distinguishes individual users within an org, e.g., a maker and a checker
+func getTxCreatorSubject(stub shim.ChaincodeStubInterface) (string, error) {
+	var err error
+	var cert *x509.Certificate
+
+	cert, err = cid.GetX509Certificate(stub)
+	if err != nil {
+		fmt.Printf("Error getting client certificate: %s\n", err.Error())
+		return "", err
+	}
+
+	return getCertificateSubject(cert), nil
+}
+
+func getCertificateSubject(cert *x509.Certificate) string {
+	var subject string
+
+	subject = "CN=" + cert.Subject.CommonName
+	for _, ou := range cert.Subject.OrganizationalUnit {
+		subject += ",OU=" + ou
+	}
+	for _, o := range cert.Subject.Organization {
+		subject += ",O=" + o
+	}
+
+	return subject
+}
+
 // For now, just hardcode an ACL
 // We will support attribute checks in an upgrade
 
@@ -80,6 +108,58 @@ func authenticateCarrierOrg(mspID string, certCN string) bool {
 	return (mspID == "CarrierOrgMSP") && (certCN == "ca.carrierorg.trade.com")
 }
 
+// IoT devices are enrolled by the Carrier Org's CA with the attribute 'device=true'
+func authenticateCarrierDevice(stub shim.ChaincodeStubInterface, mspID string, certCN string) bool {
+	var value string
+	var found bool
+	var err error
+
+	if !authenticateCarrierOrg(mspID, certCN) {
+		return false
+	}
+	value, found, err = getCustomAttribute(stub, "device")
+	return err == nil && found && value == "true"
+}
+
 func authenticateRegulatorOrg(mspID string, certCN string) bool {
 	return (mspID == "RegulatorOrgMSP") && (certCN == "ca.regulatororg.trade.com")
 }
+
+// Customs officers are enrolled by the Regulator Org's CA with the attribute 'customs=true'
+func authenticateCustomsOfficer(stub shim.ChaincodeStubInterface, mspID string, certCN string) bool {
+	var value string
+	var found bool
+	var err error
+
+	if !authenticateRegulatorOrg(mspID, certCN) {
+		return false
+	}
+	value, found, err = getCustomAttribute(stub, "customs")
+	return err == nil && found && value == "true"
+}
+
+// Cargo insurers are enrolled by the Lender Org's CA with the attribute 'insurer=true'
+func authenticateInsurer(stub shim.ChaincodeStubInterface, mspID string, certCN string) bool {
+	var value string
+	var found bool
+	var err error
+
+	if !authenticateLenderOrg(mspID, certCN) {
+		return false
+	}
+	value, found, err = getCustomAttribute(stub, "insurer")
+	return err == nil && found && value == "true"
+}
+
+// Arbitrators are enrolled by the Regulator Org's CA with the attribute 'arbitrator=true'
+func authenticateArbitrator(stub shim.ChaincodeStubInterface, mspID string, certCN string) bool {
+	var value string
+	var found bool
+	var err error
+
+	if !authenticateRegulatorOrg(mspID, certCN) {
+		return false
+	}
+	value, found, err = getCustomAttribute(stub, "arbitrator")
+	return err == nil && found && value == "true"
+}
@@ -0,0 +1,366 @@
+/*
+ * Copyright 2018 IBM All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the 'License');
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an 'AS IS' BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const hoursPerDay = 24
+
+// Returns disabled rules if the Regulator has not set any
+func getAMLRules(stub shim.ChaincodeStubInterface) (*AMLRules, error) {
+	var amlRulesBytes []byte
+	var amlRules *AMLRules
+	var err error
+
+	// Lookup AML rules from the ledger
+	amlRulesBytes, err = stub.GetState(amlRulesKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(amlRulesBytes) == 0 {
+		return &AMLRules{}, nil
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(amlRulesBytes, &amlRules)
+	if err != nil {
+		return nil, err
+	}
+	return amlRules, nil
+}
+
+// Payments and transfers are private to the parties that can see the amounts; the monitoring rules read them from there
+// Activity is kept per pair of parties, payee first, so that a rule reads only the activity of the parties it checks
+func getPaymentActivity(stub shim.ChaincodeStubInterface, payee string) ([]PaymentActivity, error) {
+	var iterator shim.StateQueryIteratorInterface
+	var kv *queryresult.KV
+	var activity []PaymentActivity
+	var previous PaymentActivity
+	var err error
+
+	iterator, err = getPrivateDataByPartialCompositeKey(stub, tradeTermsCollection, "AMLActivity", []string{payee})
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+
+	activity = []PaymentActivity{}
+	for iterator.HasNext() {
+		kv, err = iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		previous = PaymentActivity{}
+		err = json.Unmarshal(kv.Value, &previous)
+		if err != nil {
+			return nil, err
+		}
+		activity = append(activity, previous)
+	}
+	return activity, nil
+}
+
+// Record a payment or a transfer under the pair of parties it moves between
+func putPaymentActivity(stub shim.ChaincodeStubInterface, activity PaymentActivity, index int) error {
+	var amlActivityKey string
+	var activityBytes []byte
+	var err error
+
+	activityBytes, err = json.Marshal(&activity)
+	if err != nil {
+		return errors.New("Error marshaling payment activity structure")
+	}
+	amlActivityKey, err = getAMLActivityKey(stub, activity.Payee, activity.Payer, activity.Timestamp, stub.GetTxID(), activity.Type, strconv.Itoa(index))
+	if err != nil {
+		return err
+	}
+	return putPrivateData(stub, tradeTermsCollection, amlActivityKey, activityBytes)
+}
+
+// Record the transfer of a trade's L/C, which starts the window of the TRANSFER_PAYMENT rule
+func recordLCTransferActivity(stub shim.ChaincodeStubInterface, tradeID string, transferor string, transferee string) error {
+	var now time.Time
+	var err error
+
+	now, err = getTxTime(stub)
+	if err != nil {
+		return err
+	}
+	return putPaymentActivity(stub, PaymentActivity{TRANSFER, tradeID, transferor, transferee, 0, now.Format(time.RFC3339)}, 0)
+}
+
+// Evaluate the AML rules on a payment, and record a flag for each rule it trips
+// Flags are written to a collection held only by the Regulator; the paying parties can neither read nor suppress them
+func monitorPayment(stub shim.ChaincodeStubInterface, function string, tradeID string, payer string, payee string, amount int) error {
+	return monitorPayments(stub, function, tradeID, payer, []string{payee}, []int{amount})
+}
+
+// Evaluate the AML rules on a payment split between several payees, as a payment to each
+func monitorPayments(stub shim.ChaincodeStubInterface, function string, tradeID string, payer string, payees []string, amounts []int) error {
+	var amlRules *AMLRules
+	var activity []PaymentActivity
+	var flags []AMLFlag
+	var trades map[string]bool
+	var now, recorded time.Time
+	var timestamp, amlFlagKey string
+	var amlFlagBytes []byte
+	var err error
+
+	now, err = getTxTime(stub)
+	if err != nil {
+		return err
+	}
+	timestamp = now.Format(time.RFC3339)
+	amlRules, err = getAMLRules(stub)
+	if err != nil {
+		return err
+	}
+
+	for i, payee := range payees {
+		activity, err = getPaymentActivity(stub, payee)
+		if err != nil {
+			return err
+		}
+
+		// A single payment over the threshold
+		if amlRules.PaymentThreshold > 0 && amounts[i] > amlRules.PaymentThreshold {
+			flags = append(flags, AMLFlag{LARGE_PAYMENT, tradeID, function, payer, payee, amounts[i], timestamp, stub.GetTxID(),
+				fmt.Sprintf("Payment of %d exceeds the threshold of %d", amounts[i], amlRules.PaymentThreshold)})
+		}
+
+		// Many trades paid between the same parties within the window, counting this one
+		if amlRules.TradeCountThreshold > 0 && amlRules.TradeCountWindowDays > 0 {
+			trades = map[string]bool{tradeID: true}
+			for _, previous := range activity {
+				recorded, err = time.Parse(time.RFC3339, previous.Timestamp)
+				if err != nil {
+					return err
+				}
+				if previous.Type == PAYMENT && previous.Payer == payer && previous.Payee == payee && now.Sub(recorded).Hours() <= float64(amlRules.TradeCountWindowDays * hoursPerDay) {
+					trades[previous.TradeId] = true
+				}
+			}
+			if len(trades) >= amlRules.TradeCountThreshold {
+				flags = append(flags, AMLFlag{FREQUENT_TRADES, tradeID, function, payer, payee, amounts[i], timestamp, stub.GetTxID(),
+					fmt.Sprintf("%d trades paid by %s to %s within %d days", len(trades), payer, payee, amlRules.TradeCountWindowDays)})
+			}
+		}
+
+		// A payment to the party the trade's L/C was transferred to, shortly after the transfer
+		if amlRules.TransferWindowDays > 0 {
+			for _, previous := range activity {
+				recorded, err = time.Parse(time.RFC3339, previous.Timestamp)
+				if err != nil {
+					return err
+				}
+				if previous.Type == TRANSFER && previous.TradeId == tradeID && previous.Payee == payee && now.Sub(recorded).Hours() <= float64(amlRules.TransferWindowDays * hoursPerDay) {
+					flags = append(flags, AMLFlag{TRANSFER_PAYMENT, tradeID, function, payer, payee, amounts[i], timestamp, stub.GetTxID(),
+						fmt.Sprintf("Payment to %s %d days after the L/C was transferred from %s", payee, int(now.Sub(recorded).Hours()) / hoursPerDay, previous.Payer)})
+					break
+				}
+			}
+		}
+
+		err = putPaymentActivity(stub, PaymentActivity{PAYMENT, tradeID, payer, payee, amounts[i], timestamp}, i)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, flag := range flags {
+		amlFlagBytes, err = json.Marshal(&flag)
+		if err != nil {
+			return errors.New("Error marshaling AML flag structure")
+		}
+		amlFlagKey, err = getAMLFlagKey(stub, flag.Timestamp, flag.TxId, flag.Rule, flag.Payee)
+		if err != nil {
+			return err
+		}
+		err = putPrivateData(stub, amlFlagsCollection, amlFlagKey, amlFlagBytes)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Payment for trade %s flagged by AML rule %s\n", tradeID, flag.Rule)
+	}
+
+	return nil
+}
+
+// Set the thresholds of the AML monitoring rules
+func (t *TradeWorkflowChaincode) setAMLRules(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var amlRules *AMLRules
+	var amlRulesBytes []byte
+	var err error
+
+	// Access control: Only a Regulator Org member can invoke this transaction
+	if !t.testMode && !authenticateRegulatorOrg(creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a member of Regulator Org. Access denied.")
+	}
+
+	if len(args) != 1 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 1: {Rules}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+
+	err = json.Unmarshal([]byte(args[0]), &amlRules)
+	if err != nil || amlRules == nil {
+		return shim.Error("Rules must be a JSON object of {paymentThreshold, tradeCountThreshold, tradeCountWindowDays, transferWindowDays}")
+	}
+	if amlRules.PaymentThreshold < 0 || amlRules.TradeCountThreshold < 0 || amlRules.TradeCountWindowDays < 0 || amlRules.TransferWindowDays < 0 {
+		return shim.Error("Thresholds and windows must not be negative")
+	}
+	if (amlRules.TradeCountThreshold > 0) != (amlRules.TradeCountWindowDays > 0) {
+		return shim.Error("Trade count threshold and window must be set together")
+	}
+
+	amlRulesBytes, err = json.Marshal(amlRules)
+	if err != nil {
+		return shim.Error("Error marshaling AML rules structure")
+	}
+	// Write the state to the ledger
+	err = stub.PutState(amlRulesKey, amlRulesBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("AML rules recorded\n")
+
+	return shim.Success(nil)
+}
+
+// Get the thresholds of the AML monitoring rules
+func (t *TradeWorkflowChaincode) getAMLRulesQuery(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var amlRules *AMLRules
+	var amlRulesBytes []byte
+	var err error
+
+	// Access control: Only a Regulator Org member can invoke this transaction
+	if !t.testMode && !authenticateRegulatorOrg(creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a member of Regulator Org. Access denied.")
+	}
+
+	if len(args) != 0 {
+		return shim.Error("Incorrect number of arguments. Expecting 0")
+	}
+
+	amlRules, err = getAMLRules(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	amlRulesBytes, err = json.Marshal(amlRules)
+	if err != nil {
+		return shim.Error("Error marshaling AML rules structure")
+	}
+	fmt.Printf("Query Response:%s\n", string(amlRulesBytes))
+	return shim.Success(amlRulesBytes)
+}
+
+// List the AML flags raised, oldest first, optionally only those involving a party, and raised within a date range
+func (t *TradeWorkflowChaincode) listFlags(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var party string
+	var from, to, raised time.Time
+	var iterator shim.StateQueryIteratorInterface
+	var kv *queryresult.KV
+	var flags []AMLFlag
+	var flag AMLFlag
+	var flagsBytes []byte
+	var err error
+
+	// Access control: Only a Regulator Org member can invoke this transaction
+	if !t.testMode && !authenticateRegulatorOrg(creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a member of Regulator Org. Access denied.")
+	}
+
+	if len(args) > 3 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting at most 3: {Party, From Date, To Date}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+
+	// Every filter is optional; an empty argument matches all flags
+	if len(args) > 0 {
+		party = args[0]
+	}
+	if len(args) > 1 && args[1] != "" {
+		from, err = time.Parse(dateLayout, args[1])
+		if err != nil {
+			err = errors.New(fmt.Sprintf("Invalid from date %s; expecting MM/DD/YYYY", args[1]))
+			return shim.Error(err.Error())
+		}
+	}
+	if len(args) > 2 && args[2] != "" {
+		to, err = time.Parse(dateLayout, args[2])
+		if err != nil {
+			err = errors.New(fmt.Sprintf("Invalid to date %s; expecting MM/DD/YYYY", args[2]))
+			return shim.Error(err.Error())
+		}
+		if to.Before(from) {
+			return shim.Error("To date must not precede from date")
+		}
+	}
+
+	iterator, err = getPrivateDataByPartialCompositeKey(stub, amlFlagsCollection, "AMLFlag", []string{})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	defer iterator.Close()
+
+	flags = []AMLFlag{}
+	for iterator.HasNext() {
+		kv, err = iterator.Next()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		flag = AMLFlag{}
+		err = json.Unmarshal(kv.Value, &flag)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		raised, err = time.Parse(time.RFC3339, flag.Timestamp)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		// The to date is inclusive
+		if !from.IsZero() && raised.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !raised.Before(to.AddDate(0, 0, 1)) {
+			continue
+		}
+		if party != "" && !namesMatch(party, flag.Payer) && !namesMatch(party, flag.Payee) {
+			continue
+		}
+		flags = append(flags, flag)
+	}
+
+	flagsBytes, err = json.Marshal(flags)
+	if err != nil {
+		return shim.Error("Error marshaling AML flags")
+	}
+	fmt.Printf("Query Response:%s\n", string(flagsBytes))
+	return shim.Success(flagsBytes)
+}
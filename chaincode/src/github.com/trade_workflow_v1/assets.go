@@ -16,21 +16,47 @@
 
 package main
 
+// Amount, Payment, DelayPenaltyRate and Penalty are kept in the trade terms private data collection
+// The delivery window and the telemetry thresholds are public, so that the Carrier and its devices can check against them
 type TradeAgreement struct {
-	Amount						int			`json:"amount"`
+	Amount						int			`json:"-"`
 	DescriptionOfGoods			string		`json:"descriptionOfGoods"`
+	Quantity					int			`json:"quantity,omitempty"`
 	Status						string		`json:"status"`
-	Payment						int			`json:"payment"`
+	Payment						int			`json:"-"`
+	DeliveryWindowStart			string		`json:"deliveryWindowStart,omitempty"`
+	DeliveryWindowEnd			string		`json:"deliveryWindowEnd,omitempty"`
+	DelayPenaltyRate			float32		`json:"-"`
+	Penalty						int			`json:"-"`
+	TelemetryThresholds			*TelemetryThresholds	`json:"telemetryThresholds,omitempty"`
+	TermsHash					string		`json:"termsHash"`
 }
 
+// Amount is kept in the trade terms private data collection
+// Beneficiary is the first beneficiary, who retains the part of the amount not transferred to second beneficiaries
 type LetterOfCredit struct {
 	Id							string		`json:"id"`
 	ExpirationDate				string		`json:"expirationDate"`
 	Beneficiary					string		`json:"beneficiary"`
-	Amount						int			`json:"amount"`
-	Documents					[]string	`json:"documents"`
+	Amount						int			`json:"-"`
+	Documents					[]DocumentReference	`json:"documents"`
 	Status						string		`json:"status"`
-	DiscountRate				float32		`json:"discountRate"`
+	PartialShipments			bool		`json:"partialShipments"`
+	Transfers					[]LCTransfer	`json:"transfers,omitempty"`
+	TermsHash					string		`json:"termsHash"`
+}
+
+// A transfer of part of an L/C to a second beneficiary (UCP 600 article 38)
+// Amount, the part of the L/C amount allocated to the beneficiary and not yet paid, and DiscountRate are kept in the
+// trade terms private data collection
+type LCTransfer struct {
+	Beneficiary					string		`json:"beneficiary"`
+	Status						string		`json:"status"`
+	Amount						int			`json:"-"`
+	DiscountRate				float32		`json:"-"`
+	AnnualRate					float32		`json:"-"`
+	DayCount					string		`json:"dayCount,omitempty"`
+	ExpectedPaymentDate			string		`json:"expectedPaymentDate,omitempty"`
 	AdvancePaymentSettlement	bool		`json:"advancePaymentSettlement"`
 }
 
@@ -40,18 +66,616 @@ type ExportLicense struct {
 	Exporter					string		`json:"exporter"`
 	Carrier						string		`json:"carrier"`
 	DescriptionOfGoods			string		`json:"descriptionOfGoods"`
+	HsCode						string		`json:"hsCode,omitempty"`
+	Approver					string		`json:"approver"`
+	Status						string		`json:"status"`
+	Conditions					*ExportLicenseConditions	`json:"conditions,omitempty"`
+	Reason						string		`json:"reason,omitempty"`
+	Standalone					bool		`json:"standalone,omitempty"`
+	Signature					string		`json:"signature,omitempty"`
+	SignerCertificate			string		`json:"signerCertificate,omitempty"`
+}
+
+// Conditions a Regulator attaches to an E/L; the license is valid from ValidFrom through its expiration date
+type ExportLicenseConditions struct {
+	ValidFrom					string		`json:"validFrom,omitempty"`
+	MaxQuantity					int			`json:"maxQuantity,omitempty"`
+	Destinations				[]string	`json:"destinations,omitempty"`
+}
+
+// An E/L issued to an exporter for a quantity and value of goods over a period, rather than for a single trade
+// Trades that reference it (see ExportLicense.Standalone) draw down its quota as their goods are prepared for shipment
+type StandaloneExportLicense struct {
+	Id							string		`json:"id"`
+	ExpirationDate				string		`json:"expirationDate"`
+	Exporter					string		`json:"exporter"`
+	DescriptionOfGoods			string		`json:"descriptionOfGoods"`
 	Approver					string		`json:"approver"`
 	Status						string		`json:"status"`
+	Conditions					*ExportLicenseConditions	`json:"conditions,omitempty"`
+	Reason						string		`json:"reason,omitempty"`
+	QuantityQuota				int			`json:"quantityQuota"`
+	ValueQuota					int			`json:"valueQuota"`
+	QuantityDrawn				int			`json:"quantityDrawn"`
+	ValueDrawn					int			`json:"-"`
+	Drawdowns					[]QuotaDrawdown	`json:"drawdowns"`
+	Signature					string		`json:"signature,omitempty"`
+	SignerCertificate			string		`json:"signerCertificate,omitempty"`
+	TermsHash					string		`json:"termsHash,omitempty"`
+}
+
+// The goods of a trade, or of one of its partial shipments, counted against a standalone E/L
+// Value is the trade amount, or the shipment's share of it; it is kept, with the value drawn on the license, in the export quota
+// private data collection, which the Regulator holds to enforce the quota
+type QuotaDrawdown struct {
+	TradeId						string		`json:"tradeId"`
+	ShipmentId					string		`json:"shipmentId,omitempty"`
+	Quantity					int			`json:"quantity"`
+	Value						int			`json:"-"`
+	Timestamp					string		`json:"timestamp"`
 }
 
+// The price of the goods is not recorded on the B/L; the Carrier has no access to commercial terms
 type BillOfLading struct {
 	Id							string		`json:"id"`
 	ExpirationDate				string		`json:"expirationDate"`
 	Exporter					string		`json:"exporter"`
 	Carrier						string		`json:"carrier"`
 	DescriptionOfGoods			string		`json:"descriptionOfGoods"`
-	Amount						int			`json:"amount"`
+	Quantity					int			`json:"quantity,omitempty"`
 	Beneficiary					string		`json:"beneficiary"`
 	SourcePort					string		`json:"sourcePort"`
 	DestinationPort				string		`json:"destinationPort"`
+	Containers					[]ContainerReference	`json:"containers,omitempty"`
+	Holder						string		`json:"holder,omitempty"`
+	HolderOrg					string		`json:"holderOrg,omitempty"`
+	HolderSubject				string		`json:"holderSubject,omitempty"`
+	Status						string		`json:"status,omitempty"`
+	Endorsements				[]Endorsement	`json:"endorsements,omitempty"`
+	Signature					string		`json:"signature,omitempty"`
+	SignerCertificate			string		`json:"signerCertificate,omitempty"`
+}
+
+// Cargo insurance on a trade's shipment; claims are paid from the Insurer's account up to the insured value
+// The insured value, premium and amount paid out are kept in the trade terms private data collection
+type InsurancePolicy struct {
+	Id							string		`json:"id"`
+	TradeId						string		`json:"tradeId"`
+	Insurer						string		`json:"insurer"`
+	InsuredValue				int			`json:"-"`
+	Coverage					string		`json:"coverage"`
+	Premium						int			`json:"-"`
+	PremiumPayer				string		`json:"premiumPayer"`
+	ExpirationDate				string		`json:"expirationDate"`
+	Status						string		`json:"status"`
+	Claims						[]string	`json:"claims"`
+	PaidOut						int			`json:"-"`
+	TermsHash					string		`json:"termsHash,omitempty"`
+}
+
+// The amount claimed and the payout are kept in the trade terms private data collection
+type InsuranceClaim struct {
+	Id							string		`json:"id"`
+	TradeId						string		`json:"tradeId"`
+	PolicyId					string		`json:"policyId"`
+	Claimant					string		`json:"claimant"`
+	ClaimantRole				string		`json:"claimantRole"`
+	Amount						int			`json:"-"`
+	Incidents					[]ShipmentIncident	`json:"incidents"`
+	Description					string		`json:"description"`
+	Status						string		`json:"status"`
+	Payout						int			`json:"-"`
+	Reason						string		`json:"reason,omitempty"`
+	TermsHash					string		`json:"termsHash,omitempty"`
+}
+
+// Evidence lists the documents attached to the dispute; payments on the trade are frozen while it is OPEN
+// Amount, paid or refunded on resolution, is kept in the trade terms private data collection
+type Dispute struct {
+	Id							string		`json:"id"`
+	TradeId						string		`json:"tradeId"`
+	Claimant					string		`json:"claimant"`
+	ClaimantOrg					string		`json:"claimantOrg"`
+	Reason						string		`json:"reason"`
+	Evidence					[]string	`json:"evidence"`
+	Status						string		`json:"status"`
+	OpenedAt					string		`json:"openedAt"`
+	Arbitrator					string		`json:"arbitrator,omitempty"`
+	Outcome						string		`json:"outcome,omitempty"`
+	Amount						int			`json:"-"`
+	Ruling						string		`json:"ruling,omitempty"`
+	ResolvedAt					string		`json:"resolvedAt,omitempty"`
+	TermsHash					string		`json:"termsHash,omitempty"`
+}
+
+// The disputes opened on a trade, in the order they were opened; the last one is the current dispute
+type TradeDisputes struct {
+	TradeId						string		`json:"tradeId"`
+	Disputes					[]string	`json:"disputes"`
+}
+
+// A lender registered to bid for L/C financing, besides the one configured at Init; its account is its own
+type Lender struct {
+	Name						string		`json:"name"`
+	RegisteredAt				string		`json:"registeredAt"`
+}
+
+// An accepted L/C posted by the exporter for financing; lenders bid until the end of the bid deadline
+// Reserve, held back from the winning lender's advance until collection, is kept in the trade terms private data collection
+type Receivable struct {
+	TradeId						string		`json:"tradeId"`
+	Exporter					string		`json:"exporter"`
+	BidDeadline					string		`json:"bidDeadline"`
+	Status						string		`json:"status"`
+	Bidders						[]string	`json:"bidders"`
+	WinningBidder				string		`json:"winningBidder,omitempty"`
+	PostedAt					string		`json:"postedAt"`
+	Reserve						int			`json:"-"`
+	TermsHash					string		`json:"termsHash,omitempty"`
+}
+
+// A sealed bid; until the deadline only the Commitment to its rates is recorded, and the rates are kept in the financing bids
+// private data collection once the lender reveals them
+type FinancingBid struct {
+	TradeId						string		`json:"tradeId"`
+	Lender						string		`json:"lender"`
+	Status						string		`json:"status"`
+	SubmittedAt					string		`json:"submittedAt"`
+	Commitment					string		`json:"commitment"`
+	DiscountRate				float32		`json:"-"`
+	AdvanceRate					float32		`json:"-"`
+	TermsHash					string		`json:"termsHash,omitempty"`
+}
+
+// A lender's exposure on the part of an L/C transferred to it, opened by its advance payment and reconciled as the importer pays
+// Shortfall is what remains to be collected; once the L/C has expired unpaid, the lender has recourse to the exporter for it
+// An advance at an annual rate is charged Interest up front to the expected payment date; interest Accrued on the actual
+// collections is trued up against it, TrueUp being the net paid by the exporter to the lender so far
+// The amounts and rate are kept in the trade terms private data collection
+type FinancingPosition struct {
+	TradeId						string		`json:"tradeId"`
+	Lender						string		`json:"lender"`
+	Status						string		`json:"status"`
+	ExpirationDate				string		`json:"expirationDate"`
+	DayCount					string		`json:"dayCount,omitempty"`
+	ExpectedPaymentDate			string		`json:"expectedPaymentDate,omitempty"`
+	OpenedAt					string		`json:"openedAt"`
+	ClosedAt					string		`json:"closedAt,omitempty"`
+	Advanced					int			`json:"-"`
+	Expected					int			`json:"-"`
+	Collected					int			`json:"-"`
+	Shortfall					int			`json:"-"`
+	AnnualRate					float32		`json:"-"`
+	Interest					int			`json:"-"`
+	Accrued						int			`json:"-"`
+	TrueUp						int			`json:"-"`
+	TermsHash					string		`json:"termsHash,omitempty"`
+}
+
+// Import duty and tax on goods of an HS code (or of any code under it), as fractions of the customs value
+// Tax is levied on the customs value plus duty
+type TariffRate struct {
+	HsCode						string		`json:"hsCode"`
+	DutyRate					float32		`json:"dutyRate"`
+	TaxRate						float32		`json:"taxRate"`
+}
+
+// A line of a customs declaration; rates, duty and tax are filled in by Customs at assessment
+type CustomsLineItem struct {
+	HsCode						string		`json:"hsCode"`
+	Description					string		`json:"description"`
+	Quantity					int			`json:"quantity"`
+	Value						int			`json:"-"`
+	DutyRate					float32		`json:"dutyRate,omitempty"`
+	TaxRate						float32		`json:"taxRate,omitempty"`
+	Duty						int			`json:"-"`
+	Tax							int			`json:"-"`
+}
+
+// The importer's declaration of the goods under a B/L; the goods are released once Customs has CLEARED it
+// The values, duty and tax, of the declaration and of each line, are kept in the customs private data collection
+type CustomsDeclaration struct {
+	TradeId						string		`json:"tradeId"`
+	ShipmentId					string		`json:"shipmentId,omitempty"`
+	BillOfLadingId				string		`json:"billOfLadingId"`
+	Importer					string		`json:"importer"`
+	DescriptionOfGoods			string		`json:"descriptionOfGoods"`
+	SourcePort					string		`json:"sourcePort"`
+	DestinationPort				string		`json:"destinationPort"`
+	LineItems					[]CustomsLineItem	`json:"lineItems"`
+	CustomsValue				int			`json:"-"`
+	Duty						int			`json:"-"`
+	Tax							int			`json:"-"`
+	Status						string		`json:"status"`
+	TermsHash					string		`json:"termsHash,omitempty"`
+}
+
+// A restricted party, country (ISO 3166 code), port (name or UN/LOCODE) or HS code (or chapter, heading) kept by the Regulator
+// A match with a BLOCK entry refuses the transaction; a match with a HOLD entry puts the trade on compliance hold
+type ScreeningEntry struct {
+	Type						string		`json:"type"`
+	Value						string		`json:"value"`
+	Action						string		`json:"action"`
+}
+
+type ScreeningList struct {
+	Entries						[]ScreeningEntry	`json:"entries"`
+}
+
+// A value presented by a transaction that matched a screening entry
+type ScreeningMatch struct {
+	Type						string		`json:"type"`
+	Entry						string		`json:"entry"`
+	Value						string		`json:"value"`
+	Function					string		`json:"function"`
+}
+
+// While on hold, no transaction can be invoked on the trade; matches remain recorded once the Regulator clears the hold
+type ComplianceHold struct {
+	TradeId						string		`json:"tradeId"`
+	Status						string		`json:"status"`
+	Matches						[]ScreeningMatch	`json:"matches"`
+	Reason						string		`json:"reason,omitempty"`
+}
+
+// Thresholds of the AML monitoring rules; a rule with a zero threshold is disabled
+type AMLRules struct {
+	PaymentThreshold			int			`json:"paymentThreshold"`
+	TradeCountThreshold			int			`json:"tradeCountThreshold"`
+	TradeCountWindowDays		int			`json:"tradeCountWindowDays"`
+	TransferWindowDays			int			`json:"transferWindowDays"`
+}
+
+// A payment or L/C transfer, kept for the monitoring rules that look back over a window
+type PaymentActivity struct {
+	Type						string		`json:"type"`
+	TradeId						string		`json:"tradeId"`
+	Payer						string		`json:"payer"`
+	Payee						string		`json:"payee"`
+	Amount						int			`json:"amount"`
+	Timestamp					string		`json:"timestamp"`
+}
+
+type AMLFlag struct {
+	Rule						string		`json:"rule"`
+	TradeId						string		`json:"tradeId"`
+	Function					string		`json:"function"`
+	Payer						string		`json:"payer"`
+	Payee						string		`json:"payee"`
+	Amount						int			`json:"amount"`
+	Timestamp					string		`json:"timestamp"`
+	TxId						string		`json:"txId"`
+	Details						string		`json:"details"`
+}
+
+// Transfer of title to the goods; a blank endorsement (no endorsee) makes the B/L payable to bearer
+type Endorsement struct {
+	Endorser					string		`json:"endorser"`
+	EndorserOrg					string		`json:"endorserOrg"`
+	Endorsee					string		`json:"endorsee"`
+	EndorseeOrg					string		`json:"endorseeOrg"`
+	BearerTokenHash				string		`json:"bearerTokenHash,omitempty"`
+}
+
+// Payload of the event emitted when the importer's bank releases the B/L
+type BillOfLadingReleasedEvent struct {
+	TradeId						string		`json:"tradeId"`
+	BillOfLadingId				string		`json:"billOfLadingId"`
+	Holder						string		`json:"holder"`
+	HolderOrg					string		`json:"holderOrg"`
+}
+
+// A document required by an L/C, and the attachment that fulfils it once uploaded
+type DocumentReference struct {
+	Type						string		`json:"type"`
+	DocumentId					string		`json:"documentId"`
+}
+
+// Off-chain document anchored to a trade asset by its hash
+type Document struct {
+	Id							string		`json:"id"`
+	TradeId						string		`json:"tradeId"`
+	ShipmentId					string		`json:"shipmentId,omitempty"`
+	AssetType					string		`json:"assetType"`
+	DocumentType				string		`json:"documentType"`
+	Hash						string		`json:"hash"`
+	MediaType					string		`json:"mediaType"`
+	Size						int			`json:"size"`
+	URI							string		`json:"uri"`
+	UploaderOrg					string		`json:"uploaderOrg"`
+	Uploader					string		`json:"uploader"`
+}
+
+// Private data: commercial terms, shared only by the parties that settle the trade
+// DelayPenaltyRate is the fraction of the amount credited to the importer per day of late delivery
+type TradeTerms struct {
+	Amount						int			`json:"amount"`
+	Payment						int			`json:"payment"`
+	DelayPenaltyRate			float32		`json:"delayPenaltyRate,omitempty"`
+	Penalty						int			`json:"penalty,omitempty"`
+}
+
+type LetterOfCreditTerms struct {
+	Amount						int			`json:"amount"`
+	Transfers					[]LCTransferTerms	`json:"transfers,omitempty"`
+}
+
+type LCTransferTerms struct {
+	Beneficiary					string		`json:"beneficiary"`
+	Amount						int			`json:"amount"`
+	DiscountRate				float32		`json:"discountRate"`
+	AnnualRate					float32		`json:"annualRate,omitempty"`
+}
+
+// A movement of funds on a trade, kept in the trade terms private data collection; records are never updated
+// A refund names the payment it returns funds from in PaymentId; an L/C payment carries its late surcharge, and its
+// installment number among the payments for the trade or shipment
+// An L/C payment split between the beneficiaries of a transferred L/C is recorded per beneficiary, under one installment
+type PaymentRecord struct {
+	Id							string		`json:"id"`
+	TradeId						string		`json:"tradeId"`
+	ShipmentId					string		`json:"shipmentId,omitempty"`
+	Type						string		`json:"type"`
+	Function					string		`json:"function"`
+	Payer						string		`json:"payer"`
+	Payee						string		`json:"payee"`
+	Amount						int			`json:"amount"`
+	Surcharge					int			`json:"surcharge,omitempty"`
+	Installment					int			`json:"installment,omitempty"`
+	Timestamp					string		`json:"timestamp"`
+	TxId						string		`json:"txId"`
+	PaymentId					string		`json:"paymentId,omitempty"`
+}
+
+// The payments in and out of an account over a date range, both dates inclusive
+type Statement struct {
+	Account						string		`json:"account"`
+	From						string		`json:"from"`
+	To							string		`json:"to"`
+	Payments					[]PaymentRecord	`json:"payments"`
+	Credits						int			`json:"credits"`
+	Debits						int			`json:"debits"`
+	Net							int			`json:"net"`
+}
+
+type ReceivableTerms struct {
+	Reserve						int			`json:"reserve"`
+}
+
+type FinancingPositionTerms struct {
+	Advanced					int			`json:"advanced"`
+	Expected					int			`json:"expected"`
+	Collected					int			`json:"collected"`
+	Shortfall					int			`json:"shortfall"`
+	AnnualRate					float32		`json:"annualRate,omitempty"`
+	Interest					int			`json:"interest,omitempty"`
+	Accrued						int			`json:"accrued,omitempty"`
+	TrueUp						int			`json:"trueUp,omitempty"`
+}
+
+type BidTerms struct {
+	DiscountRate				float32		`json:"discountRate"`
+	AdvanceRate					float32		`json:"advanceRate"`
+}
+
+// The salted rates a bid commits to; the salt keeps the few plausible rates from being found by hashing them all
+type SealedBidTerms struct {
+	DiscountRate				float32		`json:"discountRate"`
+	AdvanceRate					float32		`json:"advanceRate"`
+	Salt						string		`json:"salt"`
+}
+
+type DisputeTerms struct {
+	Amount						int			`json:"amount"`
+}
+
+type InsurancePolicyTerms struct {
+	InsuredValue				int			`json:"insuredValue"`
+	Premium						int			`json:"premium"`
+	PaidOut						int			`json:"paidOut"`
+}
+
+type InsuranceClaimTerms struct {
+	Amount						int			`json:"amount"`
+	Payout						int			`json:"payout"`
+}
+
+// Line terms are kept in the order of the declaration's lines
+type CustomsDeclarationTerms struct {
+	LineItems					[]CustomsLineItemTerms	`json:"lineItems"`
+	CustomsValue				int			`json:"customsValue"`
+	Duty						int			`json:"duty"`
+	Tax							int			`json:"tax"`
+}
+
+type CustomsLineItemTerms struct {
+	Value						int			`json:"value"`
+	Duty						int			`json:"duty,omitempty"`
+	Tax							int			`json:"tax,omitempty"`
+}
+
+// Drawdown terms are kept in the order of the license's drawdowns
+type StandaloneExportLicenseTerms struct {
+	ValueDrawn					int			`json:"valueDrawn"`
+	Drawdowns					[]QuotaDrawdownTerms	`json:"drawdowns"`
+}
+
+type QuotaDrawdownTerms struct {
+	TradeId						string		`json:"tradeId"`
+	ShipmentId					string		`json:"shipmentId,omitempty"`
+	Value						int			`json:"value"`
+}
+
+type PendingAction struct {
+	Id							string		`json:"id"`
+	Function					string		`json:"function"`
+	Args						[]string	`json:"args"`
+	MakerOrg					string		`json:"makerOrg"`
+	Maker						string		`json:"maker"`
+	Checker						string		`json:"checker"`
+	Status						string		`json:"status"`
+	Reason						string		`json:"reason"`
+	SubmittedAt					string		`json:"submittedAt"`
+	ExpiresAt					string		`json:"expiresAt"`
+	InputsHash					string		`json:"inputsHash"`
+}
+
+// The maker's identity and transient map, kept privately until the action is approved
+type PendingActionInputs struct {
+	Creator						[]byte				`json:"creator"`
+	Transient					map[string][]byte	`json:"transient"`
+}
+
+// A participant is a user of an org, or, when seeded from the trade's parties, any user of the org acting in the role for the party
+type TradeParticipant struct {
+	Org							string		`json:"org"`
+	Subject						string		`json:"subject"`
+	Role						string		`json:"role,omitempty"`
+	Party						string		`json:"party,omitempty"`
+}
+
+type TradeParticipants struct {
+	Jurisdiction				string		`json:"jurisdiction"`
+	Members						[]TradeParticipant	`json:"members"`
+}
+
+// A leg of a shipment's route between two UN/LOCODE locations (port of loading, transshipment port, port of discharge, inland depot)
+type ShipmentLeg struct {
+	Origin						string		`json:"origin"`
+	Destination					string		`json:"destination"`
+	Vessel						string		`json:"vessel"`
+	Voyage						string		`json:"voyage"`
+}
+
+type ShipmentCheckpoint struct {
+	Location					string		`json:"location"`
+	Timestamp					string		`json:"timestamp"`
+	Vessel						string		`json:"vessel"`
+	Voyage						string		`json:"voyage"`
+	Status						string		`json:"status"`
+}
+
+// An estimated time of arrival published by the Carrier, and the delay it implies against the delivery window
+type EstimatedArrival struct {
+	Timestamp					string		`json:"timestamp"`
+	DelayDays					int			`json:"delayDays"`
+}
+
+// A reading outside the telemetry thresholds of the trade
+type ShipmentIncident struct {
+	Type						string		`json:"type"`
+	DeviceId					string		`json:"deviceId"`
+	Timestamp					string		`json:"timestamp"`
+	Value						float64		`json:"value"`
+	Threshold					float64		`json:"threshold"`
+}
+
+// The leg plan of a shipment and the checkpoints recorded along it; the shipment reaches DESTINATION on arrival at the end of the last leg
+// The last of the estimated arrivals is the current ETA
+type Shipment struct {
+	TradeId						string		`json:"tradeId"`
+	Legs						[]ShipmentLeg	`json:"legs"`
+	Checkpoints					[]ShipmentCheckpoint	`json:"checkpoints"`
+	EstimatedArrivals			[]EstimatedArrival	`json:"estimatedArrivals,omitempty"`
+	Devices						[]string	`json:"devices,omitempty"`
+	Incidents					[]ShipmentIncident	`json:"incidents,omitempty"`
+	Containers					[]Container	`json:"containers,omitempty"`
+	SealChanges					[]SealChange	`json:"sealChanges,omitempty"`
+}
+
+// Packages are counted by type, e.g., 20 PALLET or 400 CARTON
+type Package struct {
+	Type						string		`json:"type"`
+	Quantity					int			`json:"quantity"`
+}
+
+// A container identified by its ISO 6346 number; Type is the ISO 6346 size and type code (e.g., 22G1, 45R1), GrossWeight is in kilograms
+type Container struct {
+	Number						string		`json:"number"`
+	SealNumber					string		`json:"sealNumber"`
+	Type						string		`json:"type"`
+	GrossWeight					int			`json:"grossWeight"`
+	Packages					[]Package	`json:"packages,omitempty"`
+}
+
+// A container as loaded by the Carrier and listed on the B/L
+type ContainerReference struct {
+	Number						string		`json:"number"`
+	SealNumber					string		`json:"sealNumber"`
+}
+
+type SealChange struct {
+	ContainerNumber				string		`json:"containerNumber"`
+	OldSealNumber				string		`json:"oldSealNumber"`
+	NewSealNumber				string		`json:"newSealNumber"`
+	Reason						string		`json:"reason"`
+	Timestamp					string		`json:"timestamp"`
+}
+
+// A part of the trade's goods shipped under its own B/L
+type PartialShipment struct {
+	Id							string		`json:"id"`
+	Quantity					int			`json:"quantity"`
+	Location					string		`json:"location"`
+	ArrivalDate					string		`json:"arrivalDate,omitempty"`
+	BillOfLadingId				string		`json:"billOfLadingId,omitempty"`
+	PaymentStatus				string		`json:"paymentStatus,omitempty"`
+}
+
+type PartialShipments struct {
+	TradeId						string		`json:"tradeId"`
+	Shipments					[]PartialShipment	`json:"shipments"`
+}
+
+type SealChangedEvent struct {
+	TradeId						string		`json:"tradeId"`
+	ContainerNumber				string		`json:"containerNumber"`
+	OldSealNumber				string		`json:"oldSealNumber"`
+	NewSealNumber				string		`json:"newSealNumber"`
+	Reason						string		`json:"reason"`
+}
+
+// Payload of the event emitted when a shipment is expected, or found, to arrive after the delivery window
+type ShipmentDelayedEvent struct {
+	TradeId						string		`json:"tradeId"`
+	DeliveryWindowEnd			string		`json:"deliveryWindowEnd"`
+	ArrivalDate					string		`json:"arrivalDate"`
+	Estimated					bool		`json:"estimated"`
+	DelayDays					int			`json:"delayDays"`
+}
+
+// Cold-chain limits for perishable goods: temperature in degrees Celsius, relative humidity in percent
+type TelemetryThresholds struct {
+	MinTemperature				float64		`json:"minTemperature"`
+	MaxTemperature				float64		`json:"maxTemperature"`
+	MinHumidity					float64		`json:"minHumidity"`
+	MaxHumidity					float64		`json:"maxHumidity"`
+}
+
+type TelemetryReading struct {
+	Timestamp					string		`json:"timestamp"`
+	Temperature					float64		`json:"temperature"`
+	Humidity					float64		`json:"humidity"`
+}
+
+// A batch of sensor readings submitted by a device in one transaction
+type TelemetryBatch struct {
+	Id							string		`json:"id"`
+	TradeId						string		`json:"tradeId"`
+	DeviceId					string		`json:"deviceId"`
+	Readings					[]TelemetryReading	`json:"readings"`
+}
+
+// Running aggregate of all readings for a shipment
+type TelemetrySummary struct {
+	TradeId						string		`json:"tradeId"`
+	Batches						int			`json:"batches"`
+	Readings					int			`json:"readings"`
+	FirstReading				string		`json:"firstReading"`
+	LastReading					string		`json:"lastReading"`
+	MinTemperature				float64		`json:"minTemperature"`
+	MaxTemperature				float64		`json:"maxTemperature"`
+	MinHumidity					float64		`json:"minHumidity"`
+	MaxHumidity					float64		`json:"maxHumidity"`
+	Excursions					int			`json:"excursions"`
 }
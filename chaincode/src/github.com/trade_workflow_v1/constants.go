@@ -29,6 +29,22 @@ const (
 	lenBalKey	= "LendersAccountBalance"
 	carKey		= "Carrier"
 	raKey		= "RegulatoryAuthority"
+	insKey		= "Insurer"
+	insBalKey	= "InsurersAccountBalance"
+	cusKey		= "Customs"
+	cusBalKey	= "CustomsRevenueAccountBalance"
+	screeningListKey	= "ScreeningList"
+	amlRulesKey			= "AMLRules"
+)
+
+// Private data collections (see collections_config.json)
+const (
+	tradeTermsCollection		= "tradeTermsCollection"
+	accountBalancesCollection	= "accountBalancesCollection"
+	amlFlagsCollection			= "amlFlagsCollection"
+	financingBidsCollection		= "financingBidsCollection"
+	customsCollection			= "customsCollection"
+	exportQuotaCollection		= "exportQuotaCollection"
 )
 
 // State values
@@ -39,10 +55,125 @@ const (
 	TRANSFER_REQUESTED	= "TRANSFER_REQUESTED"
 	TRANSFER_ISSUED		= "TRANSFER_ISSUED"
 	TRANSFER_ACCEPTED	= "TRANSFER_ACCEPTED"
+	PENDING_APPROVAL	= "PENDING_APPROVAL"
+	APPROVED	= "APPROVED"
+	REJECTED	= "REJECTED"
+	HELD_BY_BANK	= "HELD_BY_BANK"
+	RELEASED_TO_IMPORTER	= "RELEASED_TO_IMPORTER"
+	NEGOTIATED	= "NEGOTIATED"
+	SURRENDERED	= "SURRENDERED"
+	REVOKED		= "REVOKED"
+	FILED		= "FILED"
+	ASSESSED	= "ASSESSED"
+	CLEARED		= "CLEARED"
+	COMPLIANCE_HOLD	= "COMPLIANCE_HOLD"
+	OPEN		= "OPEN"
+	RESOLVED	= "RESOLVED"
+	DISPUTED	= "DISPUTED"
+	CANCELLED	= "CANCELLED"
+	SUBMITTED	= "SUBMITTED"
+	REVEALED	= "REVEALED"
+	AWARDED		= "AWARDED"
+	SETTLED		= "SETTLED"
+	RECOURSED	= "RECOURSED"
+)
+
+// Screening entry types and actions
+const (
+	PARTY		= "PARTY"
+	COUNTRY		= "COUNTRY"
+	PORT		= "PORT"
+	HS_CODE		= "HS_CODE"
+	BLOCK		= "BLOCK"
+	HOLD		= "HOLD"
 )
 
+// AML monitoring rules, and the funds movements they are evaluated against
+const (
+	LARGE_PAYMENT		= "LARGE_PAYMENT"
+	FREQUENT_TRADES		= "FREQUENT_TRADES"
+	TRANSFER_PAYMENT	= "TRANSFER_PAYMENT"
+	PAYMENT				= "PAYMENT"
+	TRANSFER			= "TRANSFER"
+)
+
+// Payment record types of an advance against the L/C, of the release of a financing reserve, of a lender's recourse
+// to the exporter, of the true-up of interest on an advance, of import duty and tax paid to Customs, and of an insurance
+// premium and a claim payout, besides PAYMENT and REFUND
+const (
+	ADVANCE_PAYMENT		= "ADVANCE_PAYMENT"
+	RESERVE_RELEASE		= "RESERVE_RELEASE"
+	RECOURSE			= "RECOURSE"
+	TRUE_UP				= "TRUE_UP"
+	DUTY				= "DUTY"
+	PREMIUM				= "PREMIUM"
+	CLAIM				= "CLAIM"
+)
+
+// Cargo insurance coverage (Institute Cargo Clauses), and the party that pays the premium
+const (
+	ICC_A		= "ICC_A"
+	ICC_B		= "ICC_B"
+	ICC_C		= "ICC_C"
+	BUYER		= "BUYER"
+	SELLER		= "SELLER"
+)
+
+// Dispute outcomes, executed by the chaincode on resolution
+const (
+	PAY_IN_FULL		= "PAY_IN_FULL"
+	PARTIAL_PAY		= "PARTIAL_PAY"
+	REFUND			= "REFUND"
+	CANCEL			= "CANCEL"
+)
+
+// Partial shipment terms of an L/C
+const (
+	ALLOWED		= "ALLOWED"
+	NOT_ALLOWED	= "NOT_ALLOWED"
+)
+
+// Payment status values of a partial shipment
+const (
+	PARTIALLY_PAID	= "PARTIALLY_PAID"
+	PAID			= "PAID"
+)
+
+// Shipment incident types
+const (
+	TEMPERATURE_EXCURSION	= "TEMPERATURE_EXCURSION"
+	HUMIDITY_EXCURSION		= "HUMIDITY_EXCURSION"
+)
+
+// Event names
+const (
+	billOfLadingReleasedEvent	= "BillOfLadingReleased"
+	shipmentDelayedEvent		= "ShipmentDelayed"
+	sealChangedEvent			= "SealChanged"
+)
+
+// Minimum length of the token that a blank endorser hands over with a bearer B/L; only its hash is recorded
+const bearerTokenMinLength = 32
+
+// Minimum length of the salt that seals a bid's rates until they are revealed
+const bidSaltMinLength = 16
+
+// Hours a pending action waits for its checker before it expires
+const pendingActionExpiryHours = 72
+
+// Layout of dates passed to and recorded by the chaincode (MM/DD/YYYY)
+const dateLayout = "01/02/2006"
+
 // Location values
 const (
 	SOURCE		= "SOURCE"
 	DESTINATION	= "DESTINATION"
 )
+
+// Shipment checkpoint status values
+const (
+	LOADED		= "LOADED"
+	DEPARTED	= "DEPARTED"
+	ARRIVED		= "ARRIVED"
+	DISCHARGED	= "DISCHARGED"
+)
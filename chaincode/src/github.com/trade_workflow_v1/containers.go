@@ -0,0 +1,222 @@
+/*
+ * Copyright 2018 IBM All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the 'License');
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an 'AS IS' BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// ISO 6346: owner code (3 letters), equipment category (U, J or Z), serial number (6 digits) and check digit
+var containerNumberPattern = regexp.MustCompile("^[A-Z]{3}[UJZ][0-9]{7}$")
+
+// ISO 6346 size and type code, e.g., 22G1 or L5R1
+var containerTypePattern = regexp.MustCompile("^[0-9A-Z]{2}[A-Z][0-9A-Z]$")
+
+// Letters are valued from 10 upwards, skipping multiples of 11
+func containerCharacterValue(c rune) int {
+	var value int
+
+	if c >= '0' && c <= '9' {
+		return int(c - '0')
+	}
+	value = 10
+	for l := 'A'; l < c; l++ {
+		value++
+		if value%11 == 0 {
+			value++
+		}
+	}
+	return value
+}
+
+// Validate a container number, including its check digit; returns the number in upper case
+func parseContainerNumber(number string) (string, error) {
+	var normalized string
+	var sum int
+
+	normalized = strings.ToUpper(strings.Replace(number, " ", "", -1))
+	if !containerNumberPattern.MatchString(normalized) {
+		return "", errors.New(fmt.Sprintf("Invalid container number %s", number))
+	}
+	for i, c := range normalized[:10] {
+		sum += containerCharacterValue(c) << uint(i)
+	}
+	if sum%11%10 != int(normalized[10]-'0') {
+		return "", errors.New(fmt.Sprintf("Invalid check digit in container number %s", number))
+	}
+	return normalized, nil
+}
+
+// Parse and validate the containers prepared for a shipment
+func parseContainers(containersJSON string) ([]Container, error) {
+	var containers []Container
+	var numbers map[string]bool
+	var err error
+
+	err = json.Unmarshal([]byte(containersJSON), &containers)
+	if err != nil {
+		return nil, errors.New("Containers must be a JSON array of {number, sealNumber, type, grossWeight, packages}")
+	}
+	if len(containers) == 0 {
+		return nil, errors.New("At least one container must be listed")
+	}
+
+	numbers = make(map[string]bool)
+	for i := range containers {
+		containers[i].Number, err = parseContainerNumber(containers[i].Number)
+		if err != nil {
+			return nil, err
+		}
+		if numbers[containers[i].Number] {
+			return nil, errors.New(fmt.Sprintf("Container %s listed more than once", containers[i].Number))
+		}
+		numbers[containers[i].Number] = true
+		if containers[i].SealNumber == "" {
+			return nil, errors.New(fmt.Sprintf("Seal number of container %s must be non-empty", containers[i].Number))
+		}
+		containers[i].Type = strings.ToUpper(containers[i].Type)
+		if !containerTypePattern.MatchString(containers[i].Type) {
+			return nil, errors.New(fmt.Sprintf("Invalid size and type code %s for container %s", containers[i].Type, containers[i].Number))
+		}
+		if containers[i].GrossWeight <= 0 {
+			return nil, errors.New(fmt.Sprintf("Gross weight of container %s must be positive", containers[i].Number))
+		}
+		for _, pkg := range containers[i].Packages {
+			if pkg.Type == "" || pkg.Quantity <= 0 {
+				return nil, errors.New(fmt.Sprintf("Packages in container %s must have a type and a positive quantity", containers[i].Number))
+			}
+		}
+	}
+	return containers, nil
+}
+
+// The containers loaded by the Carrier must be the prepared containers, under their current seals
+func checkLoadedContainers(prepared []Container, loadedJSON string) ([]ContainerReference, error) {
+	var loaded []ContainerReference
+	var seals map[string]string
+	var seal string
+	var ok bool
+	var err error
+
+	err = json.Unmarshal([]byte(loadedJSON), &loaded)
+	if err != nil {
+		return nil, errors.New("Loaded containers must be a JSON array of {number, sealNumber}")
+	}
+
+	seals = make(map[string]string)
+	for _, container := range prepared {
+		seals[container.Number] = container.SealNumber
+	}
+	for i := range loaded {
+		loaded[i].Number, err = parseContainerNumber(loaded[i].Number)
+		if err != nil {
+			return nil, err
+		}
+		seal, ok = seals[loaded[i].Number]
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("Container %s was not prepared for this shipment, or is listed more than once", loaded[i].Number))
+		}
+		if seal != loaded[i].SealNumber {
+			return nil, errors.New(fmt.Sprintf("Seal %s on container %s does not match its recorded seal %s", loaded[i].SealNumber, loaded[i].Number, seal))
+		}
+		delete(seals, loaded[i].Number)
+	}
+	if len(seals) != 0 {
+		return nil, errors.New(fmt.Sprintf("%d prepared containers were not loaded", len(seals)))
+	}
+	return loaded, nil
+}
+
+// Record the replacement of a container's seal, e.g., after a customs inspection
+func (t *TradeWorkflowChaincode) recordSealChange(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var shipmentKey, containerNumber string
+	var eventBytes []byte
+	var shipment *Shipment
+	var container *Container
+	var sealChange SealChange
+	var err error
+
+	// Access control: Only a Carrier Org member can invoke this transaction
+	if !t.testMode && !authenticateCarrierOrg(creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a member of Carrier Org. Access denied.")
+	}
+
+	if len(args) != 5 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 5: {Trade ID, Container Number, New Seal Number, Reason, Timestamp}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+
+	containerNumber, err = parseContainerNumber(args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if args[2] == "" || args[3] == "" {
+		return shim.Error("New seal number and reason must be non-empty")
+	}
+	_, err = time.Parse(time.RFC3339, args[4])
+	if err != nil {
+		err = errors.New(fmt.Sprintf("Invalid timestamp %s; expecting RFC 3339", args[4]))
+		return shim.Error(err.Error())
+	}
+
+	shipmentKey, shipment, err = getShipmentRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if shipment != nil {
+		for i := range shipment.Containers {
+			if shipment.Containers[i].Number == containerNumber {
+				container = &shipment.Containers[i]
+			}
+		}
+	}
+	if container == nil {
+		err = errors.New(fmt.Sprintf("Container %s not found in the shipment for trade %s", containerNumber, args[0]))
+		return shim.Error(err.Error())
+	}
+	if container.SealNumber == args[2] {
+		return shim.Error("New seal number is the container's current seal")
+	}
+
+	sealChange = SealChange{containerNumber, container.SealNumber, args[2], args[3], args[4]}
+	container.SealNumber = args[2]
+	shipment.SealChanges = append(shipment.SealChanges, sealChange)
+	err = putShipmentRecord(stub, shipmentKey, shipment)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Seal change on container %s for trade %s recorded\n", containerNumber, args[0])
+
+	eventBytes, err = json.Marshal(&SealChangedEvent{args[0], containerNumber, sealChange.OldSealNumber, sealChange.NewSealNumber, sealChange.Reason})
+	if err != nil {
+		return shim.Error("Error marshaling seal change event")
+	}
+	err = stub.SetEvent(sealChangedEvent, eventBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
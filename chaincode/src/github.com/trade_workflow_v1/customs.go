@@ -0,0 +1,501 @@
+/*
+ * Copyright 2018 IBM All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the 'License');
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an 'AS IS' BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// Harmonized System codes: a 2-digit chapter, a 4-digit heading, a 6-digit subheading, or a national tariff line of up to 10 digits
+var hsCodePattern = regexp.MustCompile("^[0-9]{2,10}$")
+
+// Returns the code without the dots and spaces it is usually written with, e.g., 4421.91 becomes 442191
+func parseHsCode(hsCode string, minDigits int) (string, error) {
+	var normalized string
+
+	normalized = strings.Replace(strings.Replace(hsCode, ".", "", -1), " ", "", -1)
+	if !hsCodePattern.MatchString(normalized) || len(normalized) < minDigits {
+		return "", errors.New(fmt.Sprintf("Invalid HS code %s; expecting %d to 10 digits", hsCode, minDigits))
+	}
+	return normalized, nil
+}
+
+// Duty and tax are rounded to the nearest unit of currency; rates such as 0.02 are not exact in floating point
+func applyRate(amount int, rate float32) int {
+	return int(float64(amount) * float64(rate) + 0.5)
+}
+
+// The goods under a B/L of a trade shipped in full, or of one of its partial shipments, are declared separately
+func getTradeCustomsDeclarationKey(stub shim.ChaincodeStubInterface, tradeID string, shipmentID string) (string, error) {
+	if shipmentID == "" {
+		return getCustomsDeclarationKey(stub, tradeID)
+	}
+	return getPartialCustomsDeclarationKey(stub, tradeID, shipmentID)
+}
+
+// Returns nil if no declaration has been filed
+func getCustomsDeclarationRecord(stub shim.ChaincodeStubInterface, tradeID string, shipmentID string) (string, *CustomsDeclaration, error) {
+	var customsDeclarationKey string
+	var customsDeclarationBytes []byte
+	var customsDeclaration *CustomsDeclaration
+	var err error
+
+	// Lookup customs declaration from the ledger
+	customsDeclarationKey, err = getTradeCustomsDeclarationKey(stub, tradeID, shipmentID)
+	if err != nil {
+		return "", nil, err
+	}
+	customsDeclarationBytes, err = stub.GetState(customsDeclarationKey)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(customsDeclarationBytes) == 0 {
+		return customsDeclarationKey, nil, nil
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(customsDeclarationBytes, &customsDeclaration)
+	if err != nil {
+		return "", nil, err
+	}
+	return customsDeclarationKey, customsDeclaration, nil
+}
+
+func putCustomsDeclarationRecord(stub shim.ChaincodeStubInterface, customsDeclarationKey string, customsDeclaration *CustomsDeclaration) error {
+	var customsDeclarationBytes []byte
+	var err error
+
+	customsDeclarationBytes, err = json.Marshal(customsDeclaration)
+	if err != nil {
+		return errors.New("Error marshaling customs declaration structure")
+	}
+	// Write the state to the ledger
+	return stub.PutState(customsDeclarationKey, customsDeclarationBytes)
+}
+
+// The most specific tariff line recorded for an HS code: the code itself, or the longest of its prefixes
+func getTariffRate(stub shim.ChaincodeStubInterface, hsCode string) (*TariffRate, error) {
+	var tariffKey string
+	var tariffBytes []byte
+	var tariffRate *TariffRate
+	var err error
+
+	for n := len(hsCode); n >= 4; n-- {
+		tariffKey, err = getTariffKey(stub, hsCode[:n])
+		if err != nil {
+			return nil, err
+		}
+		tariffBytes, err = stub.GetState(tariffKey)
+		if err != nil {
+			return nil, err
+		}
+		if len(tariffBytes) != 0 {
+			// Unmarshal the JSON
+			err = json.Unmarshal(tariffBytes, &tariffRate)
+			if err != nil {
+				return nil, err
+			}
+			return tariffRate, nil
+		}
+	}
+	return nil, errors.New(fmt.Sprintf("No tariff rate recorded for HS code %s", hsCode))
+}
+
+// Parse the line items of a declaration, and their values, in the same order; the quantities must add up to that on the B/L,
+// if it states one
+func parseCustomsLineItems(lineItemsJSON string, valuesJSON string, billOfLading *BillOfLading) ([]CustomsLineItem, int, error) {
+	var lineItems []CustomsLineItem
+	var values []int
+	var quantity, customsValue int
+	var err error
+
+	err = json.Unmarshal([]byte(lineItemsJSON), &lineItems)
+	if err != nil {
+		return nil, 0, errors.New("Line items must be a JSON array of {hsCode, description, quantity}")
+	}
+	if len(lineItems) == 0 {
+		return nil, 0, errors.New("At least one line item must be declared")
+	}
+	err = json.Unmarshal([]byte(valuesJSON), &values)
+	if err != nil || len(values) != len(lineItems) {
+		return nil, 0, errors.New("Values must be a JSON array with the value of each line item")
+	}
+
+	for i := range lineItems {
+		lineItems[i].HsCode, err = parseHsCode(lineItems[i].HsCode, 6)
+		if err != nil {
+			return nil, 0, err
+		}
+		if lineItems[i].Quantity <= 0 || values[i] < 0 {
+			return nil, 0, errors.New(fmt.Sprintf("Line item %d must have a positive quantity and a non-negative value", i + 1))
+		}
+		lineItems[i].Value = values[i]
+		lineItems[i].DutyRate, lineItems[i].TaxRate, lineItems[i].Duty, lineItems[i].Tax = 0, 0, 0, 0
+		quantity += lineItems[i].Quantity
+		customsValue += lineItems[i].Value
+	}
+	if billOfLading.Quantity > 0 && quantity != billOfLading.Quantity {
+		return nil, 0, errors.New(fmt.Sprintf("Line items declare %d units; the B/L covers %d", quantity, billOfLading.Quantity))
+	}
+	return lineItems, customsValue, nil
+}
+
+// Record the duty and tax rates of an HS code, or of all the codes under a heading
+func (t *TradeWorkflowChaincode) setTariffRate(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var tariffKey, hsCode string
+	var tariffBytes []byte
+	var dutyRate, taxRate float64
+	var err error
+
+	// Access control: Only a customs officer can invoke this transaction
+	if !t.testMode && !authenticateCustomsOfficer(stub, creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a customs officer of Regulator Org. Access denied.")
+	}
+
+	if len(args) != 3 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 3: {HS Code, Duty Rate, Tax Rate}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+
+	hsCode, err = parseHsCode(args[0], 4)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	dutyRate, err = strconv.ParseFloat(args[1], 32)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	taxRate, err = strconv.ParseFloat(args[2], 32)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if dutyRate < 0 || taxRate < 0 {
+		return shim.Error("Duty and tax rates cannot be negative")
+	}
+
+	tariffBytes, err = json.Marshal(&TariffRate{hsCode, float32(dutyRate), float32(taxRate)})
+	if err != nil {
+		return shim.Error("Error marshaling tariff rate structure")
+	}
+	tariffKey, err = getTariffKey(stub, hsCode)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	// Write the state to the ledger
+	err = stub.PutState(tariffKey, tariffBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Tariff rate for HS code %s recorded\n", hsCode)
+
+	return shim.Success(nil)
+}
+
+// Declare the goods under a B/L to Customs; a declaration can be amended until the goods are cleared
+func (t *TradeWorkflowChaincode) fileCustomsDeclaration(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var customsDeclarationKey, shipmentID, values string
+	var importerBytes []byte
+	var billOfLading *BillOfLading
+	var customsDeclaration *CustomsDeclaration
+	var lineItems []CustomsLineItem
+	var customsValue int
+	var err error
+
+	// Access control: Only an Importer Org member can invoke this transaction
+	if !t.testMode && !authenticateImporterOrg(creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a member of Importer Org. Access denied.")
+	}
+
+	if len(args) != 2 && len(args) != 3 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 2: {Trade ID, Line Items}, or 3: {Trade ID, Shipment ID, Line Items}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+	if len(args) == 3 {
+		shipmentID = args[1]
+	}
+
+	_, billOfLading, err = getBillOfLadingRecord(stub, args[0], shipmentID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if billOfLading.Status == SURRENDERED {
+		fmt.Printf("B/L for trade %s has already been surrendered\n", args[0])
+		return shim.Error("Goods already released")
+	}
+
+	// The values of the goods are private terms, passed in the transient map
+	values, err = getTransientValue(stub, "values")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	lineItems, customsValue, err = parseCustomsLineItems(args[len(args) - 1], values, billOfLading)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	customsDeclarationKey, customsDeclaration, err = getCustomsDeclarationRecord(stub, args[0], shipmentID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if customsDeclaration != nil && customsDeclaration.Status == CLEARED {
+		fmt.Printf("Goods for trade %s have already been cleared\n", args[0])
+		return shim.Error("Goods already cleared by Customs")
+	}
+
+	// Lookup importer (declarant)
+	importerBytes, err = stub.GetState(impKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	customsDeclaration = &CustomsDeclaration{args[0], shipmentID, billOfLading.Id, string(importerBytes), billOfLading.DescriptionOfGoods, billOfLading.SourcePort, billOfLading.DestinationPort, lineItems, customsValue, 0, 0, FILED, ""}
+	err = putCustomsDeclarationTerms(stub, customsDeclarationKey, customsDeclaration)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = putCustomsDeclarationRecord(stub, customsDeclarationKey, customsDeclaration)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Customs declaration for trade %s recorded\n", args[0])
+
+	return shim.Success(nil)
+}
+
+// Assess duty and tax on each line of a declaration at the tariff rates in force; goods owing nothing are cleared at once
+func (t *TradeWorkflowChaincode) assessDuty(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var customsDeclarationKey, shipmentID string
+	var customsDeclaration *CustomsDeclaration
+	var tariffRate *TariffRate
+	var err error
+
+	// Access control: Only a customs officer can invoke this transaction
+	if !t.testMode && !authenticateCustomsOfficer(stub, creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a customs officer of Regulator Org. Access denied.")
+	}
+
+	if len(args) != 1 && len(args) != 2 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 1: {Trade ID}, or 2: {Trade ID, Shipment ID}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+	if len(args) == 2 {
+		shipmentID = args[1]
+	}
+
+	customsDeclarationKey, customsDeclaration, err = getCustomsDeclarationRecord(stub, args[0], shipmentID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if customsDeclaration == nil {
+		err = errors.New(fmt.Sprintf("No customs declaration found for trade ID %s", args[0]))
+		return shim.Error(err.Error())
+	}
+	if customsDeclaration.Status != FILED {
+		fmt.Printf("Customs declaration for trade %s is not pending assessment; status is %s\n", args[0], customsDeclaration.Status)
+		return shim.Error("Customs declaration already assessed")
+	}
+	err = getCustomsDeclarationTerms(stub, customsDeclarationKey, customsDeclaration)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	customsDeclaration.Duty, customsDeclaration.Tax = 0, 0
+	for i := range customsDeclaration.LineItems {
+		tariffRate, err = getTariffRate(stub, customsDeclaration.LineItems[i].HsCode)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		customsDeclaration.LineItems[i].DutyRate = tariffRate.DutyRate
+		customsDeclaration.LineItems[i].TaxRate = tariffRate.TaxRate
+		customsDeclaration.LineItems[i].Duty = applyRate(customsDeclaration.LineItems[i].Value, tariffRate.DutyRate)
+		customsDeclaration.LineItems[i].Tax = applyRate(customsDeclaration.LineItems[i].Value + customsDeclaration.LineItems[i].Duty, tariffRate.TaxRate)
+		customsDeclaration.Duty += customsDeclaration.LineItems[i].Duty
+		customsDeclaration.Tax += customsDeclaration.LineItems[i].Tax
+	}
+
+	if customsDeclaration.Duty + customsDeclaration.Tax == 0 {
+		customsDeclaration.Status = CLEARED
+	} else {
+		customsDeclaration.Status = ASSESSED
+	}
+	err = putCustomsDeclarationTerms(stub, customsDeclarationKey, customsDeclaration)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = putCustomsDeclarationRecord(stub, customsDeclarationKey, customsDeclaration)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Duty %d and tax %d assessed for trade %s\n", customsDeclaration.Duty, customsDeclaration.Tax, args[0])
+
+	return shim.Success(nil)
+}
+
+// Pay the assessed duty and tax from the importer's account; the goods are then cleared
+func (t *TradeWorkflowChaincode) payDuty(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var customsDeclarationKey, shipmentID string
+	var importerBytes []byte
+	var customsDeclaration *CustomsDeclaration
+	var dutyAmount int
+	var balances map[string]int
+	var err error
+
+	// Access control: Only an Importer Org member can invoke this transaction
+	if !t.testMode && !authenticateImporterOrg(creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a member of Importer Org. Access denied.")
+	}
+
+	if len(args) != 1 && len(args) != 2 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 1: {Trade ID}, or 2: {Trade ID, Shipment ID}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+	if len(args) == 2 {
+		shipmentID = args[1]
+	}
+
+	customsDeclarationKey, customsDeclaration, err = getCustomsDeclarationRecord(stub, args[0], shipmentID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if customsDeclaration == nil {
+		err = errors.New(fmt.Sprintf("No customs declaration found for trade ID %s", args[0]))
+		return shim.Error(err.Error())
+	}
+	if customsDeclaration.Status != ASSESSED {
+		fmt.Printf("Customs declaration for trade %s is not awaiting payment; status is %s\n", args[0], customsDeclaration.Status)
+		return shim.Error("Duty not assessed or already paid")
+	}
+	err = getCustomsDeclarationTerms(stub, customsDeclarationKey, customsDeclaration)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Lookup importer
+	importerBytes, err = stub.GetState(impKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Duty and tax are paid from the importer's account into the Customs revenue account
+	dutyAmount = customsDeclaration.Duty + customsDeclaration.Tax
+	balances = map[string]int{}
+	err = moveAccountFunds(stub, balances, impBalKey, cusBalKey, dutyAmount)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = putAccountBalances(stub, balances)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	_, err = recordPayment(stub, args[0], shipmentID, DUTY, "payDuty", string(importerBytes), cusKey, dutyAmount, 0, "")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Update ledger state
+	customsDeclaration.Status = CLEARED
+	err = putCustomsDeclarationRecord(stub, customsDeclarationKey, customsDeclaration)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Duty payment for trade %s recorded; goods cleared\n", args[0])
+
+	return shim.Success(nil)
+}
+
+// Get the customs declaration for the goods under a B/L
+func (t *TradeWorkflowChaincode) getCustomsDeclaration(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var customsDeclarationKey, shipmentID, jsonResp string
+	var customsDeclarationBytes []byte
+	var err error
+
+	if len(args) != 1 && len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 1: <trade ID>, or 2: <trade ID, shipment ID>")
+	}
+	if len(args) == 2 {
+		shipmentID = args[1]
+	}
+
+	// Access control: Only Customs, the trade's participants, or a regulator for its jurisdiction, can invoke this transaction
+	if !t.testMode && !authenticateCustomsOfficer(stub, creatorOrg, creatorCertIssuer) {
+		err = authorizeTradeAccess(stub, creatorOrg, creatorCertIssuer, args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	// Get the state from the ledger
+	customsDeclarationKey, err = getTradeCustomsDeclarationKey(stub, args[0], shipmentID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	customsDeclarationBytes, err = stub.GetState(customsDeclarationKey)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Failed to get state for " + customsDeclarationKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+
+	if len(customsDeclarationBytes) == 0 {
+		jsonResp = "{\"Error\":\"No record found for " + customsDeclarationKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+	customsDeclarationBytes, err = addPrivateTerms(stub, customsCollection, customsDeclarationKey, customsDeclarationBytes)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Failed to get private terms for " + customsDeclarationKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+	fmt.Printf("Query Response:%s\n", string(customsDeclarationBytes))
+	return shim.Success(customsDeclarationBytes)
+}
+
+// Get the tariff line that applies to an HS code
+func (t *TradeWorkflowChaincode) getTariff(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var hsCode, jsonResp string
+	var tariffRate *TariffRate
+	var tariffBytes []byte
+	var err error
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1: <HS code>")
+	}
+
+	hsCode, err = parseHsCode(args[0], 4)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	tariffRate, err = getTariffRate(stub, hsCode)
+	if err != nil {
+		jsonResp = "{\"Error\":\"" + err.Error() + "\"}"
+		return shim.Error(jsonResp)
+	}
+
+	tariffBytes, err = json.Marshal(tariffRate)
+	if err != nil {
+		return shim.Error("Error marshaling tariff rate structure")
+	}
+	fmt.Printf("Query Response:%s\n", string(tariffBytes))
+	return shim.Success(tariffBytes)
+}
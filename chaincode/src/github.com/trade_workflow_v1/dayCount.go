@@ -0,0 +1,96 @@
+/*
+ * Copyright 2018 IBM All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the 'License');
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an 'AS IS' BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Day count conventions for interest on financing advances
+const (
+	ACT_360		= "ACT/360"
+	ACT_365		= "ACT/365"
+	THIRTY_360	= "30/360"
+	THIRTY_E_360	= "30E/360"
+)
+
+// Rates are applied in parts per million, so that interest is computed in integers and is the same on every peer
+const ratePrecision = 1000000
+
+// The number of days in a year under a day count convention
+func getDayCountBasis(convention string) (int, error) {
+	if convention == ACT_360 || convention == THIRTY_360 || convention == THIRTY_E_360 {
+		return 360, nil
+	} else if convention == ACT_365 {
+		return 365, nil
+	}
+	return 0, errors.New(fmt.Sprintf("Unknown day count convention %s; expecting %s, %s, %s or %s", convention, ACT_360, ACT_365, THIRTY_360, THIRTY_E_360))
+}
+
+// The number of days from the start date to the end date under a day count convention; only the calendar dates count
+// ACT counts the actual days, leap days included
+// 30/360 (bond basis) counts a 31st as the 30th, at the end only if the start is on the 30th or 31st; 30E/360 always does
+func getDayCount(convention string, start time.Time, end time.Time) (int, error) {
+	var y1, y2 int
+	var m1, m2 time.Month
+	var d1, d2 int
+	var err error
+
+	_, err = getDayCountBasis(convention)
+	if err != nil {
+		return 0, err
+	}
+	y1, m1, d1 = start.Date()
+	y2, m2, d2 = end.Date()
+	start = time.Date(y1, m1, d1, 0, 0, 0, 0, time.UTC)
+	end = time.Date(y2, m2, d2, 0, 0, 0, 0, time.UTC)
+	if end.Before(start) {
+		return 0, errors.New(fmt.Sprintf("End date %s is before start date %s", end.Format(dateLayout), start.Format(dateLayout)))
+	}
+
+	if convention == ACT_360 || convention == ACT_365 {
+		return int((end.Unix() - start.Unix()) / 86400), nil
+	}
+	if d1 == 31 {
+		d1 = 30
+	}
+	if d2 == 31 && (convention == THIRTY_E_360 || d1 == 30) {
+		d2 = 30
+	}
+	return 360 * (y2 - y1) + 30 * int(m2 - m1) + d2 - d1, nil
+}
+
+// Simple interest on an amount at an annual rate from the start date to the end date, truncated to a whole unit
+func getAccruedInterest(amount int, annualRate float32, convention string, start time.Time, end time.Time) (int, error) {
+	var days, basis int
+	var rate int64
+	var err error
+
+	basis, err = getDayCountBasis(convention)
+	if err != nil {
+		return 0, err
+	}
+	days, err = getDayCount(convention, start, end)
+	if err != nil {
+		return 0, err
+	}
+	rate = int64(math.Round(float64(annualRate) * ratePrecision))
+	return int(int64(amount) * rate * int64(days) / (ratePrecision * int64(basis))), nil
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright 2018 IBM All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the 'License');
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an 'AS IS' BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func parseTestDate(t *testing.T, date string) time.Time {
+	parsed, err := time.Parse(dateLayout, date)
+	if err != nil {
+		fmt.Println("Invalid date", date)
+		t.FailNow()
+	}
+	return parsed
+}
+
+func checkDayCount(t *testing.T, convention string, start string, end string, days int) {
+	count, err := getDayCount(convention, parseTestDate(t, start), parseTestDate(t, end))
+	if err != nil {
+		fmt.Println("Day count", convention, "from", start, "to", end, "failed", err)
+		t.FailNow()
+	}
+	if count != days {
+		fmt.Println("Day count", convention, "from", start, "to", end, "was", count, "and not", days, "as expected")
+		t.FailNow()
+	}
+}
+
+func checkBadDayCount(t *testing.T, convention string, start string, end string) {
+	_, err := getDayCount(convention, parseTestDate(t, start), parseTestDate(t, end))
+	if err == nil {
+		fmt.Println("Day count", convention, "from", start, "to", end, "should have failed")
+		t.FailNow()
+	}
+}
+
+func checkAccruedInterest(t *testing.T, amount int, annualRate float32, convention string, start string, end string, interest int) {
+	accrued, err := getAccruedInterest(amount, annualRate, convention, parseTestDate(t, start), parseTestDate(t, end))
+	if err != nil {
+		fmt.Println("Interest on", amount, "from", start, "to", end, "failed", err)
+		t.FailNow()
+	}
+	if accrued != interest {
+		fmt.Println("Interest on", amount, "at", annualRate, convention, "from", start, "to", end, "was", accrued, "and not", interest, "as expected")
+		t.FailNow()
+	}
+}
+
+func TestDayCount_Actual(t *testing.T) {
+	// Actual days, across month and year ends, with and without a leap day
+	checkDayCount(t, ACT_360, "01/01/2019", "01/01/2019", 0)
+	checkDayCount(t, ACT_360, "01/01/2019", "03/01/2019", 59)
+	checkDayCount(t, ACT_360, "01/01/2020", "03/01/2020", 60)
+	checkDayCount(t, ACT_365, "02/28/2020", "03/01/2020", 2)
+	checkDayCount(t, ACT_365, "12/31/2019", "01/01/2020", 1)
+	checkDayCount(t, ACT_365, "01/01/2019", "01/01/2021", 731)
+
+	// Only the calendar dates count, not the time of day
+	count, _ := getDayCount(ACT_360, time.Date(2019, 1, 1, 23, 59, 59, 0, time.UTC), time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC))
+	if count != 1 {
+		fmt.Println("Day count across midnight was", count, "and not 1 as expected")
+		t.FailNow()
+	}
+}
+
+func TestDayCount_Thirty360(t *testing.T) {
+	// A 31st counts as the 30th at the start
+	checkDayCount(t, THIRTY_360, "01/31/2019", "02/28/2019", 28)
+	checkDayCount(t, THIRTY_E_360, "01/31/2019", "02/28/2019", 28)
+
+	// At the end, bond basis keeps the 31st unless the start is on the 30th or 31st; 30E/360 never keeps it
+	checkDayCount(t, THIRTY_360, "01/30/2019", "03/31/2019", 60)
+	checkDayCount(t, THIRTY_360, "01/15/2019", "03/31/2019", 76)
+	checkDayCount(t, THIRTY_E_360, "01/15/2019", "03/31/2019", 75)
+	checkDayCount(t, THIRTY_360, "02/28/2019", "03/31/2019", 33)
+	checkDayCount(t, THIRTY_E_360, "02/28/2019", "03/31/2019", 32)
+
+	// A whole year is 360 days, leap year or not
+	checkDayCount(t, THIRTY_360, "12/31/2019", "12/31/2020", 360)
+	checkDayCount(t, THIRTY_E_360, "02/29/2020", "02/28/2021", 359)
+}
+
+func TestDayCount_Invalid(t *testing.T) {
+	checkBadDayCount(t, ACT_360, "01/02/2019", "01/01/2019")
+	checkBadDayCount(t, THIRTY_360, "01/01/2020", "12/31/2019")
+	checkBadDayCount(t, "ACT/ACT", "01/01/2019", "01/02/2019")
+	_, err := getDayCountBasis("")
+	if err == nil {
+		fmt.Println("An empty day count convention should be refused")
+		t.FailNow()
+	}
+}
+
+func TestDayCount_AccruedInterest(t *testing.T) {
+	checkAccruedInterest(t, 100000, 0.05, ACT_360, "01/01/2019", "04/01/2019", 1250)
+	checkAccruedInterest(t, 100000, 0.05, ACT_365, "01/01/2019", "03/15/2019", 1000)
+	checkAccruedInterest(t, 50000, 0.07, THIRTY_360, "01/31/2019", "01/31/2020", 3500)
+
+	// Interest is truncated to a whole unit, towards zero for a negative amount
+	checkAccruedInterest(t, 1000, 0.05, ACT_360, "01/01/2019", "01/02/2019", 0)
+	checkAccruedInterest(t, 10000, 0.05, ACT_360, "01/01/2019", "01/09/2019", 11)
+	checkAccruedInterest(t, -10000, 0.05, ACT_360, "01/01/2019", "01/09/2019", -11)
+
+	// No interest accrues at a zero rate or over no days
+	checkAccruedInterest(t, 100000, 0, ACT_360, "01/01/2019", "04/01/2019", 0)
+	checkAccruedInterest(t, 100000, 0.05, ACT_360, "04/01/2019", "04/01/2019", 0)
+}
@@ -0,0 +1,711 @@
+/*
+ * Copyright 2018 IBM All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the 'License');
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an 'AS IS' BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// Transactions that move funds on a trade, frozen while a dispute is open
+var paymentTransactions = map[string]bool{
+	"requestAdvancePayment": true,
+	"makeAdvancePayment":    true,
+	"requestPayment":        true,
+	"makePayment":           true,
+	"refundPayment":         true,
+}
+
+// Returns nil if the dispute has not been opened on the trade
+func getDisputeRecord(stub shim.ChaincodeStubInterface, tradeID string, disputeID string) (string, *Dispute, error) {
+	var disputeKey string
+	var disputeBytes []byte
+	var dispute *Dispute
+	var err error
+
+	// Lookup dispute from the ledger
+	disputeKey, err = getDisputeKey(stub, tradeID, disputeID)
+	if err != nil {
+		return "", nil, err
+	}
+	disputeBytes, err = stub.GetState(disputeKey)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(disputeBytes) == 0 {
+		return disputeKey, nil, nil
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(disputeBytes, &dispute)
+	if err != nil {
+		return "", nil, err
+	}
+	return disputeKey, dispute, nil
+}
+
+// Returns nil if no dispute has been opened on the trade
+func getTradeDisputesRecord(stub shim.ChaincodeStubInterface, tradeID string) (string, *TradeDisputes, error) {
+	var tradeDisputesKey string
+	var tradeDisputesBytes []byte
+	var tradeDisputes *TradeDisputes
+	var err error
+
+	// Lookup the trade's disputes from the ledger
+	tradeDisputesKey, err = getTradeDisputesKey(stub, tradeID)
+	if err != nil {
+		return "", nil, err
+	}
+	tradeDisputesBytes, err = stub.GetState(tradeDisputesKey)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(tradeDisputesBytes) == 0 {
+		return tradeDisputesKey, nil, nil
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(tradeDisputesBytes, &tradeDisputes)
+	if err != nil {
+		return "", nil, err
+	}
+	return tradeDisputesKey, tradeDisputes, nil
+}
+
+func putTradeDisputesRecord(stub shim.ChaincodeStubInterface, tradeDisputesKey string, tradeDisputes *TradeDisputes) error {
+	var tradeDisputesBytes []byte
+	var err error
+
+	tradeDisputesBytes, err = json.Marshal(tradeDisputes)
+	if err != nil {
+		return errors.New("Error marshaling trade disputes structure")
+	}
+	// Write the state to the ledger
+	return stub.PutState(tradeDisputesKey, tradeDisputesBytes)
+}
+
+// The dispute last opened on the trade; returns nil if none has been opened
+func getCurrentDisputeRecord(stub shim.ChaincodeStubInterface, tradeID string) (string, *Dispute, error) {
+	var tradeDisputes *TradeDisputes
+	var err error
+
+	_, tradeDisputes, err = getTradeDisputesRecord(stub, tradeID)
+	if err != nil {
+		return "", nil, err
+	}
+	if tradeDisputes == nil || len(tradeDisputes.Disputes) == 0 {
+		return "", nil, nil
+	}
+	return getDisputeRecord(stub, tradeID, tradeDisputes.Disputes[len(tradeDisputes.Disputes) - 1])
+}
+
+func putDisputeRecord(stub shim.ChaincodeStubInterface, disputeKey string, dispute *Dispute) error {
+	var disputeBytes []byte
+	var err error
+
+	disputeBytes, err = json.Marshal(dispute)
+	if err != nil {
+		return errors.New("Error marshaling dispute structure")
+	}
+	// Write the state to the ledger
+	return stub.PutState(disputeKey, disputeBytes)
+}
+
+// Payments are frozen while a dispute is open; once it is resolved, its outcome decides whether the trade goes on
+func checkPaymentFreeze(stub shim.ChaincodeStubInterface, tradeID string) error {
+	var dispute *Dispute
+	var err error
+
+	_, dispute, err = getCurrentDisputeRecord(stub, tradeID)
+	if err != nil {
+		return err
+	}
+	if dispute != nil && dispute.Status == OPEN {
+		return errors.New(fmt.Sprintf("Payments on trade %s are frozen while dispute %s is open", tradeID, dispute.Id))
+	}
+	return nil
+}
+
+// A trade cancelled by the resolution of a dispute is closed; no transaction can act on it any more
+func checkTradeCancelled(stub shim.ChaincodeStubInterface, tradeID string) error {
+	var cancelled bool
+	var err error
+
+	cancelled, err = isTradeCancelled(stub, tradeID)
+	if err != nil {
+		return err
+	}
+	if cancelled {
+		return errors.New(fmt.Sprintf("Trade %s has been cancelled", tradeID))
+	}
+	return nil
+}
+
+func isTradeCancelled(stub shim.ChaincodeStubInterface, tradeID string) (bool, error) {
+	var tradeKey string
+	var tradeAgreementBytes []byte
+	var tradeAgreement *TradeAgreement
+	var err error
+
+	tradeKey, err = getTradeKey(stub, tradeID)
+	if err != nil {
+		return false, err
+	}
+	tradeAgreementBytes, err = stub.GetState(tradeKey)
+	if err != nil {
+		return false, err
+	}
+	if len(tradeAgreementBytes) == 0 {
+		return false, nil
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(tradeAgreementBytes, &tradeAgreement)
+	if err != nil {
+		return false, err
+	}
+	return tradeAgreement.Status == CANCELLED, nil
+}
+
+// Record a document attached to a dispute as evidence; evidence is closed once the dispute is resolved
+func linkDocumentToDispute(stub shim.ChaincodeStubInterface, document *Document) error {
+	var disputeKey string
+	var dispute *Dispute
+	var err error
+
+	disputeKey, dispute, err = getCurrentDisputeRecord(stub, document.TradeId)
+	if err != nil {
+		return err
+	}
+	if dispute.Status != OPEN {
+		return errors.New(fmt.Sprintf("Dispute %s on trade %s is already resolved", dispute.Id, document.TradeId))
+	}
+	dispute.Evidence = append(dispute.Evidence, document.Id)
+	err = putDisputeRecord(stub, disputeKey, dispute)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Document %s recorded as evidence in dispute %s\n", document.Id, dispute.Id)
+	return nil
+}
+
+// The account credited by payments under the L/C
+func getBeneficiaryAccount(stub shim.ChaincodeStubInterface, beneficiary string) (string, error) {
+	var exporterBytes []byte
+	var err error
+
+	exporterBytes, err = stub.GetState(expKey)
+	if err != nil {
+		return "", err
+	}
+
+	if beneficiary == string(exporterBytes) {
+		return expBalKey, nil
+	}
+	return getLenderAccount(stub, beneficiary)
+}
+
+// Withdraw the trade's outstanding payment requests, which the resolution supersedes
+func deletePaymentRequests(stub shim.ChaincodeStubInterface, tradeID string, partialShipments *PartialShipments) error {
+	var paymentKey string
+	var err error
+
+	paymentKey, err = getPaymentKey(stub, tradeID)
+	if err != nil {
+		return err
+	}
+	err = stub.DelState(paymentKey)
+	if err != nil {
+		return err
+	}
+	paymentKey, err = getAdvancePaymentKey(stub, tradeID)
+	if err != nil {
+		return err
+	}
+	err = stub.DelState(paymentKey)
+	if err != nil {
+		return err
+	}
+	if partialShipments != nil {
+		for _, partialShipment := range partialShipments.Shipments {
+			paymentKey, err = getPartialPaymentKey(stub, tradeID, partialShipment.Id)
+			if err != nil {
+				return err
+			}
+			err = stub.DelState(paymentKey)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Open a dispute against a trade
+func (t *TradeWorkflowChaincode) openDispute(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var tradeKey, disputeKey, tradeDisputesKey, claimant string
+	var tradeAgreementBytes []byte
+	var dispute *Dispute
+	var tradeDisputes *TradeDisputes
+	var now time.Time
+	var err error
+
+	if len(args) != 3 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 3: {Trade ID, Dispute ID, Reason}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+
+	// Access control: Only the trade's participants, or a regulator for its jurisdiction, can invoke this transaction
+	if !t.testMode {
+		err = authorizeTradeAccess(stub, creatorOrg, creatorCertIssuer, args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		claimant, err = getTxCreatorSubject(stub)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	if args[1] == "" {
+		return shim.Error("Dispute ID must be non-empty")
+	}
+	if args[2] == "" {
+		return shim.Error("Reason must be non-empty")
+	}
+
+	// Lookup trade agreement from the ledger
+	tradeKey, err = getTradeKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	tradeAgreementBytes, err = stub.GetState(tradeKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(tradeAgreementBytes) == 0 {
+		err = errors.New(fmt.Sprintf("No record found for trade ID %s", args[0]))
+		return shim.Error(err.Error())
+	}
+
+	// A new dispute may be opened once the one last opened is resolved; earlier disputes keep their rulings and evidence
+	tradeDisputesKey, tradeDisputes, err = getTradeDisputesRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if tradeDisputes == nil {
+		tradeDisputes = &TradeDisputes{args[0], []string{}}
+	}
+	_, dispute, err = getCurrentDisputeRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if dispute != nil && dispute.Status == OPEN {
+		fmt.Printf("Dispute %s already open on trade %s\n", dispute.Id, args[0])
+		return shim.Error("Dispute already open")
+	}
+	disputeKey, dispute, err = getDisputeRecord(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if dispute != nil {
+		fmt.Printf("Dispute %s on trade %s already recorded\n", args[1], args[0])
+		return shim.Error("Dispute ID already used")
+	}
+
+	now, err = getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	dispute = &Dispute{args[1], args[0], claimant, creatorOrg, args[2], []string{}, OPEN, now.Format(time.RFC3339), "", "", 0, "", "", ""}
+	err = putDisputeRecord(stub, disputeKey, dispute)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	tradeDisputes.Disputes = append(tradeDisputes.Disputes, args[1])
+	err = putTradeDisputesRecord(stub, tradeDisputesKey, tradeDisputes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Dispute %s on trade %s recorded\n", args[1], args[0])
+
+	return shim.Success(nil)
+}
+
+// Resolve a dispute; the chaincode executes the outcome, and the trade goes on unless it is cancelled
+func (t *TradeWorkflowChaincode) resolveDispute(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var disputeKey, tradeKey, lcKey, partialShipmentsKey, outcome, arbitrator, amountStr string
+	var tradeAgreementBytes, letterOfCreditBytes, exporterBytes, importerBytes []byte
+	var dispute *Dispute
+	var tradeAgreement *TradeAgreement
+	var letterOfCredit *LetterOfCredit
+	var partialShipments *PartialShipments
+	var balances map[string]int
+	var paymentRecords, trueUpRecords, refundRecords []PaymentRecord
+	var refund, reserveRecord *PaymentRecord
+	var amount, outstanding int
+	var found bool
+	var now time.Time
+	var err error
+
+	// Access control: Only an arbitrator can invoke this transaction
+	if !t.testMode && !authenticateArbitrator(stub, creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not an arbitrator of Regulator Org. Access denied.")
+	}
+
+	if len(args) != 3 && len(args) != 4 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 3: {Trade ID, Outcome, Ruling}, or 4 for a refund: {Trade ID, REFUND, Ruling, Payment ID}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+	if !t.testMode {
+		arbitrator, err = getTxCreatorSubject(stub)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	outcome = strings.ToUpper(args[1])
+	if outcome != PAY_IN_FULL && outcome != PARTIAL_PAY && outcome != REFUND && outcome != CANCEL {
+		err = errors.New(fmt.Sprintf("Invalid outcome %s; Permissible values: {PAY_IN_FULL, PARTIAL_PAY, REFUND, CANCEL}", args[1]))
+		return shim.Error(err.Error())
+	}
+	if args[2] == "" {
+		return shim.Error("Ruling must be non-empty")
+	}
+	if (outcome == REFUND) != (len(args) == 4) {
+		return shim.Error("A refund, and only a refund, names the payment it returns funds from")
+	}
+
+	disputeKey, dispute, err = getCurrentDisputeRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if dispute == nil {
+		err = errors.New(fmt.Sprintf("No dispute found for trade ID %s", args[0]))
+		return shim.Error(err.Error())
+	}
+	if dispute.Status != OPEN {
+		fmt.Printf("Dispute %s on trade %s already resolved\n", dispute.Id, args[0])
+		return shim.Error("Dispute already resolved")
+	}
+
+	// Lookup trade agreement from the ledger
+	tradeKey, err = getTradeKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	tradeAgreementBytes, err = stub.GetState(tradeKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(tradeAgreementBytes, &tradeAgreement)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Lookup trade amount and payment from the private terms
+	err = getTradeTerms(stub, tradeKey, tradeAgreement)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	outstanding = tradeAgreement.Amount - tradeAgreement.Payment - tradeAgreement.Penalty
+
+	// Lookup L/C from the ledger; payments go to its beneficiaries, or to the exporter if none was issued
+	lcKey, err = getLCKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	letterOfCreditBytes, err = stub.GetState(lcKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if len(letterOfCreditBytes) != 0 {
+		// Unmarshal the JSON
+		err = json.Unmarshal(letterOfCreditBytes, &letterOfCredit)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		// Lookup L/C amount and transfers from the private terms
+		err = getLetterOfCreditTerms(stub, lcKey, letterOfCredit)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	// The amount of a partial payment or refund is passed in the transient map; a payment is refunded in full without it
+	if outcome == PARTIAL_PAY {
+		amountStr, err = getTransientValue(stub, "amount")
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		amount, err = strconv.Atoi(amountStr)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	} else if outcome == REFUND {
+		amountStr, found, err = getOptionalTransientValue(stub, "amount")
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if found {
+			amount, err = strconv.Atoi(amountStr)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+			if amount <= 0 {
+				return shim.Error("Refund amount must be positive")
+			}
+		}
+	} else if outcome == PAY_IN_FULL {
+		amount = outstanding
+	}
+
+	// Execute the outcome
+	if outcome == PAY_IN_FULL || outcome == PARTIAL_PAY {
+		if amount <= 0 || amount > outstanding {
+			err = errors.New(fmt.Sprintf("Payment must be positive and not exceed the outstanding amount %d", outstanding))
+			return shim.Error(err.Error())
+		}
+		balances = map[string]int{}
+		err = loadAccountBalance(stub, balances, impBalKey)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if balances[impBalKey] < amount {
+			fmt.Printf("Balance %d of %s is insufficient to cover %d\n", balances[impBalKey], impBalKey, amount)
+			return shim.Error("Insufficient balance")
+		}
+		balances[impBalKey] -= amount
+		tradeAgreement.Payment += amount
+
+		if letterOfCredit != nil {
+			// Distribute the payment among the L/C's beneficiaries, pro rata to their parts of the L/C amount
+			paymentRecords, err = payLCBeneficiaries(stub, args[0], "", "resolveDispute", letterOfCredit, amount, amount, 0, balances)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+		} else {
+			err = loadAccountBalance(stub, balances, expBalKey)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+			balances[expBalKey] += amount
+
+			// Evaluate the AML rules on the payment
+			exporterBytes, err = stub.GetState(expKey)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+			importerBytes, err = stub.GetState(impKey)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+			err = monitorPayment(stub, "resolveDispute", args[0], string(importerBytes), string(exporterBytes), amount)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+			paymentRecords = []PaymentRecord{{"", args[0], "", PAYMENT, "resolveDispute", string(importerBytes), string(exporterBytes), amount, 0, 0, "", "", ""}}
+		}
+
+		// A reserve held back from a financing advance is due to the exporter once the trade is paid in full
+		if tradeAgreement.Payment + tradeAgreement.Penalty >= tradeAgreement.Amount {
+			reserveRecord, err = releaseFinancingReserve(stub, args[0], "resolveDispute", balances)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+			if reserveRecord != nil {
+				paymentRecords = append(paymentRecords, *reserveRecord)
+			}
+		}
+		trueUpRecords, err = reconcileFinancingPositions(stub, args[0], "resolveDispute", paymentRecords, balances)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		_, err = recordPayments(stub, args[0], append(paymentRecords, trueUpRecords...))
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		err = putAccountBalances(stub, balances)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	} else if outcome == REFUND {
+		refund, err = refundPaymentRecord(stub, tradeAgreement, letterOfCredit, args[0], args[3], amount, "resolveDispute")
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		amount = refund.Amount
+	} else {
+		// The payments made on a cancelled trade are returned to the importer
+		refundRecords, err = refundPaymentRecords(stub, tradeAgreement, letterOfCredit, args[0], nil, 0, "resolveDispute")
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		for _, record := range refundRecords {
+			if record.Type == REFUND {
+				amount += record.Amount
+			}
+		}
+		tradeAgreement.Status = CANCELLED
+	}
+
+	// Update ledger state
+	err = putTradeTerms(stub, tradeKey, tradeAgreement)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	tradeAgreementBytes, err = json.Marshal(tradeAgreement)
+	if err != nil {
+		return shim.Error("Error marshaling trade agreement structure")
+	}
+	err = stub.PutState(tradeKey, tradeAgreementBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if letterOfCredit != nil {
+		err = putLetterOfCreditTerms(stub, lcKey, letterOfCredit)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		letterOfCreditBytes, err = json.Marshal(letterOfCredit)
+		if err != nil {
+			return shim.Error("Error marshaling L/C structure")
+		}
+		err = stub.PutState(lcKey, letterOfCreditBytes)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	// Title to the goods passes to the importer once paid for in full; otherwise the B/L stays with the bank
+	partialShipmentsKey, partialShipments, err = getPartialShipmentsRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if (outcome == PAY_IN_FULL || outcome == PARTIAL_PAY) && tradeAgreement.Payment + tradeAgreement.Penalty >= tradeAgreement.Amount {
+		err = releaseBillOfLading(stub, args[0], "")
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if partialShipments != nil {
+			for i := range partialShipments.Shipments {
+				if partialShipments.Shipments[i].BillOfLadingId == "" {
+					continue
+				}
+				partialShipments.Shipments[i].PaymentStatus = PAID
+				err = releaseBillOfLading(stub, args[0], partialShipments.Shipments[i].Id)
+				if err != nil {
+					return shim.Error(err.Error())
+				}
+			}
+			err = putPartialShipmentsRecord(stub, partialShipmentsKey, partialShipments)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+		}
+	}
+	err = deletePaymentRequests(stub, args[0], partialShipments)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	now, err = getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	dispute.Status = RESOLVED
+	dispute.Arbitrator = arbitrator
+	dispute.Outcome = outcome
+	dispute.Amount = amount
+	dispute.Ruling = args[2]
+	dispute.ResolvedAt = now.Format(time.RFC3339)
+	err = putDisputeTerms(stub, disputeKey, dispute)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = putDisputeRecord(stub, disputeKey, dispute)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Dispute %s on trade %s resolved: %s %d\n", dispute.Id, args[0], outcome, amount)
+
+	return shim.Success(nil)
+}
+
+// Get a dispute opened on a trade; the one last opened unless a Dispute ID is given
+func (t *TradeWorkflowChaincode) getDispute(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var disputeKey, jsonResp string
+	var disputeBytes []byte
+	var tradeDisputes *TradeDisputes
+	var err error
+
+	if len(args) != 1 && len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 or 2: {Trade ID[, Dispute ID]}")
+	}
+
+	// Access control: Only the Arbitrator, the trade's participants, or a regulator for its jurisdiction, can invoke this transaction
+	if !t.testMode && !authenticateArbitrator(stub, creatorOrg, creatorCertIssuer) {
+		err = authorizeTradeAccess(stub, creatorOrg, creatorCertIssuer, args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	if len(args) == 1 {
+		_, tradeDisputes, err = getTradeDisputesRecord(stub, args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if tradeDisputes == nil || len(tradeDisputes.Disputes) == 0 {
+			err = errors.New(fmt.Sprintf("No dispute found for trade ID %s", args[0]))
+			return shim.Error(err.Error())
+		}
+		args = append(args, tradeDisputes.Disputes[len(tradeDisputes.Disputes) - 1])
+	}
+
+	// Get the state from the ledger
+	disputeKey, err = getDisputeKey(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	disputeBytes, err = stub.GetState(disputeKey)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Failed to get state for " + disputeKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+
+	if len(disputeBytes) == 0 {
+		jsonResp = "{\"Error\":\"No record found for " + disputeKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+	fmt.Printf("Query Response:%s\n", string(disputeBytes))
+	return shim.Success(disputeBytes)
+}
@@ -0,0 +1,341 @@
+/*
+ * Copyright 2018 IBM All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the 'License');
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an 'AS IS' BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// Trade assets that documents can be attached to, mapped to their ledger keys
+var documentAssetKeys = map[string]func(shim.ChaincodeStubInterface, string) (string, error){
+	"Trade":          getTradeKey,
+	"LetterOfCredit": getLCKey,
+	"ExportLicense":  getELKey,
+	"BillOfLading":   getBLKey,
+	"Dispute":        getTradeDisputesKey,
+}
+
+// Asset types whose assets exist once per partial shipment, mapped to their ledger keys
+var shipmentDocumentAssetKeys = map[string]func(shim.ChaincodeStubInterface, string, string) (string, error){
+	"BillOfLading": getTradeBLKey,
+}
+
+// A SHA-256 digest in hex; stored in lower case
+func parseDocumentHash(hash string) (string, error) {
+	var hashBytes []byte
+	var err error
+
+	hashBytes, err = hex.DecodeString(hash)
+	if err != nil || len(hashBytes) != 32 {
+		return "", errors.New(fmt.Sprintf("Invalid SHA-256 hash %s; expecting 64 hex digits", hash))
+	}
+	return strings.ToLower(hash), nil
+}
+
+// Shipping documents required by an L/C, mapped to the asset they must be attached to
+// Any other document type is a commercial document (e.g., an invoice or packing list), attached to the trade
+var lcShippingDocumentAssetTypes = map[string]string{
+	"E/L": "ExportLicense",
+	"B/L": "BillOfLading",
+}
+
+// Orgs that issue the shipping documents; only their members can upload one as the document fulfilling an L/C reference
+var lcShippingDocumentIssuers = map[string]func(string, string) bool{
+	"E/L": authenticateRegulatorOrg,
+	"B/L": authenticateCarrierOrg,
+}
+
+func getLCDocumentAssetType(documentType string) string {
+	assetType, found := lcShippingDocumentAssetTypes[documentType]
+	if !found {
+		return "Trade"
+	}
+	return assetType
+}
+
+// Link an uploaded document to the first unfulfilled L/C document reference of the same type
+// Only the trade's own shipping or commercial documents fulfil a reference; e.g., a B/L attached to a dispute does not
+func linkDocumentToLC(stub shim.ChaincodeStubInterface, tradeID string, document *Document) error {
+	var lcKey string
+	var letterOfCreditBytes []byte
+	var letterOfCredit *LetterOfCredit
+	var err error
+
+	if document.AssetType != getLCDocumentAssetType(document.DocumentType) {
+		return nil
+	}
+
+	// Lookup L/C from the ledger
+	lcKey, err = getLCKey(stub, tradeID)
+	if err != nil {
+		return err
+	}
+	letterOfCreditBytes, err = stub.GetState(lcKey)
+	if err != nil {
+		return err
+	}
+
+	if len(letterOfCreditBytes) == 0 {
+		return nil
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(letterOfCreditBytes, &letterOfCredit)
+	if err != nil {
+		return err
+	}
+
+	for i, reference := range letterOfCredit.Documents {
+		if reference.Type == document.DocumentType && reference.DocumentId == "" {
+			letterOfCredit.Documents[i].DocumentId = document.Id
+			letterOfCreditBytes, err = json.Marshal(letterOfCredit)
+			if err != nil {
+				return errors.New("Error marshaling L/C structure")
+			}
+			// Write the state to the ledger
+			err = stub.PutState(lcKey, letterOfCreditBytes)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Document %s linked to L/C for trade %s\n", document.Id, tradeID)
+			return nil
+		}
+	}
+	return nil
+}
+
+// Attach a document to a trade asset; the document itself is kept off-chain
+// The B/L of a partial shipment is identified by the Shipment ID following the Trade ID
+func (t *TradeWorkflowChaincode) uploadDocument(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var assetKey, documentKey, hash, uploader, shipmentID string
+	var assetBytes, documentBytes []byte
+	var size int
+	var getAssetKey func(shim.ChaincodeStubInterface, string) (string, error)
+	var getShipmentAssetKey func(shim.ChaincodeStubInterface, string, string) (string, error)
+	var authenticateIssuerOrg func(string, string) bool
+	var documentArgs []string
+	var found bool
+	var document *Document
+	var err error
+
+	if len(args) != 8 && len(args) != 9 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 8: {Trade ID, Document ID, Asset Type, Document Type, SHA-256, Media Type, Size, URI}, with an optional Shipment ID after the Trade ID. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+	documentArgs = args[1:]
+	if len(args) == 9 {
+		shipmentID = args[1]
+		documentArgs = args[2:]
+		if shipmentID == "" {
+			return shim.Error("Shipment ID must be non-empty")
+		}
+	}
+
+	// Access control: Only the trade's participants, or a regulator for its jurisdiction, can invoke this transaction
+	if !t.testMode {
+		err = authorizeTradeAccess(stub, creatorOrg, creatorCertIssuer, args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		// The shipping document fulfilling an L/C reference can only be uploaded by its issuer: the carrier for a B/L, the regulator for an E/L
+		authenticateIssuerOrg, found = lcShippingDocumentIssuers[documentArgs[2]]
+		if found && documentArgs[1] == getLCDocumentAssetType(documentArgs[2]) && !authenticateIssuerOrg(creatorOrg, creatorCertIssuer) {
+			err = errors.New(fmt.Sprintf("Caller not a member of the org issuing the %s. Access denied.", documentArgs[2]))
+			return shim.Error(err.Error())
+		}
+		uploader, err = getTxCreatorSubject(stub)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	// Type checks
+	hash, err = parseDocumentHash(documentArgs[3])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	size, err = strconv.Atoi(documentArgs[5])
+	if err != nil || size < 0 {
+		fmt.Printf("Document size must be a non-negative integer. Found %s\n", documentArgs[5])
+		return shim.Error("Invalid document size " + documentArgs[5])
+	}
+
+	// Lookup the asset the document is attached to
+	getAssetKey, found = documentAssetKeys[documentArgs[1]]
+	if !found {
+		err = errors.New(fmt.Sprintf("Invalid asset type %s; Permissible values: {Trade, LetterOfCredit, ExportLicense, BillOfLading, Dispute}", documentArgs[1]))
+		return shim.Error(err.Error())
+	}
+	if shipmentID != "" {
+		getShipmentAssetKey, found = shipmentDocumentAssetKeys[documentArgs[1]]
+		if !found {
+			err = errors.New(fmt.Sprintf("Asset type %s is not held per shipment; Permissible values with a Shipment ID: {BillOfLading}", documentArgs[1]))
+			return shim.Error(err.Error())
+		}
+		assetKey, err = getShipmentAssetKey(stub, args[0], shipmentID)
+	} else {
+		assetKey, err = getAssetKey(stub, args[0])
+	}
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	assetBytes, err = stub.GetState(assetKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(assetBytes) == 0 {
+		err = errors.New(fmt.Sprintf("No %s found for trade ID %s", documentArgs[1], args[0]))
+		return shim.Error(err.Error())
+	}
+
+	// Documents are immutable once recorded
+	documentKey, err = getDocumentKey(stub, args[0], documentArgs[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	documentBytes, err = stub.GetState(documentKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(documentBytes) != 0 {
+		err = errors.New(fmt.Sprintf("Document %s already recorded for trade %s", documentArgs[0], args[0]))
+		return shim.Error(err.Error())
+	}
+
+	document = &Document{documentArgs[0], args[0], shipmentID, documentArgs[1], documentArgs[2], hash, documentArgs[4], size, documentArgs[6], creatorOrg, uploader}
+	documentBytes, err = json.Marshal(document)
+	if err != nil {
+		return shim.Error("Error marshaling document structure")
+	}
+
+	// Write the state to the ledger
+	err = stub.PutState(documentKey, documentBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Document %s for trade %s recorded\n", documentArgs[0], args[0])
+
+	err = linkDocumentToLC(stub, args[0], document)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if document.AssetType == "Dispute" {
+		err = linkDocumentToDispute(stub, document)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	return shim.Success(nil)
+}
+
+func getDocumentRecord(stub shim.ChaincodeStubInterface, tradeID string, documentID string) (*Document, []byte, error) {
+	var documentKey string
+	var documentBytes []byte
+	var document *Document
+	var err error
+
+	// Get the state from the ledger
+	documentKey, err = getDocumentKey(stub, tradeID, documentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	documentBytes, err = stub.GetState(documentKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(documentBytes) == 0 {
+		return nil, nil, errors.New(fmt.Sprintf("No record found for document %s of trade %s", documentID, tradeID))
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(documentBytes, &document)
+	if err != nil {
+		return nil, nil, err
+	}
+	return document, documentBytes, nil
+}
+
+// Get a document record
+func (t *TradeWorkflowChaincode) getDocument(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var documentBytes []byte
+	var err error
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2: {Trade ID, Document ID}")
+	}
+
+	// Access control: Only the trade's participants, or a regulator for its jurisdiction, can invoke this transaction
+	if !t.testMode {
+		err = authorizeTradeAccess(stub, creatorOrg, creatorCertIssuer, args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	_, documentBytes, err = getDocumentRecord(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	fmt.Printf("Query Response:%s\n", string(documentBytes))
+	return shim.Success(documentBytes)
+}
+
+// Check whether a document's hash matches the one recorded on the ledger
+func (t *TradeWorkflowChaincode) verifyDocument(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var hash, jsonResp string
+	var document *Document
+	var err error
+
+	if len(args) != 3 {
+		return shim.Error("Incorrect number of arguments. Expecting 3: {Trade ID, Document ID, SHA-256}")
+	}
+
+	// Access control: Only the trade's participants, or a regulator for its jurisdiction, can invoke this transaction
+	if !t.testMode {
+		err = authorizeTradeAccess(stub, creatorOrg, creatorCertIssuer, args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	hash, err = parseDocumentHash(args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	document, _, err = getDocumentRecord(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	jsonResp = "{\"Verified\":\"" + strconv.FormatBool(document.Hash == hash) + "\"}"
+	fmt.Printf("Query Response:%s\n", jsonResp)
+	return shim.Success([]byte(jsonResp))
+}
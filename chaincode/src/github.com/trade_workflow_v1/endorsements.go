@@ -0,0 +1,344 @@
+/*
+ * Copyright 2018 IBM All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the 'License');
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an 'AS IS' BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// The Shipment ID is empty for a trade shipped in full
+func getBillOfLadingRecord(stub shim.ChaincodeStubInterface, tradeID string, shipmentID string) (string, *BillOfLading, error) {
+	var blKey string
+	var billOfLadingBytes []byte
+	var billOfLading *BillOfLading
+	var err error
+
+	// Lookup B/L from the ledger
+	blKey, err = getTradeBLKey(stub, tradeID, shipmentID)
+	if err != nil {
+		return "", nil, err
+	}
+	billOfLadingBytes, err = stub.GetState(blKey)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(billOfLadingBytes) == 0 {
+		return "", nil, errors.New(fmt.Sprintf("No B/L found for trade ID %s", tradeID))
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(billOfLadingBytes, &billOfLading)
+	if err != nil {
+		return "", nil, err
+	}
+	return blKey, billOfLading, nil
+}
+
+func putBillOfLadingRecord(stub shim.ChaincodeStubInterface, blKey string, billOfLading *BillOfLading) error {
+	var billOfLadingBytes []byte
+	var err error
+
+	billOfLadingBytes, err = json.Marshal(billOfLading)
+	if err != nil {
+		return errors.New("Error marshaling bill of lading structure")
+	}
+	// Write the state to the ledger
+	return stub.PutState(blKey, billOfLadingBytes)
+}
+
+// A B/L endorsed in blank is a bearer document
+func isBearerBL(billOfLading *BillOfLading) bool {
+	var lastEndorsement Endorsement
+
+	if billOfLading.HolderOrg != "" || len(billOfLading.Endorsements) == 0 {
+		return false
+	}
+	lastEndorsement = billOfLading.Endorsements[len(billOfLading.Endorsements)-1]
+	return lastEndorsement.Endorsee == "" && lastEndorsement.EndorseeOrg == ""
+}
+
+// Only the holder of a B/L can act on it: a user of the holder's org with the holder's certificate subject
+// The importer's bank holds the B/L as an org; its users act for it in the 'ImportersBank' role
+// A B/L released to an importer whose user was not recorded (e.g., on a trade requested before the upgrade) is held by
+// the importer's org, and any of its users can act on it
+// A B/L endorsed in blank is presented with the bearer token its endorser handed over with it; any other B/L without a
+// recorded holder is refused
+func authenticateBLHolder(stub shim.ChaincodeStubInterface, testMode bool, creatorOrg string, billOfLading *BillOfLading) bool {
+	var subject, role string
+	var err error
+
+	if isBearerBL(billOfLading) {
+		return presentsBearerToken(stub, billOfLading)
+	}
+	if testMode {
+		return true
+	}
+	if billOfLading.HolderOrg == "" || billOfLading.HolderOrg != creatorOrg {
+		return false
+	}
+	if billOfLading.Status == HELD_BY_BANK {
+		role, _, err = getCustomAttribute(stub, "tradeRole")
+		return err == nil && role == ibKey
+	}
+	if billOfLading.HolderSubject == "" {
+		return true
+	}
+	subject, err = getTxCreatorSubject(stub)
+	if err != nil {
+		return false
+	}
+	return subject == billOfLading.HolderSubject
+}
+
+// Proof of possession of a bearer B/L: the token passed in the transient map matches the hash recorded by the blank endorsement
+func presentsBearerToken(stub shim.ChaincodeStubInterface, billOfLading *BillOfLading) bool {
+	var token string
+	var found bool
+	var err error
+
+	token, found, err = getOptionalTransientValue(stub, "bearerToken")
+	if err != nil || !found {
+		return false
+	}
+	return hashPrivateData([]byte(token)) == billOfLading.Endorsements[len(billOfLading.Endorsements)-1].BearerTokenHash
+}
+
+// Release the B/L held by the importer's bank to the importer, and emit an event
+// Called once the trade, or the partial shipment, has been paid in full; a trade without a B/L has nothing to release
+func releaseBillOfLading(stub shim.ChaincodeStubInterface, tradeID string, shipmentID string) error {
+	var blKey, importerSubject string
+	var billOfLadingBytes, importerBytes, eventBytes []byte
+	var billOfLading *BillOfLading
+	var err error
+
+	// Lookup B/L from the ledger
+	blKey, err = getTradeBLKey(stub, tradeID, shipmentID)
+	if err != nil {
+		return err
+	}
+	billOfLadingBytes, err = stub.GetState(blKey)
+	if err != nil {
+		return err
+	}
+
+	if len(billOfLadingBytes) == 0 {
+		fmt.Printf("No B/L issued for trade %s; nothing to release\n", tradeID)
+		return nil
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(billOfLadingBytes, &billOfLading)
+	if err != nil {
+		return err
+	}
+
+	if billOfLading.Status != HELD_BY_BANK {
+		return nil
+	}
+
+	// Lookup importer, and the user who requested the trade on its behalf; without one, the importer's org holds the B/L
+	importerBytes, err = stub.GetState(impKey)
+	if err != nil {
+		return err
+	}
+	importerSubject, err = getTradeRequester(stub, tradeID)
+	if err != nil {
+		return err
+	}
+
+	billOfLading.Endorsements = append(billOfLading.Endorsements, Endorsement{billOfLading.Holder, billOfLading.HolderOrg, string(importerBytes), "ImporterOrgMSP", ""})
+	billOfLading.Holder = string(importerBytes)
+	billOfLading.HolderOrg = "ImporterOrgMSP"
+	billOfLading.HolderSubject = importerSubject
+	billOfLading.Status = RELEASED_TO_IMPORTER
+	err = putBillOfLadingRecord(stub, blKey, billOfLading)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("B/L for trade %s released to %s\n", tradeID, billOfLading.Holder)
+
+	eventBytes, err = json.Marshal(&BillOfLadingReleasedEvent{tradeID, billOfLading.Id, billOfLading.Holder, billOfLading.HolderOrg})
+	if err != nil {
+		return errors.New("Error marshaling B/L release event")
+	}
+	return stub.SetEvent(billOfLadingReleasedEvent, eventBytes)
+}
+
+// Transfer title to the goods to a named party, or endorse in blank
+// The importer's bank can negotiate a B/L it still holds; once endorsed away from the bank or the importer, it is NEGOTIATED
+// The B/L of a partial shipment is identified by the Shipment ID following the Trade ID
+func (t *TradeWorkflowChaincode) endorseBL(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var blKey, shipmentID, subject, bearerToken string
+	var billOfLading *BillOfLading
+	var endorsement Endorsement
+	var endorseeArgs []string
+	var err error
+
+	if len(args) != 1 && len(args) != 2 && len(args) != 4 && len(args) != 5 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 1 for a blank endorsement: {Trade ID}, and transient field {bearerToken}, or 4: {Trade ID, Endorsee, Endorsee Org MSP, Endorsee Subject}, with an optional Shipment ID after the Trade ID. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+	endorseeArgs = args[1:]
+	if len(args) == 2 || len(args) == 5 {
+		shipmentID = args[1]
+		endorseeArgs = args[2:]
+	}
+
+	blKey, billOfLading, err = getBillOfLadingRecord(stub, args[0], shipmentID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Access control: Only the B/L holder can invoke this transaction
+	if !authenticateBLHolder(stub, t.testMode, creatorOrg, billOfLading) {
+		return shim.Error("Caller not the holder of the B/L. Access denied.")
+	}
+
+	if billOfLading.Status != HELD_BY_BANK && billOfLading.Status != RELEASED_TO_IMPORTER && billOfLading.Status != NEGOTIATED {
+		fmt.Printf("B/L for trade %s is not negotiable; status is %s\n", args[0], billOfLading.Status)
+		return shim.Error("B/L already surrendered")
+	}
+
+	// The presenter of a bearer B/L endorses it under their org
+	endorsement = Endorsement{billOfLading.Holder, billOfLading.HolderOrg, "", "", ""}
+	if billOfLading.HolderOrg == "" {
+		endorsement.EndorserOrg = creatorOrg
+	}
+	if len(endorseeArgs) == 3 {
+		if endorseeArgs[0] == "" || endorseeArgs[1] == "" || endorseeArgs[2] == "" {
+			return shim.Error("Endorsee, Endorsee Org MSP and Endorsee Subject must be non-empty")
+		}
+		endorsement.Endorsee = endorseeArgs[0]
+		endorsement.EndorseeOrg = endorseeArgs[1]
+		subject = endorseeArgs[2]
+	} else {
+		// A blank endorsement is payable to whoever presents the token the endorser hands over with the B/L
+		bearerToken, err = getTransientValue(stub, "bearerToken")
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if len(bearerToken) < bearerTokenMinLength {
+			err = errors.New(fmt.Sprintf("Bearer token must be at least %d characters long", bearerTokenMinLength))
+			return shim.Error(err.Error())
+		}
+		endorsement.BearerTokenHash = hashPrivateData([]byte(bearerToken))
+	}
+
+	billOfLading.Holder = endorsement.Endorsee
+	billOfLading.HolderOrg = endorsement.EndorseeOrg
+	billOfLading.HolderSubject = subject
+	billOfLading.Status = NEGOTIATED
+	billOfLading.Endorsements = append(billOfLading.Endorsements, endorsement)
+	err = putBillOfLadingRecord(stub, blKey, billOfLading)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("B/L endorsement for trade %s recorded\n", args[0])
+
+	return shim.Success(nil)
+}
+
+// Surrender the B/L at destination; its holder takes delivery of the goods
+func (t *TradeWorkflowChaincode) surrenderBL(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var blKey, shipmentLocationKey, shipmentID string
+	var shipmentLocationBytes []byte
+	var billOfLading *BillOfLading
+	var partialShipment *PartialShipment
+	var customsDeclaration *CustomsDeclaration
+	var err error
+
+	if len(args) != 1 && len(args) != 2 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 1: {Trade ID}, or 2: {Trade ID, Shipment ID}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+	if len(args) == 2 {
+		shipmentID = args[1]
+	}
+
+	blKey, billOfLading, err = getBillOfLadingRecord(stub, args[0], shipmentID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Access control: Only the B/L holder can invoke this transaction
+	if !authenticateBLHolder(stub, t.testMode, creatorOrg, billOfLading) {
+		return shim.Error("Caller not the holder of the B/L. Access denied.")
+	}
+
+	if billOfLading.Status != RELEASED_TO_IMPORTER && billOfLading.Status != NEGOTIATED {
+		fmt.Printf("B/L for trade %s cannot be surrendered; status is %s\n", args[0], billOfLading.Status)
+		return shim.Error("B/L held by the bank or already surrendered")
+	}
+
+	// Lookup shipment location from the ledger
+	if shipmentID != "" {
+		_, _, partialShipment, err = getPartialShipment(stub, args[0], shipmentID)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		shipmentLocationBytes = []byte(partialShipment.Location)
+	} else {
+		shipmentLocationKey, err = getShipmentLocationKey(stub, args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		shipmentLocationBytes, err = stub.GetState(shipmentLocationKey)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	if string(shipmentLocationBytes) != DESTINATION {
+		fmt.Printf("Shipment for trade %s has not reached its destination\n", args[0])
+		return shim.Error("Shipment not at destination")
+	}
+
+	// The goods are only released once import customs has cleared them
+	_, customsDeclaration, err = getCustomsDeclarationRecord(stub, args[0], shipmentID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if customsDeclaration == nil || customsDeclaration.Status != CLEARED {
+		fmt.Printf("Goods for trade %s have not been cleared by Customs\n", args[0])
+		return shim.Error("Goods not cleared by Customs")
+	}
+
+	// A bearer B/L is surrendered by the user that presents it
+	if billOfLading.HolderOrg == "" {
+		billOfLading.HolderOrg = creatorOrg
+		if !t.testMode {
+			billOfLading.HolderSubject, err = getTxCreatorSubject(stub)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+		}
+	}
+	billOfLading.Status = SURRENDERED
+	err = putBillOfLadingRecord(stub, blKey, billOfLading)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("B/L for trade %s surrendered; goods released to %s (%s)\n", args[0], billOfLading.Holder, billOfLading.HolderOrg)
+
+	return shim.Success(nil)
+}
@@ -0,0 +1,660 @@
+/*
+ * Copyright 2018 IBM All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the 'License');
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an 'AS IS' BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// The time at which the transaction was proposed; the same on every endorsing peer
+func getTxTime(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	var txTimestamp *timestamp.Timestamp
+	var err error
+
+	txTimestamp, err = stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos)).UTC(), nil
+}
+
+func parseExportLicenseConditions(conditionsJSON string, expirationDate string) (*ExportLicenseConditions, error) {
+	var conditions *ExportLicenseConditions
+	var validFrom, expiration time.Time
+	var err error
+
+	err = json.Unmarshal([]byte(conditionsJSON), &conditions)
+	if err != nil || conditions == nil {
+		return nil, errors.New("Conditions must be a JSON object of {validFrom, maxQuantity, destinations}")
+	}
+	if conditions.ValidFrom != "" {
+		validFrom, err = time.Parse(dateLayout, conditions.ValidFrom)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid start of validity %s; expecting MM/DD/YYYY", conditions.ValidFrom))
+		}
+		expiration, _ = time.Parse(dateLayout, expirationDate)
+		if expiration.Before(validFrom) {
+			return nil, errors.New("E/L expires before its validity starts")
+		}
+	}
+	if conditions.MaxQuantity < 0 {
+		return nil, errors.New("Maximum quantity cannot be negative")
+	}
+	for _, destination := range conditions.Destinations {
+		if strings.TrimSpace(destination) == "" {
+			return nil, errors.New("Destinations must be non-empty")
+		}
+	}
+	return conditions, nil
+}
+
+func getExportLicenseRecord(stub shim.ChaincodeStubInterface, tradeID string) (string, *ExportLicense, error) {
+	var elKey string
+	var exportLicenseBytes []byte
+	var exportLicense *ExportLicense
+	var err error
+
+	// Lookup E/L from the ledger
+	elKey, err = getELKey(stub, tradeID)
+	if err != nil {
+		return "", nil, err
+	}
+	exportLicenseBytes, err = stub.GetState(elKey)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(exportLicenseBytes) == 0 {
+		return "", nil, errors.New(fmt.Sprintf("No E/L found for trade ID %s", tradeID))
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(exportLicenseBytes, &exportLicense)
+	if err != nil {
+		return "", nil, err
+	}
+	return elKey, exportLicense, nil
+}
+
+func putExportLicenseRecord(stub shim.ChaincodeStubInterface, elKey string, exportLicense *ExportLicense) error {
+	var exportLicenseBytes []byte
+	var err error
+
+	exportLicenseBytes, err = json.Marshal(exportLicense)
+	if err != nil {
+		return errors.New("Error marshaling E/L structure")
+	}
+	// Write the state to the ledger
+	return stub.PutState(elKey, exportLicenseBytes)
+}
+
+func getStandaloneExportLicenseRecord(stub shim.ChaincodeStubInterface, licenseID string) (string, *StandaloneExportLicense, error) {
+	var standaloneELKey string
+	var licenseBytes []byte
+	var license *StandaloneExportLicense
+	var err error
+
+	// Lookup standalone E/L from the ledger
+	standaloneELKey, err = getStandaloneELKey(stub, licenseID)
+	if err != nil {
+		return "", nil, err
+	}
+	licenseBytes, err = stub.GetState(standaloneELKey)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(licenseBytes) == 0 {
+		return "", nil, errors.New(fmt.Sprintf("No standalone E/L found for license ID %s", licenseID))
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(licenseBytes, &license)
+	if err != nil {
+		return "", nil, err
+	}
+	return standaloneELKey, license, nil
+}
+
+func putStandaloneExportLicenseRecord(stub shim.ChaincodeStubInterface, standaloneELKey string, license *StandaloneExportLicense) error {
+	var licenseBytes []byte
+	var err error
+
+	licenseBytes, err = json.Marshal(license)
+	if err != nil {
+		return errors.New("Error marshaling standalone E/L structure")
+	}
+	// Write the state to the ledger
+	return stub.PutState(standaloneELKey, licenseBytes)
+}
+
+// The E/L a trade ships under; when it references a standalone E/L, that license's status, validity and conditions apply
+// The standalone E/L is also returned, with its key, so that its quota can be drawn down
+func getTradeExportLicense(stub shim.ChaincodeStubInterface, tradeID string) (*ExportLicense, string, *StandaloneExportLicense, error) {
+	var standaloneELKey string
+	var exportLicense *ExportLicense
+	var license *StandaloneExportLicense
+	var err error
+
+	_, exportLicense, err = getExportLicenseRecord(stub, tradeID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if !exportLicense.Standalone || exportLicense.Status != ISSUED {
+		return exportLicense, "", nil, nil
+	}
+
+	standaloneELKey, license, err = getStandaloneExportLicenseRecord(stub, exportLicense.Id)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	exportLicense.ExpirationDate = license.ExpirationDate
+	exportLicense.Status = license.Status
+	exportLicense.Conditions = license.Conditions
+	exportLicense.Reason = license.Reason
+	return exportLicense, standaloneELKey, license, nil
+}
+
+// Count the goods of a trade, or of one of its partial shipments, against the quota of a standalone E/L
+// The drawdown is written in the same transaction as the shipment preparation, so concurrent drawdowns cannot both commit
+func drawDownExportQuota(stub shim.ChaincodeStubInterface, standaloneELKey string, license *StandaloneExportLicense, tradeID string, shipmentID string, quantity int, value int) error {
+	var now time.Time
+	var err error
+
+	for _, drawdown := range license.Drawdowns {
+		if drawdown.TradeId == tradeID && drawdown.ShipmentId == shipmentID {
+			return nil
+		}
+	}
+
+	if quantity <= 0 {
+		return errors.New("Standalone E/L quota is counted in units of goods; the trade must state its quantity")
+	}
+	err = getStandaloneExportLicenseTerms(stub, standaloneELKey, license)
+	if err != nil {
+		return err
+	}
+	if license.QuantityDrawn + quantity > license.QuantityQuota {
+		return errors.New(fmt.Sprintf("Quantity %d exceeds the %d remaining on E/L %s", quantity, license.QuantityQuota - license.QuantityDrawn, license.Id))
+	}
+	if license.ValueDrawn + value > license.ValueQuota {
+		return errors.New(fmt.Sprintf("Value %d exceeds the %d remaining on E/L %s", value, license.ValueQuota - license.ValueDrawn, license.Id))
+	}
+
+	now, err = getTxTime(stub)
+	if err != nil {
+		return err
+	}
+	license.QuantityDrawn += quantity
+	license.ValueDrawn += value
+	license.Drawdowns = append(license.Drawdowns, QuotaDrawdown{tradeID, shipmentID, quantity, value, now.Format(time.RFC3339)})
+	err = putStandaloneExportLicenseTerms(stub, standaloneELKey, license)
+	if err != nil {
+		return err
+	}
+	err = putStandaloneExportLicenseRecord(stub, standaloneELKey, license)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Drawdown of %d units on E/L %s for trade %s recorded\n", quantity, license.Id, tradeID)
+
+	return nil
+}
+
+// Verify that the E/L is in force at the time of the transaction, and that the shipment meets its conditions
+// The quantity is the total shipped for the trade; an empty destination port is not checked
+func checkExportLicense(stub shim.ChaincodeStubInterface, exportLicense *ExportLicense, quantity int, destinationPort string) error {
+	var now, validFrom, expiration time.Time
+	var allowed bool
+	var err error
+
+	if exportLicense.Status == REJECTED || exportLicense.Status == REVOKED {
+		return errors.New(fmt.Sprintf("E/L %s: %s", strings.ToLower(exportLicense.Status), exportLicense.Reason))
+	}
+	if exportLicense.Status != ISSUED {
+		return errors.New("E/L not issued yet")
+	}
+
+	now, err = getTxTime(stub)
+	if err != nil {
+		return err
+	}
+	expiration, err = time.Parse(dateLayout, exportLicense.ExpirationDate)
+	if err != nil {
+		return err
+	}
+	if !now.Before(expiration.AddDate(0, 0, 1)) {
+		return errors.New(fmt.Sprintf("E/L expired on %s", exportLicense.ExpirationDate))
+	}
+
+	if exportLicense.Conditions == nil {
+		return nil
+	}
+	if exportLicense.Conditions.ValidFrom != "" {
+		validFrom, err = time.Parse(dateLayout, exportLicense.Conditions.ValidFrom)
+		if err != nil {
+			return err
+		}
+		if now.Before(validFrom) {
+			return errors.New(fmt.Sprintf("E/L not valid before %s", exportLicense.Conditions.ValidFrom))
+		}
+	}
+	if exportLicense.Conditions.MaxQuantity > 0 {
+		if quantity == 0 {
+			return errors.New("E/L limits the quantity of goods; the trade must state its quantity")
+		}
+		if quantity > exportLicense.Conditions.MaxQuantity {
+			return errors.New(fmt.Sprintf("Quantity %d exceeds the %d allowed by the E/L", quantity, exportLicense.Conditions.MaxQuantity))
+		}
+	}
+	if destinationPort != "" && len(exportLicense.Conditions.Destinations) > 0 {
+		for _, destination := range exportLicense.Conditions.Destinations {
+			if strings.EqualFold(strings.TrimSpace(destination), strings.TrimSpace(destinationPort)) {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return errors.New(fmt.Sprintf("E/L does not allow export to %s", destinationPort))
+		}
+	}
+	return nil
+}
+
+// Refuse an E/L request, with the reason
+func (t *TradeWorkflowChaincode) rejectEL(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var elKey string
+	var exportLicense *ExportLicense
+	var err error
+
+	// Access control: Only a Regulator Org member can invoke this transaction
+	if !t.testMode && !authenticateRegulatorOrg(creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a member of Regulator Org. Access denied.")
+	}
+
+	if len(args) != 2 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 2: {Trade ID, Reason}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+	if args[1] == "" {
+		return shim.Error("Reason must be non-empty")
+	}
+
+	elKey, exportLicense, err = getExportLicenseRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if exportLicense.Status != REQUESTED {
+		fmt.Printf("E/L for trade %s is not pending; status is %s\n", args[0], exportLicense.Status)
+		return shim.Error("E/L not requested or already decided")
+	}
+
+	exportLicense.Status = REJECTED
+	exportLicense.Reason = args[1]
+	err = putExportLicenseRecord(stub, elKey, exportLicense)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Export License rejection for trade %s recorded\n", args[0])
+
+	return shim.Success(nil)
+}
+
+// Withdraw an issued E/L, with the reason; goods not yet shipped can no longer be shipped under it
+func (t *TradeWorkflowChaincode) revokeEL(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var elKey string
+	var exportLicense *ExportLicense
+	var err error
+
+	// Access control: Only a Regulator Org member can invoke this transaction
+	if !t.testMode && !authenticateRegulatorOrg(creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a member of Regulator Org. Access denied.")
+	}
+
+	if len(args) != 2 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 2: {Trade ID, Reason}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+	if args[1] == "" {
+		return shim.Error("Reason must be non-empty")
+	}
+
+	elKey, exportLicense, err = getExportLicenseRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if exportLicense.Status != ISSUED {
+		fmt.Printf("E/L for trade %s is not in force; status is %s\n", args[0], exportLicense.Status)
+		return shim.Error("E/L not issued or already revoked")
+	}
+
+	exportLicense.Status = REVOKED
+	exportLicense.Reason = args[1]
+	err = putExportLicenseRecord(stub, elKey, exportLicense)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Export License revocation for trade %s recorded\n", args[0])
+
+	return shim.Success(nil)
+}
+
+// Issue an E/L to the exporter for a quota of goods, which any number of its trades can reference
+func (t *TradeWorkflowChaincode) issueStandaloneEL(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var standaloneELKey string
+	var licenseBytes, exporterBytes, approverBytes []byte
+	var license *StandaloneExportLicense
+	var conditions *ExportLicenseConditions
+	var quantityQuota, valueQuota int
+	var err error
+
+	// Access control: Only a Regulator Org member can invoke this transaction
+	if !t.testMode && !authenticateRegulatorOrg(creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a member of Regulator Org. Access denied.")
+	}
+
+	if len(args) != 5 && len(args) != 6 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 5: {License ID, Description of Goods, Expiry Date, Quantity Quota, Value Quota}, or 6: {License ID, Description of Goods, Expiry Date, Quantity Quota, Value Quota, Conditions}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+	if args[0] == "" || args[1] == "" {
+		return shim.Error("License ID and description of goods must be non-empty")
+	}
+	_, err = time.Parse(dateLayout, args[2])
+	if err != nil {
+		err = errors.New(fmt.Sprintf("Invalid expiry date %s; expecting MM/DD/YYYY", args[2]))
+		return shim.Error(err.Error())
+	}
+	quantityQuota, err = strconv.Atoi(args[3])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	valueQuota, err = strconv.Atoi(args[4])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if quantityQuota <= 0 || valueQuota <= 0 {
+		return shim.Error("Quantity and value quotas must be positive")
+	}
+	if len(args) == 6 {
+		conditions, err = parseExportLicenseConditions(args[5], args[2])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	// Lookup standalone E/L from the ledger
+	standaloneELKey, err = getStandaloneELKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	licenseBytes, err = stub.GetState(standaloneELKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(licenseBytes) != 0 {
+		err = errors.New(fmt.Sprintf("Standalone E/L %s has already been issued", args[0]))
+		return shim.Error(err.Error())
+	}
+
+	// Lookup exporter (license holder)
+	exporterBytes, err = stub.GetState(expKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Lookup regulatory authority (license approver)
+	approverBytes, err = stub.GetState(raKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	license = &StandaloneExportLicense{args[0], args[2], string(exporterBytes), args[1], string(approverBytes), ISSUED, conditions, "", quantityQuota, valueQuota, 0, 0, []QuotaDrawdown{}, "", "", ""}
+	err = signStandaloneExportLicense(stub, t.testMode, license)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = putStandaloneExportLicenseTerms(stub, standaloneELKey, license)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = putStandaloneExportLicenseRecord(stub, standaloneELKey, license)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Standalone Export License %s issuance recorded\n", args[0])
+
+	return shim.Success(nil)
+}
+
+// Withdraw a standalone E/L, with the reason; none of the trades referencing it can ship any further goods under it
+func (t *TradeWorkflowChaincode) revokeStandaloneEL(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var standaloneELKey string
+	var license *StandaloneExportLicense
+	var err error
+
+	// Access control: Only a Regulator Org member can invoke this transaction
+	if !t.testMode && !authenticateRegulatorOrg(creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a member of Regulator Org. Access denied.")
+	}
+
+	if len(args) != 2 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 2: {License ID, Reason}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+	if args[1] == "" {
+		return shim.Error("Reason must be non-empty")
+	}
+
+	standaloneELKey, license, err = getStandaloneExportLicenseRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if license.Status != ISSUED {
+		fmt.Printf("Standalone E/L %s is not in force; status is %s\n", args[0], license.Status)
+		return shim.Error("E/L already revoked")
+	}
+
+	license.Status = REVOKED
+	license.Reason = args[1]
+	err = putStandaloneExportLicenseRecord(stub, standaloneELKey, license)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Standalone Export License %s revocation recorded\n", args[0])
+
+	return shim.Success(nil)
+}
+
+// Ship a trade under a standalone E/L held by the exporter, instead of requesting an E/L for the trade
+func (t *TradeWorkflowChaincode) attachEL(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var tradeKey, lcKey, elKey string
+	var tradeAgreementBytes, letterOfCreditBytes, exportLicenseBytes, exporterBytes, carrierBytes []byte
+	var tradeAgreement *TradeAgreement
+	var letterOfCredit *LetterOfCredit
+	var exportLicense *ExportLicense
+	var license *StandaloneExportLicense
+	var err error
+
+	// Access control: Only an Exporter Org member can invoke this transaction
+	if !t.testMode && !authenticateExporterOrg(creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a member of Exporter Org. Access denied.")
+	}
+
+	if len(args) != 2 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 2: {Trade ID, License ID}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+
+	// Lookup L/C from the ledger
+	lcKey, err = getLCKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	letterOfCreditBytes, err = stub.GetState(lcKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(letterOfCreditBytes) == 0 {
+		err = errors.New(fmt.Sprintf("No L/C found for trade ID %s", args[0]))
+		return shim.Error(err.Error())
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(letterOfCreditBytes, &letterOfCredit)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Verify that the L/C has already been accepted
+	if letterOfCredit.Status != ACCEPTED {
+		fmt.Printf("L/C for trade %s has not been accepted\n", args[0])
+		return shim.Error("L/C not accepted yet")
+	}
+
+	// Lookup trade agreement from the ledger
+	tradeKey, err = getTradeKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	tradeAgreementBytes, err = stub.GetState(tradeKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(tradeAgreementBytes) == 0 {
+		err = errors.New(fmt.Sprintf("No record found for trade ID %s", args[0]))
+		return shim.Error(err.Error())
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(tradeAgreementBytes, &tradeAgreement)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// A trade whose own E/L is pending or in force cannot switch licenses
+	elKey, err = getELKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	exportLicenseBytes, err = stub.GetState(elKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if len(exportLicenseBytes) != 0 {
+		err = json.Unmarshal(exportLicenseBytes, &exportLicense)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if exportLicense.Status == REQUESTED || exportLicense.Status == ISSUED {
+			fmt.Printf("E/L for trade %s is %s\n", args[0], strings.ToLower(exportLicense.Status))
+			return shim.Error("E/L already requested or issued for the trade")
+		}
+	}
+
+	// Lookup exporter
+	exporterBytes, err = stub.GetState(expKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Lookup carrier
+	carrierBytes, err = stub.GetState(carKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// The license must be in force, held by the trade's exporter, and cover the trade's goods
+	_, license, err = getStandaloneExportLicenseRecord(stub, args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if license.Status != ISSUED {
+		err = errors.New(fmt.Sprintf("E/L %s %s: %s", args[1], strings.ToLower(license.Status), license.Reason))
+		return shim.Error(err.Error())
+	}
+	if license.Exporter != string(exporterBytes) {
+		err = errors.New(fmt.Sprintf("E/L %s is not held by exporter %s", args[1], string(exporterBytes)))
+		return shim.Error(err.Error())
+	}
+	if !strings.EqualFold(strings.TrimSpace(license.DescriptionOfGoods), strings.TrimSpace(tradeAgreement.DescriptionOfGoods)) {
+		err = errors.New(fmt.Sprintf("E/L %s covers %s, not %s", args[1], license.DescriptionOfGoods, tradeAgreement.DescriptionOfGoods))
+		return shim.Error(err.Error())
+	}
+
+	exportLicense = &ExportLicense{license.Id, license.ExpirationDate, license.Exporter, string(carrierBytes), tradeAgreement.DescriptionOfGoods, "", license.Approver, ISSUED, license.Conditions, "", true, "", ""}
+	err = putExportLicenseRecord(stub, elKey, exportLicense)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Standalone Export License %s attached to trade %s\n", args[1], args[0])
+
+	return shim.Success(nil)
+}
+
+// Get a standalone E/L, with its remaining quota and the trades that have drawn on it
+func (t *TradeWorkflowChaincode) getStandaloneEL(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var standaloneELKey, jsonResp string
+	var licenseBytes []byte
+	var err error
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1: <license ID>")
+	}
+
+	// Access control: Only an Exporter or Regulator Org member can invoke this transaction
+	if !t.testMode && !(authenticateExporterOrg(creatorOrg, creatorCertIssuer) || authenticateRegulatorOrg(creatorOrg, creatorCertIssuer)) {
+		return shim.Error("Caller not a member of Exporter or Regulator Org. Access denied.")
+	}
+
+	// Get the state from the ledger
+	standaloneELKey, err = getStandaloneELKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	licenseBytes, err = stub.GetState(standaloneELKey)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Failed to get state for " + standaloneELKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+
+	if len(licenseBytes) == 0 {
+		jsonResp = "{\"Error\":\"No record found for " + standaloneELKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+	licenseBytes, err = addPrivateTerms(stub, exportQuotaCollection, standaloneELKey, licenseBytes)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Failed to get private terms for " + standaloneELKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+	fmt.Printf("Query Response:%s\n", string(licenseBytes))
+	return shim.Success(licenseBytes)
+}
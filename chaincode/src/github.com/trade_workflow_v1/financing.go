@@ -0,0 +1,1442 @@
+/*
+ * Copyright 2018 IBM All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the 'License');
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an 'AS IS' BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// Returns nil if no lender of that name has registered
+func getLenderRecord(stub shim.ChaincodeStubInterface, lenderName string) (string, *Lender, error) {
+	var lenderKey string
+	var lenderBytes []byte
+	var lender *Lender
+	var err error
+
+	// Lookup lender from the ledger
+	lenderKey, err = getLenderKey(stub, lenderName)
+	if err != nil {
+		return "", nil, err
+	}
+	lenderBytes, err = stub.GetState(lenderKey)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(lenderBytes) == 0 {
+		return lenderKey, nil, nil
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(lenderBytes, &lender)
+	if err != nil {
+		return "", nil, err
+	}
+	return lenderKey, lender, nil
+}
+
+// The account balance key of a lender: the one configured at Init, or one registered to bid for financing
+func getLenderAccount(stub shim.ChaincodeStubInterface, lenderName string) (string, error) {
+	var lenderBytes []byte
+	var lender *Lender
+	var err error
+
+	lenderBytes, err = stub.GetState(lenKey)
+	if err != nil {
+		return "", err
+	}
+	if lenderName == string(lenderBytes) {
+		return lenBalKey, nil
+	}
+
+	_, lender, err = getLenderRecord(stub, lenderName)
+	if err != nil {
+		return "", err
+	}
+	if lender == nil {
+		return "", errors.New(fmt.Sprintf("No lender %s registered", lenderName))
+	}
+	return getLenderBalanceKey(stub, lenderName)
+}
+
+// A Lender Org member acts for the lender named by its 'lender' attribute, or for the lender configured at Init without one
+func authorizeLender(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, lenderName string) error {
+	var lenderAttr string
+	var lenderBytes []byte
+	var found bool
+	var err error
+
+	if !authenticateLenderOrg(creatorOrg, creatorCertIssuer) {
+		return errors.New("Caller not a member of Lender Org. Access denied.")
+	}
+
+	lenderAttr, found, err = getCustomAttribute(stub, "lender")
+	if err != nil {
+		return err
+	}
+	if found {
+		if lenderAttr != lenderName {
+			return errors.New(fmt.Sprintf("Caller does not act for lender %s. Access denied.", lenderName))
+		}
+		return nil
+	}
+
+	lenderBytes, err = stub.GetState(lenKey)
+	if err != nil {
+		return err
+	}
+	if lenderName != string(lenderBytes) {
+		return errors.New(fmt.Sprintf("Caller does not act for lender %s. Access denied.", lenderName))
+	}
+	return nil
+}
+
+// Returns nil if the trade's L/C has not been posted for financing
+func getReceivableRecord(stub shim.ChaincodeStubInterface, tradeID string) (string, *Receivable, error) {
+	var receivableKey string
+	var receivableBytes []byte
+	var receivable *Receivable
+	var err error
+
+	// Lookup receivable from the ledger
+	receivableKey, err = getReceivableKey(stub, tradeID)
+	if err != nil {
+		return "", nil, err
+	}
+	receivableBytes, err = stub.GetState(receivableKey)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(receivableBytes) == 0 {
+		return receivableKey, nil, nil
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(receivableBytes, &receivable)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Lookup the reserve from the private terms
+	err = getReceivableTerms(stub, receivableKey, receivable)
+	if err != nil {
+		return "", nil, err
+	}
+	return receivableKey, receivable, nil
+}
+
+func putReceivableRecord(stub shim.ChaincodeStubInterface, receivableKey string, receivable *Receivable) error {
+	var receivableBytes []byte
+	var err error
+
+	err = putReceivableTerms(stub, receivableKey, receivable)
+	if err != nil {
+		return err
+	}
+	receivableBytes, err = json.Marshal(receivable)
+	if err != nil {
+		return errors.New("Error marshaling receivable structure")
+	}
+	// Write the state to the ledger
+	return stub.PutState(receivableKey, receivableBytes)
+}
+
+// Returns nil if the lender has not bid; the rates of a revealed bid are read only by peers of the financing bids collection
+func getFinancingBidRecord(stub shim.ChaincodeStubInterface, tradeID string, lenderName string) (string, *FinancingBid, error) {
+	var financingBidKey string
+	var financingBidBytes []byte
+	var financingBid *FinancingBid
+	var err error
+
+	// Lookup bid from the ledger
+	financingBidKey, err = getFinancingBidKey(stub, tradeID, lenderName)
+	if err != nil {
+		return "", nil, err
+	}
+	financingBidBytes, err = stub.GetState(financingBidKey)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(financingBidBytes) == 0 {
+		return financingBidKey, nil, nil
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(financingBidBytes, &financingBid)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Lookup discount and advance rates from the private terms, once revealed
+	if financingBid.TermsHash != "" {
+		err = getBidTerms(stub, financingBidKey, financingBid)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	return financingBidKey, financingBid, nil
+}
+
+func putFinancingBidRecord(stub shim.ChaincodeStubInterface, financingBidKey string, financingBid *FinancingBid) error {
+	var financingBidBytes []byte
+	var err error
+
+	financingBidBytes, err = json.Marshal(financingBid)
+	if err != nil {
+		return errors.New("Error marshaling financing bid structure")
+	}
+	// Write the state to the ledger
+	return stub.PutState(financingBidKey, financingBidBytes)
+}
+
+// The winning lender holds back a reserve from its advance until the trade is paid in full, then releases it to the exporter
+// The reserve is moved on the balances given; returns the payment record to make, or nil if there is no reserve
+func releaseFinancingReserve(stub shim.ChaincodeStubInterface, tradeID string, function string, balances map[string]int) (*PaymentRecord, error) {
+	var receivableKey, lenderBalKey string
+	var exporterBytes []byte
+	var receivable *Receivable
+	var reserve int
+	var err error
+
+	receivableKey, receivable, err = getReceivableRecord(stub, tradeID)
+	if err != nil {
+		return nil, err
+	}
+	if receivable == nil || receivable.Status != AWARDED || receivable.Reserve == 0 {
+		return nil, nil
+	}
+
+	lenderBalKey, err = getLenderAccount(stub, receivable.WinningBidder)
+	if err != nil {
+		return nil, err
+	}
+	err = moveAccountFunds(stub, balances, lenderBalKey, expBalKey, receivable.Reserve)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Financing reserve of %d for trade %s released to the exporter\n", receivable.Reserve, tradeID)
+
+	exporterBytes, err = stub.GetState(expKey)
+	if err != nil {
+		return nil, err
+	}
+	reserve = receivable.Reserve
+	receivable.Reserve = 0
+	err = putReceivableRecord(stub, receivableKey, receivable)
+	if err != nil {
+		return nil, err
+	}
+	return &PaymentRecord{"", tradeID, "", RESERVE_RELEASE, function, receivable.WinningBidder, string(exporterBytes), reserve, 0, 0, "", "", ""}, nil
+}
+
+// Returns nil if the lender has not advanced against the L/C
+func getFinancingPositionRecord(stub shim.ChaincodeStubInterface, tradeID string, lenderName string) (string, *FinancingPosition, error) {
+	var financingPositionKey string
+	var financingPositionBytes []byte
+	var financingPosition *FinancingPosition
+	var err error
+
+	// Lookup financing position from the ledger
+	financingPositionKey, err = getFinancingPositionKey(stub, tradeID, lenderName)
+	if err != nil {
+		return "", nil, err
+	}
+	financingPositionBytes, err = stub.GetState(financingPositionKey)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(financingPositionBytes) == 0 {
+		return financingPositionKey, nil, nil
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(financingPositionBytes, &financingPosition)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Lookup the amounts from the private terms
+	err = getFinancingPositionTerms(stub, financingPositionKey, financingPosition)
+	if err != nil {
+		return "", nil, err
+	}
+	return financingPositionKey, financingPosition, nil
+}
+
+func putFinancingPositionRecord(stub shim.ChaincodeStubInterface, financingPositionKey string, financingPosition *FinancingPosition) error {
+	var financingPositionBytes []byte
+	var err error
+
+	err = putFinancingPositionTerms(stub, financingPositionKey, financingPosition)
+	if err != nil {
+		return err
+	}
+	financingPositionBytes, err = json.Marshal(financingPosition)
+	if err != nil {
+		return errors.New("Error marshaling financing position structure")
+	}
+	// Write the state to the ledger
+	return stub.PutState(financingPositionKey, financingPositionBytes)
+}
+
+// Accrue interest on an amount collected, or refunded if negative, by a lender financing at an annual rate, from its advance
+// to the date of the transaction; the lender's exposure is outstanding until then
+func accrueFinancingInterest(financingPosition *FinancingPosition, amount int, now time.Time) error {
+	var opened time.Time
+	var accrued int
+	var err error
+
+	if financingPosition.AnnualRate == 0 || amount == 0 {
+		return nil
+	}
+	opened, err = time.Parse(time.RFC3339, financingPosition.OpenedAt)
+	if err != nil {
+		return err
+	}
+	accrued, err = getAccruedInterest(amount, financingPosition.AnnualRate, financingPosition.DayCount, opened, now)
+	if err != nil {
+		return err
+	}
+	financingPosition.Accrued += accrued
+	return nil
+}
+
+// True up the interest charged up front on an advance against the interest accrued on the actual collections: the exporter
+// pays the lender for late collection, and the lender returns the interest not earned on early collection
+// Funds are moved on the balances given; returns the payment record to make, or nil if nothing is due
+func trueUpFinancingInterest(stub shim.ChaincodeStubInterface, financingPosition *FinancingPosition, function string, balances map[string]int) (*PaymentRecord, error) {
+	var lenderBalKey string
+	var exporterBytes []byte
+	var due int
+	var err error
+
+	due = financingPosition.Accrued - financingPosition.Interest - financingPosition.TrueUp
+	if financingPosition.AnnualRate == 0 || due == 0 {
+		return nil, nil
+	}
+	lenderBalKey, err = getLenderAccount(stub, financingPosition.Lender)
+	if err != nil {
+		return nil, err
+	}
+	exporterBytes, err = stub.GetState(expKey)
+	if err != nil {
+		return nil, err
+	}
+	financingPosition.TrueUp += due
+	fmt.Printf("Interest of lender %s on trade %s trued up by %d\n", financingPosition.Lender, financingPosition.TradeId, due)
+
+	if due > 0 {
+		err = moveAccountFunds(stub, balances, expBalKey, lenderBalKey, due)
+		if err != nil {
+			return nil, err
+		}
+		return &PaymentRecord{"", financingPosition.TradeId, "", TRUE_UP, function, string(exporterBytes), financingPosition.Lender, due, 0, 0, "", "", ""}, nil
+	}
+	err = moveAccountFunds(stub, balances, lenderBalKey, expBalKey, -due)
+	if err != nil {
+		return nil, err
+	}
+	return &PaymentRecord{"", financingPosition.TradeId, "", TRUE_UP, function, financingPosition.Lender, string(exporterBytes), -due, 0, 0, "", "", ""}, nil
+}
+
+// Reconcile the financing positions of the lenders in the payment records given: payments collected by a lender, refunds
+// it makes, and reserves it releases; a position is settled once nothing remains to be collected, and its interest trued up
+// Each position is read and written once, as a transaction does not read its own writes
+// Funds are moved on the balances given; returns the true-up payment records to make
+func reconcileFinancingPositions(stub shim.ChaincodeStubInterface, tradeID string, function string, paymentRecords []PaymentRecord, balances map[string]int) ([]PaymentRecord, error) {
+	var lenders []string
+	var collected, advanced map[string]int
+	var seen map[string]bool
+	var financingPositionKey, lender string
+	var financingPosition *FinancingPosition
+	var trueUpRecords []PaymentRecord
+	var trueUpRecord *PaymentRecord
+	var now time.Time
+	var err error
+
+	collected = map[string]int{}
+	advanced = map[string]int{}
+	seen = map[string]bool{}
+	for _, record := range paymentRecords {
+		if record.Type == PAYMENT {
+			lender = record.Payee
+			collected[lender] += record.Amount
+		} else if record.Type == REFUND {
+			lender = record.Payer
+			collected[lender] -= record.Amount
+		} else if record.Type == RESERVE_RELEASE {
+			lender = record.Payer
+			advanced[lender] += record.Amount
+		} else {
+			continue
+		}
+		if !seen[lender] {
+			seen[lender] = true
+			lenders = append(lenders, lender)
+		}
+	}
+
+	now, err = getTxTime(stub)
+	if err != nil {
+		return nil, err
+	}
+	for _, lender = range lenders {
+		financingPositionKey, financingPosition, err = getFinancingPositionRecord(stub, tradeID, lender)
+		if err != nil {
+			return nil, err
+		}
+		if financingPosition == nil {
+			continue
+		}
+		financingPosition.Collected += collected[lender]
+		financingPosition.Advanced += advanced[lender]
+
+		// A position closed by recourse keeps the shortfall, and the interest, it was closed on
+		if financingPosition.Status != RECOURSED {
+			err = accrueFinancingInterest(financingPosition, collected[lender], now)
+			if err != nil {
+				return nil, err
+			}
+			financingPosition.Shortfall = financingPosition.Expected - financingPosition.Collected
+			if financingPosition.Shortfall <= 0 {
+				financingPosition.Shortfall = 0
+				if financingPosition.Status != SETTLED {
+					financingPosition.Status = SETTLED
+					financingPosition.ClosedAt = now.Format(time.RFC3339)
+				}
+				trueUpRecord, err = trueUpFinancingInterest(stub, financingPosition, function, balances)
+				if err != nil {
+					return nil, err
+				}
+				if trueUpRecord != nil {
+					trueUpRecords = append(trueUpRecords, *trueUpRecord)
+				}
+			} else {
+				financingPosition.Status = OPEN
+				financingPosition.ClosedAt = ""
+			}
+		}
+		err = putFinancingPositionRecord(stub, financingPositionKey, financingPosition)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return trueUpRecords, nil
+}
+
+// Register a lender to bid for L/C financing, with its opening account balance; the regulator licenses lenders and funds
+// their accounts, so a lender cannot set its own balance
+func (t *TradeWorkflowChaincode) registerLender(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var lenderKey, lenderBalKey, balanceStr string
+	var roleBytes, lenderBytes []byte
+	var lender *Lender
+	var balance int
+	var now time.Time
+	var err error
+
+	if len(args) != 1 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 1: {Lender}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+
+	// Access control: Only a Regulatory Authority Org member can invoke this transaction
+	if !t.testMode && !authenticateRegulatorOrg(creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a member of Regulatory Authority Org. Access denied.")
+	}
+
+	if args[0] == "" {
+		return shim.Error("Lender name must be non-empty")
+	}
+
+	// The opening balance is passed in the transient map
+	balanceStr, err = getTransientValue(stub, "balance")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	balance, err = strconv.Atoi(balanceStr)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if balance < 0 {
+		return shim.Error("Opening balance must not be negative")
+	}
+
+	// The name must not already hold an account
+	if args[0] == cusKey {
+		return shim.Error("Customs holds the revenue account")
+	}
+	for _, roleKey := range []string{expKey, impKey, lenKey, insKey} {
+		roleBytes, err = stub.GetState(roleKey)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if args[0] == string(roleBytes) {
+			err = errors.New(fmt.Sprintf("%s already holds an account as %s", args[0], roleKey))
+			return shim.Error(err.Error())
+		}
+	}
+	lenderKey, lender, err = getLenderRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if lender != nil {
+		fmt.Printf("Lender %s already registered\n", args[0])
+		return shim.Error("Lender already registered")
+	}
+
+	now, err = getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Write the state to the ledger
+	lenderBalKey, err = getLenderBalanceKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = putPrivateData(stub, accountBalancesCollection, lenderBalKey, []byte(strconv.Itoa(balance)))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	lender = &Lender{args[0], now.Format(time.RFC3339)}
+	lenderBytes, err = json.Marshal(lender)
+	if err != nil {
+		return shim.Error("Error marshaling lender structure")
+	}
+	err = stub.PutState(lenderKey, lenderBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Lender %s registered\n", args[0])
+
+	return shim.Success(nil)
+}
+
+// Post an accepted L/C for financing; lenders bid until the end of the deadline
+func (t *TradeWorkflowChaincode) postReceivable(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var lcKey, receivableKey string
+	var letterOfCreditBytes, exporterBytes []byte
+	var letterOfCredit *LetterOfCredit
+	var receivable *Receivable
+	var deadline, now time.Time
+	var err error
+
+	// Access control: Only an Exporter Org member can invoke this transaction
+	if !t.testMode && !authenticateExporterOrg(creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a member of Exporter Org. Access denied.")
+	}
+
+	if len(args) != 2 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 2: {Trade ID, Bid Deadline}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+
+	deadline, err = time.Parse(dateLayout, args[1])
+	if err != nil {
+		err = errors.New(fmt.Sprintf("Invalid bid deadline %s; expecting MM/DD/YYYY", args[1]))
+		return shim.Error(err.Error())
+	}
+	now, err = getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if !now.Before(deadline.AddDate(0, 0, 1)) {
+		return shim.Error("Bid deadline has already passed")
+	}
+
+	// Lookup L/C from the ledger
+	lcKey, err = getLCKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	letterOfCreditBytes, err = stub.GetState(lcKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(letterOfCreditBytes) == 0 {
+		err = errors.New(fmt.Sprintf("No L/C found for trade ID %s", args[0]))
+		return shim.Error(err.Error())
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(letterOfCreditBytes, &letterOfCredit)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Lookup L/C amount and transfers from the private terms
+	err = getLetterOfCreditTerms(stub, lcKey, letterOfCredit)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Only the part of an accepted L/C that the exporter retains can be financed, with no transfer in progress
+	if !(letterOfCredit.Status == ACCEPTED || letterOfCredit.Status == TRANSFER_ACCEPTED) {
+		fmt.Printf("L/C for trade %s is not accepted or has a transfer in progress; status is %s\n", args[0], letterOfCredit.Status)
+		return shim.Error("L/C not accepted or transfer in progress")
+	}
+	if getLCRetainedAmount(letterOfCredit) <= 0 {
+		fmt.Printf("L/C for trade %s doesn't have available amount for transfer\n", args[0])
+		return shim.Error("L/C no available amount for transfer")
+	}
+
+	receivableKey, receivable, err = getReceivableRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if receivable != nil {
+		fmt.Printf("L/C for trade %s already posted for financing\n", args[0])
+		return shim.Error("Receivable already posted")
+	}
+
+	// Lookup exporter
+	exporterBytes, err = stub.GetState(expKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	receivable = &Receivable{args[0], string(exporterBytes), args[1], OPEN, []string{}, "", now.Format(time.RFC3339), 0, ""}
+	err = putReceivableRecord(stub, receivableKey, receivable)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("L/C for trade %s posted for financing until %s\n", args[0], args[1])
+
+	return shim.Success(nil)
+}
+
+// Submit or revise a sealed bid to finance a posted L/C; only a salted hash of its rates is recorded until the lender reveals them
+func (t *TradeWorkflowChaincode) submitBid(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var receivableKey, financingBidKey, discountRateValue, advanceRateValue, salt, commitment string
+	var receivable *Receivable
+	var financingBid *FinancingBid
+	var discountRate, advanceRate float64
+	var deadline, now time.Time
+	var err error
+
+	if len(args) != 2 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 2: {Trade ID, Lender}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+
+	// Access control: Only a Lender Org member acting for the lender can invoke this transaction
+	if !t.testMode {
+		err = authorizeLender(stub, creatorOrg, creatorCertIssuer, args[1])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+	_, err = getLenderAccount(stub, args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Parse discount and advance rates (passed in the transient map)
+	discountRateValue, err = getTransientValue(stub, "discountRate")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	discountRate, err = strconv.ParseFloat(discountRateValue, 32)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	advanceRateValue, err = getTransientValue(stub, "advanceRate")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	advanceRate, err = strconv.ParseFloat(advanceRateValue, 32)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if discountRate < 0 || discountRate >= 1 || advanceRate <= 0 || advanceRate > 1 {
+		return shim.Error("Discount rate must be at least 0 and below 1, and advance rate above 0 and at most 1")
+	}
+	salt, err = getTransientValue(stub, "salt")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if len(salt) < bidSaltMinLength {
+		err = errors.New(fmt.Sprintf("Bid salt must be at least %d characters long", bidSaltMinLength))
+		return shim.Error(err.Error())
+	}
+	commitment, err = sealBidTerms(float32(discountRate), float32(advanceRate), salt)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	receivableKey, receivable, err = getReceivableRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if receivable == nil {
+		err = errors.New(fmt.Sprintf("L/C for trade %s not posted for financing", args[0]))
+		return shim.Error(err.Error())
+	}
+	if receivable.Status != OPEN {
+		fmt.Printf("Bidding for trade %s is closed; status is %s\n", args[0], receivable.Status)
+		return shim.Error("Bidding closed")
+	}
+
+	// The deadline is inclusive
+	deadline, err = time.Parse(dateLayout, receivable.BidDeadline)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	now, err = getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if !now.Before(deadline.AddDate(0, 0, 1)) {
+		fmt.Printf("Bidding for trade %s closed at the end of %s\n", args[0], receivable.BidDeadline)
+		return shim.Error("Bid deadline passed")
+	}
+
+	// A lender may revise its bid until the deadline
+	financingBidKey, financingBid, err = getFinancingBidRecord(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if financingBid == nil {
+		receivable.Bidders = append(receivable.Bidders, args[1])
+		err = putReceivableRecord(stub, receivableKey, receivable)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+	financingBid = &FinancingBid{args[0], args[1], SUBMITTED, now.Format(time.RFC3339), commitment, 0, 0, ""}
+	err = putFinancingBidRecord(stub, financingBidKey, financingBid)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Bid of lender %s for trade %s recorded\n", args[1], args[0])
+
+	return shim.Success(nil)
+}
+
+// Reveal the rates of a sealed bid once bidding has closed; they must match the bid's commitment
+// A bid not revealed by the time the exporter accepts one is rejected
+func (t *TradeWorkflowChaincode) revealBid(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var financingBidKey, discountRateValue, advanceRateValue, salt, commitment string
+	var receivable *Receivable
+	var financingBid *FinancingBid
+	var discountRate, advanceRate float64
+	var deadline, now time.Time
+	var err error
+
+	if len(args) != 2 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 2: {Trade ID, Lender}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+
+	// Access control: Only a Lender Org member acting for the lender can invoke this transaction
+	if !t.testMode {
+		err = authorizeLender(stub, creatorOrg, creatorCertIssuer, args[1])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	// Parse discount and advance rates, and the salt they were sealed with (passed in the transient map)
+	discountRateValue, err = getTransientValue(stub, "discountRate")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	discountRate, err = strconv.ParseFloat(discountRateValue, 32)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	advanceRateValue, err = getTransientValue(stub, "advanceRate")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	advanceRate, err = strconv.ParseFloat(advanceRateValue, 32)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	salt, err = getTransientValue(stub, "salt")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	_, receivable, err = getReceivableRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if receivable == nil {
+		err = errors.New(fmt.Sprintf("L/C for trade %s not posted for financing", args[0]))
+		return shim.Error(err.Error())
+	}
+	if receivable.Status != OPEN {
+		fmt.Printf("Bidding for trade %s is closed; status is %s\n", args[0], receivable.Status)
+		return shim.Error("Bid already accepted")
+	}
+
+	// Bids are revealed only after the deadline has passed
+	deadline, err = time.Parse(dateLayout, receivable.BidDeadline)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	now, err = getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if now.Before(deadline.AddDate(0, 0, 1)) {
+		fmt.Printf("Bidding for trade %s is open until the end of %s\n", args[0], receivable.BidDeadline)
+		return shim.Error("Bidding still open")
+	}
+
+	financingBidKey, financingBid, err = getFinancingBidRecord(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if financingBid == nil {
+		err = errors.New(fmt.Sprintf("No bid from lender %s for trade %s", args[1], args[0]))
+		return shim.Error(err.Error())
+	}
+	if financingBid.Status != SUBMITTED {
+		fmt.Printf("Bid of lender %s for trade %s already revealed\n", args[1], args[0])
+		return shim.Error("Bid already revealed")
+	}
+	commitment, err = sealBidTerms(float32(discountRate), float32(advanceRate), salt)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if commitment != financingBid.Commitment {
+		fmt.Printf("Revealed rates of lender %s for trade %s don't match its bid\n", args[1], args[0])
+		return shim.Error("Revealed rates don't match the sealed bid")
+	}
+
+	// Update ledger state
+	financingBid.Status = REVEALED
+	financingBid.DiscountRate = float32(discountRate)
+	financingBid.AdvanceRate = float32(advanceRate)
+	err = putBidTerms(stub, financingBidKey, financingBid)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = putFinancingBidRecord(stub, financingBidKey, financingBid)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Bid of lender %s for trade %s revealed\n", args[1], args[0])
+
+	return shim.Success(nil)
+}
+
+// Select the winning bid once bidding has closed; the L/C transfer to the lender is requested on its terms
+func (t *TradeWorkflowChaincode) acceptBid(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var receivableKey, financingBidKey, lcKey, paymentKey, shipmentLocationKey string
+	var letterOfCreditBytes, paymentBytes, shipmentLocationBytes []byte
+	var receivable *Receivable
+	var financingBid, winningBid *FinancingBid
+	var letterOfCredit *LetterOfCredit
+	var deadline, now time.Time
+	var err error
+
+	// Access control: Only an Exporter Org member can invoke this transaction
+	if !t.testMode && !authenticateExporterOrg(creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a member of Exporter Org. Access denied.")
+	}
+
+	if len(args) != 2 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 2: {Trade ID, Lender}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+
+	receivableKey, receivable, err = getReceivableRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if receivable == nil {
+		err = errors.New(fmt.Sprintf("L/C for trade %s not posted for financing", args[0]))
+		return shim.Error(err.Error())
+	}
+	if receivable.Status != OPEN {
+		fmt.Printf("Bidding for trade %s is closed; status is %s\n", args[0], receivable.Status)
+		return shim.Error("Bid already accepted")
+	}
+
+	// Bids stay sealed until the deadline has passed, and only a revealed bid can win
+	deadline, err = time.Parse(dateLayout, receivable.BidDeadline)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	now, err = getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if now.Before(deadline.AddDate(0, 0, 1)) {
+		fmt.Printf("Bidding for trade %s is open until the end of %s\n", args[0], receivable.BidDeadline)
+		return shim.Error("Bidding still open")
+	}
+
+	_, winningBid, err = getFinancingBidRecord(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if winningBid == nil {
+		err = errors.New(fmt.Sprintf("No bid from lender %s for trade %s", args[1], args[0]))
+		return shim.Error(err.Error())
+	}
+	if winningBid.Status != REVEALED {
+		fmt.Printf("Bid of lender %s for trade %s has not been revealed\n", args[1], args[0])
+		return shim.Error("Bid not revealed")
+	}
+
+	// Lookup L/C from the ledger
+	lcKey, err = getLCKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	letterOfCreditBytes, err = stub.GetState(lcKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(letterOfCreditBytes, &letterOfCredit)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Lookup L/C amount and transfers from the private terms
+	err = getLetterOfCreditTerms(stub, lcKey, letterOfCredit)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Check if there's a pending payment request
+	paymentKey, err = getPaymentKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	paymentBytes, err = stub.GetState(paymentKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(paymentBytes) != 0 {
+		fmt.Printf("Payment request pending for trade %s\n", args[0])
+		return shim.Error("Payment request pending")
+	}
+
+	// Lookup shipment location from the ledger
+	shipmentLocationKey, err = getShipmentLocationKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	shipmentLocationBytes, err = stub.GetState(shipmentLocationKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(shipmentLocationBytes) == 0 {
+		fmt.Printf("Shipment for trade %s has not been prepared yet\n", args[0])
+		return shim.Error("Shipment not prepared yet")
+	}
+
+	// Check if there is available amount for transfer
+	if getLCRetainedAmount(letterOfCredit) <= 0 {
+		fmt.Printf("L/C for trade %s doesn't have available amount for transfer\n", args[0])
+		return shim.Error("L/C no available amount for transfer")
+	}
+	if !(letterOfCredit.Status == ACCEPTED || letterOfCredit.Status == TRANSFER_ACCEPTED) {
+		fmt.Printf("L/C for trade %s is not accepted or has a transfer in progress; status is %s\n", args[0], letterOfCredit.Status)
+		return shim.Error("L/C not accepted or transfer in progress")
+	}
+	if getLCTransfer(letterOfCredit, winningBid.Lender) != nil {
+		err = errors.New(fmt.Sprintf("L/C for trade %s already transferred to %s", args[0], winningBid.Lender))
+		return shim.Error(err.Error())
+	}
+
+	// Request the transfer of the part of the L/C the exporter retains to the winning lender, on its bid's discount rate
+	letterOfCredit.Transfers = append(letterOfCredit.Transfers, LCTransfer{winningBid.Lender, REQUESTED, getLCRetainedAmount(letterOfCredit), winningBid.DiscountRate, 0, "", "", false})
+	letterOfCredit.Status = TRANSFER_REQUESTED
+	err = putLetterOfCreditTerms(stub, lcKey, letterOfCredit)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	letterOfCreditBytes, err = json.Marshal(letterOfCredit)
+	if err != nil {
+		return shim.Error("Error marshaling L/C structure")
+	}
+	err = stub.PutState(lcKey, letterOfCreditBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Update ledger state
+	for _, bidder := range receivable.Bidders {
+		financingBidKey, financingBid, err = getFinancingBidRecord(stub, args[0], bidder)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if bidder == winningBid.Lender {
+			financingBid.Status = ACCEPTED
+		} else {
+			financingBid.Status = REJECTED
+		}
+		err = putFinancingBidRecord(stub, financingBidKey, financingBid)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+	receivable.Status = AWARDED
+	receivable.WinningBidder = winningBid.Lender
+	err = putReceivableRecord(stub, receivableKey, receivable)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Bid of lender %s for trade %s accepted; L/C transfer requested\n", args[1], args[0])
+
+	return shim.Success(nil)
+}
+
+// Exercise a lender's recourse to the exporter for the shortfall on its financing, once the L/C has expired unpaid
+// A reserve the lender still holds back from its advance is kept against the shortfall, and any excess released to the exporter
+func (t *TradeWorkflowChaincode) exerciseRecourse(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var financingPositionKey, receivableKey, lenderBalKey, lender string
+	var lenderBytes, exporterBytes []byte
+	var financingPosition *FinancingPosition
+	var receivable *Receivable
+	var dispute *Dispute
+	var paymentRecords []PaymentRecord
+	var trueUpRecord *PaymentRecord
+	var balances map[string]int
+	var expiration, now time.Time
+	var reserve, recourse int
+	var cancelled bool
+	var err error
+
+	// Access control: Only a Lender Org member can invoke this transaction
+	if !t.testMode && !authenticateLenderOrg(creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a member of Lender Org. Access denied.")
+	}
+
+	if len(args) != 1 && len(args) != 2 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 1: {Trade ID}, or 2: {Trade ID, Lender}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+
+	// The lender is the one configured at Init, unless a registered lender is named
+	if len(args) == 2 {
+		lender = args[1]
+	} else {
+		lenderBytes, err = stub.GetState(lenKey)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		lender = string(lenderBytes)
+	}
+	lenderBalKey, err = getLenderAccount(stub, lender)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Access control: Only a Lender Org member acting for the lender can invoke this transaction
+	if !t.testMode {
+		err = authorizeLender(stub, creatorOrg, creatorCertIssuer, lender)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	financingPositionKey, financingPosition, err = getFinancingPositionRecord(stub, args[0], lender)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if financingPosition == nil {
+		err = errors.New(fmt.Sprintf("No financing position of lender %s found for trade ID %s", lender, args[0]))
+		return shim.Error(err.Error())
+	}
+	if financingPosition.Status != OPEN {
+		fmt.Printf("Financing position of lender %s for trade %s is closed; status is %s\n", lender, args[0], financingPosition.Status)
+		return shim.Error("Financing position not open")
+	}
+
+	// The L/C is payable until the end of its expiration date, unless the trade has been cancelled
+	expiration, err = time.Parse(dateLayout, financingPosition.ExpirationDate)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	now, err = getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	cancelled, err = isTradeCancelled(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if !cancelled && now.Before(expiration.AddDate(0, 0, 1)) {
+		fmt.Printf("L/C for trade %s is payable until the end of %s\n", args[0], financingPosition.ExpirationDate)
+		return shim.Error("L/C not expired yet")
+	}
+
+	// An open dispute may yet settle the trade
+	_, dispute, err = getCurrentDisputeRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if dispute != nil && dispute.Status == OPEN {
+		err = errors.New(fmt.Sprintf("Payments on trade %s are frozen while dispute %s is open", args[0], dispute.Id))
+		return shim.Error(err.Error())
+	}
+
+	// The lender keeps the reserve it still holds back against the shortfall
+	receivableKey, receivable, err = getReceivableRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if receivable != nil && receivable.Status == AWARDED && receivable.WinningBidder == lender && receivable.Reserve > 0 {
+		reserve = receivable.Reserve
+		receivable.Reserve = 0
+		err = putReceivableRecord(stub, receivableKey, receivable)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	// Debit the exporter for the rest of the shortfall, or release what is left of the reserve to it
+	exporterBytes, err = stub.GetState(expKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	balances = map[string]int{}
+	recourse = financingPosition.Shortfall - reserve
+	if recourse > 0 {
+		err = moveAccountFunds(stub, balances, expBalKey, lenderBalKey, recourse)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		err = monitorPayment(stub, "exerciseRecourse", args[0], string(exporterBytes), lender, recourse)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		paymentRecords = append(paymentRecords, PaymentRecord{"", args[0], "", RECOURSE, "exerciseRecourse", string(exporterBytes), lender, recourse, 0, 0, "", "", ""})
+	} else if recourse < 0 {
+		err = moveAccountFunds(stub, balances, lenderBalKey, expBalKey, -recourse)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		paymentRecords = append(paymentRecords, PaymentRecord{"", args[0], "", RESERVE_RELEASE, "exerciseRecourse", lender, string(exporterBytes), -recourse, 0, 0, "", "", ""})
+		financingPosition.Advanced -= recourse
+	}
+
+	// Interest accrues on the shortfall until recourse, and is trued up with it
+	err = accrueFinancingInterest(financingPosition, financingPosition.Shortfall, now)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	trueUpRecord, err = trueUpFinancingInterest(stub, financingPosition, "exerciseRecourse", balances)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if trueUpRecord != nil {
+		paymentRecords = append(paymentRecords, *trueUpRecord)
+	}
+	_, err = recordPayments(stub, args[0], paymentRecords)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = putAccountBalances(stub, balances)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	financingPosition.Status = RECOURSED
+	financingPosition.ClosedAt = now.Format(time.RFC3339)
+	err = putFinancingPositionRecord(stub, financingPositionKey, financingPosition)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Lender %s exercised recourse for a shortfall of %d on trade %s\n", lender, financingPosition.Shortfall, args[0])
+
+	return shim.Success(nil)
+}
+
+// Get a registered lender
+func (t *TradeWorkflowChaincode) getLender(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var lenderKey, jsonResp string
+	var lenderBytes []byte
+	var err error
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1: <lender>")
+	}
+
+	// Get the state from the ledger
+	lenderKey, err = getLenderKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	lenderBytes, err = stub.GetState(lenderKey)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Failed to get state for " + lenderKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+
+	if len(lenderBytes) == 0 {
+		jsonResp = "{\"Error\":\"No record found for " + lenderKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+	fmt.Printf("Query Response:%s\n", string(lenderBytes))
+	return shim.Success(lenderBytes)
+}
+
+// Get the account balance of a lender, for the lender itself
+func (t *TradeWorkflowChaincode) getLenderBalance(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var lenderBalKey, jsonResp string
+	var balanceBytes []byte
+	var err error
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1: <lender>")
+	}
+
+	// Access control: Only a Lender Org member acting for the lender can invoke this transaction
+	if !t.testMode {
+		err = authorizeLender(stub, creatorOrg, creatorCertIssuer, args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	lenderBalKey, err = getLenderAccount(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Get the account balance from the ledger
+	balanceBytes, err = getPrivateData(stub, accountBalancesCollection, lenderBalKey)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Failed to get state for " + lenderBalKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+
+	if len(balanceBytes) == 0 {
+		jsonResp = "{\"Error\":\"No record found for " + lenderBalKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+	jsonResp = "{\"Balance\":\"" + string(balanceBytes) + "\"}"
+	fmt.Printf("Query Response:%s\n", jsonResp)
+	return shim.Success([]byte(jsonResp))
+}
+
+// Get the financing posted for a trade's L/C
+func (t *TradeWorkflowChaincode) getReceivable(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var receivableKey, jsonResp string
+	var receivableBytes []byte
+	var err error
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1: <trade ID>")
+	}
+
+	// Access control: Only lenders, the trade's participants, or a regulator for its jurisdiction, can invoke this transaction
+	if !t.testMode && !authenticateLenderOrg(creatorOrg, creatorCertIssuer) {
+		err = authorizeTradeAccess(stub, creatorOrg, creatorCertIssuer, args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	// Get the state from the ledger
+	receivableKey, err = getReceivableKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	receivableBytes, err = stub.GetState(receivableKey)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Failed to get state for " + receivableKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+
+	if len(receivableBytes) == 0 {
+		jsonResp = "{\"Error\":\"No record found for " + receivableKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+	fmt.Printf("Query Response:%s\n", string(receivableBytes))
+	return shim.Success(receivableBytes)
+}
+
+// Get a lender's bid for the exporter once bidding has closed, with its rates if the lender has revealed them
+func (t *TradeWorkflowChaincode) getBid(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var financingBidKey, jsonResp string
+	var termsBytes, financingBidBytes []byte
+	var receivable *Receivable
+	var financingBid map[string]interface{}
+	var bidTerms BidTerms
+	var deadline, now time.Time
+	var err error
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2: <trade ID, lender>")
+	}
+
+	// Access control: Only an Exporter Org member can invoke this transaction
+	if !t.testMode && !authenticateExporterOrg(creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a member of Exporter Org. Access denied.")
+	}
+
+	_, receivable, err = getReceivableRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if receivable == nil {
+		err = errors.New(fmt.Sprintf("L/C for trade %s not posted for financing", args[0]))
+		return shim.Error(err.Error())
+	}
+
+	// Bids stay sealed until the deadline has passed
+	deadline, err = time.Parse(dateLayout, receivable.BidDeadline)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	now, err = getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if now.Before(deadline.AddDate(0, 0, 1)) {
+		fmt.Printf("Bidding for trade %s is open until the end of %s\n", args[0], receivable.BidDeadline)
+		return shim.Error("Bidding still open")
+	}
+
+	// Get the state from the ledger
+	financingBidKey, err = getFinancingBidKey(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	financingBidBytes, err = stub.GetState(financingBidKey)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Failed to get state for " + financingBidKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+
+	if len(financingBidBytes) == 0 {
+		jsonResp = "{\"Error\":\"No record found for " + financingBidKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(financingBidBytes, &financingBid)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Add the private terms of a revealed bid
+	if financingBid["termsHash"] != nil {
+		termsBytes, err = getPrivateData(stub, financingBidsCollection, financingBidKey)
+		if err != nil {
+			jsonResp = "{\"Error\":\"Failed to get private terms for " + financingBidKey + "\"}"
+			return shim.Error(jsonResp)
+		}
+		err = json.Unmarshal(termsBytes, &bidTerms)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		financingBid["discountRate"] = bidTerms.DiscountRate
+		financingBid["advanceRate"] = bidTerms.AdvanceRate
+
+		financingBidBytes, err = json.Marshal(financingBid)
+		if err != nil {
+			return shim.Error("Error marshaling financing bid")
+		}
+	}
+	fmt.Printf("Query Response:%s\n", string(financingBidBytes))
+	return shim.Success(financingBidBytes)
+}
+
+// Get a lender's financing position on a trade, with its amounts and interest, for the exporter or the lender itself
+func (t *TradeWorkflowChaincode) getFinancingPosition(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var financingPositionKey, jsonResp string
+	var termsBytes, financingPositionBytes []byte
+	var financingPosition map[string]interface{}
+	var financingPositionTerms FinancingPositionTerms
+	var err error
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2: <trade ID, lender>")
+	}
+
+	// Access control: Only an Exporter Org member, or a Lender Org member acting for the lender, can invoke this transaction
+	if !t.testMode && !authenticateExporterOrg(creatorOrg, creatorCertIssuer) {
+		err = authorizeLender(stub, creatorOrg, creatorCertIssuer, args[1])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	// Get the state and the private terms from the ledger
+	financingPositionKey, err = getFinancingPositionKey(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	financingPositionBytes, err = stub.GetState(financingPositionKey)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Failed to get state for " + financingPositionKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+
+	if len(financingPositionBytes) == 0 {
+		jsonResp = "{\"Error\":\"No record found for " + financingPositionKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+	termsBytes, err = getPrivateData(stub, tradeTermsCollection, financingPositionKey)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Failed to get private terms for " + financingPositionKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(financingPositionBytes, &financingPosition)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = json.Unmarshal(termsBytes, &financingPositionTerms)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	financingPosition["advanced"] = financingPositionTerms.Advanced
+	financingPosition["expected"] = financingPositionTerms.Expected
+	financingPosition["collected"] = financingPositionTerms.Collected
+	financingPosition["shortfall"] = financingPositionTerms.Shortfall
+	if financingPositionTerms.AnnualRate > 0 {
+		financingPosition["annualRate"] = financingPositionTerms.AnnualRate
+		financingPosition["interest"] = financingPositionTerms.Interest
+		financingPosition["accrued"] = financingPositionTerms.Accrued
+		financingPosition["trueUp"] = financingPositionTerms.TrueUp
+	}
+
+	financingPositionBytes, err = json.Marshal(financingPosition)
+	if err != nil {
+		return shim.Error("Error marshaling financing position")
+	}
+	fmt.Printf("Query Response:%s\n", string(financingPositionBytes))
+	return shim.Success(financingPositionBytes)
+}
@@ -0,0 +1,677 @@
+/*
+ * Copyright 2018 IBM All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the 'License');
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an 'AS IS' BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// Returns nil if no policy has been issued for the trade; the policy's amounts are read from its private terms
+func getInsurancePolicyRecord(stub shim.ChaincodeStubInterface, tradeID string) (string, *InsurancePolicy, error) {
+	var insurancePolicyKey string
+	var insurancePolicyBytes []byte
+	var insurancePolicy *InsurancePolicy
+	var insurancePolicyTerms InsurancePolicyTerms
+	var err error
+
+	// Lookup insurance policy from the ledger
+	insurancePolicyKey, err = getInsurancePolicyKey(stub, tradeID)
+	if err != nil {
+		return "", nil, err
+	}
+	insurancePolicyBytes, err = stub.GetState(insurancePolicyKey)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(insurancePolicyBytes) == 0 {
+		return insurancePolicyKey, nil, nil
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(insurancePolicyBytes, &insurancePolicy)
+	if err != nil {
+		return "", nil, err
+	}
+	err = getPrivateTerms(stub, insurancePolicyKey, &insurancePolicyTerms)
+	if err != nil {
+		return "", nil, err
+	}
+	insurancePolicy.InsuredValue = insurancePolicyTerms.InsuredValue
+	insurancePolicy.Premium = insurancePolicyTerms.Premium
+	insurancePolicy.PaidOut = insurancePolicyTerms.PaidOut
+	return insurancePolicyKey, insurancePolicy, nil
+}
+
+// Record the policy's amounts privately, then the policy itself with the hash of its terms
+func putInsurancePolicyRecord(stub shim.ChaincodeStubInterface, insurancePolicyKey string, insurancePolicy *InsurancePolicy) error {
+	var insurancePolicyBytes []byte
+	var err error
+
+	insurancePolicy.TermsHash, err = putPrivateTerms(stub, insurancePolicyKey, &InsurancePolicyTerms{insurancePolicy.InsuredValue, insurancePolicy.Premium, insurancePolicy.PaidOut})
+	if err != nil {
+		return err
+	}
+	insurancePolicyBytes, err = json.Marshal(insurancePolicy)
+	if err != nil {
+		return errors.New("Error marshaling insurance policy structure")
+	}
+	// Write the state to the ledger
+	return stub.PutState(insurancePolicyKey, insurancePolicyBytes)
+}
+
+// Returns nil if no such claim has been filed; the claim's amounts are read from its private terms
+func getInsuranceClaimRecord(stub shim.ChaincodeStubInterface, tradeID string, claimID string) (string, *InsuranceClaim, error) {
+	var insuranceClaimKey string
+	var insuranceClaimBytes []byte
+	var insuranceClaim *InsuranceClaim
+	var insuranceClaimTerms InsuranceClaimTerms
+	var err error
+
+	// Lookup insurance claim from the ledger
+	insuranceClaimKey, err = getInsuranceClaimKey(stub, tradeID, claimID)
+	if err != nil {
+		return "", nil, err
+	}
+	insuranceClaimBytes, err = stub.GetState(insuranceClaimKey)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(insuranceClaimBytes) == 0 {
+		return insuranceClaimKey, nil, nil
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(insuranceClaimBytes, &insuranceClaim)
+	if err != nil {
+		return "", nil, err
+	}
+	err = getPrivateTerms(stub, insuranceClaimKey, &insuranceClaimTerms)
+	if err != nil {
+		return "", nil, err
+	}
+	insuranceClaim.Amount = insuranceClaimTerms.Amount
+	insuranceClaim.Payout = insuranceClaimTerms.Payout
+	return insuranceClaimKey, insuranceClaim, nil
+}
+
+// Record the claim's amounts privately, then the claim itself with the hash of its terms
+func putInsuranceClaimRecord(stub shim.ChaincodeStubInterface, insuranceClaimKey string, insuranceClaim *InsuranceClaim) error {
+	var insuranceClaimBytes []byte
+	var err error
+
+	insuranceClaim.TermsHash, err = putPrivateTerms(stub, insuranceClaimKey, &InsuranceClaimTerms{insuranceClaim.Amount, insuranceClaim.Payout})
+	if err != nil {
+		return err
+	}
+	insuranceClaimBytes, err = json.Marshal(insuranceClaim)
+	if err != nil {
+		return errors.New("Error marshaling insurance claim structure")
+	}
+	// Write the state to the ledger
+	return stub.PutState(insuranceClaimKey, insuranceClaimBytes)
+}
+
+// The account and name of the buyer (importer) or the seller (exporter)
+func getTradePartyAccount(stub shim.ChaincodeStubInterface, role string) (string, string, error) {
+	var nameBytes []byte
+	var err error
+
+	if role == BUYER {
+		nameBytes, err = stub.GetState(impKey)
+		return impBalKey, string(nameBytes), err
+	} else if role == SELLER {
+		nameBytes, err = stub.GetState(expKey)
+		return expBalKey, string(nameBytes), err
+	}
+	return "", "", errors.New(fmt.Sprintf("Invalid party %s; Permissible values: {BUYER, SELLER}", role))
+}
+
+// Incidents are referenced by their position in the shipment's list of incidents
+func getClaimIncidents(stub shim.ChaincodeStubInterface, tradeID string, incidentsJSON string) ([]ShipmentIncident, error) {
+	var shipment *Shipment
+	var positions []int
+	var incidents []ShipmentIncident
+	var claimed map[int]bool
+	var err error
+
+	err = json.Unmarshal([]byte(incidentsJSON), &positions)
+	if err != nil {
+		return nil, errors.New("Incidents must be a JSON array of positions in the shipment's list of incidents")
+	}
+	if len(positions) == 0 {
+		return nil, errors.New("A claim must reference at least one shipment incident")
+	}
+
+	_, shipment, err = getShipmentRecord(stub, tradeID)
+	if err != nil {
+		return nil, err
+	}
+	if shipment == nil {
+		shipment = &Shipment{}
+	}
+
+	claimed = make(map[int]bool)
+	for _, position := range positions {
+		if position < 0 || position >= len(shipment.Incidents) {
+			return nil, errors.New(fmt.Sprintf("No incident %d recorded on the shipment for trade %s", position, tradeID))
+		}
+		if claimed[position] {
+			return nil, errors.New(fmt.Sprintf("Incident %d referenced more than once", position))
+		}
+		claimed[position] = true
+		incidents = append(incidents, shipment.Incidents[position])
+	}
+	return incidents, nil
+}
+
+// Issue a cargo insurance policy on a trade's shipment, and collect the premium
+func (t *TradeWorkflowChaincode) issueInsurancePolicy(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var tradeKey, shipmentLocationKey, insurancePolicyKey, coverage, payerBalKey, payer, insuredValueStr, premiumStr string
+	var tradeAgreementBytes, shipmentLocationBytes, insurerBytes []byte
+	var tradeAgreement *TradeAgreement
+	var insurancePolicy *InsurancePolicy
+	var insuredValue, premium int
+	var balances map[string]int
+	var expiration, now time.Time
+	var err error
+
+	// Access control: Only the insurer can invoke this transaction
+	if !t.testMode && !authenticateInsurer(stub, creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not an insurer of Lender Org. Access denied.")
+	}
+
+	if len(args) != 5 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 5: {Trade ID, Policy ID, Coverage, Premium Payer, Expiration Date}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+
+	// The insured value and premium are private terms, passed in the transient map
+	insuredValueStr, err = getTransientValue(stub, "insuredValue")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	insuredValue, err = strconv.Atoi(insuredValueStr)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	premiumStr, err = getTransientValue(stub, "premium")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	premium, err = strconv.Atoi(premiumStr)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if insuredValue <= 0 || premium <= 0 || premium >= insuredValue {
+		return shim.Error("Insured value and premium must be positive, and the premium less than the insured value")
+	}
+	coverage = strings.ToUpper(args[2])
+	if coverage != ICC_A && coverage != ICC_B && coverage != ICC_C {
+		err = errors.New(fmt.Sprintf("Invalid coverage %s; Permissible values: {ICC_A, ICC_B, ICC_C}", args[2]))
+		return shim.Error(err.Error())
+	}
+	payerBalKey, payer, err = getTradePartyAccount(stub, strings.ToUpper(args[3]))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	expiration, err = time.Parse(dateLayout, args[4])
+	if err != nil {
+		err = errors.New(fmt.Sprintf("Invalid expiration date %s; expecting MM/DD/YYYY", args[4]))
+		return shim.Error(err.Error())
+	}
+	now, err = getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if expiration.Before(now.Truncate(hoursPerDay * time.Hour)) {
+		return shim.Error("Policy would already have expired")
+	}
+
+	// Lookup trade agreement from the ledger
+	tradeKey, err = getTradeKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	tradeAgreementBytes, err = stub.GetState(tradeKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(tradeAgreementBytes) == 0 {
+		err = errors.New(fmt.Sprintf("No record found for trade ID %s", args[0]))
+		return shim.Error(err.Error())
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(tradeAgreementBytes, &tradeAgreement)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if tradeAgreement.Status != ACCEPTED {
+		fmt.Printf("Trade %s has not been accepted\n", args[0])
+		return shim.Error("Trade not accepted")
+	}
+
+	// Cover must be in place before the goods arrive
+	shipmentLocationKey, err = getShipmentLocationKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	shipmentLocationBytes, err = stub.GetState(shipmentLocationKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if string(shipmentLocationBytes) == DESTINATION {
+		fmt.Printf("Shipment for trade %s has already arrived\n", args[0])
+		return shim.Error("Shipment already arrived")
+	}
+
+	insurancePolicyKey, insurancePolicy, err = getInsurancePolicyRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if insurancePolicy != nil {
+		fmt.Printf("Trade %s is already insured under policy %s\n", args[0], insurancePolicy.Id)
+		return shim.Error("Insurance policy already issued")
+	}
+
+	// Lookup insurer
+	insurerBytes, err = stub.GetState(insKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if len(insurerBytes) == 0 {
+		return shim.Error("No Insurer recorded; the chaincode must be initialized with one")
+	}
+
+	// Collect the premium
+	balances = map[string]int{}
+	err = moveAccountFunds(stub, balances, payerBalKey, insBalKey, premium)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = putAccountBalances(stub, balances)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	_, err = recordPayment(stub, args[0], "", PREMIUM, "issueInsurancePolicy", payer, string(insurerBytes), premium, 0, "")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	insurancePolicy = &InsurancePolicy{args[1], args[0], string(insurerBytes), insuredValue, coverage, premium, strings.ToUpper(args[3]), args[4], ISSUED, []string{}, 0, ""}
+	err = putInsurancePolicyRecord(stub, insurancePolicyKey, insurancePolicy)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Insurance policy %s for trade %s recorded\n", args[1], args[0])
+
+	return shim.Success(nil)
+}
+
+// Claim for loss or damage, referencing the incidents recorded on the shipment
+func (t *TradeWorkflowChaincode) fileClaim(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var insurancePolicyKey, insuranceClaimKey, claimantRole, claimantName, amountStr string
+	var insurancePolicy *InsurancePolicy
+	var insuranceClaim, otherClaim *InsuranceClaim
+	var incidents []ShipmentIncident
+	var amount int
+	var expiration, now time.Time
+	var err error
+
+	if len(args) != 5 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 5: {Trade ID, Claim ID, Claimant, Incidents, Description}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+
+	// Access control: Only the buyer (an Importer Org member) or the seller (an Exporter Org member) can claim, each on their own behalf
+	claimantRole = strings.ToUpper(args[2])
+	if !t.testMode && !((claimantRole == BUYER && authenticateImporterOrg(creatorOrg, creatorCertIssuer)) || (claimantRole == SELLER && authenticateExporterOrg(creatorOrg, creatorCertIssuer))) {
+		return shim.Error("Caller not a member of the claimant's Org. Access denied.")
+	}
+	_, claimantName, err = getTradePartyAccount(stub, claimantRole)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// The amount claimed is a private term, passed in the transient map
+	amountStr, err = getTransientValue(stub, "amount")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	amount, err = strconv.Atoi(amountStr)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if args[4] == "" {
+		return shim.Error("Description must be non-empty")
+	}
+
+	insurancePolicyKey, insurancePolicy, err = getInsurancePolicyRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if insurancePolicy == nil {
+		err = errors.New(fmt.Sprintf("No insurance policy found for trade ID %s", args[0]))
+		return shim.Error(err.Error())
+	}
+
+	// Claims must be filed while the policy is in force
+	expiration, err = time.Parse(dateLayout, insurancePolicy.ExpirationDate)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	now, err = getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if !now.Before(expiration.AddDate(0, 0, 1)) {
+		fmt.Printf("Insurance policy %s for trade %s expired on %s\n", insurancePolicy.Id, args[0], insurancePolicy.ExpirationDate)
+		return shim.Error("Insurance policy expired")
+	}
+	if amount <= 0 || amount > insurancePolicy.InsuredValue - insurancePolicy.PaidOut {
+		err = errors.New(fmt.Sprintf("Claim amount must be positive and not exceed the remaining insured value %d", insurancePolicy.InsuredValue - insurancePolicy.PaidOut))
+		return shim.Error(err.Error())
+	}
+
+	insuranceClaimKey, insuranceClaim, err = getInsuranceClaimRecord(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if insuranceClaim != nil {
+		fmt.Printf("Claim %s for trade %s already filed\n", args[1], args[0])
+		return shim.Error("Claim already filed")
+	}
+
+	incidents, err = getClaimIncidents(stub, args[0], args[3])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// An incident can be claimed for only once, unless the claim was rejected
+	for _, claimID := range insurancePolicy.Claims {
+		_, otherClaim, err = getInsuranceClaimRecord(stub, args[0], claimID)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if otherClaim.Status == REJECTED {
+			continue
+		}
+		for _, claimed := range otherClaim.Incidents {
+			for _, incident := range incidents {
+				if claimed == incident {
+					err = errors.New(fmt.Sprintf("Incident at %s already claimed under claim %s", incident.Timestamp, claimID))
+					return shim.Error(err.Error())
+				}
+			}
+		}
+	}
+
+	insuranceClaim = &InsuranceClaim{args[1], args[0], insurancePolicy.Id, claimantName, claimantRole, amount, incidents, args[4], FILED, 0, "", ""}
+	err = putInsuranceClaimRecord(stub, insuranceClaimKey, insuranceClaim)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	insurancePolicy.Claims = append(insurancePolicy.Claims, args[1])
+	err = putInsurancePolicyRecord(stub, insurancePolicyKey, insurancePolicy)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Claim %s for trade %s recorded\n", args[1], args[0])
+
+	return shim.Success(nil)
+}
+
+// Approve a claim, in full or in part, or reject it with a reason
+func (t *TradeWorkflowChaincode) adjudicateClaim(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var insuranceClaimKey, decision, payoutStr string
+	var insurancePolicy *InsurancePolicy
+	var insuranceClaim *InsuranceClaim
+	var payout int
+	var err error
+
+	// Access control: Only the insurer can invoke this transaction
+	if !t.testMode && !authenticateInsurer(stub, creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not an insurer of Lender Org. Access denied.")
+	}
+
+	if len(args) != 3 && len(args) != 4 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 3: {Trade ID, Claim ID, APPROVED}, or 4: {Trade ID, Claim ID, REJECTED, Reason}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+
+	insuranceClaimKey, insuranceClaim, err = getInsuranceClaimRecord(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if insuranceClaim == nil {
+		err = errors.New(fmt.Sprintf("No claim %s found for trade ID %s", args[1], args[0]))
+		return shim.Error(err.Error())
+	}
+	if insuranceClaim.Status != FILED {
+		fmt.Printf("Claim %s for trade %s already adjudicated\n", args[1], args[0])
+		return shim.Error("Claim already adjudicated")
+	}
+
+	decision = strings.ToUpper(args[2])
+	if decision == APPROVED && len(args) == 3 {
+		// The payout is a private term, passed in the transient map
+		payoutStr, err = getTransientValue(stub, "payout")
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		payout, err = strconv.Atoi(payoutStr)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		_, insurancePolicy, err = getInsurancePolicyRecord(stub, args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if payout <= 0 || payout > insuranceClaim.Amount || payout > insurancePolicy.InsuredValue - insurancePolicy.PaidOut {
+			err = errors.New(fmt.Sprintf("Payout must be positive, and not exceed the amount claimed %d or the remaining insured value %d", insuranceClaim.Amount, insurancePolicy.InsuredValue - insurancePolicy.PaidOut))
+			return shim.Error(err.Error())
+		}
+		insuranceClaim.Payout = payout
+	} else if decision == REJECTED && len(args) == 4 {
+		if args[3] == "" {
+			return shim.Error("Reason must be non-empty")
+		}
+		insuranceClaim.Reason = args[3]
+	} else {
+		err = errors.New(fmt.Sprintf("Invalid decision %s; Permissible values: {APPROVED} with the payout in the transient map, or {REJECTED, Reason}", strings.Join(args[2:], ", ")))
+		return shim.Error(err.Error())
+	}
+
+	insuranceClaim.Status = decision
+	err = putInsuranceClaimRecord(stub, insuranceClaimKey, insuranceClaim)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Claim %s for trade %s %s\n", args[1], args[0], strings.ToLower(decision))
+
+	return shim.Success(nil)
+}
+
+// Pay an approved claim from the Insurer's account to the claimant's
+func (t *TradeWorkflowChaincode) payClaim(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var insurancePolicyKey, insuranceClaimKey, claimantBalKey string
+	var insurancePolicy *InsurancePolicy
+	var balances map[string]int
+	var insuranceClaim *InsuranceClaim
+	var err error
+
+	// Access control: Only the insurer can invoke this transaction
+	if !t.testMode && !authenticateInsurer(stub, creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not an insurer of Lender Org. Access denied.")
+	}
+
+	if len(args) != 2 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 2: {Trade ID, Claim ID}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+
+	insuranceClaimKey, insuranceClaim, err = getInsuranceClaimRecord(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if insuranceClaim == nil {
+		err = errors.New(fmt.Sprintf("No claim %s found for trade ID %s", args[1], args[0]))
+		return shim.Error(err.Error())
+	}
+	if insuranceClaim.Status != APPROVED {
+		fmt.Printf("Claim %s for trade %s is not awaiting payment; status is %s\n", args[1], args[0], insuranceClaim.Status)
+		return shim.Error("Claim not approved or already paid")
+	}
+
+	// Claims approved earlier may have used up the insured value
+	insurancePolicyKey, insurancePolicy, err = getInsurancePolicyRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if insuranceClaim.Payout > insurancePolicy.InsuredValue - insurancePolicy.PaidOut {
+		fmt.Printf("Payout %d exceeds the remaining insured value %d of policy %s\n", insuranceClaim.Payout, insurancePolicy.InsuredValue - insurancePolicy.PaidOut, insurancePolicy.Id)
+		return shim.Error("Payout exceeds the remaining insured value")
+	}
+
+	claimantBalKey, _, err = getTradePartyAccount(stub, insuranceClaim.ClaimantRole)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	balances = map[string]int{}
+	err = moveAccountFunds(stub, balances, insBalKey, claimantBalKey, insuranceClaim.Payout)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = putAccountBalances(stub, balances)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	_, err = recordPayment(stub, args[0], "", CLAIM, "payClaim", insurancePolicy.Insurer, insuranceClaim.Claimant, insuranceClaim.Payout, 0, "")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Update ledger state
+	insuranceClaim.Status = PAID
+	err = putInsuranceClaimRecord(stub, insuranceClaimKey, insuranceClaim)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	insurancePolicy.PaidOut += insuranceClaim.Payout
+	err = putInsurancePolicyRecord(stub, insurancePolicyKey, insurancePolicy)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Payout of claim %s for trade %s recorded\n", args[1], args[0])
+
+	return shim.Success(nil)
+}
+
+// Get the insurance policy on a trade's shipment
+func (t *TradeWorkflowChaincode) getInsurancePolicy(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var insurancePolicyKey, jsonResp string
+	var insurancePolicyBytes []byte
+	var err error
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1: <trade ID>")
+	}
+
+	// Access control: Only the Insurer, the trade's participants, or a regulator for its jurisdiction, can invoke this transaction
+	if !t.testMode && !authenticateInsurer(stub, creatorOrg, creatorCertIssuer) {
+		err = authorizeTradeAccess(stub, creatorOrg, creatorCertIssuer, args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	// Get the state from the ledger
+	insurancePolicyKey, err = getInsurancePolicyKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	insurancePolicyBytes, err = stub.GetState(insurancePolicyKey)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Failed to get state for " + insurancePolicyKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+
+	if len(insurancePolicyBytes) == 0 {
+		jsonResp = "{\"Error\":\"No record found for " + insurancePolicyKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+	insurancePolicyBytes, err = addPrivateTerms(stub, tradeTermsCollection, insurancePolicyKey, insurancePolicyBytes)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Failed to get private terms for " + insurancePolicyKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+	fmt.Printf("Query Response:%s\n", string(insurancePolicyBytes))
+	return shim.Success(insurancePolicyBytes)
+}
+
+// Get a claim under the insurance policy on a trade's shipment
+func (t *TradeWorkflowChaincode) getInsuranceClaim(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var insuranceClaimKey, jsonResp string
+	var insuranceClaimBytes []byte
+	var err error
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2: <trade ID, claim ID>")
+	}
+
+	// Access control: Only the Insurer, the trade's participants, or a regulator for its jurisdiction, can invoke this transaction
+	if !t.testMode && !authenticateInsurer(stub, creatorOrg, creatorCertIssuer) {
+		err = authorizeTradeAccess(stub, creatorOrg, creatorCertIssuer, args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	// Get the state from the ledger
+	insuranceClaimKey, err = getInsuranceClaimKey(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	insuranceClaimBytes, err = stub.GetState(insuranceClaimKey)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Failed to get state for " + insuranceClaimKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+
+	if len(insuranceClaimBytes) == 0 {
+		jsonResp = "{\"Error\":\"No record found for " + insuranceClaimKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+	insuranceClaimBytes, err = addPrivateTerms(stub, tradeTermsCollection, insuranceClaimKey, insuranceClaimBytes)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Failed to get private terms for " + insuranceClaimKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+	fmt.Printf("Query Response:%s\n", string(insuranceClaimBytes))
+	return shim.Success(insuranceClaimBytes)
+}
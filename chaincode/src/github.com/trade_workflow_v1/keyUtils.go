@@ -47,6 +47,15 @@ func getELKey(stub shim.ChaincodeStubInterface, tradeID string) (string, error)
 	}
 }
 
+func getStandaloneELKey(stub shim.ChaincodeStubInterface, licenseID string) (string, error) {
+	standaloneELKey, err := stub.CreateCompositeKey("StandaloneExportLicense", []string{licenseID})
+	if err != nil {
+		return "", err
+	} else {
+		return standaloneELKey, nil
+	}
+}
+
 func getShipmentLocationKey(stub shim.ChaincodeStubInterface, tradeID string) (string, error) {
 	shipmentLocationKey, err := stub.CreateCompositeKey("Shipment", []string{"Location", tradeID})
 	if err != nil {
@@ -56,6 +65,15 @@ func getShipmentLocationKey(stub shim.ChaincodeStubInterface, tradeID string) (s
 	}
 }
 
+func getShipmentKey(stub shim.ChaincodeStubInterface, tradeID string) (string, error) {
+	shipmentKey, err := stub.CreateCompositeKey("Shipment", []string{"Tracking", tradeID})
+	if err != nil {
+		return "", err
+	} else {
+		return shipmentKey, nil
+	}
+}
+
 func getBLKey(stub shim.ChaincodeStubInterface, tradeID string) (string, error) {
 	blKey, err := stub.CreateCompositeKey("BillOfLading", []string{tradeID})
 	if err != nil {
@@ -65,6 +83,123 @@ func getBLKey(stub shim.ChaincodeStubInterface, tradeID string) (string, error)
 	}
 }
 
+func getPartialShipmentsKey(stub shim.ChaincodeStubInterface, tradeID string) (string, error) {
+	partialShipmentsKey, err := stub.CreateCompositeKey("Shipment", []string{"Partial", tradeID})
+	if err != nil {
+		return "", err
+	} else {
+		return partialShipmentsKey, nil
+	}
+}
+
+func getPartialBLKey(stub shim.ChaincodeStubInterface, tradeID string, shipmentID string) (string, error) {
+	blKey, err := stub.CreateCompositeKey("BillOfLading", []string{tradeID, shipmentID})
+	if err != nil {
+		return "", err
+	} else {
+		return blKey, nil
+	}
+}
+
+func getCustomsDeclarationKey(stub shim.ChaincodeStubInterface, tradeID string) (string, error) {
+	customsDeclarationKey, err := stub.CreateCompositeKey("CustomsDeclaration", []string{tradeID})
+	if err != nil {
+		return "", err
+	} else {
+		return customsDeclarationKey, nil
+	}
+}
+
+func getPartialCustomsDeclarationKey(stub shim.ChaincodeStubInterface, tradeID string, shipmentID string) (string, error) {
+	customsDeclarationKey, err := stub.CreateCompositeKey("CustomsDeclaration", []string{tradeID, shipmentID})
+	if err != nil {
+		return "", err
+	} else {
+		return customsDeclarationKey, nil
+	}
+}
+
+func getTariffKey(stub shim.ChaincodeStubInterface, hsCode string) (string, error) {
+	tariffKey, err := stub.CreateCompositeKey("Tariff", []string{hsCode})
+	if err != nil {
+		return "", err
+	} else {
+		return tariffKey, nil
+	}
+}
+
+func getComplianceHoldKey(stub shim.ChaincodeStubInterface, tradeID string) (string, error) {
+	complianceHoldKey, err := stub.CreateCompositeKey("ComplianceHold", []string{tradeID})
+	if err != nil {
+		return "", err
+	} else {
+		return complianceHoldKey, nil
+	}
+}
+
+func getAMLActivityKey(stub shim.ChaincodeStubInterface, payee string, payer string, timestamp string, txID string, activityType string, index string) (string, error) {
+	amlActivityKey, err := stub.CreateCompositeKey("AMLActivity", []string{payee, payer, timestamp, txID, activityType, index})
+	if err != nil {
+		return "", err
+	} else {
+		return amlActivityKey, nil
+	}
+}
+
+func getAMLFlagKey(stub shim.ChaincodeStubInterface, timestamp string, txID string, rule string, payee string) (string, error) {
+	amlFlagKey, err := stub.CreateCompositeKey("AMLFlag", []string{timestamp, txID, rule, payee})
+	if err != nil {
+		return "", err
+	} else {
+		return amlFlagKey, nil
+	}
+}
+
+func getInsurancePolicyKey(stub shim.ChaincodeStubInterface, tradeID string) (string, error) {
+	insurancePolicyKey, err := stub.CreateCompositeKey("InsurancePolicy", []string{tradeID})
+	if err != nil {
+		return "", err
+	} else {
+		return insurancePolicyKey, nil
+	}
+}
+
+func getInsuranceClaimKey(stub shim.ChaincodeStubInterface, tradeID string, claimID string) (string, error) {
+	insuranceClaimKey, err := stub.CreateCompositeKey("InsuranceClaim", []string{tradeID, claimID})
+	if err != nil {
+		return "", err
+	} else {
+		return insuranceClaimKey, nil
+	}
+}
+
+func getDisputeKey(stub shim.ChaincodeStubInterface, tradeID string, disputeID string) (string, error) {
+	disputeKey, err := stub.CreateCompositeKey("Dispute", []string{tradeID, disputeID})
+	if err != nil {
+		return "", err
+	} else {
+		return disputeKey, nil
+	}
+}
+
+func getTradeDisputesKey(stub shim.ChaincodeStubInterface, tradeID string) (string, error) {
+	tradeDisputesKey, err := stub.CreateCompositeKey("TradeDisputes", []string{tradeID})
+	if err != nil {
+		return "", err
+	} else {
+		return tradeDisputesKey, nil
+	}
+}
+
+func getPaymentRecordKey(stub shim.ChaincodeStubInterface, tradeID string, paymentID string) (string, error) {
+	paymentRecordKey, err := stub.CreateCompositeKey("PaymentRecord", []string{tradeID, paymentID})
+	if err != nil {
+		return "", err
+	} else {
+		return paymentRecordKey, nil
+	}
+}
+
 func getPaymentKey(stub shim.ChaincodeStubInterface, tradeID string) (string, error) {
 	paymentKey, err := stub.CreateCompositeKey("Payment", []string{tradeID})
 	if err != nil {
@@ -74,6 +209,15 @@ func getPaymentKey(stub shim.ChaincodeStubInterface, tradeID string) (string, er
 	}
 }
 
+func getPartialPaymentKey(stub shim.ChaincodeStubInterface, tradeID string, shipmentID string) (string, error) {
+	paymentKey, err := stub.CreateCompositeKey("Payment", []string{tradeID, shipmentID})
+	if err != nil {
+		return "", err
+	} else {
+		return paymentKey, nil
+	}
+}
+
 func getAdvancePaymentKey(stub shim.ChaincodeStubInterface, tradeID string) (string, error) {
 	advancePaymentKey, err := stub.CreateCompositeKey("AdvancePayment", []string{tradeID})
 	if err != nil {
@@ -91,3 +235,111 @@ func getArrivalDateKey(stub shim.ChaincodeStubInterface, tradeID string) (string
 		return arrivalDateKey, nil
 	}
 }
+
+func getShipmentPreparedAtKey(stub shim.ChaincodeStubInterface, tradeID string) (string, error) {
+	shipmentPreparedAtKey, err := stub.CreateCompositeKey("Shipment", []string{"PreparedAt", tradeID})
+	if err != nil {
+		return "", err
+	} else {
+		return shipmentPreparedAtKey, nil
+	}
+}
+
+func getPendingActionKey(stub shim.ChaincodeStubInterface, actionID string) (string, error) {
+	pendingActionKey, err := stub.CreateCompositeKey("PendingAction", []string{actionID})
+	if err != nil {
+		return "", err
+	} else {
+		return pendingActionKey, nil
+	}
+}
+
+func getTradeParticipantsKey(stub shim.ChaincodeStubInterface, tradeID string) (string, error) {
+	tradeParticipantsKey, err := stub.CreateCompositeKey("TradeParticipants", []string{tradeID})
+	if err != nil {
+		return "", err
+	} else {
+		return tradeParticipantsKey, nil
+	}
+}
+
+func getDocumentKey(stub shim.ChaincodeStubInterface, tradeID string, documentID string) (string, error) {
+	documentKey, err := stub.CreateCompositeKey("Document", []string{tradeID, documentID})
+	if err != nil {
+		return "", err
+	} else {
+		return documentKey, nil
+	}
+}
+
+func getTelemetryBatchKey(stub shim.ChaincodeStubInterface, tradeID string, batchID string) (string, error) {
+	telemetryBatchKey, err := stub.CreateCompositeKey("TelemetryBatch", []string{tradeID, batchID})
+	if err != nil {
+		return "", err
+	} else {
+		return telemetryBatchKey, nil
+	}
+}
+
+func getTelemetryReadingKey(stub shim.ChaincodeStubInterface, tradeID string, deviceID string, timestamp string) (string, error) {
+	telemetryReadingKey, err := stub.CreateCompositeKey("TelemetryReading", []string{tradeID, deviceID, timestamp})
+	if err != nil {
+		return "", err
+	} else {
+		return telemetryReadingKey, nil
+	}
+}
+
+func getTelemetrySummaryKey(stub shim.ChaincodeStubInterface, tradeID string) (string, error) {
+	telemetrySummaryKey, err := stub.CreateCompositeKey("TelemetrySummary", []string{tradeID})
+	if err != nil {
+		return "", err
+	} else {
+		return telemetrySummaryKey, nil
+	}
+}
+
+func getLenderKey(stub shim.ChaincodeStubInterface, lenderName string) (string, error) {
+	lenderKey, err := stub.CreateCompositeKey("Lender", []string{lenderName})
+	if err != nil {
+		return "", err
+	} else {
+		return lenderKey, nil
+	}
+}
+
+func getLenderBalanceKey(stub shim.ChaincodeStubInterface, lenderName string) (string, error) {
+	lenderBalanceKey, err := stub.CreateCompositeKey("LenderBalance", []string{lenderName})
+	if err != nil {
+		return "", err
+	} else {
+		return lenderBalanceKey, nil
+	}
+}
+
+func getReceivableKey(stub shim.ChaincodeStubInterface, tradeID string) (string, error) {
+	receivableKey, err := stub.CreateCompositeKey("Receivable", []string{tradeID})
+	if err != nil {
+		return "", err
+	} else {
+		return receivableKey, nil
+	}
+}
+
+func getFinancingBidKey(stub shim.ChaincodeStubInterface, tradeID string, lenderName string) (string, error) {
+	financingBidKey, err := stub.CreateCompositeKey("FinancingBid", []string{tradeID, lenderName})
+	if err != nil {
+		return "", err
+	} else {
+		return financingBidKey, nil
+	}
+}
+
+func getFinancingPositionKey(stub shim.ChaincodeStubInterface, tradeID string, lenderName string) (string, error) {
+	financingPositionKey, err := stub.CreateCompositeKey("FinancingPosition", []string{tradeID, lenderName})
+	if err != nil {
+		return "", err
+	} else {
+		return financingPositionKey, nil
+	}
+}
@@ -0,0 +1,495 @@
+/*
+ * Copyright 2018 IBM All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the 'License');
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an 'AS IS' BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// A trade shipped in full has a single B/L; a trade shipped in parts has one per partial shipment
+func getTradeBLKey(stub shim.ChaincodeStubInterface, tradeID string, shipmentID string) (string, error) {
+	if shipmentID == "" {
+		return getBLKey(stub, tradeID)
+	}
+	return getPartialBLKey(stub, tradeID, shipmentID)
+}
+
+// Returns nil if the trade has not been shipped in parts
+func getPartialShipmentsRecord(stub shim.ChaincodeStubInterface, tradeID string) (string, *PartialShipments, error) {
+	var partialShipmentsKey string
+	var partialShipmentsBytes []byte
+	var partialShipments *PartialShipments
+	var err error
+
+	// Lookup partial shipments from the ledger
+	partialShipmentsKey, err = getPartialShipmentsKey(stub, tradeID)
+	if err != nil {
+		return "", nil, err
+	}
+	partialShipmentsBytes, err = stub.GetState(partialShipmentsKey)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(partialShipmentsBytes) == 0 {
+		return partialShipmentsKey, nil, nil
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(partialShipmentsBytes, &partialShipments)
+	if err != nil {
+		return "", nil, err
+	}
+	return partialShipmentsKey, partialShipments, nil
+}
+
+func putPartialShipmentsRecord(stub shim.ChaincodeStubInterface, partialShipmentsKey string, partialShipments *PartialShipments) error {
+	var partialShipmentsBytes []byte
+	var err error
+
+	partialShipmentsBytes, err = json.Marshal(partialShipments)
+	if err != nil {
+		return errors.New("Error marshaling partial shipments structure")
+	}
+	// Write the state to the ledger
+	return stub.PutState(partialShipmentsKey, partialShipmentsBytes)
+}
+
+func getPartialShipment(stub shim.ChaincodeStubInterface, tradeID string, shipmentID string) (string, *PartialShipments, *PartialShipment, error) {
+	var partialShipmentsKey string
+	var partialShipments *PartialShipments
+	var err error
+
+	partialShipmentsKey, partialShipments, err = getPartialShipmentsRecord(stub, tradeID)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if partialShipments != nil {
+		for i := range partialShipments.Shipments {
+			if partialShipments.Shipments[i].Id == shipmentID {
+				return partialShipmentsKey, partialShipments, &partialShipments.Shipments[i], nil
+			}
+		}
+	}
+	return "", nil, nil, errors.New(fmt.Sprintf("No partial shipment %s found for trade ID %s", shipmentID, tradeID))
+}
+
+// The part of the trade amount owed for a partial shipment, in proportion to its quantity
+// Shares are taken from the running total so that rounding never loses or adds a unit of currency
+func getPartialShipmentShare(partialShipments *PartialShipments, shipmentID string, amount int, quantity int) int {
+	var shippedQuantity int
+
+	for _, partialShipment := range partialShipments.Shipments {
+		if partialShipment.Id == shipmentID {
+			return amount * (shippedQuantity + partialShipment.Quantity) / quantity - amount * shippedQuantity / quantity
+		}
+		shippedQuantity += partialShipment.Quantity
+	}
+	return 0
+}
+
+// Prepare a part of the trade's goods for shipment under its own B/L; the L/C must allow partial shipments
+func (t *TradeWorkflowChaincode) preparePartialShipment(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var tradeKey, lcKey, shipmentLocationKey, partialShipmentsKey, standaloneELKey string
+	var tradeAgreementBytes, letterOfCreditBytes, shipmentLocationBytes []byte
+	var tradeAgreement *TradeAgreement
+	var letterOfCredit *LetterOfCredit
+	var exportLicense *ExportLicense
+	var standaloneLicense *StandaloneExportLicense
+	var partialShipments *PartialShipments
+	var quantity, shippedQuantity int
+	var err error
+
+	// Access control: Only an Exporter Org member can invoke this transaction
+	if !t.testMode && !authenticateExporterOrg(creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a member of Exporter Org. Access denied.")
+	}
+
+	if len(args) != 3 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 3: {Trade ID, Shipment ID, Quantity}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+	if args[1] == "" {
+		return shim.Error("Shipment ID must be non-empty")
+	}
+	quantity, err = strconv.Atoi(args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if quantity <= 0 {
+		return shim.Error("Quantity must be positive")
+	}
+
+	// Lookup shipment location from the ledger
+	shipmentLocationKey, err = getShipmentLocationKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	shipmentLocationBytes, err = stub.GetState(shipmentLocationKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(shipmentLocationBytes) != 0 {
+		fmt.Printf("Shipment for trade %s has been prepared in full\n", args[0])
+		return shim.Error("Shipment prepared in full")
+	}
+
+	// Lookup trade agreement from the ledger
+	tradeKey, err = getTradeKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	tradeAgreementBytes, err = stub.GetState(tradeKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(tradeAgreementBytes) == 0 {
+		err = errors.New(fmt.Sprintf("No record found for trade ID %s", args[0]))
+		return shim.Error(err.Error())
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(tradeAgreementBytes, &tradeAgreement)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Lookup L/C from the ledger
+	lcKey, err = getLCKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	letterOfCreditBytes, err = stub.GetState(lcKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(letterOfCreditBytes) == 0 {
+		err = errors.New(fmt.Sprintf("No L/C found for trade ID %s", args[0]))
+		return shim.Error(err.Error())
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(letterOfCreditBytes, &letterOfCredit)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if !letterOfCredit.PartialShipments {
+		fmt.Printf("L/C for trade %s does not allow partial shipments\n", args[0])
+		return shim.Error("Partial shipments not allowed by the L/C")
+	}
+
+	partialShipmentsKey, partialShipments, err = getPartialShipmentsRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if partialShipments == nil {
+		partialShipments = &PartialShipments{args[0], []PartialShipment{}}
+	}
+	for _, partialShipment := range partialShipments.Shipments {
+		if partialShipment.Id == args[1] {
+			fmt.Printf("Partial shipment %s for trade %s has already been prepared\n", args[1], args[0])
+			return shim.Success(nil)
+		}
+		shippedQuantity += partialShipment.Quantity
+	}
+
+	// The partial shipments together cannot exceed the quantity of goods in the trade
+	if shippedQuantity + quantity > tradeAgreement.Quantity {
+		err = errors.New(fmt.Sprintf("Quantity %d exceeds the %d remaining to be shipped for trade %s", quantity, tradeAgreement.Quantity - shippedQuantity, args[0]))
+		return shim.Error(err.Error())
+	}
+
+	// Verify that the E/L has been issued, is in force, and covers the goods shipped so far
+	exportLicense, standaloneELKey, standaloneLicense, err = getTradeExportLicense(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = checkExportLicense(stub, exportLicense, shippedQuantity + quantity, "")
+	if err != nil {
+		fmt.Printf("E/L for trade %s does not cover the shipment\n", args[0])
+		return shim.Error(err.Error())
+	}
+
+	partialShipments.Shipments = append(partialShipments.Shipments, PartialShipment{args[1], quantity, SOURCE, "", "", ""})
+
+	// Goods shipped under a standalone E/L are counted against its quota, at the shipment's share of the trade amount
+	if standaloneLicense != nil {
+		err = getTradeTerms(stub, tradeKey, tradeAgreement)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		err = drawDownExportQuota(stub, standaloneELKey, standaloneLicense, args[0], args[1], quantity, getPartialShipmentShare(partialShipments, args[1], tradeAgreement.Amount, tradeAgreement.Quantity))
+		if err != nil {
+			fmt.Printf("Quota of E/L %s is insufficient for partial shipment %s of trade %s\n", standaloneLicense.Id, args[1], args[0])
+			return shim.Error(err.Error())
+		}
+	}
+	err = putPartialShipmentsRecord(stub, partialShipmentsKey, partialShipments)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Partial shipment %s preparation for trade %s recorded\n", args[1], args[0])
+
+	return shim.Success(nil)
+}
+
+// Accept a partial shipment and issue a B/L for its quantity
+func (t *TradeWorkflowChaincode) acceptPartialShipmentAndIssueBL(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var partialShipmentsKey, tradeKey, blKey string
+	var tradeAgreementBytes, billOfLadingBytes, exporterBytes, carrierBytes, beneficiaryBytes []byte
+	var partialShipments *PartialShipments
+	var partialShipment *PartialShipment
+	var tradeAgreement *TradeAgreement
+	var exportLicense *ExportLicense
+	var billOfLading *BillOfLading
+	var shipment *Shipment
+	var shippedQuantity int
+	var err error
+
+	// Access control: Only a Carrier Org member can invoke this transaction
+	if !t.testMode && !authenticateCarrierOrg(creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a member of Carrier Org. Access denied.")
+	}
+
+	if len(args) != 6 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 6: {Trade ID, Shipment ID, B/L ID, Expiration Date, Source Port, Destination Port}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+
+	partialShipmentsKey, partialShipments, partialShipment, err = getPartialShipment(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if partialShipment.BillOfLadingId != "" {
+		fmt.Printf("B/L for partial shipment %s of trade %s has already been issued\n", args[1], args[0])
+		return shim.Error("B/L already issued")
+	}
+	if partialShipment.Location != SOURCE {
+		fmt.Printf("Partial shipment %s for trade %s has passed the preparation stage\n", args[1], args[0])
+		return shim.Error("Shipment past the preparation stage")
+	}
+
+	// Lookup trade agreement from the ledger
+	tradeKey, err = getTradeKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	tradeAgreementBytes, err = stub.GetState(tradeKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(tradeAgreementBytes) == 0 {
+		err = errors.New(fmt.Sprintf("No record found for trade ID %s", args[0]))
+		return shim.Error(err.Error())
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(tradeAgreementBytes, &tradeAgreement)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Verify that the E/L is still in force, and allows export to the destination
+	for _, shipped := range partialShipments.Shipments {
+		shippedQuantity += shipped.Quantity
+		if shipped.Id == args[1] {
+			break
+		}
+	}
+	exportLicense, _, _, err = getTradeExportLicense(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = checkExportLicense(stub, exportLicense, shippedQuantity, args[5])
+	if err != nil {
+		fmt.Printf("E/L for trade %s does not cover the shipment\n", args[0])
+		return shim.Error(err.Error())
+	}
+
+	// Lookup exporter
+	exporterBytes, err = stub.GetState(expKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Lookup carrier
+	carrierBytes, err = stub.GetState(carKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Lookup importer's bank (holds the title to goods until payment is made)
+	beneficiaryBytes, err = stub.GetState(ibKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Screen the parties, the route and the goods against the Regulator's list
+	_, shipment, err = getShipmentRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = screenTrade(stub, args[0], "acceptPartialShipmentAndIssueBL", []string{string(exporterBytes), string(carrierBytes), string(beneficiaryBytes)},
+		getShipmentPorts(shipment, args[4], args[5]), getExportLicenseHsCodes(exportLicense))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Create and record a B/L
+	billOfLading = &BillOfLading{args[2], args[3], string(exporterBytes), string(carrierBytes), tradeAgreement.DescriptionOfGoods, partialShipment.Quantity,
+		string(beneficiaryBytes), args[4], args[5], nil, string(beneficiaryBytes), "ImporterOrgMSP", "", HELD_BY_BANK, []Endorsement{}, "", ""}
+
+	// Attach the Carrier's signature over the B/L content
+	err = signBillOfLading(stub, t.testMode, billOfLading)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	billOfLadingBytes, err = json.Marshal(billOfLading)
+	if err != nil {
+		return shim.Error("Error marshaling bill of lading structure")
+	}
+
+	// Write the state to the ledger
+	blKey, err = getPartialBLKey(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = stub.PutState(blKey, billOfLadingBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	partialShipment.BillOfLadingId = args[2]
+	err = putPartialShipmentsRecord(stub, partialShipmentsKey, partialShipments)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Bill of Lading for partial shipment %s of trade %s recorded\n", args[1], args[0])
+
+	return shim.Success(nil)
+}
+
+// Record the arrival of a partial shipment at its destination
+func (t *TradeWorkflowChaincode) updatePartialShipmentLocation(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var partialShipmentsKey, deliveryWindowEnd string
+	var partialShipments *PartialShipments
+	var partialShipment *PartialShipment
+	var delayDays int
+	var err error
+
+	// Access control: Only a Carrier Org member can invoke this transaction
+	if !t.testMode && !authenticateCarrierOrg(creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a member of Carrier Org. Access denied.")
+	}
+
+	if len(args) != 4 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 4: {Trade ID, Shipment ID, Location, Date}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+	if args[2] != DESTINATION {
+		err = errors.New(fmt.Sprintf("Invalid location %s; a partial shipment can only move to %s", args[2], DESTINATION))
+		return shim.Error(err.Error())
+	}
+	_, err = time.Parse(dateLayout, args[3])
+	if err != nil {
+		err = errors.New(fmt.Sprintf("Invalid date %s; expecting MM/DD/YYYY", args[3]))
+		return shim.Error(err.Error())
+	}
+
+	partialShipmentsKey, partialShipments, partialShipment, err = getPartialShipment(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if partialShipment.Location == DESTINATION {
+		fmt.Printf("Partial shipment %s for trade %s is already in location %s\n", args[1], args[0], args[2])
+		return shim.Success(nil)
+	}
+	if partialShipment.BillOfLadingId == "" {
+		fmt.Printf("No B/L issued for partial shipment %s of trade %s\n", args[1], args[0])
+		return shim.Error("B/L not issued yet")
+	}
+
+	partialShipment.Location = DESTINATION
+	partialShipment.ArrivalDate = args[3]
+	err = putPartialShipmentsRecord(stub, partialShipmentsKey, partialShipments)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Partial shipment %s location for trade %s recorded\n", args[1], args[0])
+
+	// Report a late arrival
+	deliveryWindowEnd, delayDays, err = getDeliveryDelay(stub, args[0], args[3])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if delayDays > 0 {
+		err = emitShipmentDelayed(stub, args[0], deliveryWindowEnd, args[3], false, delayDays)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	return shim.Success(nil)
+}
+
+// Get the partial shipments of a trade
+func (t *TradeWorkflowChaincode) getPartialShipments(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var partialShipmentsKey, jsonResp string
+	var partialShipmentsBytes []byte
+	var err error
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1: <trade ID>")
+	}
+
+	// Access control: Only the trade's participants, or a regulator for its jurisdiction, can invoke this transaction
+	if !t.testMode {
+		err = authorizeTradeAccess(stub, creatorOrg, creatorCertIssuer, args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	// Get the state from the ledger
+	partialShipmentsKey, err = getPartialShipmentsKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	partialShipmentsBytes, err = stub.GetState(partialShipmentsKey)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Failed to get state for " + partialShipmentsKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+
+	if len(partialShipmentsBytes) == 0 {
+		jsonResp = "{\"Error\":\"No record found for " + partialShipmentsKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+	fmt.Printf("Query Response:%s\n", string(partialShipmentsBytes))
+	return shim.Success(partialShipmentsBytes)
+}
@@ -0,0 +1,813 @@
+/*
+ * Copyright 2018 IBM All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the 'License');
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an 'AS IS' BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// The payment records of a trade, in the order they were made
+func getTradePaymentRecords(stub shim.ChaincodeStubInterface, tradeID string) ([]PaymentRecord, error) {
+	var iterator shim.StateQueryIteratorInterface
+	var kv *queryresult.KV
+	var records []PaymentRecord
+	var record PaymentRecord
+	var err error
+
+	iterator, err = getPrivateDataByPartialCompositeKey(stub, tradeTermsCollection, "PaymentRecord", []string{tradeID})
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+
+	records = []PaymentRecord{}
+	for iterator.HasNext() {
+		kv, err = iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		record = PaymentRecord{}
+		err = json.Unmarshal(kv.Value, &record)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	// Payment IDs are sequence numbers
+	sort.Slice(records, func(i, j int) bool {
+		a, _ := strconv.Atoi(records[i].Id)
+		b, _ := strconv.Atoi(records[j].Id)
+		return a < b
+	})
+	return records, nil
+}
+
+// Returns nil if there is no such payment on the trade
+func getPaymentRecord(stub shim.ChaincodeStubInterface, tradeID string, paymentID string) (*PaymentRecord, error) {
+	var paymentRecordKey string
+	var paymentRecordBytes []byte
+	var paymentRecord *PaymentRecord
+	var err error
+
+	paymentRecordKey, err = getPaymentRecordKey(stub, tradeID, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	paymentRecordBytes, err = getPrivateData(stub, tradeTermsCollection, paymentRecordKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(paymentRecordBytes) == 0 {
+		return nil, nil
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(paymentRecordBytes, &paymentRecord)
+	if err != nil {
+		return nil, err
+	}
+	return paymentRecord, nil
+}
+
+// The name of an entity's account holder, and whether the caller acts for it
+// The lender is the one configured at Init unless a registered lender is named
+func getAccountHolder(stub shim.ChaincodeStubInterface, entity string, lenderName string, creatorOrg string, creatorCertIssuer string) (string, bool, error) {
+	var holderBytes []byte
+	var err error
+
+	switch strings.ToLower(entity) {
+	case "exporter":
+		holderBytes, err = stub.GetState(expKey)
+		return string(holderBytes), authenticateExporterOrg(creatorOrg, creatorCertIssuer) || authenticateExportingEntityOrg(creatorOrg, creatorCertIssuer), err
+	case "importer":
+		holderBytes, err = stub.GetState(impKey)
+		return string(holderBytes), authenticateImporterOrg(creatorOrg, creatorCertIssuer), err
+	case "lender":
+		if lenderName == "" {
+			holderBytes, err = stub.GetState(lenKey)
+			if err != nil {
+				return "", false, err
+			}
+			lenderName = string(holderBytes)
+		}
+		_, err = getLenderAccount(stub, lenderName)
+		if err != nil {
+			return "", false, err
+		}
+		return lenderName, authorizeLender(stub, creatorOrg, creatorCertIssuer, lenderName) == nil, nil
+	case "insurer":
+		holderBytes, err = stub.GetState(insKey)
+		return string(holderBytes), authenticateInsurer(stub, creatorOrg, creatorCertIssuer), err
+	case "customs":
+		return cusKey, authenticateCustomsOfficer(stub, creatorOrg, creatorCertIssuer), nil
+	default:
+		return "", false, errors.New(fmt.Sprintf("Invalid entity %s; Permissible values: {exporter, importer, lender, insurer, customs}", entity))
+	}
+}
+
+// Customs records are copied where the Regulator Org's peers, which do not hold the trade terms, can read them
+func getPaymentRecordsCollection(holder string) string {
+	if holder == cusKey {
+		return customsCollection
+	}
+	return tradeTermsCollection
+}
+
+// The payment records, across all trades, in which an account holder paid or was paid
+func getAccountPaymentRecords(stub shim.ChaincodeStubInterface, holder string) ([]PaymentRecord, error) {
+	var iterator shim.StateQueryIteratorInterface
+	var kv *queryresult.KV
+	var records []PaymentRecord
+	var record PaymentRecord
+	var err error
+
+	iterator, err = getPrivateDataByPartialCompositeKey(stub, getPaymentRecordsCollection(holder), "PaymentRecord", []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+
+	records = []PaymentRecord{}
+	for iterator.HasNext() {
+		kv, err = iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		record = PaymentRecord{}
+		err = json.Unmarshal(kv.Value, &record)
+		if err != nil {
+			return nil, err
+		}
+		if record.Payer == holder || record.Payee == holder {
+			records = append(records, record)
+		}
+	}
+
+	// In the order they were made; payment IDs break ties within a transaction
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].Timestamp != records[j].Timestamp {
+			return records[i].Timestamp < records[j].Timestamp
+		}
+		if records[i].TradeId != records[j].TradeId {
+			return records[i].TradeId < records[j].TradeId
+		}
+		a, _ := strconv.Atoi(records[i].Id)
+		b, _ := strconv.Atoi(records[j].Id)
+		return a < b
+	})
+	return records, nil
+}
+
+// Record a movement of funds on a trade under the next payment ID; records are never updated
+func recordPayment(stub shim.ChaincodeStubInterface, tradeID string, shipmentID string, recordType string, function string, payer string, payee string, amount int, surcharge int, paymentID string) (*PaymentRecord, error) {
+	var paymentRecords []PaymentRecord
+	var err error
+
+	paymentRecords, err = recordPayments(stub, tradeID, []PaymentRecord{{"", tradeID, shipmentID, recordType, function, payer, payee, amount, surcharge, 0, "", "", paymentID}})
+	if err != nil {
+		return nil, err
+	}
+	return &paymentRecords[0], nil
+}
+
+// Record the movements of funds a transaction makes on a trade under consecutive payment IDs
+// A transaction does not read its own writes, so records it makes together must be numbered together
+func recordPayments(stub shim.ChaincodeStubInterface, tradeID string, paymentRecords []PaymentRecord) ([]PaymentRecord, error) {
+	var paymentRecordKey string
+	var paymentRecordBytes []byte
+	var records []PaymentRecord
+	var installments map[string]int
+	var now time.Time
+	var err error
+
+	records, err = getTradePaymentRecords(stub, tradeID)
+	if err != nil {
+		return nil, err
+	}
+	now, err = getTxTime(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	// L/C payments are numbered in installments per trade, or per shipment of a partial shipment
+	installments = map[string]int{}
+	for _, record := range records {
+		if record.Type == PAYMENT && record.Installment > installments[record.ShipmentId] {
+			installments[record.ShipmentId] = record.Installment
+		}
+	}
+
+	for i := range paymentRecords {
+		paymentRecords[i].Id = strconv.Itoa(len(records) + i + 1)
+		paymentRecords[i].TradeId = tradeID
+		if paymentRecords[i].Type == PAYMENT {
+			paymentRecords[i].Installment = installments[paymentRecords[i].ShipmentId] + 1
+		}
+		paymentRecords[i].Timestamp = now.Format(time.RFC3339)
+		paymentRecords[i].TxId = stub.GetTxID()
+
+		paymentRecordKey, err = getPaymentRecordKey(stub, tradeID, paymentRecords[i].Id)
+		if err != nil {
+			return nil, err
+		}
+		paymentRecordBytes, err = json.Marshal(&paymentRecords[i])
+		if err != nil {
+			return nil, errors.New("Error marshaling payment record structure")
+		}
+		err = putPrivateData(stub, tradeTermsCollection, paymentRecordKey, paymentRecordBytes)
+		if err != nil {
+			return nil, err
+		}
+		if paymentRecords[i].Payer == cusKey || paymentRecords[i].Payee == cusKey {
+			err = putPrivateData(stub, customsCollection, paymentRecordKey, paymentRecordBytes)
+			if err != nil {
+				return nil, err
+			}
+		}
+		fmt.Printf("Payment %s of %d for trade %s recorded\n", paymentRecords[i].Id, paymentRecords[i].Amount, tradeID)
+	}
+	return paymentRecords, nil
+}
+
+// The Customs revenue account is kept apart from the trade parties' accounts, where the Regulator Org's peers can hold it
+func getAccountBalanceCollection(balanceKey string) string {
+	if balanceKey == cusBalKey {
+		return customsCollection
+	}
+	return accountBalancesCollection
+}
+
+// An account not funded at Init, such as the Insurer's, starts with a zero balance
+func getAccountBalanceValue(stub shim.ChaincodeStubInterface, balanceKey string) (int, error) {
+	var balanceBytes []byte
+	var err error
+
+	balanceBytes, err = getPrivateData(stub, getAccountBalanceCollection(balanceKey), balanceKey)
+	if err != nil {
+		return 0, err
+	}
+	if len(balanceBytes) == 0 {
+		return 0, nil
+	}
+	return strconv.Atoi(string(balanceBytes))
+}
+
+// Lookup an account balance into the balances a transaction moves funds between, unless already there
+// A transaction does not read its own writes, so each balance is read and written once
+func loadAccountBalance(stub shim.ChaincodeStubInterface, balances map[string]int, balanceKey string) error {
+	var balance int
+	var found bool
+	var err error
+
+	_, found = balances[balanceKey]
+	if found {
+		return nil
+	}
+	balance, err = getAccountBalanceValue(stub, balanceKey)
+	if err != nil {
+		return err
+	}
+	balances[balanceKey] = balance
+	return nil
+}
+
+// Move funds between two accounts on the balances given; the payer's balance must cover the amount
+func moveAccountFunds(stub shim.ChaincodeStubInterface, balances map[string]int, fromKey string, toKey string, amount int) error {
+	var err error
+
+	err = loadAccountBalance(stub, balances, fromKey)
+	if err != nil {
+		return err
+	}
+	err = loadAccountBalance(stub, balances, toKey)
+	if err != nil {
+		return err
+	}
+	if balances[fromKey] < amount {
+		fmt.Printf("Balance %d of %s is insufficient to cover %d\n", balances[fromKey], fromKey, amount)
+		return errors.New("Insufficient balance")
+	}
+	balances[fromKey] -= amount
+	balances[toKey] += amount
+	return nil
+}
+
+// Write the balances a transaction has moved funds between, in key order
+func putAccountBalances(stub shim.ChaincodeStubInterface, balances map[string]int) error {
+	var balanceKeys []string
+	var err error
+
+	for balanceKey := range balances {
+		balanceKeys = append(balanceKeys, balanceKey)
+	}
+	sort.Strings(balanceKeys)
+	for _, balanceKey := range balanceKeys {
+		err = putPrivateData(stub, getAccountBalanceCollection(balanceKey), balanceKey, []byte(strconv.Itoa(balances[balanceKey])))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Title to the goods paid for passes to the importer with the B/L; the payment can no longer be refunded once it has
+// A payment on a partial shipment is held by that shipment's B/L; a payment on the trade, by every B/L of the trade
+func checkTitleRetained(stub shim.ChaincodeStubInterface, tradeID string, shipmentID string, partialShipments *PartialShipments) error {
+	var blKey string
+	var billOfLadingBytes []byte
+	var billOfLading *BillOfLading
+	var shipmentIDs []string
+	var err error
+
+	shipmentIDs = []string{shipmentID}
+	if shipmentID == "" && partialShipments != nil {
+		for _, partialShipment := range partialShipments.Shipments {
+			shipmentIDs = append(shipmentIDs, partialShipment.Id)
+		}
+	}
+	for _, id := range shipmentIDs {
+		if partialShipments != nil {
+			for _, partialShipment := range partialShipments.Shipments {
+				if id != "" && partialShipment.Id == id && partialShipment.PaymentStatus == PAID {
+					return errors.New(fmt.Sprintf("Shipment %s of trade %s has been paid for; title to its goods has passed to the importer", id, tradeID))
+				}
+			}
+		}
+
+		// Lookup B/L from the ledger; without one, title has not passed
+		blKey, err = getTradeBLKey(stub, tradeID, id)
+		if err != nil {
+			return err
+		}
+		billOfLadingBytes, err = stub.GetState(blKey)
+		if err != nil {
+			return err
+		}
+		if len(billOfLadingBytes) == 0 {
+			continue
+		}
+
+		// Unmarshal the JSON
+		err = json.Unmarshal(billOfLadingBytes, &billOfLading)
+		if err != nil {
+			return err
+		}
+		if billOfLading.Status != HELD_BY_BANK {
+			return errors.New(fmt.Sprintf("B/L %s of trade %s is %s; title to the goods has passed to the importer", billOfLading.Id, tradeID, billOfLading.Status))
+		}
+	}
+	return nil
+}
+
+// Return funds from a payment to the importer, up to the amount not yet refunded; the whole remainder if amount is 0
+// The trade's payment, the L/C amount and the part of it allocated to the payee are adjusted in place, for the caller to write
+func refundPaymentRecord(stub shim.ChaincodeStubInterface, tradeAgreement *TradeAgreement, letterOfCredit *LetterOfCredit, tradeID string, paymentID string, amount int, function string) (*PaymentRecord, error) {
+	var refundRecords []PaymentRecord
+	var err error
+
+	refundRecords, err = refundPaymentRecords(stub, tradeAgreement, letterOfCredit, tradeID, []string{paymentID}, amount, function)
+	if err != nil {
+		return nil, err
+	}
+	return &refundRecords[0], nil
+}
+
+// Return funds from several payments to the importer in one transaction, each in full of what is not yet refunded, or amount
+// from a single payment; with no payments named, every L/C payment of the trade with funds left to refund is refunded
+// Balances, financing positions and payment records are each read and written once, as a transaction does not read its own writes
+func refundPaymentRecords(stub shim.ChaincodeStubInterface, tradeAgreement *TradeAgreement, letterOfCredit *LetterOfCredit, tradeID string, paymentIDs []string, amount int, function string) ([]PaymentRecord, error) {
+	var payeeBalKey string
+	var transfer *LCTransfer
+	var partialShipments *PartialShipments
+	var records, payments, refundRecords, trueUpRecords []PaymentRecord
+	var refundable map[string]int
+	var balances map[string]int
+	var found bool
+	var err error
+
+	if amount != 0 && len(paymentIDs) != 1 {
+		return nil, errors.New("A refund amount applies to a single payment")
+	}
+
+	// Net what has already been refunded from each payment
+	records, err = getTradePaymentRecords(stub, tradeID)
+	if err != nil {
+		return nil, err
+	}
+	refundable = map[string]int{}
+	for _, record := range records {
+		if record.Type == PAYMENT {
+			refundable[record.Id] += record.Amount
+		} else if record.Type == REFUND {
+			refundable[record.PaymentId] -= record.Amount
+		}
+	}
+	if len(paymentIDs) == 0 {
+		for _, record := range records {
+			if record.Type == PAYMENT && refundable[record.Id] > 0 {
+				payments = append(payments, record)
+			}
+		}
+	}
+	for _, paymentID := range paymentIDs {
+		found = false
+		for _, record := range records {
+			if record.Id != paymentID {
+				continue
+			}
+			if record.Type != PAYMENT {
+				return nil, errors.New(fmt.Sprintf("Payment %s of trade %s is a %s; only an L/C payment can be refunded", paymentID, tradeID, record.Type))
+			}
+			payments = append(payments, record)
+			found = true
+		}
+		if !found {
+			return nil, errors.New(fmt.Sprintf("No payment %s found for trade ID %s", paymentID, tradeID))
+		}
+	}
+
+	// The importer cannot keep both the goods and the funds paid for them
+	_, partialShipments, err = getPartialShipmentsRecord(stub, tradeID)
+	if err != nil {
+		return nil, err
+	}
+	for _, payment := range payments {
+		err = checkTitleRetained(stub, tradeID, payment.ShipmentId, partialShipments)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	balances = map[string]int{}
+	for _, payment := range payments {
+		if amount == 0 {
+			amount = refundable[payment.Id]
+		}
+		if amount <= 0 || amount > refundable[payment.Id] {
+			return nil, errors.New(fmt.Sprintf("Refund must be positive and not exceed %d, the amount of payment %s not yet refunded", refundable[payment.Id], payment.Id))
+		}
+
+		// The payee returns the funds to the payer
+		payeeBalKey, err = getBeneficiaryAccount(stub, payment.Payee)
+		if err != nil {
+			return nil, err
+		}
+		err = moveAccountFunds(stub, balances, payeeBalKey, impBalKey, amount)
+		if err != nil {
+			return nil, err
+		}
+		tradeAgreement.Payment -= amount
+		if letterOfCredit != nil {
+			letterOfCredit.Amount += amount
+			transfer = getLCTransfer(letterOfCredit, payment.Payee)
+			if transfer != nil {
+				transfer.Amount += amount
+			}
+		}
+		refundRecords = append(refundRecords, PaymentRecord{"", tradeID, payment.ShipmentId, REFUND, function, payment.Payee, payment.Payer, amount, 0, 0, "", "", payment.Id})
+		refundable[payment.Id] -= amount
+		amount = 0
+	}
+	if len(refundRecords) == 0 {
+		return refundRecords, nil
+	}
+
+	// A lender refunding a payment has that much more to collect
+	trueUpRecords, err = reconcileFinancingPositions(stub, tradeID, function, refundRecords, balances)
+	if err != nil {
+		return nil, err
+	}
+	refundRecords, err = recordPayments(stub, tradeID, append(refundRecords, trueUpRecords...))
+	if err != nil {
+		return nil, err
+	}
+	err = putAccountBalances(stub, balances)
+	if err != nil {
+		return nil, err
+	}
+	return refundRecords, nil
+}
+
+// Refund a payment made under the L/C, in full or in part
+func (t *TradeWorkflowChaincode) refundPayment(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var tradeKey, lcKey, amountStr string
+	var tradeAgreementBytes, letterOfCreditBytes, exporterBytes []byte
+	var tradeAgreement *TradeAgreement
+	var letterOfCredit *LetterOfCredit
+	var payment *PaymentRecord
+	var amount int
+	var found bool
+	var err error
+
+	// Access control: Only an Exporter or Lender Org member can invoke this transaction
+	if !t.testMode && !(authenticateExporterOrg(creatorOrg, creatorCertIssuer) || authenticateLenderOrg(creatorOrg, creatorCertIssuer)) {
+		return shim.Error("Caller not a member of Exporter or Lender Org. Access denied.")
+	}
+
+	if len(args) != 2 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 2: {Trade ID, Payment ID}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+
+	// The amount is passed in the transient map; the payment is refunded in full without it
+	amountStr, found, err = getOptionalTransientValue(stub, "amount")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if found {
+		amount, err = strconv.Atoi(amountStr)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if amount <= 0 {
+			return shim.Error("Refund amount must be positive")
+		}
+	}
+
+	// Only the beneficiary that received the payment can refund it
+	payment, err = getPaymentRecord(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if payment == nil {
+		err = errors.New(fmt.Sprintf("No payment %s found for trade ID %s", args[1], args[0]))
+		return shim.Error(err.Error())
+	}
+	exporterBytes, err = stub.GetState(expKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if !t.testMode && !((authenticateExporterOrg(creatorOrg, creatorCertIssuer) && payment.Payee == string(exporterBytes)) || (authenticateLenderOrg(creatorOrg, creatorCertIssuer) && authorizeLender(stub, creatorOrg, creatorCertIssuer, payment.Payee) == nil)) {
+		fmt.Printf("Refund requestor and payee of payment %s not match for trade %s\n", args[1], args[0])
+		return shim.Error("Refund requestor and payee not match")
+	}
+
+	// Lookup trade agreement from the ledger
+	tradeKey, err = getTradeKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	tradeAgreementBytes, err = stub.GetState(tradeKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(tradeAgreementBytes, &tradeAgreement)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Lookup trade amount and payment from the private terms
+	err = getTradeTerms(stub, tradeKey, tradeAgreement)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Lookup L/C from the ledger
+	lcKey, err = getLCKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	letterOfCreditBytes, err = stub.GetState(lcKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(letterOfCreditBytes, &letterOfCredit)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Lookup L/C amount and transfers from the private terms
+	err = getLetterOfCreditTerms(stub, lcKey, letterOfCredit)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	_, err = refundPaymentRecord(stub, tradeAgreement, letterOfCredit, args[0], args[1], amount, "refundPayment")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Update ledger state
+	err = putTradeTerms(stub, tradeKey, tradeAgreement)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	tradeAgreementBytes, err = json.Marshal(tradeAgreement)
+	if err != nil {
+		return shim.Error("Error marshaling trade agreement structure")
+	}
+	err = stub.PutState(tradeKey, tradeAgreementBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = putLetterOfCreditTerms(stub, lcKey, letterOfCredit)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	letterOfCreditBytes, err = json.Marshal(letterOfCredit)
+	if err != nil {
+		return shim.Error("Error marshaling L/C structure")
+	}
+	err = stub.PutState(lcKey, letterOfCreditBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// Get a payment record of a trade
+func (t *TradeWorkflowChaincode) getPayment(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var paymentRecordKey, jsonResp string
+	var paymentRecordBytes []byte
+	var err error
+
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2: <trade ID, payment ID>")
+	}
+
+	// Access control: Only the trade's participants, or a regulator for its jurisdiction, can invoke this transaction
+	if !t.testMode {
+		err = authorizeTradeAccess(stub, creatorOrg, creatorCertIssuer, args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	// Get the private data from the ledger
+	paymentRecordKey, err = getPaymentRecordKey(stub, args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	paymentRecordBytes, err = getPrivateData(stub, tradeTermsCollection, paymentRecordKey)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Failed to get state for " + paymentRecordKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+
+	if len(paymentRecordBytes) == 0 {
+		jsonResp = "{\"Error\":\"No record found for " + paymentRecordKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+	fmt.Printf("Query Response:%s\n", string(paymentRecordBytes))
+	return shim.Success(paymentRecordBytes)
+}
+
+// List the payment records of a trade, or of an account across all trades
+func (t *TradeWorkflowChaincode) listPayments(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var scope, holderName, lenderName string
+	var recordsBytes []byte
+	var records []PaymentRecord
+	var holder bool
+	var err error
+
+	if len(args) != 2 && !(len(args) == 3 && strings.ToLower(args[0]) == "account" && strings.ToLower(args[1]) == "lender") {
+		return shim.Error("Incorrect number of arguments. Expecting 2: {trade, Trade ID} or {account, Entity}, or 3: {account, lender, Lender}")
+	}
+	if len(args) == 3 {
+		lenderName = args[2]
+	}
+
+	scope = strings.ToLower(args[0])
+	if scope == "trade" {
+		// Access control: Only the trade's participants, or a regulator for its jurisdiction, can invoke this transaction
+		if !t.testMode {
+			err = authorizeTradeAccess(stub, creatorOrg, creatorCertIssuer, args[1])
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+		}
+		records, err = getTradePaymentRecords(stub, args[1])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	} else if scope == "account" {
+		// Access control: Only the account holder, or its org, can invoke this transaction
+		holderName, holder, err = getAccountHolder(stub, args[1], lenderName, creatorOrg, creatorCertIssuer)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if !t.testMode && !holder {
+			return shim.Error("Caller not the holder of the account. Access denied.")
+		}
+		records, err = getAccountPaymentRecords(stub, holderName)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	} else {
+		err = errors.New(fmt.Sprintf("Invalid scope %s; Permissible values: {trade, account}", args[0]))
+		return shim.Error(err.Error())
+	}
+
+	recordsBytes, err = json.Marshal(records)
+	if err != nil {
+		return shim.Error("Error marshaling payment records")
+	}
+	fmt.Printf("Query Response:%s\n", string(recordsBytes))
+	return shim.Success(recordsBytes)
+}
+
+// Generate the statement of an account for a date range
+func (t *TradeWorkflowChaincode) getStatement(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var holderName, lenderName string
+	var statementBytes []byte
+	var records []PaymentRecord
+	var statement *Statement
+	var from, to, made time.Time
+	var holder bool
+	var err error
+
+	if len(args) != 3 && !(len(args) == 4 && strings.ToLower(args[0]) == "lender") {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 3: {Entity, From Date, To Date}, or 4: {lender, From Date, To Date, Lender}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+	if len(args) == 4 {
+		lenderName = args[3]
+	}
+
+	// Access control: Only the account holder, or its org, can invoke this transaction
+	holderName, holder, err = getAccountHolder(stub, args[0], lenderName, creatorOrg, creatorCertIssuer)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if !t.testMode && !holder {
+		return shim.Error("Caller not the holder of the account. Access denied.")
+	}
+
+	from, err = time.Parse(dateLayout, args[1])
+	if err != nil {
+		err = errors.New(fmt.Sprintf("Invalid from date %s; expecting MM/DD/YYYY", args[1]))
+		return shim.Error(err.Error())
+	}
+	to, err = time.Parse(dateLayout, args[2])
+	if err != nil {
+		err = errors.New(fmt.Sprintf("Invalid to date %s; expecting MM/DD/YYYY", args[2]))
+		return shim.Error(err.Error())
+	}
+	if to.Before(from) {
+		return shim.Error("To date must not precede from date")
+	}
+
+	records, err = getAccountPaymentRecords(stub, holderName)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	statement = &Statement{holderName, args[1], args[2], []PaymentRecord{}, 0, 0, 0}
+	for _, record := range records {
+		made, err = time.Parse(time.RFC3339, record.Timestamp)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		// The to date is inclusive
+		if made.Before(from) || !made.Before(to.AddDate(0, 0, 1)) {
+			continue
+		}
+		statement.Payments = append(statement.Payments, record)
+		if record.Payee == statement.Account {
+			statement.Credits += record.Amount
+		}
+		if record.Payer == statement.Account {
+			statement.Debits += record.Amount
+		}
+	}
+	statement.Net = statement.Credits - statement.Debits
+
+	statementBytes, err = json.Marshal(statement)
+	if err != nil {
+		return shim.Error("Error marshaling statement")
+	}
+	fmt.Printf("Query Response:%s\n", string(statementBytes))
+	return shim.Success(statementBytes)
+}
@@ -0,0 +1,368 @@
+/*
+ * Copyright 2018 IBM All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the 'License');
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an 'AS IS' BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// Bank actions that need a maker and a checker (four-eyes control), mapped to the org allowed to submit them
+var dualAuthorizationActions = map[string]func(string, string) bool{
+	"issueLC":     authenticateImporterOrg,
+	"acceptLC":    authenticateExporterOrg,
+	"makePayment": authenticateImporterOrg,
+}
+
+func requiresDualAuthorization(function string) bool {
+	_, found := dualAuthorizationActions[function]
+	return found
+}
+
+// An approved action runs as its maker: with the maker's identity and the transient map of the submission
+type makerStub struct {
+	shim.ChaincodeStubInterface
+	creator		[]byte
+	transient	map[string][]byte
+}
+
+func (stub *makerStub) GetCreator() ([]byte, error) {
+	return stub.creator, nil
+}
+
+func (stub *makerStub) GetTransient() (map[string][]byte, error) {
+	return stub.transient, nil
+}
+
+func (stub *makerStub) GetPrivateData(collection string, key string) ([]byte, error) {
+	return getPrivateData(stub.ChaincodeStubInterface, collection, key)
+}
+
+func (stub *makerStub) PutPrivateData(collection string, key string, value []byte) error {
+	return putPrivateData(stub.ChaincodeStubInterface, collection, key, value)
+}
+
+func (stub *makerStub) GetPrivateDataByPartialCompositeKey(collection string, objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	return getPrivateDataByPartialCompositeKey(stub.ChaincodeStubInterface, collection, objectType, keys)
+}
+
+// Run the handler of an action once it has been approved
+func (t *TradeWorkflowChaincode) executeAction(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, function string, args []string) pb.Response {
+	var err error
+
+	// The trade may have been put on hold, or its payments frozen, since the action was submitted
+	err = checkTradeTransaction(stub, function, args)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	switch function {
+	case "issueLC":
+		return t.issueLC(stub, creatorOrg, creatorCertIssuer, args)
+	case "acceptLC":
+		return t.acceptLC(stub, creatorOrg, creatorCertIssuer, args)
+	case "makePayment":
+		return t.makePayment(stub, creatorOrg, creatorCertIssuer, args)
+	}
+	return shim.Error("Invalid action function name")
+}
+
+// Record an action submitted by a maker; it takes effect only after approval by a checker
+func (t *TradeWorkflowChaincode) submitAction(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, function string, args []string) pb.Response {
+	var tradeKey, pendingActionKey, maker, inputsHash, jsonResp string
+	var tradeAgreementBytes, pendingActionBytes []byte
+	var pendingAction *PendingAction
+	var inputs *PendingActionInputs
+	var now time.Time
+	var err error
+
+	// Access control: Only a member of the org that owns the action can submit it
+	if !t.testMode && !dualAuthorizationActions[function](creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not authorized to submit " + function + ". Access denied.")
+	}
+
+	// The action must be on an existing trade
+	if len(args) == 0 {
+		return shim.Error("Incorrect number of arguments. Expecting at least 1: {Trade ID}")
+	}
+	tradeKey, err = getTradeKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	tradeAgreementBytes, err = stub.GetState(tradeKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if len(tradeAgreementBytes) == 0 {
+		err = errors.New(fmt.Sprintf("No record found for trade ID %s", args[0]))
+		return shim.Error(err.Error())
+	}
+
+	maker, err = getTxCreatorSubject(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	now, err = getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Keep the maker's identity and transient map privately, so that the handler runs with them on approval
+	inputs = &PendingActionInputs{}
+	inputs.Creator, err = stub.GetCreator()
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	inputs.Transient, err = stub.GetTransient()
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	pendingActionKey, err = getPendingActionKey(stub, stub.GetTxID())
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	inputsHash, err = putPrivateTerms(stub, pendingActionKey, inputs)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	pendingAction = &PendingAction{stub.GetTxID(), function, args, creatorOrg, maker, "", PENDING_APPROVAL, "",
+		now.Format(time.RFC3339), now.Add(pendingActionExpiryHours * time.Hour).Format(time.RFC3339), inputsHash}
+	pendingActionBytes, err = json.Marshal(pendingAction)
+	if err != nil {
+		return shim.Error("Error marshaling pending action structure")
+	}
+
+	// Write the state to the ledger
+	err = stub.PutState(pendingActionKey, pendingActionBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Action %s (%s) recorded pending approval\n", pendingAction.Id, function)
+
+	jsonResp = "{\"ActionID\":\"" + pendingAction.Id + "\"}"
+	return shim.Success([]byte(jsonResp))
+}
+
+// Lookup a pending action and verify that the caller may act as its checker
+func getActionForChecker(stub shim.ChaincodeStubInterface, testMode bool, creatorOrg string, creatorCertIssuer string, actionID string) (string, *PendingAction, string, error) {
+	var pendingActionKey, checker string
+	var pendingActionBytes []byte
+	var pendingAction *PendingAction
+	var err error
+
+	// Lookup pending action from the ledger
+	pendingActionKey, err = getPendingActionKey(stub, actionID)
+	if err != nil {
+		return "", nil, "", err
+	}
+	pendingActionBytes, err = stub.GetState(pendingActionKey)
+	if err != nil {
+		return "", nil, "", err
+	}
+
+	if len(pendingActionBytes) == 0 {
+		return "", nil, "", errors.New(fmt.Sprintf("No record found for action ID %s", actionID))
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(pendingActionBytes, &pendingAction)
+	if err != nil {
+		return "", nil, "", err
+	}
+
+	if pendingAction.Status != PENDING_APPROVAL {
+		return "", nil, "", errors.New(fmt.Sprintf("Action %s is not pending approval; status is %s", actionID, pendingAction.Status))
+	}
+
+	// The checker must belong to the maker's org, enrolled by the CA of the org that owns the action, but be a different user
+	if creatorOrg != pendingAction.MakerOrg {
+		return "", nil, "", errors.New("Caller not a member of the maker's org. Access denied.")
+	}
+	if !testMode && !dualAuthorizationActions[pendingAction.Function](creatorOrg, creatorCertIssuer) {
+		return "", nil, "", errors.New("Caller not authorized to approve or reject " + pendingAction.Function + ". Access denied.")
+	}
+	checker, err = getTxCreatorSubject(stub)
+	if err != nil {
+		return "", nil, "", err
+	}
+	if checker == pendingAction.Maker {
+		return "", nil, "", errors.New("Maker cannot approve or reject their own action. Access denied.")
+	}
+
+	return pendingActionKey, pendingAction, checker, nil
+}
+
+// Approve a pending action and run its original handler
+func (t *TradeWorkflowChaincode) approveAction(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var pendingActionKey, checker, makerOrg, makerCertIssuer string
+	var pendingActionBytes []byte
+	var pendingAction *PendingAction
+	var inputs PendingActionInputs
+	var expiration, now time.Time
+	var changeStub *stateChangeStub
+	var actionStub *makerStub
+	var response pb.Response
+	var err error
+
+	if len(args) != 1 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 1: {Action ID}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+
+	pendingActionKey, pendingAction, checker, err = getActionForChecker(stub, t.testMode, creatorOrg, creatorCertIssuer, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// An action left unapproved past its expiry must be submitted again
+	expiration, err = time.Parse(time.RFC3339, pendingAction.ExpiresAt)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	now, err = getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if now.After(expiration) {
+		return shim.Error(fmt.Sprintf("Action %s expired at %s", args[0], pendingAction.ExpiresAt))
+	}
+
+	// Run the handler as the maker
+	err = getPrivateTerms(stub, pendingActionKey, &inputs)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	changeStub = &stateChangeStub{stub, false}
+	actionStub = &makerStub{changeStub, inputs.Creator, inputs.Transient}
+	if !t.testMode {
+		makerOrg, makerCertIssuer, err = getTxCreatorInfo(actionStub)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	response = t.executeAction(actionStub, makerOrg, makerCertIssuer, pendingAction.Function, pendingAction.Args)
+	if response.Status != shim.OK {
+		fmt.Printf("Action %s failed on approval: %s\n", args[0], response.Message)
+		return response
+	}
+
+	// The maker and the checker become participants of the trade once the action has succeeded and changed the trade
+	if !t.testMode && changeStub.changed && isTradeTransaction(pendingAction.Function) {
+		err = addTradeParticipants(stub, pendingAction.Args[0], []TradeParticipant{{pendingAction.MakerOrg, pendingAction.Maker, "", ""}, {creatorOrg, checker, "", ""}})
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	pendingAction.Checker = checker
+	pendingAction.Status = APPROVED
+	pendingActionBytes, err = json.Marshal(pendingAction)
+	if err != nil {
+		return shim.Error("Error marshaling pending action structure")
+	}
+	// Write the state to the ledger
+	err = stub.PutState(pendingActionKey, pendingActionBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Action %s approval recorded\n", args[0])
+
+	return response
+}
+
+// Reject a pending action; its handler is never run
+func (t *TradeWorkflowChaincode) rejectAction(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var pendingActionKey, checker string
+	var pendingActionBytes []byte
+	var pendingAction *PendingAction
+	var err error
+
+	if len(args) != 2 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 2: {Action ID, Reason}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+
+	pendingActionKey, pendingAction, checker, err = getActionForChecker(stub, t.testMode, creatorOrg, creatorCertIssuer, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	pendingAction.Checker = checker
+	pendingAction.Status = REJECTED
+	pendingAction.Reason = args[1]
+	pendingActionBytes, err = json.Marshal(pendingAction)
+	if err != nil {
+		return shim.Error("Error marshaling pending action structure")
+	}
+	// Write the state to the ledger
+	err = stub.PutState(pendingActionKey, pendingActionBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Action %s rejection recorded\n", args[0])
+
+	return shim.Success(nil)
+}
+
+// Get a pending action
+func (t *TradeWorkflowChaincode) getPendingAction(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var pendingActionKey, jsonResp string
+	var pendingActionBytes []byte
+	var pendingAction PendingAction
+	var err error
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1: <action ID>")
+	}
+
+	// Get the state from the ledger
+	pendingActionKey, err = getPendingActionKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	pendingActionBytes, err = stub.GetState(pendingActionKey)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Failed to get state for " + pendingActionKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+
+	if len(pendingActionBytes) == 0 {
+		jsonResp = "{\"Error\":\"No record found for " + pendingActionKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(pendingActionBytes, &pendingAction)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Access control: Only a member of the maker's org can invoke this transaction
+	if !t.testMode && creatorOrg != pendingAction.MakerOrg {
+		return shim.Error("Caller not a member of the maker's org. Access denied.")
+	}
+
+	fmt.Printf("Query Response:%s\n", string(pendingActionBytes))
+	return shim.Success(pendingActionBytes)
+}
@@ -0,0 +1,429 @@
+/*
+ * Copyright 2018 IBM All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the 'License');
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an 'AS IS' BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// The Fabric 1.1 shim exposes private data only when built with the 'experimental' tag
+type privateDataStub interface {
+	GetPrivateData(collection string, key string) ([]byte, error)
+	PutPrivateData(collection string, key string, value []byte) error
+}
+
+func getPrivateData(stub shim.ChaincodeStubInterface, collection string, key string) ([]byte, error) {
+	privateStub, ok := stub.(privateDataStub)
+	if !ok {
+		return nil, errors.New("Private data not supported; chaincode must be built with the 'experimental' tag")
+	}
+	return privateStub.GetPrivateData(collection, key)
+}
+
+func putPrivateData(stub shim.ChaincodeStubInterface, collection string, key string, value []byte) error {
+	privateStub, ok := stub.(privateDataStub)
+	if !ok {
+		return errors.New("Private data not supported; chaincode must be built with the 'experimental' tag")
+	}
+	return privateStub.PutPrivateData(collection, key, value)
+}
+
+// Range queries over private data return results only on peers of the collection's member orgs
+type privateDataQueryStub interface {
+	GetPrivateDataByPartialCompositeKey(collection string, objectType string, keys []string) (shim.StateQueryIteratorInterface, error)
+}
+
+func getPrivateDataByPartialCompositeKey(stub shim.ChaincodeStubInterface, collection string, objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	privateStub, ok := stub.(privateDataQueryStub)
+	if !ok {
+		return nil, errors.New("Private data not supported; chaincode must be built with the 'experimental' tag")
+	}
+	return privateStub.GetPrivateDataByPartialCompositeKey(collection, objectType, keys)
+}
+
+// Sensitive inputs are passed in the transient map so that they are not recorded in the transaction
+func getTransientValue(stub shim.ChaincodeStubInterface, name string) (string, error) {
+	var value string
+	var found bool
+	var err error
+
+	value, found, err = getOptionalTransientValue(stub, name)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", errors.New(fmt.Sprintf("Transient field %s is missing", name))
+	}
+	return value, nil
+}
+
+func getOptionalTransientValue(stub shim.ChaincodeStubInterface, name string) (string, bool, error) {
+	var transientMap map[string][]byte
+	var value []byte
+	var found bool
+	var err error
+
+	transientMap, err = stub.GetTransient()
+	if err != nil {
+		return "", false, err
+	}
+	value, found = transientMap[name]
+	if !found || len(value) == 0 {
+		return "", false, nil
+	}
+	return string(value), true, nil
+}
+
+// The public record of an asset carries the hash of its private terms
+func hashPrivateData(privateBytes []byte) string {
+	hash := sha256.Sum256(privateBytes)
+	return hex.EncodeToString(hash[:])
+}
+
+func putPrivateTerms(stub shim.ChaincodeStubInterface, key string, terms interface{}) (string, error) {
+	return putCollectionTerms(stub, tradeTermsCollection, key, terms)
+}
+
+func getPrivateTerms(stub shim.ChaincodeStubInterface, key string, terms interface{}) error {
+	return getCollectionTerms(stub, tradeTermsCollection, key, terms)
+}
+
+// Terms that parties other than the trade's must read, such as Customs or the Regulator, are kept in their own collection
+func putCollectionTerms(stub shim.ChaincodeStubInterface, collection string, key string, terms interface{}) (string, error) {
+	var termsBytes []byte
+	var err error
+
+	termsBytes, err = json.Marshal(terms)
+	if err != nil {
+		return "", errors.New("Error marshaling private terms structure")
+	}
+	err = putPrivateData(stub, collection, key, termsBytes)
+	if err != nil {
+		return "", err
+	}
+	return hashPrivateData(termsBytes), nil
+}
+
+func getCollectionTerms(stub shim.ChaincodeStubInterface, collection string, key string, terms interface{}) error {
+	var termsBytes []byte
+	var err error
+
+	termsBytes, err = getPrivateData(stub, collection, key)
+	if err != nil {
+		return err
+	}
+	if len(termsBytes) == 0 {
+		return errors.New(fmt.Sprintf("No private terms found for %s", key))
+	}
+	return json.Unmarshal(termsBytes, terms)
+}
+
+// Add the private terms of an asset to its public record in a query response, on the peers that hold them
+// Elsewhere the public record is returned as it is
+func addPrivateTerms(stub shim.ChaincodeStubInterface, collection string, key string, recordBytes []byte) ([]byte, error) {
+	var termsBytes []byte
+	var record, terms interface{}
+	var err error
+
+	termsBytes, err = getPrivateData(stub, collection, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(termsBytes) == 0 {
+		return recordBytes, nil
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(recordBytes, &record)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(termsBytes, &terms)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergePrivateTerms(record, terms))
+}
+
+// Terms of the items of a list, such as the lines of a declaration, are kept in the order of the list
+func mergePrivateTerms(record interface{}, terms interface{}) interface{} {
+	switch recordValue := record.(type) {
+	case map[string]interface{}:
+		termsValue, ok := terms.(map[string]interface{})
+		if !ok {
+			return terms
+		}
+		for name, value := range termsValue {
+			recordValue[name] = mergePrivateTerms(recordValue[name], value)
+		}
+		return recordValue
+	case []interface{}:
+		termsValue, ok := terms.([]interface{})
+		if !ok || len(termsValue) != len(recordValue) {
+			return terms
+		}
+		for i := range recordValue {
+			recordValue[i] = mergePrivateTerms(recordValue[i], termsValue[i])
+		}
+		return recordValue
+	}
+	return terms
+}
+
+// Record the amount and payment of a trade privately; must precede writing the public trade agreement
+func putTradeTerms(stub shim.ChaincodeStubInterface, tradeKey string, tradeAgreement *TradeAgreement) error {
+	var err error
+
+	tradeAgreement.TermsHash, err = putPrivateTerms(stub, tradeKey, &TradeTerms{tradeAgreement.Amount, tradeAgreement.Payment, tradeAgreement.DelayPenaltyRate, tradeAgreement.Penalty})
+	return err
+}
+
+func getTradeTerms(stub shim.ChaincodeStubInterface, tradeKey string, tradeAgreement *TradeAgreement) error {
+	var tradeTerms TradeTerms
+	var err error
+
+	err = getPrivateTerms(stub, tradeKey, &tradeTerms)
+	if err != nil {
+		return err
+	}
+	tradeAgreement.Amount = tradeTerms.Amount
+	tradeAgreement.Payment = tradeTerms.Payment
+	tradeAgreement.DelayPenaltyRate = tradeTerms.DelayPenaltyRate
+	tradeAgreement.Penalty = tradeTerms.Penalty
+	return nil
+}
+
+// Record the amount of an L/C, and the amounts and rates of its transfers, privately; must precede writing the public L/C
+func putLetterOfCreditTerms(stub shim.ChaincodeStubInterface, lcKey string, letterOfCredit *LetterOfCredit) error {
+	var letterOfCreditTerms *LetterOfCreditTerms
+	var err error
+
+	letterOfCreditTerms = &LetterOfCreditTerms{letterOfCredit.Amount, nil}
+	for _, transfer := range letterOfCredit.Transfers {
+		letterOfCreditTerms.Transfers = append(letterOfCreditTerms.Transfers, LCTransferTerms{transfer.Beneficiary, transfer.Amount, transfer.DiscountRate, transfer.AnnualRate})
+	}
+	letterOfCredit.TermsHash, err = putPrivateTerms(stub, lcKey, letterOfCreditTerms)
+	return err
+}
+
+func getLetterOfCreditTerms(stub shim.ChaincodeStubInterface, lcKey string, letterOfCredit *LetterOfCredit) error {
+	var letterOfCreditTerms LetterOfCreditTerms
+	var err error
+
+	err = getPrivateTerms(stub, lcKey, &letterOfCreditTerms)
+	if err != nil {
+		return err
+	}
+	letterOfCredit.Amount = letterOfCreditTerms.Amount
+
+	// Transfer terms are kept in the order of the L/C's transfers
+	if len(letterOfCreditTerms.Transfers) != len(letterOfCredit.Transfers) {
+		return errors.New("Transfers of the L/C do not match its private terms")
+	}
+	for i, transferTerms := range letterOfCreditTerms.Transfers {
+		if transferTerms.Beneficiary != letterOfCredit.Transfers[i].Beneficiary {
+			return errors.New("Transfers of the L/C do not match its private terms")
+		}
+		letterOfCredit.Transfers[i].Amount = transferTerms.Amount
+		letterOfCredit.Transfers[i].DiscountRate = transferTerms.DiscountRate
+		letterOfCredit.Transfers[i].AnnualRate = transferTerms.AnnualRate
+	}
+	return nil
+}
+
+// Record the amount paid or refunded on resolving a dispute privately; must precede writing the public dispute
+func putDisputeTerms(stub shim.ChaincodeStubInterface, disputeKey string, dispute *Dispute) error {
+	var err error
+
+	dispute.TermsHash, err = putPrivateTerms(stub, disputeKey, &DisputeTerms{dispute.Amount})
+	return err
+}
+
+// Record the reserve held back from a financing advance privately; must precede writing the public receivable
+func putReceivableTerms(stub shim.ChaincodeStubInterface, receivableKey string, receivable *Receivable) error {
+	var err error
+
+	receivable.TermsHash, err = putPrivateTerms(stub, receivableKey, &ReceivableTerms{receivable.Reserve})
+	return err
+}
+
+func getReceivableTerms(stub shim.ChaincodeStubInterface, receivableKey string, receivable *Receivable) error {
+	var receivableTerms ReceivableTerms
+	var err error
+
+	err = getPrivateTerms(stub, receivableKey, &receivableTerms)
+	if err != nil {
+		return err
+	}
+	receivable.Reserve = receivableTerms.Reserve
+	return nil
+}
+
+// Record the amounts and rate of a lender's financing position privately; must precede writing the public position
+func putFinancingPositionTerms(stub shim.ChaincodeStubInterface, financingPositionKey string, financingPosition *FinancingPosition) error {
+	var err error
+
+	financingPosition.TermsHash, err = putPrivateTerms(stub, financingPositionKey, &FinancingPositionTerms{financingPosition.Advanced, financingPosition.Expected, financingPosition.Collected, financingPosition.Shortfall, financingPosition.AnnualRate, financingPosition.Interest, financingPosition.Accrued, financingPosition.TrueUp})
+	return err
+}
+
+func getFinancingPositionTerms(stub shim.ChaincodeStubInterface, financingPositionKey string, financingPosition *FinancingPosition) error {
+	var financingPositionTerms FinancingPositionTerms
+	var err error
+
+	err = getPrivateTerms(stub, financingPositionKey, &financingPositionTerms)
+	if err != nil {
+		return err
+	}
+	financingPosition.Advanced = financingPositionTerms.Advanced
+	financingPosition.Expected = financingPositionTerms.Expected
+	financingPosition.Collected = financingPositionTerms.Collected
+	financingPosition.Shortfall = financingPositionTerms.Shortfall
+	financingPosition.AnnualRate = financingPositionTerms.AnnualRate
+	financingPosition.Interest = financingPositionTerms.Interest
+	financingPosition.Accrued = financingPositionTerms.Accrued
+	financingPosition.TrueUp = financingPositionTerms.TrueUp
+	return nil
+}
+
+// Record the values, duty and tax of a declaration privately, where Customs can read them; must precede writing the public declaration
+func putCustomsDeclarationTerms(stub shim.ChaincodeStubInterface, customsDeclarationKey string, customsDeclaration *CustomsDeclaration) error {
+	var customsDeclarationTerms *CustomsDeclarationTerms
+	var err error
+
+	customsDeclarationTerms = &CustomsDeclarationTerms{[]CustomsLineItemTerms{}, customsDeclaration.CustomsValue, customsDeclaration.Duty, customsDeclaration.Tax}
+	for _, lineItem := range customsDeclaration.LineItems {
+		customsDeclarationTerms.LineItems = append(customsDeclarationTerms.LineItems, CustomsLineItemTerms{lineItem.Value, lineItem.Duty, lineItem.Tax})
+	}
+	customsDeclaration.TermsHash, err = putCollectionTerms(stub, customsCollection, customsDeclarationKey, customsDeclarationTerms)
+	return err
+}
+
+// The public declaration is enough to tell whether the goods are cleared; only Customs and the importer hold its terms
+func getCustomsDeclarationTerms(stub shim.ChaincodeStubInterface, customsDeclarationKey string, customsDeclaration *CustomsDeclaration) error {
+	var customsDeclarationTerms CustomsDeclarationTerms
+	var err error
+
+	err = getCollectionTerms(stub, customsCollection, customsDeclarationKey, &customsDeclarationTerms)
+	if err != nil {
+		return err
+	}
+	if len(customsDeclarationTerms.LineItems) != len(customsDeclaration.LineItems) {
+		return errors.New("Line items of the customs declaration do not match its private terms")
+	}
+	customsDeclaration.CustomsValue = customsDeclarationTerms.CustomsValue
+	customsDeclaration.Duty = customsDeclarationTerms.Duty
+	customsDeclaration.Tax = customsDeclarationTerms.Tax
+	for i, lineItemTerms := range customsDeclarationTerms.LineItems {
+		customsDeclaration.LineItems[i].Value = lineItemTerms.Value
+		customsDeclaration.LineItems[i].Duty = lineItemTerms.Duty
+		customsDeclaration.LineItems[i].Tax = lineItemTerms.Tax
+	}
+	return nil
+}
+
+// Record the value drawn on a standalone E/L, and that of each drawdown, privately, where the Regulator can read them;
+// must precede writing the public license
+func putStandaloneExportLicenseTerms(stub shim.ChaincodeStubInterface, standaloneELKey string, license *StandaloneExportLicense) error {
+	var licenseTerms *StandaloneExportLicenseTerms
+	var err error
+
+	licenseTerms = &StandaloneExportLicenseTerms{license.ValueDrawn, []QuotaDrawdownTerms{}}
+	for _, drawdown := range license.Drawdowns {
+		licenseTerms.Drawdowns = append(licenseTerms.Drawdowns, QuotaDrawdownTerms{drawdown.TradeId, drawdown.ShipmentId, drawdown.Value})
+	}
+	license.TermsHash, err = putCollectionTerms(stub, exportQuotaCollection, standaloneELKey, licenseTerms)
+	return err
+}
+
+// Only the quota's drawdowns need the license's terms; its status and conditions are public
+func getStandaloneExportLicenseTerms(stub shim.ChaincodeStubInterface, standaloneELKey string, license *StandaloneExportLicense) error {
+	var licenseTerms StandaloneExportLicenseTerms
+	var err error
+
+	err = getCollectionTerms(stub, exportQuotaCollection, standaloneELKey, &licenseTerms)
+	if err != nil {
+		return err
+	}
+	if len(licenseTerms.Drawdowns) != len(license.Drawdowns) {
+		return errors.New("Drawdowns of the E/L do not match its private terms")
+	}
+	license.ValueDrawn = licenseTerms.ValueDrawn
+	for i, drawdownTerms := range licenseTerms.Drawdowns {
+		if drawdownTerms.TradeId != license.Drawdowns[i].TradeId || drawdownTerms.ShipmentId != license.Drawdowns[i].ShipmentId {
+			return errors.New("Drawdowns of the E/L do not match its private terms")
+		}
+		license.Drawdowns[i].Value = drawdownTerms.Value
+	}
+	return nil
+}
+
+// The commitment a sealed bid records in place of its rates
+func sealBidTerms(discountRate float32, advanceRate float32, salt string) (string, error) {
+	var sealedBytes []byte
+	var err error
+
+	sealedBytes, err = json.Marshal(&SealedBidTerms{discountRate, advanceRate, salt})
+	if err != nil {
+		return "", errors.New("Error marshaling sealed bid terms structure")
+	}
+	return hashPrivateData(sealedBytes), nil
+}
+
+// Revealed bid rates are kept apart from the trade terms; must precede writing the public bid
+func putBidTerms(stub shim.ChaincodeStubInterface, financingBidKey string, financingBid *FinancingBid) error {
+	var termsBytes []byte
+	var err error
+
+	termsBytes, err = json.Marshal(&BidTerms{financingBid.DiscountRate, financingBid.AdvanceRate})
+	if err != nil {
+		return errors.New("Error marshaling bid terms structure")
+	}
+	err = putPrivateData(stub, financingBidsCollection, financingBidKey, termsBytes)
+	if err != nil {
+		return err
+	}
+	financingBid.TermsHash = hashPrivateData(termsBytes)
+	return nil
+}
+
+func getBidTerms(stub shim.ChaincodeStubInterface, financingBidKey string, financingBid *FinancingBid) error {
+	var termsBytes []byte
+	var bidTerms BidTerms
+	var err error
+
+	termsBytes, err = getPrivateData(stub, financingBidsCollection, financingBidKey)
+	if err != nil {
+		return err
+	}
+	if len(termsBytes) == 0 {
+		return errors.New(fmt.Sprintf("No bid terms found for %s", financingBidKey))
+	}
+	err = json.Unmarshal(termsBytes, &bidTerms)
+	if err != nil {
+		return err
+	}
+	financingBid.DiscountRate = bidTerms.DiscountRate
+	financingBid.AdvanceRate = bidTerms.AdvanceRate
+	return nil
+}
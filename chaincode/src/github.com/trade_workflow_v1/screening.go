@@ -0,0 +1,583 @@
+/*
+ * Copyright 2018 IBM All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the 'License');
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an 'AS IS' BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// Names are similar enough to match if at most 15% of their characters must be edited to turn one into the other
+const nameMatchThreshold = 0.85
+
+var countryCodePattern = regexp.MustCompile("^[A-Z]{2}$")
+
+var nonAlphanumericPattern = regexp.MustCompile("[^A-Z0-9]+")
+
+// Legal forms and filler words that do not distinguish one party from another
+var nameStopWords = map[string]bool{
+	"THE": true, "AND": true, "OF": true,
+	"INC": true, "INCORPORATED": true, "CORP": true, "CORPORATION": true, "CO": true, "COMPANY": true,
+	"LTD": true, "LIMITED": true, "LLC": true, "PLC": true, "GMBH": true, "AG": true, "SA": true, "BV": true, "NV": true,
+}
+
+// Upper case words, without punctuation or stop words, e.g., "The Lumber Co., Inc." becomes "LUMBER"
+func normalizeName(name string) string {
+	var words []string
+
+	for _, word := range strings.Fields(nonAlphanumericPattern.ReplaceAllString(strings.ToUpper(name), " ")) {
+		if !nameStopWords[word] {
+			words = append(words, word)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// Number of single-character insertions, deletions and substitutions that turn one string into the other
+func levenshteinDistance(a string, b string) int {
+	var ra, rb []rune
+	var previous, current []int
+
+	ra, rb = []rune(a), []rune(b)
+	previous = make([]int, len(rb) + 1)
+	current = make([]int, len(rb) + 1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			current[j] = previous[j - 1]
+			if ra[i - 1] != rb[j - 1] {
+				current[j]++
+			}
+			if previous[j] + 1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if current[j - 1] + 1 < current[j] {
+				current[j] = current[j - 1] + 1
+			}
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+func nameSimilarity(a string, b string) float64 {
+	var length int
+
+	length = len([]rune(a))
+	if len([]rune(b)) > length {
+		length = len([]rune(b))
+	}
+	if length == 0 {
+		return 0
+	}
+	return 1 - float64(levenshteinDistance(a, b)) / float64(length)
+}
+
+// Fuzzy match of party or port names: tolerant of case, punctuation, legal forms, word order, spacing and small misspellings
+func namesMatch(listed string, presented string) bool {
+	var a, b string
+	var wordsA, wordsB []string
+
+	a, b = normalizeName(listed), normalizeName(presented)
+	if a == "" || b == "" {
+		return false
+	}
+	if nameSimilarity(a, b) >= nameMatchThreshold {
+		return true
+	}
+
+	// Compare the words in alphabetical order, and without the spaces between them
+	wordsA, wordsB = strings.Fields(a), strings.Fields(b)
+	sort.Strings(wordsA)
+	sort.Strings(wordsB)
+	if nameSimilarity(strings.Join(wordsA, " "), strings.Join(wordsB, " ")) >= nameMatchThreshold {
+		return true
+	}
+	if nameSimilarity(strings.Replace(a, " ", "", -1), strings.Replace(b, " ", "", -1)) >= nameMatchThreshold {
+		return true
+	}
+
+	// A legal form run into the name, e.g., "LenderInc", is not recognized as a word; compare the names with it kept
+	return nameSimilarity(nonAlphanumericPattern.ReplaceAllString(strings.ToUpper(listed), ""), nonAlphanumericPattern.ReplaceAllString(strings.ToUpper(presented), "")) >= nameMatchThreshold
+}
+
+// Check a screening entry and normalize its value
+func parseScreeningEntry(entryType string, value string, action string) (*ScreeningEntry, error) {
+	var err error
+
+	entryType = strings.ToUpper(entryType)
+	action = strings.ToUpper(action)
+	value = strings.TrimSpace(value)
+	if action != BLOCK && action != HOLD {
+		return nil, errors.New(fmt.Sprintf("Invalid screening action %s; Permissible values: {BLOCK, HOLD}", action))
+	}
+
+	if entryType == PARTY {
+		if normalizeName(value) == "" {
+			return nil, errors.New(fmt.Sprintf("Party name %s has no distinguishing words", value))
+		}
+	} else if entryType == COUNTRY {
+		value = strings.ToUpper(value)
+		if !countryCodePattern.MatchString(value) {
+			return nil, errors.New(fmt.Sprintf("Invalid country %s; expecting an ISO 3166 alpha-2 code", value))
+		}
+	} else if entryType == PORT {
+		if locode, err := parseLocode(value); err == nil {
+			value = locode
+		} else if normalizeName(value) == "" {
+			return nil, errors.New(fmt.Sprintf("Port name %s has no distinguishing words", value))
+		}
+	} else if entryType == HS_CODE {
+		value, err = parseHsCode(value, 2)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, errors.New(fmt.Sprintf("Invalid screening entry type %s; Permissible values: {PARTY, COUNTRY, PORT, HS_CODE}", entryType))
+	}
+	return &ScreeningEntry{entryType, value, action}, nil
+}
+
+// Names listed in different spellings, e.g., "Acme Ltd" and "ACME Limited", are the same entry
+func sameScreeningEntry(a ScreeningEntry, b ScreeningEntry) bool {
+	if a.Type != b.Type {
+		return false
+	}
+	if a.Type == PARTY || a.Type == PORT {
+		return normalizeName(a.Value) == normalizeName(b.Value)
+	}
+	return a.Value == b.Value
+}
+
+// Returns an empty list if the Regulator has not listed anything
+func getScreeningList(stub shim.ChaincodeStubInterface) (*ScreeningList, error) {
+	var screeningListBytes []byte
+	var screeningList *ScreeningList
+	var err error
+
+	// Lookup screening list from the ledger
+	screeningListBytes, err = stub.GetState(screeningListKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(screeningListBytes) == 0 {
+		return &ScreeningList{[]ScreeningEntry{}}, nil
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(screeningListBytes, &screeningList)
+	if err != nil {
+		return nil, err
+	}
+	return screeningList, nil
+}
+
+func putScreeningList(stub shim.ChaincodeStubInterface, screeningList *ScreeningList) error {
+	var screeningListBytes []byte
+	var err error
+
+	screeningListBytes, err = json.Marshal(screeningList)
+	if err != nil {
+		return errors.New("Error marshaling screening list structure")
+	}
+	// Write the state to the ledger
+	return stub.PutState(screeningListKey, screeningListBytes)
+}
+
+// Returns nil if the trade has never been put on hold
+func getComplianceHoldRecord(stub shim.ChaincodeStubInterface, tradeID string) (string, *ComplianceHold, error) {
+	var complianceHoldKey string
+	var complianceHoldBytes []byte
+	var complianceHold *ComplianceHold
+	var err error
+
+	// Lookup compliance hold from the ledger
+	complianceHoldKey, err = getComplianceHoldKey(stub, tradeID)
+	if err != nil {
+		return "", nil, err
+	}
+	complianceHoldBytes, err = stub.GetState(complianceHoldKey)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(complianceHoldBytes) == 0 {
+		return complianceHoldKey, nil, nil
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(complianceHoldBytes, &complianceHold)
+	if err != nil {
+		return "", nil, err
+	}
+	return complianceHoldKey, complianceHold, nil
+}
+
+func putComplianceHoldRecord(stub shim.ChaincodeStubInterface, complianceHoldKey string, complianceHold *ComplianceHold) error {
+	var complianceHoldBytes []byte
+	var err error
+
+	complianceHoldBytes, err = json.Marshal(complianceHold)
+	if err != nil {
+		return errors.New("Error marshaling compliance hold structure")
+	}
+	// Write the state to the ledger
+	return stub.PutState(complianceHoldKey, complianceHoldBytes)
+}
+
+// A trade on compliance hold is frozen until the Regulator clears it
+func checkComplianceHold(stub shim.ChaincodeStubInterface, tradeID string) error {
+	var complianceHold *ComplianceHold
+	var err error
+
+	_, complianceHold, err = getComplianceHoldRecord(stub, tradeID)
+	if err != nil {
+		return err
+	}
+	if complianceHold != nil && complianceHold.Status == COMPLIANCE_HOLD {
+		return errors.New(fmt.Sprintf("Trade %s is on compliance hold pending review by the Regulator", tradeID))
+	}
+	return nil
+}
+
+// Names of the participants recorded for the given roles
+func getRoleNames(stub shim.ChaincodeStubInterface, roleKeys ...string) ([]string, error) {
+	var names []string
+	var nameBytes []byte
+	var err error
+
+	for _, roleKey := range roleKeys {
+		nameBytes, err = stub.GetState(roleKey)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, string(nameBytes))
+	}
+	return names, nil
+}
+
+// Ports of loading and discharge, and the ports on the shipment's planned route
+func getShipmentPorts(shipment *Shipment, ports ...string) []string {
+	if shipment != nil {
+		for _, leg := range shipment.Legs {
+			ports = append(ports, leg.Origin, leg.Destination)
+		}
+	}
+	return ports
+}
+
+// HS code of the goods, if the Exporter classified them when requesting the E/L
+func getExportLicenseHsCodes(exportLicense *ExportLicense) []string {
+	if exportLicense == nil || exportLicense.HsCode == "" {
+		return nil
+	}
+	return []string{exportLicense.HsCode}
+}
+
+func matchScreeningEntry(entry ScreeningEntry, function string, parties []string, ports []string, hsCodes []string) []ScreeningMatch {
+	var matches []ScreeningMatch
+	var locode, hsCode string
+	var err error
+
+	if entry.Type == PARTY {
+		for _, party := range parties {
+			if namesMatch(entry.Value, party) {
+				matches = append(matches, ScreeningMatch{entry.Type, entry.Value, party, function})
+			}
+		}
+	} else if entry.Type == PORT || entry.Type == COUNTRY {
+		for _, port := range ports {
+			locode, err = parseLocode(port)
+			if err != nil {
+				locode = ""
+			}
+			if entry.Type == COUNTRY && locode != "" && locode[:2] == entry.Value {
+				matches = append(matches, ScreeningMatch{entry.Type, entry.Value, port, function})
+			} else if entry.Type == PORT && (locode == entry.Value || (locode == "" && namesMatch(entry.Value, port))) {
+				matches = append(matches, ScreeningMatch{entry.Type, entry.Value, port, function})
+			}
+		}
+	} else if entry.Type == HS_CODE {
+		for _, code := range hsCodes {
+			hsCode, err = parseHsCode(code, 2)
+			if err == nil && strings.HasPrefix(hsCode, entry.Value) {
+				matches = append(matches, ScreeningMatch{entry.Type, entry.Value, code, function})
+			}
+		}
+	}
+	return matches
+}
+
+// Screen the parties, ports and HS codes presented by a transaction on a trade against the Regulator's list
+// A BLOCK match refuses the transaction; a HOLD match not already cleared by the Regulator puts the trade on hold
+func screenTrade(stub shim.ChaincodeStubInterface, tradeID string, function string, parties []string, ports []string, hsCodes []string) error {
+	var complianceHoldKey string
+	var screeningList *ScreeningList
+	var complianceHold *ComplianceHold
+	var matches, held []ScreeningMatch
+	var recorded bool
+	var err error
+
+	screeningList, err = getScreeningList(stub)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range screeningList.Entries {
+		matches = matchScreeningEntry(entry, function, parties, ports, hsCodes)
+		if len(matches) > 0 && entry.Action == BLOCK {
+			fmt.Printf("Trade %s blocked by screening: %s %s matches %s\n", tradeID, matches[0].Type, matches[0].Entry, matches[0].Value)
+			return errors.New(fmt.Sprintf("Restricted %s: %s matches %s", strings.ToLower(strings.Replace(matches[0].Type, "_", " ", -1)), matches[0].Value, matches[0].Entry))
+		}
+		held = append(held, matches...)
+	}
+	if len(held) == 0 {
+		return nil
+	}
+
+	complianceHoldKey, complianceHold, err = getComplianceHoldRecord(stub, tradeID)
+	if err != nil {
+		return err
+	}
+	if complianceHold == nil {
+		complianceHold = &ComplianceHold{tradeID, CLEARED, []ScreeningMatch{}, ""}
+	}
+	for _, match := range held {
+		recorded = false
+		for _, previous := range complianceHold.Matches {
+			if previous.Type == match.Type && previous.Entry == match.Entry && previous.Value == match.Value {
+				recorded = true
+			}
+		}
+		if !recorded {
+			complianceHold.Matches = append(complianceHold.Matches, match)
+			complianceHold.Status = COMPLIANCE_HOLD
+			complianceHold.Reason = ""
+		}
+	}
+	if complianceHold.Status != COMPLIANCE_HOLD {
+		return nil
+	}
+
+	err = putComplianceHoldRecord(stub, complianceHoldKey, complianceHold)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Trade %s put on compliance hold by %s\n", tradeID, function)
+
+	return nil
+}
+
+// Add a party, country, port or HS code to the screening list, or change the action on one already listed
+func (t *TradeWorkflowChaincode) addScreeningEntry(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var screeningList *ScreeningList
+	var entry *ScreeningEntry
+	var listed bool
+	var err error
+
+	// Access control: Only a Regulator Org member can invoke this transaction
+	if !t.testMode && !authenticateRegulatorOrg(creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a member of Regulator Org. Access denied.")
+	}
+
+	if len(args) != 3 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 3: {Type, Value, Action}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+
+	entry, err = parseScreeningEntry(args[0], args[1], args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	screeningList, err = getScreeningList(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	for i := range screeningList.Entries {
+		if sameScreeningEntry(screeningList.Entries[i], *entry) {
+			screeningList.Entries[i] = *entry
+			listed = true
+		}
+	}
+	if !listed {
+		screeningList.Entries = append(screeningList.Entries, *entry)
+	}
+
+	err = putScreeningList(stub, screeningList)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Screening entry %s %s recorded\n", entry.Type, entry.Value)
+
+	return shim.Success(nil)
+}
+
+func (t *TradeWorkflowChaincode) removeScreeningEntry(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var screeningList *ScreeningList
+	var entry *ScreeningEntry
+	var entries []ScreeningEntry
+	var err error
+
+	// Access control: Only a Regulator Org member can invoke this transaction
+	if !t.testMode && !authenticateRegulatorOrg(creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a member of Regulator Org. Access denied.")
+	}
+
+	if len(args) != 2 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 2: {Type, Value}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+
+	entry, err = parseScreeningEntry(args[0], args[1], BLOCK)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	screeningList, err = getScreeningList(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	entries = []ScreeningEntry{}
+	for _, listed := range screeningList.Entries {
+		if !sameScreeningEntry(listed, *entry) {
+			entries = append(entries, listed)
+		}
+	}
+	if len(entries) == len(screeningList.Entries) {
+		err = errors.New(fmt.Sprintf("Screening entry %s %s not found", entry.Type, entry.Value))
+		return shim.Error(err.Error())
+	}
+	screeningList.Entries = entries
+
+	err = putScreeningList(stub, screeningList)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Screening entry %s %s removed\n", entry.Type, entry.Value)
+
+	return shim.Success(nil)
+}
+
+// Release a trade from compliance hold after review, with the reason
+func (t *TradeWorkflowChaincode) clearComplianceHold(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var complianceHoldKey string
+	var complianceHold *ComplianceHold
+	var err error
+
+	// Access control: Only a Regulator Org member can invoke this transaction
+	if !t.testMode && !authenticateRegulatorOrg(creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a member of Regulator Org. Access denied.")
+	}
+
+	if len(args) != 2 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 2: {Trade ID, Reason}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+	if args[1] == "" {
+		return shim.Error("Reason must be non-empty")
+	}
+
+	complianceHoldKey, complianceHold, err = getComplianceHoldRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if complianceHold == nil || complianceHold.Status != COMPLIANCE_HOLD {
+		fmt.Printf("Trade %s is not on compliance hold\n", args[0])
+		return shim.Error("Trade not on compliance hold")
+	}
+
+	complianceHold.Status = CLEARED
+	complianceHold.Reason = args[1]
+	err = putComplianceHoldRecord(stub, complianceHoldKey, complianceHold)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Compliance hold on trade %s cleared\n", args[0])
+
+	return shim.Success(nil)
+}
+
+// Get the screening list; it is published to all members so that they can screen their own counterparties
+func (t *TradeWorkflowChaincode) getScreeningListEntries(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var screeningList *ScreeningList
+	var screeningListBytes []byte
+	var err error
+
+	if len(args) != 0 {
+		return shim.Error("Incorrect number of arguments. Expecting 0")
+	}
+
+	screeningList, err = getScreeningList(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	screeningListBytes, err = json.Marshal(screeningList)
+	if err != nil {
+		return shim.Error("Error marshaling screening list structure")
+	}
+	fmt.Printf("Query Response:%s\n", string(screeningListBytes))
+	return shim.Success(screeningListBytes)
+}
+
+// Get the compliance hold on a trade, and the screening matches that caused it
+func (t *TradeWorkflowChaincode) getComplianceHold(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var complianceHoldKey, jsonResp string
+	var complianceHoldBytes []byte
+	var err error
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1: <trade ID>")
+	}
+
+	// Access control: Only the trade's participants, or a regulator for its jurisdiction, can invoke this transaction
+	if !t.testMode {
+		err = authorizeTradeAccess(stub, creatorOrg, creatorCertIssuer, args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	// Get the state from the ledger
+	complianceHoldKey, err = getComplianceHoldKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	complianceHoldBytes, err = stub.GetState(complianceHoldKey)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Failed to get state for " + complianceHoldKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+
+	if len(complianceHoldBytes) == 0 {
+		jsonResp = "{\"Error\":\"No record found for " + complianceHoldKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+	fmt.Printf("Query Response:%s\n", string(complianceHoldBytes))
+	return shim.Success(complianceHoldBytes)
+}
@@ -0,0 +1,488 @@
+/*
+ * Copyright 2018 IBM All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the 'License');
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an 'AS IS' BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// UN/LOCODE: ISO 3166 country code followed by a 3-character location code (letters and digits 2-9)
+var locodePattern = regexp.MustCompile("^[A-Z]{2}[A-Z2-9]{3}$")
+
+var checkpointStatuses = map[string]bool{
+	LOADED:     true,
+	DEPARTED:   true,
+	ARRIVED:    true,
+	DISCHARGED: true,
+}
+
+// Accepts both the "NLRTM" and the "NL RTM" forms of a UN/LOCODE
+func parseLocode(locode string) (string, error) {
+	var normalized string
+
+	normalized = strings.ToUpper(strings.Replace(locode, " ", "", 1))
+	if !locodePattern.MatchString(normalized) {
+		return "", errors.New(fmt.Sprintf("Invalid UN/LOCODE %s", locode))
+	}
+	return normalized, nil
+}
+
+// The delivery window must be a valid range of dates
+func checkDeliveryWindow(deliveryWindowStart string, deliveryWindowEnd string) error {
+	var start, end time.Time
+	var err error
+
+	start, err = time.Parse(dateLayout, deliveryWindowStart)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Invalid delivery window start %s; expecting MM/DD/YYYY", deliveryWindowStart))
+	}
+	end, err = time.Parse(dateLayout, deliveryWindowEnd)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Invalid delivery window end %s; expecting MM/DD/YYYY", deliveryWindowEnd))
+	}
+	if end.Before(start) {
+		return errors.New("Delivery window ends before it starts")
+	}
+	return nil
+}
+
+// Days by which an arrival date falls after the end of the trade's delivery window; 0 if the trade has none
+// Returns the end of the delivery window as well
+func getDeliveryDelay(stub shim.ChaincodeStubInterface, tradeID string, arrivalDate string) (string, int, error) {
+	var tradeKey string
+	var tradeAgreementBytes []byte
+	var tradeAgreement *TradeAgreement
+	var deliveryWindowEnd, arrival time.Time
+	var err error
+
+	// Lookup trade agreement from the ledger
+	tradeKey, err = getTradeKey(stub, tradeID)
+	if err != nil {
+		return "", 0, err
+	}
+	tradeAgreementBytes, err = stub.GetState(tradeKey)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if len(tradeAgreementBytes) == 0 {
+		return "", 0, errors.New(fmt.Sprintf("No record found for trade ID %s", tradeID))
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(tradeAgreementBytes, &tradeAgreement)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if tradeAgreement.DeliveryWindowEnd == "" {
+		return "", 0, nil
+	}
+	deliveryWindowEnd, err = time.Parse(dateLayout, tradeAgreement.DeliveryWindowEnd)
+	if err != nil {
+		return "", 0, err
+	}
+	arrival, err = time.Parse(dateLayout, arrivalDate)
+	if err != nil {
+		return "", 0, err
+	}
+	if !arrival.After(deliveryWindowEnd) {
+		return tradeAgreement.DeliveryWindowEnd, 0, nil
+	}
+	return tradeAgreement.DeliveryWindowEnd, int(arrival.Sub(deliveryWindowEnd).Hours() / 24), nil
+}
+
+func emitShipmentDelayed(stub shim.ChaincodeStubInterface, tradeID string, deliveryWindowEnd string, arrivalDate string, estimated bool, delayDays int) error {
+	var eventBytes []byte
+	var err error
+
+	eventBytes, err = json.Marshal(&ShipmentDelayedEvent{tradeID, deliveryWindowEnd, arrivalDate, estimated, delayDays})
+	if err != nil {
+		return errors.New("Error marshaling shipment delay event")
+	}
+	fmt.Printf("Shipment for trade %s is %d days late\n", tradeID, delayDays)
+	return stub.SetEvent(shipmentDelayedEvent, eventBytes)
+}
+
+// Returns a nil shipment if neither a leg nor an ETA has been recorded for the trade
+func getShipmentRecord(stub shim.ChaincodeStubInterface, tradeID string) (string, *Shipment, error) {
+	var shipmentKey string
+	var shipmentBytes []byte
+	var shipment *Shipment
+	var err error
+
+	// Lookup shipment from the ledger
+	shipmentKey, err = getShipmentKey(stub, tradeID)
+	if err != nil {
+		return "", nil, err
+	}
+	shipmentBytes, err = stub.GetState(shipmentKey)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(shipmentBytes) == 0 {
+		return shipmentKey, nil, nil
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(shipmentBytes, &shipment)
+	if err != nil {
+		return "", nil, err
+	}
+	return shipmentKey, shipment, nil
+}
+
+func putShipmentRecord(stub shim.ChaincodeStubInterface, shipmentKey string, shipment *Shipment) error {
+	var shipmentBytes []byte
+	var err error
+
+	shipmentBytes, err = json.Marshal(shipment)
+	if err != nil {
+		return errors.New("Error marshaling shipment structure")
+	}
+	// Write the state to the ledger
+	return stub.PutState(shipmentKey, shipmentBytes)
+}
+
+// Locations on a shipment's route, in order
+func getShipmentRoute(shipment *Shipment) []string {
+	var route []string
+
+	for i, leg := range shipment.Legs {
+		if i == 0 {
+			route = append(route, leg.Origin)
+		}
+		route = append(route, leg.Destination)
+	}
+	return route
+}
+
+// Add a leg to the shipment's route; each leg starts where the previous one ends
+func (t *TradeWorkflowChaincode) planShipmentLeg(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var shipmentKey, shipmentLocationKey, origin, destination string
+	var shipmentLocationBytes []byte
+	var shipment *Shipment
+	var err error
+
+	// Access control: Only a Carrier Org member can invoke this transaction
+	if !t.testMode && !authenticateCarrierOrg(creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a member of Carrier Org. Access denied.")
+	}
+
+	if len(args) != 5 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 5: {Trade ID, Origin UN/LOCODE, Destination UN/LOCODE, Vessel, Voyage}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+
+	origin, err = parseLocode(args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	destination, err = parseLocode(args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if origin == destination {
+		return shim.Error("Origin and destination of a leg must differ")
+	}
+
+	// Lookup shipment location from the ledger
+	shipmentLocationKey, err = getShipmentLocationKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	shipmentLocationBytes, err = stub.GetState(shipmentLocationKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(shipmentLocationBytes) == 0 {
+		fmt.Printf("Shipment for trade %s has not been prepared yet\n", args[0])
+		return shim.Error("Shipment not prepared yet")
+	}
+	if string(shipmentLocationBytes) == DESTINATION {
+		fmt.Printf("Shipment for trade %s has already reached its destination\n", args[0])
+		return shim.Error("Shipment already at destination")
+	}
+
+	shipmentKey, shipment, err = getShipmentRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if shipment == nil {
+		shipment = &Shipment{args[0], []ShipmentLeg{}, []ShipmentCheckpoint{}, nil, nil, nil, nil, nil}
+	} else if len(shipment.Legs) > 0 && shipment.Legs[len(shipment.Legs)-1].Destination != origin {
+		err = errors.New(fmt.Sprintf("Leg must start at %s, where the previous leg ends", shipment.Legs[len(shipment.Legs)-1].Destination))
+		return shim.Error(err.Error())
+	}
+
+	shipment.Legs = append(shipment.Legs, ShipmentLeg{origin, destination, args[3], args[4]})
+	err = putShipmentRecord(stub, shipmentKey, shipment)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Shipment leg %s-%s for trade %s recorded\n", origin, destination, args[0])
+
+	return shim.Success(nil)
+}
+
+// Record a checkpoint event on the shipment's route
+// Arrival at the end of the last leg moves the shipment to DESTINATION and records its arrival date
+func (t *TradeWorkflowChaincode) recordCheckpoint(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var shipmentKey, shipmentLocationKey, arrivalDateKey, location, arrivalDate, deliveryWindowEnd string
+	var shipmentLocationBytes []byte
+	var shipment *Shipment
+	var route []string
+	var timestamp, lastTimestamp time.Time
+	var onRoute bool
+	var delayDays int
+	var err error
+
+	// Access control: Only a Carrier Org member can invoke this transaction
+	if !t.testMode && !authenticateCarrierOrg(creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a member of Carrier Org. Access denied.")
+	}
+
+	if len(args) != 6 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 6: {Trade ID, UN/LOCODE, Timestamp, Vessel, Voyage, Status}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+
+	location, err = parseLocode(args[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	timestamp, err = time.Parse(time.RFC3339, args[2])
+	if err != nil {
+		err = errors.New(fmt.Sprintf("Invalid timestamp %s; expecting RFC 3339 format", args[2]))
+		return shim.Error(err.Error())
+	}
+	if !checkpointStatuses[args[5]] {
+		err = errors.New(fmt.Sprintf("Invalid checkpoint status %s; Permissible values: {LOADED, DEPARTED, ARRIVED, DISCHARGED}", args[5]))
+		return shim.Error(err.Error())
+	}
+
+	shipmentKey, shipment, err = getShipmentRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if shipment == nil || len(shipment.Legs) == 0 {
+		fmt.Printf("No legs planned for the shipment of trade %s\n", args[0])
+		return shim.Error("Shipment legs not planned")
+	}
+
+	// The checkpoint must be on the planned route, and in chronological order
+	route = getShipmentRoute(shipment)
+	for _, routeLocation := range route {
+		if routeLocation == location {
+			onRoute = true
+		}
+	}
+	if !onRoute {
+		err = errors.New(fmt.Sprintf("Location %s is not on the planned route of the shipment", location))
+		return shim.Error(err.Error())
+	}
+	if len(shipment.Checkpoints) > 0 {
+		lastTimestamp, err = time.Parse(time.RFC3339, shipment.Checkpoints[len(shipment.Checkpoints)-1].Timestamp)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if timestamp.Before(lastTimestamp) {
+			return shim.Error("Checkpoint precedes the last recorded checkpoint")
+		}
+	}
+
+	shipment.Checkpoints = append(shipment.Checkpoints, ShipmentCheckpoint{location, args[2], args[3], args[4], args[5]})
+	err = putShipmentRecord(stub, shipmentKey, shipment)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Shipment checkpoint %s at %s for trade %s recorded\n", args[5], location, args[0])
+
+	if args[5] != ARRIVED || location != route[len(route)-1] {
+		return shim.Success(nil)
+	}
+
+	// Lookup shipment location from the ledger
+	shipmentLocationKey, err = getShipmentLocationKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	shipmentLocationBytes, err = stub.GetState(shipmentLocationKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if string(shipmentLocationBytes) == DESTINATION {
+		fmt.Printf("Shipment for trade %s is already in location %s\n", args[0], DESTINATION)
+		return shim.Success(nil)
+	}
+
+	// Write the state to the ledger
+	arrivalDate = timestamp.Format(dateLayout)
+	arrivalDateKey, err = getArrivalDateKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = stub.PutState(arrivalDateKey, []byte(arrivalDate))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Shipment ArrivalDate for trade %s recorded\n", args[0])
+	err = stub.PutState(shipmentLocationKey, []byte(DESTINATION))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Shipment location for trade %s recorded\n", args[0])
+
+	// Report a late arrival
+	deliveryWindowEnd, delayDays, err = getDeliveryDelay(stub, args[0], arrivalDate)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if delayDays > 0 {
+		err = emitShipmentDelayed(stub, args[0], deliveryWindowEnd, arrivalDate, false, delayDays)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	return shim.Success(nil)
+}
+
+// Publish an estimated time of arrival, or an update to it; an ETA past the delivery window emits a delay event
+func (t *TradeWorkflowChaincode) updateShipmentETA(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var shipmentKey, shipmentLocationKey, arrivalDate, deliveryWindowEnd string
+	var shipmentLocationBytes []byte
+	var shipment *Shipment
+	var eta time.Time
+	var delayDays int
+	var err error
+
+	// Access control: Only a Carrier Org member can invoke this transaction
+	if !t.testMode && !authenticateCarrierOrg(creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a member of Carrier Org. Access denied.")
+	}
+
+	if len(args) != 2 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 2: {Trade ID, ETA}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+
+	eta, err = time.Parse(time.RFC3339, args[1])
+	if err != nil {
+		err = errors.New(fmt.Sprintf("Invalid ETA %s; expecting RFC 3339 format", args[1]))
+		return shim.Error(err.Error())
+	}
+
+	// Lookup shipment location from the ledger
+	shipmentLocationKey, err = getShipmentLocationKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	shipmentLocationBytes, err = stub.GetState(shipmentLocationKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(shipmentLocationBytes) == 0 {
+		fmt.Printf("Shipment for trade %s has not been prepared yet\n", args[0])
+		return shim.Error("Shipment not prepared yet")
+	}
+	if string(shipmentLocationBytes) == DESTINATION {
+		fmt.Printf("Shipment for trade %s has already reached its destination\n", args[0])
+		return shim.Error("Shipment already at destination")
+	}
+
+	shipmentKey, shipment, err = getShipmentRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if shipment == nil {
+		shipment = &Shipment{args[0], []ShipmentLeg{}, []ShipmentCheckpoint{}, nil, nil, nil, nil, nil}
+	}
+
+	arrivalDate = eta.Format(dateLayout)
+	deliveryWindowEnd, delayDays, err = getDeliveryDelay(stub, args[0], arrivalDate)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Only a change in the expected delay is reported
+	if delayDays > 0 && (len(shipment.EstimatedArrivals) == 0 || shipment.EstimatedArrivals[len(shipment.EstimatedArrivals)-1].DelayDays != delayDays) {
+		err = emitShipmentDelayed(stub, args[0], deliveryWindowEnd, arrivalDate, true, delayDays)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	shipment.EstimatedArrivals = append(shipment.EstimatedArrivals, EstimatedArrival{args[1], delayDays})
+	err = putShipmentRecord(stub, shipmentKey, shipment)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Shipment ETA %s for trade %s recorded\n", args[1], args[0])
+
+	return shim.Success(nil)
+}
+
+// Get the leg plan and checkpoints of a shipment
+func (t *TradeWorkflowChaincode) getShipment(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var shipmentKey, jsonResp string
+	var shipmentBytes []byte
+	var err error
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1: <trade ID>")
+	}
+
+	// Access control: Only the trade's participants, or a regulator for its jurisdiction, can invoke this transaction
+	if !t.testMode {
+		err = authorizeTradeAccess(stub, creatorOrg, creatorCertIssuer, args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	// Get the state from the ledger
+	shipmentKey, err = getShipmentKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	shipmentBytes, err = stub.GetState(shipmentKey)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Failed to get state for " + shipmentKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+
+	if len(shipmentBytes) == 0 {
+		jsonResp = "{\"Error\":\"No record found for " + shipmentKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+
+	fmt.Printf("Query Response:%s\n", string(shipmentBytes))
+	return shim.Success(shipmentBytes)
+}
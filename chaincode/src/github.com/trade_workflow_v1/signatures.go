@@ -0,0 +1,287 @@
+/*
+ * Copyright 2018 IBM All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the 'License');
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an 'AS IS' BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// ECDSA signatures are ASN.1 encoded, as produced by Fabric SDKs and the BCCSP
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// The content an issuer signs: the asset's JSON without its signature fields
+// Title to a B/L changes hands after issuance, so holder, status and endorsements are not signed
+func canonicalBillOfLading(billOfLading *BillOfLading) ([]byte, error) {
+	var content BillOfLading
+
+	content = *billOfLading
+	content.Holder = ""
+	content.HolderOrg = ""
+	content.HolderSubject = ""
+	content.Status = ""
+	content.Endorsements = nil
+	content.Signature = ""
+	content.SignerCertificate = ""
+	return json.Marshal(&content)
+}
+
+// A signed E/L may later be revoked, so status and reason are not signed either
+func canonicalExportLicense(exportLicense *ExportLicense) ([]byte, error) {
+	var content ExportLicense
+
+	content = *exportLicense
+	content.Status = ""
+	content.Reason = ""
+	content.Signature = ""
+	content.SignerCertificate = ""
+	return json.Marshal(&content)
+}
+
+// Quota is drawn down after issuance, so the amounts drawn and the drawdowns are not signed either
+func canonicalStandaloneExportLicense(license *StandaloneExportLicense) ([]byte, error) {
+	var content StandaloneExportLicense
+
+	content = *license
+	content.Status = ""
+	content.Reason = ""
+	content.QuantityDrawn = 0
+	content.ValueDrawn = 0
+	content.Drawdowns = nil
+	content.Signature = ""
+	content.SignerCertificate = ""
+	content.TermsHash = ""
+	return json.Marshal(&content)
+}
+
+func verifyContentSignature(cert *x509.Certificate, content []byte, signature []byte) error {
+	var publicKey *ecdsa.PublicKey
+	var parsedSignature ecdsaSignature
+	var ok bool
+	var err error
+
+	publicKey, ok = cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return errors.New("Signer certificate does not hold an ECDSA public key")
+	}
+	_, err = asn1.Unmarshal(signature, &parsedSignature)
+	if err != nil || parsedSignature.R == nil || parsedSignature.S == nil {
+		return errors.New("Malformed signature")
+	}
+
+	digest := sha256.Sum256(content)
+	if !ecdsa.Verify(publicKey, digest[:], parsedSignature.R, parsedSignature.S) {
+		return errors.New("Signature does not match the document content and the signer's certificate")
+	}
+	return nil
+}
+
+// Verify the signature passed in the transient map against the transaction creator's certificate
+// Returns the signature (base64) and the creator's certificate (PEM) to be stored with the asset
+// In test mode an unsigned asset is accepted
+func signContent(stub shim.ChaincodeStubInterface, testMode bool, content []byte) (string, string, error) {
+	var transientMap map[string][]byte
+	var signature []byte
+	var cert *x509.Certificate
+	var err error
+
+	transientMap, err = stub.GetTransient()
+	if err != nil {
+		return "", "", err
+	}
+	signature = transientMap["signature"]
+	if len(signature) == 0 {
+		if testMode {
+			return "", "", nil
+		}
+		return "", "", errors.New("Transient field signature is missing")
+	}
+
+	cert, err = cid.GetX509Certificate(stub)
+	if err != nil {
+		fmt.Printf("Error getting client certificate: %s\n", err.Error())
+		return "", "", err
+	}
+	err = verifyContentSignature(cert, content, signature)
+	if err != nil {
+		return "", "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(signature), string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})), nil
+}
+
+func signBillOfLading(stub shim.ChaincodeStubInterface, testMode bool, billOfLading *BillOfLading) error {
+	var content []byte
+	var err error
+
+	content, err = canonicalBillOfLading(billOfLading)
+	if err != nil {
+		return errors.New("Error marshaling bill of lading structure")
+	}
+	billOfLading.Signature, billOfLading.SignerCertificate, err = signContent(stub, testMode, content)
+	return err
+}
+
+func signExportLicense(stub shim.ChaincodeStubInterface, testMode bool, exportLicense *ExportLicense) error {
+	var content []byte
+	var err error
+
+	content, err = canonicalExportLicense(exportLicense)
+	if err != nil {
+		return errors.New("Error marshaling E/L structure")
+	}
+	exportLicense.Signature, exportLicense.SignerCertificate, err = signContent(stub, testMode, content)
+	return err
+}
+
+func signStandaloneExportLicense(stub shim.ChaincodeStubInterface, testMode bool, license *StandaloneExportLicense) error {
+	var content []byte
+	var err error
+
+	content, err = canonicalStandaloneExportLicense(license)
+	if err != nil {
+		return errors.New("Error marshaling standalone E/L structure")
+	}
+	license.Signature, license.SignerCertificate, err = signContent(stub, testMode, content)
+	return err
+}
+
+// Verify the issuer's signature on a B/L or E/L, and that it covers the content whose hash is supplied
+// Open to parties outside the trade (e.g., insurers, customs); no document content is revealed
+func (t *TradeWorkflowChaincode) verifySignature(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var assetKey, assetID, signature, signerCertificate, hash, jsonResp string
+	var assetBytes, content, signatureBytes []byte
+	var billOfLading *BillOfLading
+	var exportLicense *ExportLicense
+	var license *StandaloneExportLicense
+	var block *pem.Block
+	var cert *x509.Certificate
+	var verified bool
+	var err error
+
+	if len(args) != 3 && len(args) != 4 {
+		return shim.Error("Incorrect number of arguments. Expecting 3: {Trade ID, Asset Type, SHA-256 of Content}, with an optional Shipment ID (for a B/L) or License ID (for a standalone E/L) after the Trade ID")
+	}
+	if len(args) == 4 {
+		assetID = args[1]
+		args = []string{args[0], args[2], args[3]}
+		if assetID == "" {
+			return shim.Error("Shipment ID or License ID must be non-empty")
+		}
+	}
+
+	hash, err = parseDocumentHash(args[2])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Get the state from the ledger
+	// A B/L is that of the trade, or of one of its partial shipments; an E/L is the trade's own, or the standalone E/L it ships under
+	if args[1] == "BillOfLading" {
+		assetKey, err = getTradeBLKey(stub, args[0], assetID)
+	} else if args[1] == "ExportLicense" && assetID == "" {
+		assetKey, err = getELKey(stub, args[0])
+	} else if args[1] == "ExportLicense" {
+		_, exportLicense, err = getExportLicenseRecord(stub, args[0])
+		if err == nil && (!exportLicense.Standalone || exportLicense.Id != assetID) {
+			err = errors.New(fmt.Sprintf("Trade %s does not ship under standalone E/L %s", args[0], assetID))
+		}
+		if err == nil {
+			assetKey, err = getStandaloneELKey(stub, assetID)
+		}
+	} else {
+		err = errors.New(fmt.Sprintf("Invalid asset type %s; Permissible values: {BillOfLading, ExportLicense}", args[1]))
+	}
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	assetBytes, err = stub.GetState(assetKey)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Failed to get state for " + assetKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+
+	if len(assetBytes) == 0 {
+		jsonResp = "{\"Error\":\"No record found for " + assetKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+
+	// Unmarshal the JSON
+	if args[1] == "BillOfLading" {
+		err = json.Unmarshal(assetBytes, &billOfLading)
+		if err == nil {
+			content, err = canonicalBillOfLading(billOfLading)
+			signature, signerCertificate = billOfLading.Signature, billOfLading.SignerCertificate
+		}
+	} else if assetID == "" {
+		err = json.Unmarshal(assetBytes, &exportLicense)
+		if err == nil {
+			content, err = canonicalExportLicense(exportLicense)
+			signature, signerCertificate = exportLicense.Signature, exportLicense.SignerCertificate
+		}
+	} else {
+		err = json.Unmarshal(assetBytes, &license)
+		if err == nil {
+			content, err = canonicalStandaloneExportLicense(license)
+			signature, signerCertificate = license.Signature, license.SignerCertificate
+		}
+	}
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if signature == "" {
+		err = errors.New(fmt.Sprintf("%s for trade %s is not signed", args[1], args[0]))
+		return shim.Error(err.Error())
+	}
+
+	// Verify the stored signature against the stored signer certificate
+	signatureBytes, err = base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	block, _ = pem.Decode([]byte(signerCertificate))
+	if block == nil {
+		return shim.Error("Malformed signer certificate")
+	}
+	cert, err = x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	contentHash := sha256.Sum256(content)
+	verified = verifyContentSignature(cert, content, signatureBytes) == nil && hex.EncodeToString(contentHash[:]) == hash
+
+	jsonResp = "{\"Verified\":\"" + strconv.FormatBool(verified) + "\",\"Signer\":\"" + getCertificateSubject(cert) + "\",\"Issuer\":\"" + cert.Issuer.CommonName + "\"}"
+	fmt.Printf("Query Response:%s\n", jsonResp)
+	return shim.Success([]byte(jsonResp))
+}
@@ -0,0 +1,475 @@
+/*
+ * Copyright 2018 IBM All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the 'License');
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an 'AS IS' BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// Upper bound on the readings in one batch, to keep transactions small
+const maxTelemetryBatchSize = 1000
+
+// Set the cold-chain thresholds of a trade; the exporter agrees to them by accepting the trade
+func (t *TradeWorkflowChaincode) setTelemetryThresholds(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var tradeKey string
+	var tradeAgreementBytes []byte
+	var tradeAgreement *TradeAgreement
+	var limits [4]float64
+	var err error
+
+	// Access control: Only an Importer Org member can invoke this transaction
+	if !t.testMode && !authenticateImporterOrg(creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a member of Importer Org. Access denied.")
+	}
+
+	if len(args) != 5 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 5: {Trade ID, Min Temperature, Max Temperature, Min Humidity, Max Humidity}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+
+	for i := range limits {
+		limits[i], err = strconv.ParseFloat(args[i+1], 64)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+	if limits[0] > limits[1] || limits[2] > limits[3] {
+		return shim.Error("Minimum threshold exceeds maximum threshold")
+	}
+	if limits[2] < 0 || limits[3] > 100 {
+		return shim.Error("Humidity thresholds must be between 0 and 100 percent")
+	}
+
+	// Lookup trade agreement from the ledger
+	tradeKey, err = getTradeKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	tradeAgreementBytes, err = stub.GetState(tradeKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(tradeAgreementBytes) == 0 {
+		err = errors.New(fmt.Sprintf("No record found for trade ID %s", args[0]))
+		return shim.Error(err.Error())
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(tradeAgreementBytes, &tradeAgreement)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if tradeAgreement.Status != REQUESTED {
+		fmt.Printf("Trade %s has already been accepted\n", args[0])
+		return shim.Error("Thresholds can only be set before the trade is accepted")
+	}
+
+	tradeAgreement.TelemetryThresholds = &TelemetryThresholds{limits[0], limits[1], limits[2], limits[3]}
+	tradeAgreementBytes, err = json.Marshal(tradeAgreement)
+	if err != nil {
+		return shim.Error("Error marshaling trade agreement structure")
+	}
+	// Write the state to the ledger
+	err = stub.PutState(tradeKey, tradeAgreementBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Telemetry thresholds for trade %s recorded\n", args[0])
+
+	return shim.Success(nil)
+}
+
+// Register a device to report telemetry for a shipment; the Device ID is the common name of the device's certificate
+func (t *TradeWorkflowChaincode) registerDevice(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var shipmentKey, shipmentLocationKey string
+	var shipmentLocationBytes []byte
+	var shipment *Shipment
+	var err error
+
+	// Access control: Only a Carrier Org member can invoke this transaction
+	if !t.testMode && !authenticateCarrierOrg(creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a member of Carrier Org. Access denied.")
+	}
+
+	if len(args) != 2 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 2: {Trade ID, Device ID}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+	if args[1] == "" {
+		return shim.Error("Device ID must be non-empty")
+	}
+
+	// Lookup shipment location from the ledger
+	shipmentLocationKey, err = getShipmentLocationKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	shipmentLocationBytes, err = stub.GetState(shipmentLocationKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(shipmentLocationBytes) == 0 {
+		fmt.Printf("Shipment for trade %s has not been prepared yet\n", args[0])
+		return shim.Error("Shipment not prepared yet")
+	}
+
+	shipmentKey, shipment, err = getShipmentRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if shipment == nil {
+		shipment = &Shipment{args[0], []ShipmentLeg{}, []ShipmentCheckpoint{}, nil, nil, nil, nil, nil}
+	}
+	for _, device := range shipment.Devices {
+		if device == args[1] {
+			fmt.Printf("Device %s already registered for trade %s\n", args[1], args[0])
+			return shim.Success(nil)
+		}
+	}
+
+	shipment.Devices = append(shipment.Devices, args[1])
+	err = putShipmentRecord(stub, shipmentKey, shipment)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	fmt.Printf("Device %s for trade %s recorded\n", args[1], args[0])
+
+	return shim.Success(nil)
+}
+
+// Compare a reading against the thresholds; returns the incidents it raises
+func checkTelemetryReading(thresholds *TelemetryThresholds, deviceID string, reading TelemetryReading) []ShipmentIncident {
+	var incidents []ShipmentIncident
+
+	if thresholds == nil {
+		return nil
+	}
+	if reading.Temperature < thresholds.MinTemperature {
+		incidents = append(incidents, ShipmentIncident{TEMPERATURE_EXCURSION, deviceID, reading.Timestamp, reading.Temperature, thresholds.MinTemperature})
+	} else if reading.Temperature > thresholds.MaxTemperature {
+		incidents = append(incidents, ShipmentIncident{TEMPERATURE_EXCURSION, deviceID, reading.Timestamp, reading.Temperature, thresholds.MaxTemperature})
+	}
+	if reading.Humidity < thresholds.MinHumidity {
+		incidents = append(incidents, ShipmentIncident{HUMIDITY_EXCURSION, deviceID, reading.Timestamp, reading.Humidity, thresholds.MinHumidity})
+	} else if reading.Humidity > thresholds.MaxHumidity {
+		incidents = append(incidents, ShipmentIncident{HUMIDITY_EXCURSION, deviceID, reading.Timestamp, reading.Humidity, thresholds.MaxHumidity})
+	}
+	return incidents
+}
+
+// Fold a reading into the running summary
+func addToTelemetrySummary(summary *TelemetrySummary, reading TelemetryReading, timestamp time.Time) error {
+	var first, last time.Time
+	var err error
+
+	if summary.Readings == 0 {
+		summary.FirstReading = reading.Timestamp
+		summary.LastReading = reading.Timestamp
+		summary.MinTemperature = reading.Temperature
+		summary.MaxTemperature = reading.Temperature
+		summary.MinHumidity = reading.Humidity
+		summary.MaxHumidity = reading.Humidity
+		summary.Readings = 1
+		return nil
+	}
+
+	first, err = time.Parse(time.RFC3339, summary.FirstReading)
+	if err != nil {
+		return err
+	}
+	last, err = time.Parse(time.RFC3339, summary.LastReading)
+	if err != nil {
+		return err
+	}
+	if timestamp.Before(first) {
+		summary.FirstReading = reading.Timestamp
+	}
+	if timestamp.After(last) {
+		summary.LastReading = reading.Timestamp
+	}
+	if reading.Temperature < summary.MinTemperature {
+		summary.MinTemperature = reading.Temperature
+	}
+	if reading.Temperature > summary.MaxTemperature {
+		summary.MaxTemperature = reading.Temperature
+	}
+	if reading.Humidity < summary.MinHumidity {
+		summary.MinHumidity = reading.Humidity
+	}
+	if reading.Humidity > summary.MaxHumidity {
+		summary.MaxHumidity = reading.Humidity
+	}
+	summary.Readings++
+	return nil
+}
+
+// Record a batch of sensor readings from a registered device
+// Readings: JSON array of {"timestamp": RFC 3339, "temperature": degrees Celsius, "humidity": percent}
+func (t *TradeWorkflowChaincode) submitTelemetry(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var tradeKey, shipmentKey, shipmentPreparedAtKey, batchKey, summaryKey, readingKey string
+	var tradeAgreementBytes, summaryBytes, batchBytes, preparedAtBytes, readingBytes []byte
+	var tradeAgreement *TradeAgreement
+	var shipment *Shipment
+	var summary *TelemetrySummary
+	var readings, recorded []TelemetryReading
+	var incidents []ShipmentIncident
+	var cert *x509.Certificate
+	var timestamps []time.Time
+	var preparedAt, now time.Time
+	var readingKeys map[string]bool
+	var registered bool
+	var err error
+
+	// Access control: Only a device enrolled by the Carrier Org can invoke this transaction
+	if !t.testMode && !authenticateCarrierDevice(stub, creatorOrg, creatorCertIssuer) {
+		return shim.Error("Caller not a device of Carrier Org. Access denied.")
+	}
+
+	if len(args) != 3 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 3: {Trade ID, Device ID, Readings}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+
+	// A device can only report under its own identity
+	if !t.testMode {
+		cert, err = cid.GetX509Certificate(stub)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if cert.Subject.CommonName != args[1] {
+			return shim.Error("Device ID does not match the caller's certificate. Access denied.")
+		}
+	}
+
+	err = json.Unmarshal([]byte(args[2]), &readings)
+	if err != nil {
+		return shim.Error("Readings must be a JSON array of {timestamp, temperature, humidity}")
+	}
+	if len(readings) == 0 || len(readings) > maxTelemetryBatchSize {
+		err = errors.New(fmt.Sprintf("A batch must hold between 1 and %d readings. Found %d", maxTelemetryBatchSize, len(readings)))
+		return shim.Error(err.Error())
+	}
+
+	shipmentKey, shipment, err = getShipmentRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if shipment != nil {
+		for _, device := range shipment.Devices {
+			if device == args[1] {
+				registered = true
+			}
+		}
+	}
+	if !registered {
+		err = errors.New(fmt.Sprintf("Device %s is not registered for trade %s", args[1], args[0]))
+		return shim.Error(err.Error())
+	}
+
+	// Lookup trade agreement from the ledger
+	tradeKey, err = getTradeKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	tradeAgreementBytes, err = stub.GetState(tradeKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(tradeAgreementBytes, &tradeAgreement)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Lookup telemetry summary from the ledger
+	summaryKey, err = getTelemetrySummaryKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	summaryBytes, err = stub.GetState(summaryKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if len(summaryBytes) == 0 {
+		summary = &TelemetrySummary{TradeId: args[0]}
+	} else {
+		err = json.Unmarshal(summaryBytes, &summary)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	// Readings must be taken between the shipment's preparation and the transaction
+	// Shipments prepared before their preparation time was recorded only have the upper bound
+	now, err = getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	shipmentPreparedAtKey, err = getShipmentPreparedAtKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	preparedAtBytes, err = stub.GetState(shipmentPreparedAtKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if len(preparedAtBytes) != 0 {
+		preparedAt, err = time.Parse(time.RFC3339, string(preparedAtBytes))
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	timestamps = make([]time.Time, len(readings))
+	for i, reading := range readings {
+		timestamps[i], err = time.Parse(time.RFC3339, reading.Timestamp)
+		if err != nil {
+			err = errors.New(fmt.Sprintf("Invalid timestamp %s; expecting RFC 3339 format", reading.Timestamp))
+			return shim.Error(err.Error())
+		}
+		if timestamps[i].After(now) {
+			err = errors.New(fmt.Sprintf("Reading at %s is later than the transaction", reading.Timestamp))
+			return shim.Error(err.Error())
+		}
+		if len(preparedAtBytes) != 0 && timestamps[i].Before(preparedAt) {
+			err = errors.New(fmt.Sprintf("Reading at %s is earlier than the preparation of the shipment for trade %s", reading.Timestamp, args[0]))
+			return shim.Error(err.Error())
+		}
+	}
+
+	// A reading already recorded for the device at the same time (e.g., a batch resubmitted after a timeout) is ignored,
+	// so that it neither counts twice in the summary nor raises its incidents again
+	readingKeys = make(map[string]bool)
+	for i, reading := range readings {
+		readingKey, err = getTelemetryReadingKey(stub, args[0], args[1], timestamps[i].UTC().Format(time.RFC3339Nano))
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if readingKeys[readingKey] {
+			continue
+		}
+		readingKeys[readingKey] = true
+		readingBytes, err = stub.GetState(readingKey)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if len(readingBytes) != 0 {
+			fmt.Printf("Reading at %s from device %s already recorded for trade %s\n", reading.Timestamp, args[1], args[0])
+			continue
+		}
+		err = stub.PutState(readingKey, []byte(stub.GetTxID()))
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		err = addToTelemetrySummary(summary, reading, timestamps[i])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		incidents = append(incidents, checkTelemetryReading(tradeAgreement.TelemetryThresholds, args[1], reading)...)
+		recorded = append(recorded, reading)
+	}
+	if len(recorded) == 0 {
+		fmt.Printf("Telemetry batch for trade %s holds no new readings\n", args[0])
+		return shim.Success(nil)
+	}
+	summary.Batches++
+	summary.Excursions += len(incidents)
+
+	// Write the state to the ledger
+	batchKey, err = getTelemetryBatchKey(stub, args[0], stub.GetTxID())
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	batchBytes, err = json.Marshal(&TelemetryBatch{stub.GetTxID(), args[0], args[1], recorded})
+	if err != nil {
+		return shim.Error("Error marshaling telemetry batch structure")
+	}
+	err = stub.PutState(batchKey, batchBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	summaryBytes, err = json.Marshal(summary)
+	if err != nil {
+		return shim.Error("Error marshaling telemetry summary structure")
+	}
+	err = stub.PutState(summaryKey, summaryBytes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if len(incidents) > 0 {
+		shipment.Incidents = append(shipment.Incidents, incidents...)
+		err = putShipmentRecord(stub, shipmentKey, shipment)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+	fmt.Printf("Telemetry batch of %d readings for trade %s recorded; %d excursions\n", len(recorded), args[0], len(incidents))
+
+	return shim.Success(nil)
+}
+
+// Get the aggregate of the telemetry readings of a shipment
+func (t *TradeWorkflowChaincode) getTelemetrySummary(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
+	var summaryKey, jsonResp string
+	var summaryBytes []byte
+	var err error
+
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1: <trade ID>")
+	}
+
+	// Access control: Only the trade's participants, or a regulator for its jurisdiction, can invoke this transaction
+	if !t.testMode {
+		err = authorizeTradeAccess(stub, creatorOrg, creatorCertIssuer, args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	// Get the state from the ledger
+	summaryKey, err = getTelemetrySummaryKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	summaryBytes, err = stub.GetState(summaryKey)
+	if err != nil {
+		jsonResp = "{\"Error\":\"Failed to get state for " + summaryKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+
+	if len(summaryBytes) == 0 {
+		jsonResp = "{\"Error\":\"No record found for " + summaryKey + "\"}"
+		return shim.Error(jsonResp)
+	}
+
+	fmt.Printf("Query Response:%s\n", string(summaryBytes))
+	return shim.Success(summaryBytes)
+}
@@ -0,0 +1,342 @@
+/*
+ * Copyright 2018 IBM All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the 'License');
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an 'AS IS' BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Transactions that act on a trade (Trade ID is the first argument); whoever changes the trade through them becomes a participant
+// Telemetry devices are deliberately left out: submitting readings grants no read access
+var tradeTransactions = map[string]bool{
+	"requestTrade":                    true,
+	"acceptTrade":                     true,
+	"requestLC":                       true,
+	"issueLC":                         true,
+	"acceptLC":                        true,
+	"requestLCTransfer":               true,
+	"issueLCTransfer":                 true,
+	"acceptLCTransfer":                true,
+	"requestEL":                       true,
+	"issueEL":                         true,
+	"rejectEL":                        true,
+	"revokeEL":                        true,
+	"attachEL":                        true,
+	"prepareShipment":                 true,
+	"acceptShipmentAndIssueBL":        true,
+	"preparePartialShipment":          true,
+	"acceptPartialShipmentAndIssueBL": true,
+	"updatePartialShipmentLocation":   true,
+	"endorseBL":                       true,
+	"surrenderBL":                     true,
+	"fileCustomsDeclaration":          true,
+	"assessDuty":                      true,
+	"payDuty":                         true,
+	"requestAdvancePayment":           true,
+	"makeAdvancePayment":              true,
+	"requestPayment":                  true,
+	"makePayment":                     true,
+	"updateShipmentLocation":          true,
+	"planShipmentLeg":                 true,
+	"recordCheckpoint":                true,
+	"updateShipmentETA":               true,
+	"recordSealChange":                true,
+	"setTelemetryThresholds":          true,
+	"registerDevice":                  true,
+	"issueInsurancePolicy":            true,
+	"fileClaim":                       true,
+	"adjudicateClaim":                 true,
+	"payClaim":                        true,
+	"openDispute":                     true,
+	"resolveDispute":                  true,
+	"refundPayment":                   true,
+	"postReceivable":                  true,
+	"acceptBid":                       true,
+	"exerciseRecourse":                true,
+}
+
+func isTradeTransaction(function string) bool {
+	return tradeTransactions[function]
+}
+
+func getTradeParticipants(stub shim.ChaincodeStubInterface, tradeID string) (*TradeParticipants, error) {
+	var tradeParticipantsKey string
+	var tradeParticipantsBytes []byte
+	var tradeParticipants *TradeParticipants
+	var err error
+
+	tradeParticipantsKey, err = getTradeParticipantsKey(stub, tradeID)
+	if err != nil {
+		return nil, err
+	}
+	tradeParticipantsBytes, err = stub.GetState(tradeParticipantsKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tradeParticipantsBytes) == 0 {
+		return nil, nil
+	}
+
+	// Unmarshal the JSON
+	err = json.Unmarshal(tradeParticipantsBytes, &tradeParticipants)
+	if err != nil {
+		return nil, err
+	}
+	return tradeParticipants, nil
+}
+
+// Roles of a trade's counterparties and the orgs they belong to; users acting in a role carry it in their 'tradeRole' attribute
+// and the name of the party they act for in their 'tradeParty' attribute
+// They may read the trade before acting on it; the importer who requests the trade is recorded individually
+var tradeRoleParticipants = []TradeParticipant{
+	{"ImporterOrgMSP", "", ibKey, ""},
+	{"ExporterOrgMSP", "", expKey, ""},
+	{"ExporterOrgMSP", "", ebKey, ""},
+	{"CarrierOrgMSP", "", carKey, ""},
+}
+
+// A handler's writes go through this stub, so that the caller can tell whether the transaction changed the ledger
+// Writing back a value already on the ledger is not a change
+type stateChangeStub struct {
+	shim.ChaincodeStubInterface
+	changed		bool
+}
+
+func (stub *stateChangeStub) PutState(key string, value []byte) error {
+	var currentBytes []byte
+	var err error
+
+	currentBytes, err = stub.ChaincodeStubInterface.GetState(key)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(currentBytes, value) {
+		stub.changed = true
+	}
+	return stub.ChaincodeStubInterface.PutState(key, value)
+}
+
+func (stub *stateChangeStub) DelState(key string) error {
+	stub.changed = true
+	return stub.ChaincodeStubInterface.DelState(key)
+}
+
+func (stub *stateChangeStub) GetPrivateData(collection string, key string) ([]byte, error) {
+	return getPrivateData(stub.ChaincodeStubInterface, collection, key)
+}
+
+func (stub *stateChangeStub) PutPrivateData(collection string, key string, value []byte) error {
+	var currentBytes []byte
+	var err error
+
+	currentBytes, err = getPrivateData(stub.ChaincodeStubInterface, collection, key)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(currentBytes, value) {
+		stub.changed = true
+	}
+	return putPrivateData(stub.ChaincodeStubInterface, collection, key, value)
+}
+
+func (stub *stateChangeStub) GetPrivateDataByPartialCompositeKey(collection string, objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	return getPrivateDataByPartialCompositeKey(stub.ChaincodeStubInterface, collection, objectType, keys)
+}
+
+// Record the transaction creator as a participant of the trade
+func addTradeParticipant(stub shim.ChaincodeStubInterface, creatorOrg string, tradeID string) error {
+	var subject string
+	var err error
+
+	subject, err = getTxCreatorSubject(stub)
+	if err != nil {
+		return err
+	}
+	return addTradeParticipants(stub, tradeID, []TradeParticipant{{creatorOrg, subject, "", ""}})
+}
+
+// Seed the participants of a newly requested trade with its parties, the names recorded in the counterparties' roles, and
+// with the importer's user who requested it
+func seedTradeParticipants(stub shim.ChaincodeStubInterface, creatorOrg string, tradeID string) error {
+	var tradeParticipantsKey, subject string
+	var tradeParticipantsBytes, regulatorBytes, partyBytes []byte
+	var tradeParticipants *TradeParticipants
+	var err error
+
+	subject, err = getTxCreatorSubject(stub)
+	if err != nil {
+		return err
+	}
+
+	// Lookup regulatory authority (jurisdiction of the trade)
+	regulatorBytes, err = stub.GetState(raKey)
+	if err != nil {
+		return err
+	}
+	tradeParticipants = &TradeParticipants{string(regulatorBytes), []TradeParticipant{}}
+	for _, participant := range tradeRoleParticipants {
+		partyBytes, err = stub.GetState(participant.Role)
+		if err != nil {
+			return err
+		}
+		if len(partyBytes) == 0 {
+			continue
+		}
+		participant.Party = string(partyBytes)
+		tradeParticipants.Members = append(tradeParticipants.Members, participant)
+	}
+	tradeParticipants.Members = append(tradeParticipants.Members, TradeParticipant{creatorOrg, subject, "", ""})
+
+	tradeParticipantsBytes, err = json.Marshal(tradeParticipants)
+	if err != nil {
+		return errors.New("Error marshaling trade participants structure")
+	}
+	// Write the state to the ledger
+	tradeParticipantsKey, err = getTradeParticipantsKey(stub, tradeID)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(tradeParticipantsKey, tradeParticipantsBytes)
+}
+
+// Record users as participants of the trade, in a single write
+// Trades recorded before participants were tracked get their record on the first transaction after the upgrade; they have
+// no recorded parties, so only the users who act on them are participants
+func addTradeParticipants(stub shim.ChaincodeStubInterface, tradeID string, participants []TradeParticipant) error {
+	var tradeParticipantsKey string
+	var tradeParticipantsBytes, regulatorBytes []byte
+	var tradeParticipants *TradeParticipants
+	var updated bool
+	var err error
+
+	tradeParticipants, err = getTradeParticipants(stub, tradeID)
+	if err != nil {
+		return err
+	}
+	if tradeParticipants == nil {
+		// Lookup regulatory authority (jurisdiction of the trade)
+		regulatorBytes, err = stub.GetState(raKey)
+		if err != nil {
+			return err
+		}
+		tradeParticipants = &TradeParticipants{string(regulatorBytes), []TradeParticipant{}}
+		updated = true
+	}
+
+	for _, participant := range participants {
+		if isTradeParticipant(tradeParticipants, participant.Org, participant.Subject, "", "") {
+			continue
+		}
+		tradeParticipants.Members = append(tradeParticipants.Members, participant)
+		fmt.Printf("Participant %s (%s) recorded for trade %s\n", participant.Subject, participant.Org, tradeID)
+		updated = true
+	}
+	if !updated {
+		return nil
+	}
+
+	tradeParticipantsBytes, err = json.Marshal(tradeParticipants)
+	if err != nil {
+		return errors.New("Error marshaling trade participants structure")
+	}
+	// Write the state to the ledger
+	tradeParticipantsKey, err = getTradeParticipantsKey(stub, tradeID)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(tradeParticipantsKey, tradeParticipantsBytes)
+}
+
+func isTradeParticipant(tradeParticipants *TradeParticipants, org string, subject string, role string, party string) bool {
+	for _, member := range tradeParticipants.Members {
+		if member.Org != org {
+			continue
+		}
+		if (member.Subject != "" && member.Subject == subject) || (member.Role != "" && member.Role == role && member.Party == party) {
+			return true
+		}
+	}
+	return false
+}
+
+// The importer's user who requested the trade: the first individual participant of the importer's org
+// Returns a blank subject when the trade has no such participant
+func getTradeRequester(stub shim.ChaincodeStubInterface, tradeID string) (string, error) {
+	var tradeParticipants *TradeParticipants
+	var err error
+
+	tradeParticipants, err = getTradeParticipants(stub, tradeID)
+	if err != nil || tradeParticipants == nil {
+		return "", err
+	}
+	for _, member := range tradeParticipants.Members {
+		if member.Org == "ImporterOrgMSP" && member.Subject != "" {
+			return member.Subject, nil
+		}
+	}
+	return "", nil
+}
+
+// Read access to a trade: its own participants, plus regulators whose 'jurisdiction' attribute matches the trade's
+func authorizeTradeAccess(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, tradeID string) error {
+	var subject, role, party, jurisdiction string
+	var found bool
+	var tradeParticipants *TradeParticipants
+	var err error
+
+	tradeParticipants, err = getTradeParticipants(stub, tradeID)
+	if err != nil {
+		return err
+	}
+	if tradeParticipants == nil {
+		return errors.New(fmt.Sprintf("No participants recorded for trade %s. Access denied.", tradeID))
+	}
+
+	subject, err = getTxCreatorSubject(stub)
+	if err != nil {
+		return err
+	}
+	role, _, err = getCustomAttribute(stub, "tradeRole")
+	if err != nil {
+		return err
+	}
+	party, _, err = getCustomAttribute(stub, "tradeParty")
+	if err != nil {
+		return err
+	}
+	if isTradeParticipant(tradeParticipants, creatorOrg, subject, role, party) {
+		return nil
+	}
+
+	if authenticateRegulatorOrg(creatorOrg, creatorCertIssuer) {
+		jurisdiction, found, err = getCustomAttribute(stub, "jurisdiction")
+		if err != nil {
+			return err
+		}
+		if found && jurisdiction == tradeParticipants.Jurisdiction {
+			return nil
+		}
+	}
+
+	return errors.New(fmt.Sprintf("Caller not a participant of trade %s. Access denied.", tradeID))
+}
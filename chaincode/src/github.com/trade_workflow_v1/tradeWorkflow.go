@@ -31,6 +31,7 @@ import (
 // TradeWorkflowChaincode implementation
 type TradeWorkflowChaincode struct {
 	testMode bool
+	dualAuthorization bool
 }
 
 func (t *TradeWorkflowChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
@@ -44,54 +45,53 @@ func (t *TradeWorkflowChaincode) Init(stub shim.ChaincodeStubInterface) pb.Respo
 	}
 
 	// Upgrade mode 2: change all the names and account balances
-	if len(args) != 11 {
+	// Account balances are confidential and are passed in the transient map
+	if len(args) != 8 && len(args) != 9 {
 		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 8: {"+
 			"Exporter, "+
 			"Exporter's Bank, "+
-			"Exporter's Account Balance, "+
 			"Importer, "+
 			"Importer's Bank, "+
-			"Importer's Account Balance, "+
 			"Lender, "+
 			"Lender's Bank, "+
-			"Lender's Account Balance, "+
 			"Carrier, "+
 			"Regulatory Authority"+
-			"}. Found %d", len(args)))
+			"}, optionally followed by {Insurer}, and transient fields {exporterBalance, importerBalance, lenderBalance}, and {insurerBalance} with an Insurer. Found %d", len(args)))
 		return shim.Error(err.Error())
 	}
 
 	// Type checks
-	_, err = strconv.Atoi(string(args[2]))
-	if err != nil {
-		fmt.Printf("Exporter's account balance must be an integer. Found %s\n", args[2])
-		return shim.Error(err.Error())
-	}
-	_, err = strconv.Atoi(string(args[5]))
-	if err != nil {
-		fmt.Printf("Importer's account balance must be an integer. Found %s\n", args[5])
-		return shim.Error(err.Error())
+	balanceFields := []string{"exporterBalance", "importerBalance", "lenderBalance"}
+	if len(args) == 9 {
+		balanceFields = append(balanceFields, "insurerBalance")
 	}
-	_, err = strconv.Atoi(string(args[8]))
-	if err != nil {
-		fmt.Printf("Lender's account balance must be an integer. Found %s\n", args[5])
-		return shim.Error(err.Error())
+	balances := make([]string, len(balanceFields))
+	for i, balanceField := range balanceFields {
+		balances[i], err = getTransientValue(stub, balanceField)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		_, err = strconv.Atoi(balances[i])
+		if err != nil {
+			fmt.Printf("Account balance %s must be an integer. Found %s\n", balanceField, balances[i])
+			return shim.Error(err.Error())
+		}
 	}
 
 	fmt.Printf("Exporter: %s\n", args[0])
 	fmt.Printf("Exporter's Bank: %s\n", args[1])
-	fmt.Printf("Exporter's Account Balance: %s\n", args[2])
-	fmt.Printf("Importer: %s\n", args[3])
-	fmt.Printf("Importer's Bank: %s\n", args[4])
-	fmt.Printf("Importer's Account Balance: %s\n", args[5])
-	fmt.Printf("Lender: %s\n", args[6])
-	fmt.Printf("Lender's Bank: %s\n", args[7])
-	fmt.Printf("Lender's Account Balance: %s\n", args[8])
-	fmt.Printf("Carrier: %s\n", args[9])
-	fmt.Printf("Regulatory Authority: %s\n", args[10])
+	fmt.Printf("Importer: %s\n", args[2])
+	fmt.Printf("Importer's Bank: %s\n", args[3])
+	fmt.Printf("Lender: %s\n", args[4])
+	fmt.Printf("Lender's Bank: %s\n", args[5])
+	fmt.Printf("Carrier: %s\n", args[6])
+	fmt.Printf("Regulatory Authority: %s\n", args[7])
+	if len(args) == 9 {
+		fmt.Printf("Insurer: %s\n", args[8])
+	}
 
 	// Map participant identities to their roles on the ledger
-	roleKeys := []string{expKey, ebKey, expBalKey, impKey, ibKey, impBalKey, lenKey, lbKey, lenBalKey, carKey, raKey}
+	roleKeys := []string{expKey, ebKey, impKey, ibKey, lenKey, lbKey, carKey, raKey, insKey}[:len(args)]
 	for i, roleKey := range roleKeys {
 		err = stub.PutState(roleKey, []byte(args[i]))
 		if err != nil {
@@ -100,11 +100,23 @@ func (t *TradeWorkflowChaincode) Init(stub shim.ChaincodeStubInterface) pb.Respo
 		}
 	}
 
+	// Record account balances in the private data collection
+	balanceKeys := []string{expBalKey, impBalKey, lenBalKey, insBalKey}[:len(balanceFields)]
+	for i, balanceKey := range balanceKeys {
+		err = putPrivateData(stub, accountBalancesCollection, balanceKey, []byte(balances[i]))
+		if err != nil {
+			fmt.Printf("Error recording key %s: %s\n", balanceKey, err.Error())
+			return shim.Error(err.Error())
+		}
+	}
+
 	return shim.Success(nil)
 }
 
 func (t *TradeWorkflowChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
 	var creatorOrg, creatorCertIssuer string
+	var changeStub *stateChangeStub
+	var response pb.Response
 	var err error
 
 	fmt.Println("TradeWorkflow Invoke")
@@ -119,6 +131,71 @@ func (t *TradeWorkflowChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Res
 	}
 
 	function, args := stub.GetFunctionAndParameters()
+	if t.dualAuthorization && requiresDualAuthorization(function) {
+		// Bank action (maker): record it pending approval by a second user of the same org
+		// The maker becomes a participant of the trade only once the action is approved
+		return t.submitAction(stub, creatorOrg, creatorCertIssuer, function, args)
+	}
+	changeStub = &stateChangeStub{stub, false}
+	response = t.invokeFunction(changeStub, creatorOrg, creatorCertIssuer, function, args)
+
+	// Record the creator as a participant of the trade, which grants read access to it
+	// A transaction that leaves the ledger as it was (e.g., accepting a trade already accepted) grants nothing
+	if !t.testMode && response.Status == shim.OK && changeStub.changed && isTradeTransaction(function) && len(args) > 0 {
+		if function == "requestTrade" {
+			err = seedTradeParticipants(stub, creatorOrg, args[0])
+		} else {
+			err = addTradeParticipant(stub, creatorOrg, args[0])
+		}
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	return response
+}
+
+// Checks that apply to a trade transaction whether it is invoked directly or approved as a pending action
+func checkTradeTransaction(stub shim.ChaincodeStubInterface, function string, args []string) error {
+	var err error
+
+	if len(args) == 0 {
+		return nil
+	}
+
+	// No progress can be made on a trade on compliance hold until the Regulator clears it, nor on a cancelled trade
+	// A lender may still exercise recourse on a cancelled trade, to close the financing position the refunds reopened
+	if isTradeTransaction(function) {
+		err = checkComplianceHold(stub, args[0])
+		if err != nil {
+			return err
+		}
+		if function != "exerciseRecourse" {
+			err = checkTradeCancelled(stub, args[0])
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Payments on a trade are frozen while a dispute is open
+	if paymentTransactions[function] {
+		err = checkPaymentFreeze(stub, args[0])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *TradeWorkflowChaincode) invokeFunction(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, function string, args []string) pb.Response {
+	var err error
+
+	err = checkTradeTransaction(stub, function, args)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
 	if function == "requestTrade" {
 		// Importer requests a trade
 		return t.requestTrade(stub, creatorOrg, creatorCertIssuer, args)
@@ -149,12 +226,87 @@ func (t *TradeWorkflowChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Res
 	} else if function == "issueEL" {
 		// Regulatory Authority issues an E/L
 		return t.issueEL(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "rejectEL" {
+		// Regulatory Authority refuses an E/L
+		return t.rejectEL(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "revokeEL" {
+		// Regulatory Authority revokes an E/L
+		return t.revokeEL(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "issueStandaloneEL" {
+		// Regulatory Authority issues an E/L for a quota of goods, not tied to a trade
+		return t.issueStandaloneEL(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "revokeStandaloneEL" {
+		// Regulatory Authority revokes a standalone E/L
+		return t.revokeStandaloneEL(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "attachEL" {
+		// Exporter ships a trade under a standalone E/L
+		return t.attachEL(stub, creatorOrg, creatorCertIssuer, args)
 	} else if function == "prepareShipment" {
 		// Exporter prepares a shipment
 		return t.prepareShipment(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "preparePartialShipment" {
+		// Exporter prepares a part of the goods for shipment
+		return t.preparePartialShipment(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "acceptPartialShipmentAndIssueBL" {
+		// Carrier accepts a partial shipment and issues its B/L
+		return t.acceptPartialShipmentAndIssueBL(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "updatePartialShipmentLocation" {
+		// Carrier records the arrival of a partial shipment
+		return t.updatePartialShipmentLocation(stub, creatorOrg, creatorCertIssuer, args)
 	} else if function == "acceptShipmentAndIssueBL" {
 		// Carrier validates the shipment and issues a B/L
 		return t.acceptShipmentAndIssueBL(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "endorseBL" {
+		// B/L holder transfers title to the goods
+		return t.endorseBL(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "surrenderBL" {
+		// B/L holder surrenders the B/L at destination and takes delivery
+		return t.surrenderBL(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "setTariffRate" {
+		// Customs records the duty and tax rates of an HS code
+		return t.setTariffRate(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "fileCustomsDeclaration" {
+		// Importer declares the goods under a B/L to Customs
+		return t.fileCustomsDeclaration(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "assessDuty" {
+		// Customs assesses duty and tax on a declaration
+		return t.assessDuty(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "payDuty" {
+		// Importer pays the assessed duty and tax, and the goods are cleared
+		return t.payDuty(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "addScreeningEntry" {
+		// Regulatory Authority lists a party, country, port or HS code to screen trades against
+		return t.addScreeningEntry(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "removeScreeningEntry" {
+		// Regulatory Authority delists a screening entry
+		return t.removeScreeningEntry(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "clearComplianceHold" {
+		// Regulatory Authority releases a trade from compliance hold
+		return t.clearComplianceHold(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "setAMLRules" {
+		// Regulatory Authority sets the thresholds of the AML monitoring rules
+		return t.setAMLRules(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "issueInsurancePolicy" {
+		// Insurer issues a cargo insurance policy on a trade's shipment and collects the premium
+		return t.issueInsurancePolicy(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "fileClaim" {
+		// Buyer or seller claims under the insurance policy for shipment incidents
+		return t.fileClaim(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "adjudicateClaim" {
+		// Insurer approves or rejects a claim
+		return t.adjudicateClaim(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "payClaim" {
+		// Insurer pays an approved claim
+		return t.payClaim(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "openDispute" {
+		// Trade participant opens a dispute, freezing payments on the trade
+		return t.openDispute(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "resolveDispute" {
+		// Arbitrator resolves a dispute, and its outcome is executed
+		return t.resolveDispute(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "refundPayment" {
+		// Beneficiary refunds a payment made under the L/C to the importer
+		return t.refundPayment(stub, creatorOrg, creatorCertIssuer, args)
 	} else if function == "requestAdvancePayment" {
 		// Exporter's Bank requests an advance payment
 		return t.requestAdvancePayment(stub, creatorOrg, creatorCertIssuer, args)
@@ -170,6 +322,48 @@ func (t *TradeWorkflowChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Res
 	} else if function == "updateShipmentLocation" {
 		// Carrier updates the shipment location
 		return t.updateShipmentLocation(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "planShipmentLeg" {
+		// Carrier adds a leg to the shipment's route
+		return t.planShipmentLeg(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "recordCheckpoint" {
+		// Carrier records a checkpoint event on the shipment's route
+		return t.recordCheckpoint(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "updateShipmentETA" {
+		// Carrier publishes the shipment's estimated time of arrival
+		return t.updateShipmentETA(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "recordSealChange" {
+		// Carrier records the replacement of a container seal
+		return t.recordSealChange(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "setTelemetryThresholds" {
+		// Importer sets the cold-chain thresholds of a trade
+		return t.setTelemetryThresholds(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "registerDevice" {
+		// Carrier registers a device to report telemetry for a shipment
+		return t.registerDevice(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "submitTelemetry" {
+		// Carrier device submits a batch of sensor readings
+		return t.submitTelemetry(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "approveAction" {
+		// Bank checker approves a pending action, which then takes effect
+		return t.approveAction(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "rejectAction" {
+		// Bank checker rejects a pending action
+		return t.rejectAction(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "getPendingAction" {
+		// Get a pending action and its approval status
+		return t.getPendingAction(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "uploadDocument" {
+		// Trade participant attaches a document to a trade asset
+		return t.uploadDocument(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "getDocument" {
+		// Get a document attached to a trade
+		return t.getDocument(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "verifyDocument" {
+		// Check a document hash against the ledger
+		return t.verifyDocument(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "verifySignature" {
+		// Check the issuer's signature on a B/L or E/L
+		return t.verifySignature(stub, creatorOrg, creatorCertIssuer, args)
 	} else if function == "getTradeStatus" {
 		// Get status of trade agreement
 		return t.getTradeStatus(stub, creatorOrg, creatorCertIssuer, args)
@@ -179,15 +373,96 @@ func (t *TradeWorkflowChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Res
 	} else if function == "getELStatus" {
 		// Get the E/L status
 		return t.getELStatus(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "getStandaloneEL" {
+		// Get a standalone E/L and its remaining quota
+		return t.getStandaloneEL(stub, creatorOrg, creatorCertIssuer, args)
 	} else if function == "getShipmentLocation" {
 		// Get the shipment location
 		return t.getShipmentLocation(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "getPartialShipments" {
+		// Get the partial shipments of a trade
+		return t.getPartialShipments(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "getShipment" {
+		// Get the shipment's legs and checkpoints
+		return t.getShipment(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "getTelemetrySummary" {
+		// Get the aggregate of a shipment's telemetry readings
+		return t.getTelemetrySummary(stub, creatorOrg, creatorCertIssuer, args)
 	} else if function == "getArrivalDate" {
 		// Get the shipment location
 		return t.getArrivalDate(stub, creatorOrg, creatorCertIssuer, args)
 	} else if function == "getBillOfLading" {
 		// Get the bill of lading
 		return t.getBillOfLading(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "getCustomsDeclaration" {
+		// Get the customs declaration for the goods under a B/L
+		return t.getCustomsDeclaration(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "getTariff" {
+		// Get the tariff line that applies to an HS code
+		return t.getTariff(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "getScreeningList" {
+		// Get the Regulatory Authority's screening list
+		return t.getScreeningListEntries(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "getComplianceHold" {
+		// Get the compliance hold on a trade
+		return t.getComplianceHold(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "getAMLRules" {
+		// Get the thresholds of the AML monitoring rules
+		return t.getAMLRulesQuery(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "listFlags" {
+		// List the AML flags raised on payments
+		return t.listFlags(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "getInsurancePolicy" {
+		// Get the insurance policy on a trade's shipment
+		return t.getInsurancePolicy(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "getInsuranceClaim" {
+		// Get a claim under a trade's insurance policy
+		return t.getInsuranceClaim(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "getDispute" {
+		// Get the dispute opened on a trade
+		return t.getDispute(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "registerLender" {
+		// Regulatory authority registers a lender to bid for L/C financing, funding its account
+		return t.registerLender(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "postReceivable" {
+		// Exporter posts an accepted L/C for financing
+		return t.postReceivable(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "submitBid" {
+		// Lender submits a sealed bid to finance an L/C
+		return t.submitBid(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "revealBid" {
+		// Lender reveals the rates of its sealed bid once bidding has closed
+		return t.revealBid(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "acceptBid" {
+		// Exporter accepts the winning bid, requesting the L/C transfer to its lender
+		return t.acceptBid(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "getLender" {
+		// Get a registered lender
+		return t.getLender(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "getLenderBalance" {
+		// Get the account balance of a lender
+		return t.getLenderBalance(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "getReceivable" {
+		// Get the financing posted for an L/C
+		return t.getReceivable(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "getBid" {
+		// Get a lender's bid with its rates
+		return t.getBid(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "exerciseRecourse" {
+		// Lender exercises recourse to the exporter for the shortfall on its financing of an expired L/C
+		return t.exerciseRecourse(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "getFinancingPosition" {
+		// Get a lender's financing position with its amounts
+		return t.getFinancingPosition(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "getPayment" {
+		// Get a payment record of a trade
+		return t.getPayment(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "listPayments" {
+		// List the payment records of a trade or an account
+		return t.listPayments(stub, creatorOrg, creatorCertIssuer, args)
+	} else if function == "getStatement" {
+		// Generate an account statement for a date range
+		return t.getStatement(stub, creatorOrg, creatorCertIssuer, args)
 	} else if function == "getAccountBalance" {
 		// Get account balance: Exporter/Importer
 		return t.getAccountBalance(stub, creatorOrg, creatorCertIssuer, args)
@@ -201,10 +476,12 @@ func (t *TradeWorkflowChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Res
 
 // Request a trade agreement
 func (t *TradeWorkflowChaincode) requestTrade(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
-	var tradeKey string
+	var tradeKey, deliveryWindowStart, deliveryWindowEnd string
 	var tradeAgreement *TradeAgreement
 	var tradeAgreementBytes []byte
-	var amount int
+	var amount, quantity int
+	var delayPenaltyRate float64
+	var parties []string
 	var err error
 
 	// ADD TRADELIMIT RETRIEVAL HERE
@@ -230,12 +507,38 @@ func (t *TradeWorkflowChaincode) requestTrade(stub shim.ChaincodeStubInterface,
 		return shim.Error("Caller not a member of Importer Org. Access denied.")
 	}
 
-	if len(args) != 3 {
-		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 3: {ID, Amount, Description of Goods}. Found %d", len(args)))
+	if len(args) < 2 || len(args) > 5 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 2: {ID, Description of Goods}, or 4: {ID, Description of Goods, Delivery Window Start, Delivery Window End}, optionally followed by {Quantity}, and transient field {amount}. Found %d", len(args)))
 		return shim.Error(err.Error())
 	}
 
-	amount, err = strconv.Atoi(string(args[1]))
+	// The quantity of goods is optional; it is required to ship the goods in parts
+	if len(args) % 2 == 1 {
+		quantity, err = strconv.Atoi(args[len(args)-1])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if quantity <= 0 {
+			return shim.Error("Quantity must be positive")
+		}
+	}
+
+	// The contractual delivery window is optional
+	if len(args) >= 4 {
+		err = checkDeliveryWindow(args[2], args[3])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		deliveryWindowStart = args[2]
+		deliveryWindowEnd = args[3]
+	}
+
+	// The amount is confidential and is passed in the transient map
+	value, err = getTransientValue(stub, "amount")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	amount, err = strconv.Atoi(value)
 	if err != nil {
 		return shim.Error(err.Error())
 	}
@@ -246,17 +549,62 @@ func (t *TradeWorkflowChaincode) requestTrade(stub shim.ChaincodeStubInterface,
 		return shim.Error(err.Error())
 	}
 
-	tradeAgreement = &TradeAgreement{amount, args[2], REQUESTED, 0}
-	tradeAgreementBytes, err = json.Marshal(tradeAgreement)
+	// A penalty for late delivery is optional, and confidential
+	value, found, err = getOptionalTransientValue(stub, "delayPenaltyRate")
 	if err != nil {
-		return shim.Error("Error marshaling trade agreement structure")
+		return shim.Error(err.Error())
+	}
+	if found {
+		delayPenaltyRate, err = strconv.ParseFloat(value, 32)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if delayPenaltyRate < 0 || delayPenaltyRate > 1 {
+			return shim.Error("Delay penalty rate must be between 0 and 1")
+		}
+		if deliveryWindowEnd == "" {
+			return shim.Error("Delay penalty requires a delivery window")
+		}
 	}
 
-	// Write the state to the ledger
 	tradeKey, err = getTradeKey(stub, args[0])
 	if err != nil {
 		return shim.Error(err.Error())
 	}
+
+	// A trade ID cannot be reused
+	tradeAgreementBytes, err = stub.GetState(tradeKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if len(tradeAgreementBytes) != 0 {
+		err = errors.New(fmt.Sprintf("Trade %s already exists", args[0]))
+		return shim.Error(err.Error())
+	}
+
+	// Screen the trade's parties against the Regulator's list
+	parties, err = getRoleNames(stub, impKey, ibKey, expKey, ebKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = screenTrade(stub, args[0], "requestTrade", parties, nil, nil)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Record the amount privately
+	tradeAgreement = &TradeAgreement{amount, args[1], quantity, REQUESTED, 0, deliveryWindowStart, deliveryWindowEnd, float32(delayPenaltyRate), 0, nil, ""}
+	err = putTradeTerms(stub, tradeKey, tradeAgreement)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	tradeAgreementBytes, err = json.Marshal(tradeAgreement)
+	if err != nil {
+		return shim.Error("Error marshaling trade agreement structure")
+	}
+
+	// Write the state to the ledger
 	err = stub.PutState(tradeKey, tradeAgreementBytes)
 	if err != nil {
 		return shim.Error(err.Error())
@@ -329,6 +677,7 @@ func (t *TradeWorkflowChaincode) requestLC(stub shim.ChaincodeStubInterface, cre
 	var tradeAgreementBytes, letterOfCreditBytes, exporterBytes []byte
 	var tradeAgreement *TradeAgreement
 	var letterOfCredit *LetterOfCredit
+	var partialShipments bool
 	var err error
 
 	// Access control: Only an Importer Org member can invoke this transaction
@@ -336,11 +685,20 @@ func (t *TradeWorkflowChaincode) requestLC(stub shim.ChaincodeStubInterface, cre
 		return shim.Error("Caller not a member of Importer Org. Access denied.")
 	}
 
-	if len(args) != 1 {
-		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 1: {Trade ID}. Found %d", len(args)))
+	if len(args) != 1 && len(args) != 2 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 1: {Trade ID}, or 2: {Trade ID, Partial Shipments}. Found %d", len(args)))
 		return shim.Error(err.Error())
 	}
 
+	// Partial shipments are not allowed unless the applicant asks for them
+	if len(args) == 2 {
+		if args[1] != ALLOWED && args[1] != NOT_ALLOWED {
+			err = errors.New(fmt.Sprintf("Invalid partial shipment terms %s; Permissible values: {%s, %s}", args[1], ALLOWED, NOT_ALLOWED))
+			return shim.Error(err.Error())
+		}
+		partialShipments = args[1] == ALLOWED
+	}
+
 	// Lookup trade agreement from the ledger
 	tradeKey, err = getTradeKey(stub, args[0])
 	if err != nil {
@@ -366,6 +724,15 @@ func (t *TradeWorkflowChaincode) requestLC(stub shim.ChaincodeStubInterface, cre
 	if tradeAgreement.Status != ACCEPTED {
 		return shim.Error("Trade has not been accepted by the parties")
 	}
+	if partialShipments && tradeAgreement.Quantity == 0 {
+		return shim.Error("Partial shipments require the quantity of goods to be set in the trade")
+	}
+
+	// Lookup the trade amount from the private terms
+	err = getTradeTerms(stub, tradeKey, tradeAgreement)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
 
 	// Lookup exporter (L/C beneficiary)
 	exporterBytes, err = stub.GetState(expKey)
@@ -373,17 +740,24 @@ func (t *TradeWorkflowChaincode) requestLC(stub shim.ChaincodeStubInterface, cre
 		return shim.Error(err.Error())
 	}
 
-	letterOfCredit = &LetterOfCredit{"", "", string(exporterBytes), tradeAgreement.Amount, []string{}, REQUESTED, 0.0, false}
-	letterOfCreditBytes, err = json.Marshal(letterOfCredit)
+	lcKey, err = getLCKey(stub, args[0])
 	if err != nil {
-		return shim.Error("Error marshaling letter of credit structure")
+		return shim.Error(err.Error())
 	}
 
-	// Write the state to the ledger
-	lcKey, err = getLCKey(stub, args[0])
+	// Record the L/C amount privately
+	letterOfCredit = &LetterOfCredit{"", "", string(exporterBytes), tradeAgreement.Amount, []DocumentReference{}, REQUESTED, partialShipments, nil, ""}
+	err = putLetterOfCreditTerms(stub, lcKey, letterOfCredit)
 	if err != nil {
 		return shim.Error(err.Error())
 	}
+
+	letterOfCreditBytes, err = json.Marshal(letterOfCredit)
+	if err != nil {
+		return shim.Error("Error marshaling letter of credit structure")
+	}
+
+	// Write the state to the ledger
 	err = stub.PutState(lcKey, letterOfCreditBytes)
 	if err != nil {
 		return shim.Error(err.Error())
@@ -399,6 +773,7 @@ func (t *TradeWorkflowChaincode) issueLC(stub shim.ChaincodeStubInterface, creat
 	var lcKey string
 	var letterOfCreditBytes []byte
 	var letterOfCredit *LetterOfCredit
+	var parties []string
 	var err error
 
 	// Access control: Only an Importer Org member can invoke this transaction
@@ -434,9 +809,22 @@ func (t *TradeWorkflowChaincode) issueLC(stub shim.ChaincodeStubInterface, creat
 	} else if letterOfCredit.Status == TRANSFER_REQUESTED || letterOfCredit.Status == TRANSFER_ISSUED || letterOfCredit.Status == TRANSFER_ACCEPTED {
 		fmt.Printf("L/C for trade %s already transferred\n", args[0])
 	} else {
+		// Screen the banks and the beneficiary against the Regulator's list
+		parties, err = getRoleNames(stub, ibKey, ebKey)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		err = screenTrade(stub, args[0], "issueLC", append(parties, letterOfCredit.Beneficiary), nil, nil)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
 		letterOfCredit.Id = args[1]
 		letterOfCredit.ExpirationDate = args[2]
-		letterOfCredit.Documents = args[3:]
+		letterOfCredit.Documents = []DocumentReference{}
+		for _, documentType := range args[3:] {
+			letterOfCredit.Documents = append(letterOfCredit.Documents, DocumentReference{documentType, ""})
+		}
 		letterOfCredit.Status = ISSUED
 		letterOfCreditBytes, err = json.Marshal(letterOfCredit)
 		if err != nil {
@@ -517,6 +905,8 @@ func (t *TradeWorkflowChaincode) requestEL(stub shim.ChaincodeStubInterface, cre
 	var tradeAgreement *TradeAgreement
 	var letterOfCredit *LetterOfCredit
 	var exportLicense *ExportLicense
+	var hsCode string
+	var hsCodes []string
 	var err error
 
 	// Access control: Only an Exporter Org member can invoke this transaction
@@ -524,11 +914,20 @@ func (t *TradeWorkflowChaincode) requestEL(stub shim.ChaincodeStubInterface, cre
 		return shim.Error("Caller not a member of Exporter Org. Access denied.")
 	}
 
-	if len(args) != 1 {
-		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 1: {Trade ID}. Found %d", len(args)))
+	if len(args) != 1 && len(args) != 2 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 1: {Trade ID}, or 2: {Trade ID, HS Code}. Found %d", len(args)))
 		return shim.Error(err.Error())
 	}
 
+	// The HS code classifying the goods is optional; it is screened against the Regulator's list
+	if len(args) == 2 {
+		hsCode, err = parseHsCode(args[1], 6)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		hsCodes = []string{hsCode}
+	}
+
 	// Lookup L/C from the ledger
 	lcKey, err = getLCKey(stub, args[0])
 	if err != nil {
@@ -596,7 +995,13 @@ func (t *TradeWorkflowChaincode) requestEL(stub shim.ChaincodeStubInterface, cre
 		return shim.Error(err.Error())
 	}
 
-	exportLicense = &ExportLicense{"", "", string(exporterBytes), string(carrierBytes), tradeAgreement.DescriptionOfGoods, string(approverBytes), REQUESTED}
+	// Screen the exporter, the carrier and the goods against the Regulator's list
+	err = screenTrade(stub, args[0], "requestEL", []string{string(exporterBytes), string(carrierBytes)}, nil, hsCodes)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	exportLicense = &ExportLicense{"", "", string(exporterBytes), string(carrierBytes), tradeAgreement.DescriptionOfGoods, hsCode, string(approverBytes), REQUESTED, nil, "", false, "", ""}
 	exportLicenseBytes, err = json.Marshal(exportLicense)
 	if err != nil {
 		return shim.Error("Error marshaling export license structure")
@@ -621,6 +1026,7 @@ func (t *TradeWorkflowChaincode) issueEL(stub shim.ChaincodeStubInterface, creat
 	var elKey string
 	var exportLicenseBytes []byte
 	var exportLicense *ExportLicense
+	var conditions *ExportLicenseConditions
 	var err error
 
 	// Access control: Only a Regulator Org member can invoke this transaction
@@ -628,11 +1034,24 @@ func (t *TradeWorkflowChaincode) issueEL(stub shim.ChaincodeStubInterface, creat
 		return shim.Error("Caller not a member of Regulator Org. Access denied.")
 	}
 
-	if len(args) != 3 {
-		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 3: {Trade ID, L/C ID, Expiry Date}. Found %d", len(args)))
+	if len(args) != 3 && len(args) != 4 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 3: {Trade ID, E/L ID, Expiry Date}, or 4: {Trade ID, E/L ID, Expiry Date, Conditions}. Found %d", len(args)))
+		return shim.Error(err.Error())
+	}
+	_, err = time.Parse(dateLayout, args[2])
+	if err != nil {
+		err = errors.New(fmt.Sprintf("Invalid expiry date %s; expecting MM/DD/YYYY", args[2]))
 		return shim.Error(err.Error())
 	}
 
+	// The license may be restricted in time, quantity and destination
+	if len(args) == 4 {
+		conditions, err = parseExportLicenseConditions(args[3], args[2])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
 	// Lookup E/L from the ledger
 	elKey, err = getELKey(stub, args[0])
 	if err != nil {
@@ -652,10 +1071,20 @@ func (t *TradeWorkflowChaincode) issueEL(stub shim.ChaincodeStubInterface, creat
 	// Verify that the E/L has not already been issued
 	if exportLicense.Status == ISSUED {
 		fmt.Printf("E/L for trade %s has already been issued\n", args[0])
+	} else if exportLicense.Status == REJECTED || exportLicense.Status == REVOKED {
+		fmt.Printf("E/L for trade %s has been %s\n", args[0], strings.ToLower(exportLicense.Status))
+		return shim.Error("E/L rejected or revoked; a new E/L must be requested")
 	} else {
 		exportLicense.Id = args[1]
 		exportLicense.ExpirationDate = args[2]
 		exportLicense.Status = ISSUED
+		exportLicense.Conditions = conditions
+
+		// Attach the Regulator's signature over the E/L content
+		err = signExportLicense(stub, t.testMode, exportLicense)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
 		exportLicenseBytes, err = json.Marshal(exportLicense)
 		if err != nil {
 			return shim.Error("Error marshaling E/L structure")
@@ -672,10 +1101,17 @@ func (t *TradeWorkflowChaincode) issueEL(stub shim.ChaincodeStubInterface, creat
 }
 
 // Prepare a shipment; preparation is indicated by setting the location as SOURCE
+// The containers the goods are packed in may be listed, to be checked by the Carrier at loading
 func (t *TradeWorkflowChaincode) prepareShipment(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
-	var elKey, shipmentLocationKey string
-	var shipmentLocationBytes, exportLicenseBytes []byte
+	var tradeKey, shipmentLocationKey, shipmentPreparedAtKey, shipmentKey, standaloneELKey string
+	var shipmentLocationBytes, tradeAgreementBytes []byte
+	var tradeAgreement *TradeAgreement
 	var exportLicense *ExportLicense
+	var standaloneLicense *StandaloneExportLicense
+	var containers []Container
+	var shipment *Shipment
+	var partialShipments *PartialShipments
+	var now time.Time
 	var err error
 
 	// Access control: Only an Exporter Org member can invoke this transaction
@@ -683,11 +1119,18 @@ func (t *TradeWorkflowChaincode) prepareShipment(stub shim.ChaincodeStubInterfac
 		return shim.Error("Caller not a member of Exporter Org. Access denied.")
 	}
 
-	if len(args) != 1 {
-		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 1: {Trade ID}. Found %d", len(args)))
+	if len(args) != 1 && len(args) != 2 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 1: {Trade ID}, or 2: {Trade ID, Containers}. Found %d", len(args)))
 		return shim.Error(err.Error())
 	}
 
+	if len(args) == 2 {
+		containers, err = parseContainers(args[1])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
 	// Lookup shipment location from the ledger
 	shipmentLocationKey, err = getShipmentLocationKey(stub, args[0])
 	if err != nil {
@@ -709,26 +1152,59 @@ func (t *TradeWorkflowChaincode) prepareShipment(stub shim.ChaincodeStubInterfac
 		}
 	}
 
-	// Lookup E/L from the ledger
-	elKey, err = getELKey(stub, args[0])
+	// A trade is shipped either in full or in parts
+	_, partialShipments, err = getPartialShipmentsRecord(stub, args[0])
 	if err != nil {
 		return shim.Error(err.Error())
 	}
-	exportLicenseBytes, err = stub.GetState(elKey)
+	if partialShipments != nil {
+		fmt.Printf("Trade %s is being shipped in parts\n", args[0])
+		return shim.Error("Trade shipped in parts; prepare a partial shipment instead")
+	}
+
+	// Lookup trade agreement from the ledger
+	tradeKey, err = getTradeKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	tradeAgreementBytes, err = stub.GetState(tradeKey)
 	if err != nil {
 		return shim.Error(err.Error())
 	}
 
+	if len(tradeAgreementBytes) == 0 {
+		err = errors.New(fmt.Sprintf("No record found for trade ID %s", args[0]))
+		return shim.Error(err.Error())
+	}
+
 	// Unmarshal the JSON
-	err = json.Unmarshal(exportLicenseBytes, &exportLicense)
+	err = json.Unmarshal(tradeAgreementBytes, &tradeAgreement)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Verify that the E/L has been issued, is in force, and covers the goods
+	exportLicense, standaloneELKey, standaloneLicense, err = getTradeExportLicense(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = checkExportLicense(stub, exportLicense, tradeAgreement.Quantity, "")
 	if err != nil {
+		fmt.Printf("E/L for trade %s does not cover the shipment\n", args[0])
 		return shim.Error(err.Error())
 	}
 
-	// Verify that the E/L has already been issued
-	if exportLicense.Status != ISSUED {
-		fmt.Printf("E/L for trade %s has not been issued\n", args[0])
-		return shim.Error("E/L not issued yet")
+	// Goods shipped under a standalone E/L are counted against its quota
+	if standaloneLicense != nil {
+		err = getTradeTerms(stub, tradeKey, tradeAgreement)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		err = drawDownExportQuota(stub, standaloneELKey, standaloneLicense, args[0], "", tradeAgreement.Quantity, tradeAgreement.Amount)
+		if err != nil {
+			fmt.Printf("Quota of E/L %s is insufficient for trade %s\n", standaloneLicense.Id, args[0])
+			return shim.Error(err.Error())
+		}
 	}
 
 	shipmentLocationKey, err = getShipmentLocationKey(stub, args[0])
@@ -740,6 +1216,35 @@ func (t *TradeWorkflowChaincode) prepareShipment(stub shim.ChaincodeStubInterfac
 	if err != nil {
 		return shim.Error(err.Error())
 	}
+
+	// Telemetry readings taken before the shipment was prepared are not the shipment's
+	now, err = getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	shipmentPreparedAtKey, err = getShipmentPreparedAtKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = stub.PutState(shipmentPreparedAtKey, []byte(now.Format(time.RFC3339)))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(containers) > 0 {
+		shipmentKey, shipment, err = getShipmentRecord(stub, args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if shipment == nil {
+			shipment = &Shipment{args[0], []ShipmentLeg{}, []ShipmentCheckpoint{}, nil, nil, nil, nil, nil}
+		}
+		shipment.Containers = containers
+		err = putShipmentRecord(stub, shipmentKey, shipment)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
 	fmt.Printf("Shipment preparation for trade %s recorded\n", args[0])
 
 	return shim.Success(nil)
@@ -751,6 +1256,9 @@ func (t *TradeWorkflowChaincode) acceptShipmentAndIssueBL(stub shim.ChaincodeStu
 	var shipmentLocationBytes, tradeAgreementBytes, billOfLadingBytes, exporterBytes, carrierBytes, beneficiaryBytes []byte
 	var billOfLading *BillOfLading
 	var tradeAgreement *TradeAgreement
+	var exportLicense *ExportLicense
+	var shipment *Shipment
+	var containers []ContainerReference
 	var err error
 
 	// Access control: Only an Carrier Org member can invoke this transaction
@@ -758,8 +1266,8 @@ func (t *TradeWorkflowChaincode) acceptShipmentAndIssueBL(stub shim.ChaincodeStu
 		return shim.Error("Caller not a member of Carrier Org. Access denied.")
 	}
 
-	if len(args) != 5 {
-		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 5: {Trade ID, B/L ID, Expiration Date, Source Port, Destination Port}. Found %d", len(args)))
+	if len(args) != 5 && len(args) != 6 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 5: {Trade ID, B/L ID, Expiration Date, Source Port, Destination Port}, or 6: {Trade ID, B/L ID, Expiration Date, Source Port, Destination Port, Loaded Containers}. Found %d", len(args)))
 		return shim.Error(err.Error())
 	}
 
@@ -783,6 +1291,24 @@ func (t *TradeWorkflowChaincode) acceptShipmentAndIssueBL(stub shim.ChaincodeStu
 		return shim.Error("Shipment past the preparation stage")
 	}
 
+	// Containers prepared by the Exporter must all be loaded, under their current seals
+	_, shipment, err = getShipmentRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if shipment != nil && len(shipment.Containers) > 0 {
+		if len(args) != 6 {
+			return shim.Error("Loaded containers must be listed for a shipment prepared in containers")
+		}
+		containers, err = checkLoadedContainers(shipment.Containers, args[5])
+		if err != nil {
+			fmt.Printf("Loaded containers for trade %s do not match the prepared containers\n", args[0])
+			return shim.Error(err.Error())
+		}
+	} else if len(args) == 6 {
+		return shim.Error("No containers prepared for this shipment")
+	}
+
 	// Lookup trade agreement from the ledger
 	tradeKey, err = getTradeKey(stub, args[0])
 	if err != nil {
@@ -804,6 +1330,17 @@ func (t *TradeWorkflowChaincode) acceptShipmentAndIssueBL(stub shim.ChaincodeStu
 		return shim.Error(err.Error())
 	}
 
+	// Verify that the E/L is still in force, and allows export to the destination
+	exportLicense, _, _, err = getTradeExportLicense(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = checkExportLicense(stub, exportLicense, tradeAgreement.Quantity, args[4])
+	if err != nil {
+		fmt.Printf("E/L for trade %s does not cover the shipment\n", args[0])
+		return shim.Error(err.Error())
+	}
+
 	// Lookup exporter
 	exporterBytes, err = stub.GetState(expKey)
 	if err != nil {
@@ -816,15 +1353,28 @@ func (t *TradeWorkflowChaincode) acceptShipmentAndIssueBL(stub shim.ChaincodeStu
 		return shim.Error(err.Error())
 	}
 
-	// Lookup importer's bank (beneficiary of the title to goods after payment is made)
+	// Lookup importer's bank (holds the title to goods until payment is made)
 	beneficiaryBytes, err = stub.GetState(ibKey)
 	if err != nil {
 		return shim.Error(err.Error())
 	}
 
+	// Screen the parties, the route and the goods against the Regulator's list
+	err = screenTrade(stub, args[0], "acceptShipmentAndIssueBL", []string{string(exporterBytes), string(carrierBytes), string(beneficiaryBytes)},
+		getShipmentPorts(shipment, args[3], args[4]), getExportLicenseHsCodes(exportLicense))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
 	// Create and record a B/L
-	billOfLading = &BillOfLading{args[1], args[2], string(exporterBytes), string(carrierBytes), tradeAgreement.DescriptionOfGoods,
-		tradeAgreement.Amount, string(beneficiaryBytes), args[3], args[4]}
+	billOfLading = &BillOfLading{args[1], args[2], string(exporterBytes), string(carrierBytes), tradeAgreement.DescriptionOfGoods, tradeAgreement.Quantity,
+		string(beneficiaryBytes), args[3], args[4], containers, string(beneficiaryBytes), "ImporterOrgMSP", "", HELD_BY_BANK, []Endorsement{}, "", ""}
+
+	// Attach the Carrier's signature over the B/L content
+	err = signBillOfLading(stub, t.testMode, billOfLading)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
 	billOfLadingBytes, err = json.Marshal(billOfLading)
 	if err != nil {
 		return shim.Error("Error marshaling bill of lading structure")
@@ -844,12 +1394,16 @@ func (t *TradeWorkflowChaincode) acceptShipmentAndIssueBL(stub shim.ChaincodeStu
 	return shim.Success(nil)
 }
 
-// Request an L/C Transfer to Lender Org
+// Request the transfer of all or part of the amount an L/C's first beneficiary retains to a lender, as a second beneficiary
 func (t *TradeWorkflowChaincode) requestLCTransfer(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
-	var lcKey, paymentKey, shipmentLocationKey string
+	var lcKey, paymentKey, shipmentLocationKey, discountRateValue, annualRateValue, dayCount, expectedPaymentDate, amountStr, lender string
 	var letterOfCreditBytes, lenderBytes, paymentBytes, shipmentLocationBytes []byte
-	var discountRate float64
+	var discountRate, annualRate float64
+	var expectedPayment, expiration time.Time
+	var amount, retained int
+	var discounted, found bool
 	var letterOfCredit *LetterOfCredit
+	var receivable *Receivable
 	var err error
 
 	// Access control: Only an Exporter Org member can invoke this transaction
@@ -857,8 +1411,8 @@ func (t *TradeWorkflowChaincode) requestLCTransfer(stub shim.ChaincodeStubInterf
 		return shim.Error("Caller not a member of Exporter Org. Access denied.")
 	}
 
-	if len(args) != 2 {
-		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 2: {Trade ID, DiscountRate}. Found %d", len(args)))
+	if len(args) != 1 && len(args) != 2 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 1: {Trade ID}, or 2: {Trade ID, Lender}. Found %d", len(args)))
 		return shim.Error(err.Error())
 	}
 
@@ -878,24 +1432,49 @@ func (t *TradeWorkflowChaincode) requestLCTransfer(stub shim.ChaincodeStubInterf
 		return shim.Error(err.Error())
 	}
 
-	// Lookup lender (L/C beneficiary)
-	lenderBytes, err = stub.GetState(lenKey)
+	// Lookup L/C amount and transfers from the private terms
+	err = getLetterOfCreditTerms(stub, lcKey, letterOfCredit)
 	if err != nil {
 		return shim.Error(err.Error())
 	}
 
-	// Check if there's a pending payment request
-	paymentKey, err = getPaymentKey(stub, args[0])
+	// The second beneficiary is the lender configured at Init, unless a registered lender is named
+	if len(args) == 2 {
+		lender = args[1]
+	} else {
+		lenderBytes, err = stub.GetState(lenKey)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		lender = string(lenderBytes)
+	}
+	_, err = getLenderAccount(stub, lender)
 	if err != nil {
 		return shim.Error(err.Error())
 	}
-	paymentBytes, err = stub.GetState(paymentKey)
+
+	// An L/C posted for financing goes to the winning bidder instead
+	_, receivable, err = getReceivableRecord(stub, args[0])
 	if err != nil {
 		return shim.Error(err.Error())
 	}
+	if receivable != nil && receivable.Status == OPEN {
+		fmt.Printf("L/C for trade %s is posted for financing until %s\n", args[0], receivable.BidDeadline)
+		return shim.Error("L/C posted for financing")
+	}
 
-	if len(paymentBytes) != 0 {
-		fmt.Printf("Payment request pending for trade %s\n", args[0])
+	// Check if there's a pending payment request
+	paymentKey, err = getPaymentKey(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	paymentBytes, err = stub.GetState(paymentKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if len(paymentBytes) != 0 {
+		fmt.Printf("Payment request pending for trade %s\n", args[0])
 		return shim.Error("Payment request pending")
 	}
 
@@ -915,16 +1494,66 @@ func (t *TradeWorkflowChaincode) requestLCTransfer(stub shim.ChaincodeStubInterf
 		return shim.Error("Shipment not prepared yet")
 	}
 
-	// Parse discount rate
-	discountRate, err = strconv.ParseFloat(string(args[1]), 2)
+	// The transfer is financed at a flat discount rate, or at an annual interest rate up to an expected payment date (passed in the transient map)
+	discountRateValue, discounted, err = getOptionalTransientValue(stub, "discountRate")
 	if err != nil {
 		return shim.Error(err.Error())
 	}
+	annualRateValue, found, err = getOptionalTransientValue(stub, "annualRate")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if discounted == found {
+		return shim.Error("Expecting either a discountRate or an annualRate in the transient map")
+	}
+	if discounted {
+		discountRate, err = strconv.ParseFloat(discountRateValue, 32)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if discountRate < 0 || discountRate >= 1 {
+			err = errors.New(fmt.Sprintf("Discount rate %s must be at least 0 and below 1", discountRateValue))
+			return shim.Error(err.Error())
+		}
+	} else {
+		annualRate, err = strconv.ParseFloat(annualRateValue, 32)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if annualRate <= 0 || annualRate >= 1 {
+			err = errors.New(fmt.Sprintf("Annual rate %s must be between 0 and 1", annualRateValue))
+			return shim.Error(err.Error())
+		}
+		dayCount, found, err = getOptionalTransientValue(stub, "dayCount")
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if !found {
+			dayCount = ACT_360
+		}
+		_, err = getDayCountBasis(dayCount)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
 
-	// Check if there is available amount of credit
-	if letterOfCredit.Amount <= 0 {
-		fmt.Printf("L/C for trade %s doesn't have available amount of credit\n", args[0])
-		return shim.Error("L/C no available amount of credit")
+		// The L/C must be payable on the expected payment date
+		expectedPaymentDate, err = getTransientValue(stub, "expectedPaymentDate")
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		expectedPayment, err = time.Parse(dateLayout, expectedPaymentDate)
+		if err != nil {
+			err = errors.New(fmt.Sprintf("Invalid expected payment date %s; expecting MM/DD/YYYY", expectedPaymentDate))
+			return shim.Error(err.Error())
+		}
+		expiration, err = time.Parse(dateLayout, letterOfCredit.ExpirationDate)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if expectedPayment.After(expiration) {
+			err = errors.New(fmt.Sprintf("Expected payment date %s is after the L/C expiration date %s", expectedPaymentDate, letterOfCredit.ExpirationDate))
+			return shim.Error(err.Error())
+		}
 	}
 
 	if letterOfCredit.Status == REQUESTED || letterOfCredit.Status == ISSUED {
@@ -934,12 +1563,39 @@ func (t *TradeWorkflowChaincode) requestLCTransfer(stub shim.ChaincodeStubInterf
 		fmt.Printf("L/C transfer for trade %s already requested\n", args[0])
 	} else if letterOfCredit.Status == TRANSFER_ISSUED {
 		fmt.Printf("L/C transfer for trade %s already issued\n", args[0])
-	} else if letterOfCredit.Status == TRANSFER_ACCEPTED {
-		fmt.Printf("L/C transfer for trade %s already accepted\n", args[0])
 	} else {
-		letterOfCredit.Beneficiary = string(lenderBytes)
-		letterOfCredit.DiscountRate = float32(discountRate)
+		// Check if there is available amount for transfer; the first beneficiary transfers all it retains unless an amount is given
+		retained = getLCRetainedAmount(letterOfCredit)
+		if retained <= 0 {
+			fmt.Printf("L/C for trade %s doesn't have available amount for transfer\n", args[0])
+			return shim.Error("L/C no available amount for transfer")
+		}
+		amount = retained
+		amountStr, found, err = getOptionalTransientValue(stub, "amount")
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if found {
+			amount, err = strconv.Atoi(amountStr)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+			if amount <= 0 || amount > retained {
+				err = errors.New(fmt.Sprintf("Transfer amount must be positive and not exceed %d, the amount available for transfer", retained))
+				return shim.Error(err.Error())
+			}
+		}
+		if getLCTransfer(letterOfCredit, lender) != nil {
+			err = errors.New(fmt.Sprintf("L/C for trade %s already transferred to %s", args[0], lender))
+			return shim.Error(err.Error())
+		}
+
+		letterOfCredit.Transfers = append(letterOfCredit.Transfers, LCTransfer{lender, REQUESTED, amount, float32(discountRate), float32(annualRate), dayCount, expectedPaymentDate, false})
 		letterOfCredit.Status = TRANSFER_REQUESTED
+		err = putLetterOfCreditTerms(stub, lcKey, letterOfCredit)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
 		letterOfCreditBytes, err = json.Marshal(letterOfCredit)
 		if err != nil {
 			return shim.Error("Error marshaling L/C structure")
@@ -988,6 +1644,12 @@ func (t *TradeWorkflowChaincode) issueLCTransfer(stub shim.ChaincodeStubInterfac
 		return shim.Error(err.Error())
 	}
 
+	// Lookup L/C amount and transfers from the private terms
+	err = getLetterOfCreditTerms(stub, lcKey, letterOfCredit)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
 	// Check if there's a pending payment request
 	paymentKey, err = getPaymentKey(stub, args[0])
 	if err != nil {
@@ -1033,6 +1695,7 @@ func (t *TradeWorkflowChaincode) issueLCTransfer(stub shim.ChaincodeStubInterfac
 	} else if letterOfCredit.Status == TRANSFER_ACCEPTED {
 		fmt.Printf("L/C transfer for trade %s already accepted\n", args[0])
 	} else {
+		getLatestLCTransfer(letterOfCredit).Status = ISSUED
 		letterOfCredit.Status = TRANSFER_ISSUED
 		letterOfCreditBytes, err = json.Marshal(letterOfCredit)
 		if err != nil {
@@ -1052,8 +1715,9 @@ func (t *TradeWorkflowChaincode) issueLCTransfer(stub shim.ChaincodeStubInterfac
 // Accept an L/C transfer and make an advance payment
 func (t *TradeWorkflowChaincode) acceptLCTransfer(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
 	var lcKey, paymentKey, shipmentLocationKey string
-	var letterOfCreditBytes, paymentBytes, shipmentLocationBytes []byte
+	var letterOfCreditBytes, paymentBytes, shipmentLocationBytes, exporterBytes []byte
 	var letterOfCredit *LetterOfCredit
+	var transfer *LCTransfer
 	var err error
 
 	// Access control: Only an Lender Org member can invoke this transaction
@@ -1082,6 +1746,12 @@ func (t *TradeWorkflowChaincode) acceptLCTransfer(stub shim.ChaincodeStubInterfa
 		return shim.Error(err.Error())
 	}
 
+	// Lookup L/C amount and transfers from the private terms
+	err = getLetterOfCreditTerms(stub, lcKey, letterOfCredit)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
 	// Check if there's a pending payment request
 	paymentKey, err = getPaymentKey(stub, args[0])
 	if err != nil {
@@ -1125,6 +1795,16 @@ func (t *TradeWorkflowChaincode) acceptLCTransfer(stub shim.ChaincodeStubInterfa
 	} else if letterOfCredit.Status == TRANSFER_ACCEPTED {
 		fmt.Printf("L/C transfer for trade %s already accepted\n", args[0])
 	} else {
+		// Access control: Only a Lender Org member acting for the new beneficiary can accept the transfer
+		transfer = getLatestLCTransfer(letterOfCredit)
+		if !t.testMode {
+			err = authorizeLender(stub, creatorOrg, creatorCertIssuer, transfer.Beneficiary)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+		}
+
+		transfer.Status = ACCEPTED
 		letterOfCredit.Status = TRANSFER_ACCEPTED
 		letterOfCreditBytes, err = json.Marshal(letterOfCredit)
 		if err != nil {
@@ -1134,6 +1814,16 @@ func (t *TradeWorkflowChaincode) acceptLCTransfer(stub shim.ChaincodeStubInterfa
 		if err != nil {
 			return shim.Error(err.Error())
 		}
+
+		// Payments to the new beneficiary shortly after the transfer are monitored
+		exporterBytes, err = stub.GetState(expKey)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		err = recordLCTransferActivity(stub, args[0], string(exporterBytes), transfer.Beneficiary)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
 	}
 	fmt.Printf("L/C transfer acceptance for trade %s recorded\n", args[0])
 
@@ -1143,8 +1833,9 @@ func (t *TradeWorkflowChaincode) acceptLCTransfer(stub shim.ChaincodeStubInterfa
 // Request an advance payment
 func (t *TradeWorkflowChaincode) requestAdvancePayment(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
 	var lcKey, advancePaymentKey string
-	var letterOfCreditBytes, lenderBytes, advancePaymentBytes []byte
+	var letterOfCreditBytes, advancePaymentBytes []byte
 	var letterOfCredit *LetterOfCredit
+	var transfer *LCTransfer
 	var err error
 
 	// Access control: Only an Exporter member can invoke this transaction
@@ -1152,8 +1843,8 @@ func (t *TradeWorkflowChaincode) requestAdvancePayment(stub shim.ChaincodeStubIn
 		return shim.Error("Caller not a member of Exporter Org. Access denied.")
 	}
 
-	if len(args) != 1 {
-		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 1: {Trade ID}. Found %d", len(args)))
+	if len(args) != 1 && len(args) != 2 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 1: {Trade ID}, or 2: {Trade ID, Lender}. Found %d", len(args)))
 		return shim.Error(err.Error())
 	}
 
@@ -1173,12 +1864,6 @@ func (t *TradeWorkflowChaincode) requestAdvancePayment(stub shim.ChaincodeStubIn
 		return shim.Error(err.Error())
 	}
 
-	// Lookup lender
-	lenderBytes, err = stub.GetState(lenKey)
-	if err != nil {
-		return shim.Error(err.Error())
-	}
-
 	// Check if there's already a pending advance payment request
 	advancePaymentKey, err = getAdvancePaymentKey(stub, args[0])
 	if err != nil {
@@ -1189,24 +1874,31 @@ func (t *TradeWorkflowChaincode) requestAdvancePayment(stub shim.ChaincodeStubIn
 		return shim.Error(err.Error())
 	}
 
-	if len(advancePaymentBytes) != 0 { // The value doesn't matter as this is a temporary key used as a marker
+	if len(advancePaymentBytes) != 0 { // The value names the lender to make the advance, as this is a temporary key used as a marker
 		fmt.Printf("Advance payment request already pending for trade %s\n", args[0])
 	} else {
-		if letterOfCredit.Status != TRANSFER_ACCEPTED {
+		// The advance is requested from the second beneficiary of the latest transfer, unless a lender is named
+		if len(args) == 2 {
+			transfer = getLCTransfer(letterOfCredit, args[1])
+		} else {
+			transfer = getLatestLCTransfer(letterOfCredit)
+		}
+		if transfer == nil || transfer.Status != ACCEPTED {
 			fmt.Printf("L/C transfer not accepted for trade %s\n", args[0])
 			return shim.Error("L/C transfer not accepted")
 		}
-		if letterOfCredit.Beneficiary != string(lenderBytes) {
+		_, err = getLenderAccount(stub, transfer.Beneficiary)
+		if err != nil {
 			fmt.Printf("L/C beneficiary is not lender for trade %s\n", args[0])
 			return shim.Error("L/C beneficiary not lender")
 		}
-		if letterOfCredit.AdvancePaymentSettlement == true { // Advance payment has already been settled
+		if transfer.AdvancePaymentSettlement == true { // Advance payment has already been settled
 			fmt.Printf("Advance payment already settled for trade %s\n", args[0])
 			return shim.Error("Advance payment already settled")
 		}
 
 		// Record request on ledger
-		err = stub.PutState(advancePaymentKey, []byte(REQUESTED))
+		err = stub.PutState(advancePaymentKey, []byte(transfer.Beneficiary))
 		if err != nil {
 			return shim.Error(err.Error())
 		}
@@ -1217,11 +1909,15 @@ func (t *TradeWorkflowChaincode) requestAdvancePayment(stub shim.ChaincodeStubIn
 
 // Make an advance payment
 func (t *TradeWorkflowChaincode) makeAdvancePayment(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
-	var lcKey, shipmentLocationKey, advancePaymentKey string
-	var paymentAmount, expBal, lenBal int
+	var lcKey, shipmentLocationKey, advancePaymentKey, lenderBalKey, receivableKey, financingPositionKey string
+	var paymentAmount, interest, expBal, lenBal int
 	var fullRate float32
-	var letterOfCreditBytes, shipmentLocationBytes, advancePaymentBytes, lenderBytes, expBalBytes, lenBalBytes []byte
+	var now, expectedPayment time.Time
+	var letterOfCreditBytes, shipmentLocationBytes, advancePaymentBytes, exporterBytes, expBalBytes, lenBalBytes []byte
 	var letterOfCredit *LetterOfCredit
+	var transfer *LCTransfer
+	var receivable *Receivable
+	var financingBid *FinancingBid
 	var err error
 
 	// Access control: Only an Lender Org member can invoke this transaction
@@ -1265,23 +1961,34 @@ func (t *TradeWorkflowChaincode) makeAdvancePayment(stub shim.ChaincodeStubInter
 		return shim.Error(err.Error())
 	}
 
-	// Check if L/C transfer has been accepted
-	if letterOfCredit.Status != TRANSFER_ACCEPTED {
+	// Lookup L/C amount and transfers from the private terms
+	err = getLetterOfCreditTerms(stub, lcKey, letterOfCredit)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Check if the L/C transfer to the lender the advance was requested from has been accepted
+	transfer = getLCTransfer(letterOfCredit, string(advancePaymentBytes))
+	if transfer == nil || transfer.Status != ACCEPTED {
 		fmt.Printf("L/C transfer not accepted for trade %s\n", args[0])
 		return shim.Error("L/C transfer not accepted")
 	}
 
-	// Lookup lender
-	lenderBytes, err = stub.GetState(lenKey)
+	// The second beneficiary is the lender configured at Init, or a registered lender
+	lenderBalKey, err = getLenderAccount(stub, transfer.Beneficiary)
 	if err != nil {
-		return shim.Error(err.Error())
-	}
-
-	if letterOfCredit.Beneficiary != string(lenderBytes) {
 		fmt.Printf("L/C beneficiary is not lender for trade %s\n", args[0])
 		return shim.Error("L/C beneficiary not lender")
 	}
 
+	// Access control: Only a Lender Org member acting for the beneficiary can invoke this transaction
+	if !t.testMode {
+		err = authorizeLender(stub, creatorOrg, creatorCertIssuer, transfer.Beneficiary)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
 	// Lookup shipment location from the ledger
 	shipmentLocationKey, err = getShipmentLocationKey(stub, args[0])
 	if err != nil {
@@ -1299,7 +2006,7 @@ func (t *TradeWorkflowChaincode) makeAdvancePayment(stub shim.ChaincodeStubInter
 	}
 
 	// Lookup account balances
-	expBalBytes, err = stub.GetState(expBalKey)
+	expBalBytes, err = getPrivateData(stub, accountBalancesCollection, expBalKey)
 	if err != nil {
 		return shim.Error(err.Error())
 	}
@@ -1307,7 +2014,7 @@ func (t *TradeWorkflowChaincode) makeAdvancePayment(stub shim.ChaincodeStubInter
 	if err != nil {
 		return shim.Error(err.Error())
 	}
-	lenBalBytes, err = stub.GetState(lenBalKey)
+	lenBalBytes, err = getPrivateData(stub, accountBalancesCollection, lenderBalKey)
 	if err != nil {
 		return shim.Error(err.Error())
 	}
@@ -1318,13 +2025,76 @@ func (t *TradeWorkflowChaincode) makeAdvancePayment(stub shim.ChaincodeStubInter
 
 	// Record transfer of funds
 	fullRate = 1.0
-	paymentAmount = int((fullRate - letterOfCredit.DiscountRate) * float32(letterOfCredit.Amount))
+	now, err = getTxTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if transfer.AnnualRate > 0 {
+		// Interest is charged up front to the expected payment date, and trued up as the importer pays
+		expectedPayment, err = time.Parse(dateLayout, transfer.ExpectedPaymentDate)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if !now.Before(expectedPayment.AddDate(0, 0, 1)) {
+			fmt.Printf("Expected payment date %s of the L/C transfer for trade %s has passed\n", transfer.ExpectedPaymentDate, args[0])
+			return shim.Error("Expected payment date passed")
+		}
+		interest, err = getAccruedInterest(transfer.Amount, transfer.AnnualRate, transfer.DayCount, now, expectedPayment)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		paymentAmount = transfer.Amount - interest
+	} else {
+		paymentAmount = int((fullRate - transfer.DiscountRate) * float32(transfer.Amount))
+	}
+
+	// A lender that won the L/C's financing advances its bid's share, holding back the rest as a reserve until collection
+	receivableKey, receivable, err = getReceivableRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if receivable != nil && receivable.Status == AWARDED && receivable.WinningBidder == transfer.Beneficiary {
+		_, financingBid, err = getFinancingBidRecord(stub, args[0], receivable.WinningBidder)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		receivable.Reserve = paymentAmount - int(financingBid.AdvanceRate * float32(paymentAmount))
+		paymentAmount -= receivable.Reserve
+		err = putReceivableRecord(stub, receivableKey, receivable)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
 	expBal += paymentAmount
 	if lenBal < paymentAmount {
 		fmt.Printf("Lender's bank balance %d is insufficient to cover payment amount %d\n", lenBal, paymentAmount)
 	}
 	lenBal -= paymentAmount
-	letterOfCredit.AdvancePaymentSettlement = true
+	transfer.AdvancePaymentSettlement = true
+
+	// Evaluate the AML rules on the advance
+	exporterBytes, err = stub.GetState(expKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = monitorPayment(stub, "makeAdvancePayment", args[0], transfer.Beneficiary, string(exporterBytes), paymentAmount)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	_, err = recordPayment(stub, args[0], "", ADVANCE_PAYMENT, "makeAdvancePayment", transfer.Beneficiary, string(exporterBytes), paymentAmount, 0, "")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Open the lender's financing position, to be reconciled as the importer pays its part of the L/C
+	financingPositionKey, err = getFinancingPositionKey(stub, args[0], transfer.Beneficiary)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = putFinancingPositionRecord(stub, financingPositionKey, &FinancingPosition{args[0], transfer.Beneficiary, OPEN, letterOfCredit.ExpirationDate, transfer.DayCount, transfer.ExpectedPaymentDate, now.Format(time.RFC3339), "", paymentAmount, transfer.Amount, 0, transfer.Amount, transfer.AnnualRate, interest, 0, 0, ""})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
 
 	// Update ledger state
 	letterOfCreditBytes, err = json.Marshal(letterOfCredit)
@@ -1335,11 +2105,11 @@ func (t *TradeWorkflowChaincode) makeAdvancePayment(stub shim.ChaincodeStubInter
 	if err != nil {
 		return shim.Error(err.Error())
 	}
-	err = stub.PutState(expBalKey, []byte(strconv.Itoa(expBal)))
+	err = putPrivateData(stub, accountBalancesCollection, expBalKey, []byte(strconv.Itoa(expBal)))
 	if err != nil {
 		return shim.Error(err.Error())
 	}
-	err = stub.PutState(lenBalKey, []byte(strconv.Itoa(lenBal)))
+	err = putPrivateData(stub, accountBalancesCollection, lenderBalKey, []byte(strconv.Itoa(lenBal)))
 	if err != nil {
 		return shim.Error(err.Error())
 	}
@@ -1357,9 +2127,10 @@ func (t *TradeWorkflowChaincode) makeAdvancePayment(stub shim.ChaincodeStubInter
 // Request a payment
 func (t *TradeWorkflowChaincode) requestPayment(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
 	var lcKey, shipmentLocationKey, paymentKey, tradeKey string
-	var letterOfCreditBytes, shipmentLocationBytes, paymentBytes, exporterBytes, lenderBytes, tradeAgreementBytes []byte
+	var letterOfCreditBytes, shipmentLocationBytes, paymentBytes, tradeAgreementBytes []byte
 	var tradeAgreement *TradeAgreement
 	var letterOfCredit *LetterOfCredit
+	var partialShipment *PartialShipment
 	var err error
 
 	// Access control: Only an Exporter or Lender Org member can invoke this transaction
@@ -1367,8 +2138,8 @@ func (t *TradeWorkflowChaincode) requestPayment(stub shim.ChaincodeStubInterface
 		return shim.Error("Caller not a member of Exporter or Lender Org. Access denied.")
 	}
 
-	if len(args) != 1 {
-		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 1: {Trade ID}. Found %d", len(args)))
+	if len(args) != 1 && len(args) != 2 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 1: {Trade ID}, or 2: {Trade ID, Shipment ID}. Found %d", len(args)))
 		return shim.Error(err.Error())
 	}
 
@@ -1393,6 +2164,12 @@ func (t *TradeWorkflowChaincode) requestPayment(stub shim.ChaincodeStubInterface
 		return shim.Error(err.Error())
 	}
 
+	// Lookup trade amount and payment from the private terms
+	err = getTradeTerms(stub, tradeKey, tradeAgreement)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
 	// Lookup L/C from the ledger
 	lcKey, err = getLCKey(stub, args[0])
 	if err != nil {
@@ -1409,36 +2186,44 @@ func (t *TradeWorkflowChaincode) requestPayment(stub shim.ChaincodeStubInterface
 		return shim.Error(err.Error())
 	}
 
-	// Lookup exporter
-	exporterBytes, err = stub.GetState(expKey)
-	if err != nil {
-		return shim.Error(err.Error())
-	}
-
-	// Lookup lender
-	lenderBytes, err = stub.GetState(lenKey)
+	// Lookup L/C amount and transfers from the private terms
+	err = getLetterOfCreditTerms(stub, lcKey, letterOfCredit)
 	if err != nil {
 		return shim.Error(err.Error())
 	}
 
 	// Lookup shipment location from the ledger
-	shipmentLocationKey, err = getShipmentLocationKey(stub, args[0])
-	if err != nil {
-		return shim.Error(err.Error())
-	}
+	// A partial shipment is paid for in proportion to the quantity shipped under its B/L
+	if len(args) == 2 {
+		_, _, partialShipment, err = getPartialShipment(stub, args[0], args[1])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if partialShipment.BillOfLadingId == "" {
+			fmt.Printf("No B/L issued for partial shipment %s of trade %s\n", args[1], args[0])
+			return shim.Error("B/L not issued yet")
+		}
+		shipmentLocationBytes = []byte(partialShipment.Location)
+		paymentKey, err = getPartialPaymentKey(stub, args[0], args[1])
+	} else {
+		shipmentLocationKey, err = getShipmentLocationKey(stub, args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
 
-	shipmentLocationBytes, err = stub.GetState(shipmentLocationKey)
-	if err != nil {
-		return shim.Error(err.Error())
-	}
+		shipmentLocationBytes, err = stub.GetState(shipmentLocationKey)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
 
-	if len(shipmentLocationBytes) == 0 {
-		fmt.Printf("Shipment for trade %s has not been prepared yet\n", args[0])
-		return shim.Error("Shipment not prepared yet")
+		if len(shipmentLocationBytes) == 0 {
+			fmt.Printf("Shipment for trade %s has not been prepared yet\n", args[0])
+			return shim.Error("Shipment not prepared yet")
+		}
+		paymentKey, err = getPaymentKey(stub, args[0])
 	}
 
 	// Check if there's already a pending payment request
-	paymentKey, err = getPaymentKey(stub, args[0])
 	if err != nil {
 		return shim.Error(err.Error())
 	}
@@ -1452,11 +2237,11 @@ func (t *TradeWorkflowChaincode) requestPayment(stub shim.ChaincodeStubInterface
 	} else {
 		// Check what has been paid up to this point
 		fmt.Printf("Amount paid thus far for trade %s = %d; total required = %d\n", args[0], tradeAgreement.Payment, tradeAgreement.Amount)
-		if tradeAgreement.Amount == tradeAgreement.Payment { // Payment has already been settled
+		if tradeAgreement.Payment + tradeAgreement.Penalty >= tradeAgreement.Amount || (partialShipment != nil && partialShipment.PaymentStatus == PAID) { // Payment has already been settled, net of any delay penalty
 			fmt.Printf("Payment already settled for trade %s\n", args[0])
 			return shim.Error("Payment already settled")
 		}
-		if string(shipmentLocationBytes) == SOURCE && tradeAgreement.Payment != 0 { // Suppress duplicate requests for partial payment
+		if string(shipmentLocationBytes) == SOURCE && ((partialShipment == nil && tradeAgreement.Payment != 0) || (partialShipment != nil && partialShipment.PaymentStatus == PARTIALLY_PAID)) { // Suppress duplicate requests for partial payment
 			fmt.Printf("Partial payment already made for trade %s\n", args[0])
 			return shim.Error("Partial payment already made")
 		}
@@ -1464,7 +2249,7 @@ func (t *TradeWorkflowChaincode) requestPayment(stub shim.ChaincodeStubInterface
 			fmt.Printf("L/C not accepted for trade %s\n", args[0])
 			return shim.Error("L/C not accepted")
 		}
-		if !t.testMode && !((authenticateExporterOrg(creatorOrg, creatorCertIssuer) && letterOfCredit.Beneficiary == string(exporterBytes)) || (authenticateLenderOrg(creatorOrg, creatorCertIssuer) && letterOfCredit.Beneficiary == string(lenderBytes))) {
+		if !t.testMode && !authorizeLCBeneficiary(stub, creatorOrg, creatorCertIssuer, letterOfCredit) {
 			fmt.Printf("Payment requestor and L/C benificiary not match for trade %s\n", args[0])
 			return shim.Error("Payment requestor and L/C benificiary not match")
 		}
@@ -1481,11 +2266,16 @@ func (t *TradeWorkflowChaincode) requestPayment(stub shim.ChaincodeStubInterface
 
 // Make a payment
 func (t *TradeWorkflowChaincode) makePayment(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
-	var lcKey, shipmentLocationKey, arrivalDateKey, paymentKey, tradeKey, referDate string
-	var paymentAmount, expBal, impBal, lenBal, paymentDuration, halfPaymentDuration int
-	var letterOfCreditBytes, shipmentLocationBytes, arrivalDateBytes, paymentBytes, tradeAgreementBytes, exporterBytes, lenderBytes, impBalBytes, expBalBytes, lenBalBytes []byte
+	var lcKey, shipmentLocationKey, arrivalDateKey, paymentKey, tradeKey, partialShipmentsKey, shipmentID, referDate string
+	var paymentAmount, dueAmount, paidAmount, surchargeAmount, penalty, delayDays, paymentDuration, halfPaymentDuration int
+	var letterOfCreditBytes, shipmentLocationBytes, arrivalDateBytes, paymentBytes, tradeAgreementBytes []byte
+	var balances map[string]int
+	var paymentRecords, trueUpRecords []PaymentRecord
+	var reserveRecord *PaymentRecord
 	var letterOfCredit *LetterOfCredit
 	var tradeAgreement *TradeAgreement
+	var partialShipments *PartialShipments
+	var partialShipment *PartialShipment
 	var err error
 
 	// Refer date for date parsing
@@ -1499,13 +2289,17 @@ func (t *TradeWorkflowChaincode) makePayment(stub shim.ChaincodeStubInterface, c
 		return shim.Error("Caller not a member of Importer Org. Access denied.")
 	}
 
-	if len(args) != 2 {
-		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 2: {Trade ID, Payment Date}. Found %d", len(args)))
+	if len(args) != 2 && len(args) != 3 {
+		err = errors.New(fmt.Sprintf("Incorrect number of arguments. Expecting 2: {Trade ID, Payment Date}, or 3: {Trade ID, Payment Date, Shipment ID}. Found %d", len(args)))
 		return shim.Error(err.Error())
 	}
 
 	// Check if there's already a pending payment request
-	paymentKey, err = getPaymentKey(stub, args[0])
+	if len(args) == 3 {
+		paymentKey, err = getPartialPaymentKey(stub, args[0], args[2])
+	} else {
+		paymentKey, err = getPaymentKey(stub, args[0])
+	}
 	if err != nil {
 		return shim.Error(err.Error())
 	}
@@ -1540,6 +2334,12 @@ func (t *TradeWorkflowChaincode) makePayment(stub shim.ChaincodeStubInterface, c
 		return shim.Error(err.Error())
 	}
 
+	// Lookup trade amount and payment from the private terms
+	err = getTradeTerms(stub, tradeKey, tradeAgreement)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
 	// Lookup L/C from the ledger
 	lcKey, err = getLCKey(stub, args[0])
 	if err != nil {
@@ -1556,71 +2356,47 @@ func (t *TradeWorkflowChaincode) makePayment(stub shim.ChaincodeStubInterface, c
 		return shim.Error(err.Error())
 	}
 
-	// Check if L/C has been accepted
-	if !(letterOfCredit.Status == ACCEPTED || letterOfCredit.Status == TRANSFER_ACCEPTED) {
-		fmt.Printf("L/C not accepted for trade %s\n", args[0])
-		return shim.Error("L/C not accepted")
-	}
-
-	// Lookup exporter
-	exporterBytes, err = stub.GetState(expKey)
-	if err != nil {
-		return shim.Error(err.Error())
-	}
-
-	// Lookup lender
-	lenderBytes, err = stub.GetState(lenKey)
+	// Lookup L/C amount and transfers from the private terms
+	err = getLetterOfCreditTerms(stub, lcKey, letterOfCredit)
 	if err != nil {
 		return shim.Error(err.Error())
 	}
 
-	// Lookup shipment location from the ledger
-	shipmentLocationKey, err = getShipmentLocationKey(stub, args[0])
-	if err != nil {
-		return shim.Error(err.Error())
+	// Check if L/C has been accepted
+	if !(letterOfCredit.Status == ACCEPTED || letterOfCredit.Status == TRANSFER_ACCEPTED) {
+		fmt.Printf("L/C not accepted for trade %s\n", args[0])
+		return shim.Error("L/C not accepted")
 	}
 
-	shipmentLocationBytes, err = stub.GetState(shipmentLocationKey)
-	if err != nil {
-		return shim.Error(err.Error())
-	}
+	// Lookup shipment location and arrival date from the ledger
+	// A partial shipment is owed its share of the trade amount; a trade shipped in full is owed the trade amount
+	if len(args) == 3 {
+		partialShipmentsKey, partialShipments, partialShipment, err = getPartialShipment(stub, args[0], args[2])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		shipmentLocationBytes = []byte(partialShipment.Location)
+		arrivalDateBytes = []byte(partialShipment.ArrivalDate)
+		dueAmount = getPartialShipmentShare(partialShipments, args[2], tradeAgreement.Amount, tradeAgreement.Quantity)
+		if partialShipment.PaymentStatus == PARTIALLY_PAID {
+			paidAmount = dueAmount / 2
+		}
+	} else {
+		shipmentLocationKey, err = getShipmentLocationKey(stub, args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
 
-	if len(shipmentLocationBytes) == 0 {
-		fmt.Printf("Shipment for trade %s has not been prepared yet\n", args[0])
-		return shim.Error("Shipment not prepared yet")
-	}
+		shipmentLocationBytes, err = stub.GetState(shipmentLocationKey)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
 
-	// Lookup account balances
-	expBalBytes, err = stub.GetState(expBalKey)
-	if err != nil {
-		return shim.Error(err.Error())
-	}
-	expBal, err = strconv.Atoi(string(expBalBytes))
-	if err != nil {
-		return shim.Error(err.Error())
-	}
-	impBalBytes, err = stub.GetState(impBalKey)
-	if err != nil {
-		return shim.Error(err.Error())
-	}
-	impBal, err = strconv.Atoi(string(impBalBytes))
-	if err != nil {
-		return shim.Error(err.Error())
-	}
-	lenBalBytes, err = stub.GetState(lenBalKey)
-	if err != nil {
-		return shim.Error(err.Error())
-	}
-	lenBal, err = strconv.Atoi(string(lenBalBytes))
-	if err != nil {
-		return shim.Error(err.Error())
-	}
+		if len(shipmentLocationBytes) == 0 {
+			fmt.Printf("Shipment for trade %s has not been prepared yet\n", args[0])
+			return shim.Error("Shipment not prepared yet")
+		}
 
-	// Record transfer of funds
-	if string(shipmentLocationBytes) == SOURCE {
-		paymentAmount = tradeAgreement.Amount / 2
-	} else {
-		// Get ArrivalDate
 		arrivalDateKey, err = getArrivalDateKey(stub, args[0])
 		if err != nil {
 			return shim.Error(err.Error())
@@ -1630,7 +2406,21 @@ func (t *TradeWorkflowChaincode) makePayment(stub shim.ChaincodeStubInterface, c
 		if err != nil {
 			return shim.Error(err.Error())
 		}
+		dueAmount = tradeAgreement.Amount
+		paidAmount = tradeAgreement.Payment
+	}
 
+	// Lookup importer balance; the beneficiaries' balances are looked up as they are paid
+	balances = map[string]int{}
+	err = loadAccountBalance(stub, balances, impBalKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Record transfer of funds
+	if string(shipmentLocationBytes) == SOURCE {
+		paymentAmount = dueAmount / 2
+	} else {
 		if len(arrivalDateBytes) == 0 {
 			fmt.Printf("Arrival date of shipment for trade %s missing\n", args[0])
 			return shim.Error("Arrival date missing")
@@ -1648,32 +2438,75 @@ func (t *TradeWorkflowChaincode) makePayment(stub shim.ChaincodeStubInterface, c
 		delta := cd.Sub(ad).Hours()
 		surcharge := (float32(delta) - float32(paymentDuration)) / float32(halfPaymentDuration)
 
-		initialPaymentAmount := tradeAgreement.Amount - tradeAgreement.Payment
+		initialPaymentAmount := dueAmount - paidAmount
 		if surcharge <= 0 {
 			paymentAmount = initialPaymentAmount
 		} else {
 			paymentAmount = int(float32(initialPaymentAmount) * (1.0 + 0.05 * surcharge))
+			surchargeAmount = paymentAmount - initialPaymentAmount
 			fmt.Printf("Payment is increased by surcharge due to late payment after deadline (60 days after arrival)\n")
 		}
+
+		// Net the penalty for late delivery, credited to the importer, against the amount owed
+		if tradeAgreement.DelayPenaltyRate > 0 {
+			_, delayDays, err = getDeliveryDelay(stub, args[0], string(arrivalDateBytes))
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+			penalty = int(float32(dueAmount) * tradeAgreement.DelayPenaltyRate * float32(delayDays))
+			if penalty > paymentAmount {
+				penalty = paymentAmount
+			}
+			paymentAmount -= penalty
+			if penalty > 0 {
+				fmt.Printf("Payment is reduced by a penalty of %d for delivery %d days after the delivery window\n", penalty, delayDays)
+			}
+		}
 	}
 
 	tradeAgreement.Payment += paymentAmount
-	letterOfCredit.Amount -= paymentAmount
-	if letterOfCredit.Beneficiary == string(exporterBytes) {
-		expBal += paymentAmount
-	} else if letterOfCredit.Beneficiary == string(lenderBytes) {
-		lenBal += paymentAmount
-	} else {
-		fmt.Printf("L/C for trade %s does not have vaild beneficiary\n", args[0])
-		return shim.Error("Beneficiary in L/C not valid")
+	tradeAgreement.Penalty += penalty
+
+	if balances[impBalKey] < paymentAmount {
+		fmt.Printf("Importer's bank balance %d is insufficient to cover payment amount %d\n", balances[impBalKey], paymentAmount)
 	}
+	balances[impBalKey] -= paymentAmount
 
-	if impBal < paymentAmount {
-		fmt.Printf("Importer's bank balance %d is insufficient to cover payment amount %d\n", impBal, paymentAmount)
+	// Distribute the payment among the L/C's beneficiaries, pro rata to their parts of the L/C amount
+	if len(args) == 3 {
+		shipmentID = args[2]
+	}
+	paymentRecords, err = payLCBeneficiaries(stub, args[0], shipmentID, "makePayment", letterOfCredit, paymentAmount, paymentAmount + penalty, surchargeAmount, balances)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// A reserve held back from a financing advance is due to the exporter once the trade is paid in full
+	if tradeAgreement.Payment + tradeAgreement.Penalty >= tradeAgreement.Amount {
+		reserveRecord, err = releaseFinancingReserve(stub, args[0], "makePayment", balances)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if reserveRecord != nil {
+			paymentRecords = append(paymentRecords, *reserveRecord)
+		}
+	}
+
+	// Reconcile the financing positions of the lenders paid
+	trueUpRecords, err = reconcileFinancingPositions(stub, args[0], "makePayment", paymentRecords, balances)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	_, err = recordPayments(stub, args[0], append(paymentRecords, trueUpRecords...))
+	if err != nil {
+		return shim.Error(err.Error())
 	}
-	impBal -= paymentAmount
 
 	// Update ledger state
+	err = putTradeTerms(stub, tradeKey, tradeAgreement)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
 	tradeAgreementBytes, err = json.Marshal(tradeAgreement)
 	if err != nil {
 		return shim.Error("Error marshaling trade agreement structure")
@@ -1682,6 +2515,10 @@ func (t *TradeWorkflowChaincode) makePayment(stub shim.ChaincodeStubInterface, c
 	if err != nil {
 		return shim.Error(err.Error())
 	}
+	err = putLetterOfCreditTerms(stub, lcKey, letterOfCredit)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
 	letterOfCreditBytes, err = json.Marshal(letterOfCredit)
 	if err != nil {
 		return shim.Error("Error marshaling L/C structure")
@@ -1690,17 +2527,33 @@ func (t *TradeWorkflowChaincode) makePayment(stub shim.ChaincodeStubInterface, c
 	if err != nil {
 		return shim.Error(err.Error())
 	}
-	err = stub.PutState(expBalKey, []byte(strconv.Itoa(expBal)))
-	if err != nil {
-		return shim.Error(err.Error())
-	}
-	err = stub.PutState(impBalKey, []byte(strconv.Itoa(impBal)))
+	err = putAccountBalances(stub, balances)
 	if err != nil {
 		return shim.Error(err.Error())
 	}
-	err = stub.PutState(lenBalKey, []byte(strconv.Itoa(lenBal)))
-	if err != nil {
-		return shim.Error(err.Error())
+
+	// Title to the goods passes to the importer once the trade, or the partial shipment, is paid in full
+	if partialShipment != nil {
+		if string(shipmentLocationBytes) == SOURCE {
+			partialShipment.PaymentStatus = PARTIALLY_PAID
+		} else {
+			partialShipment.PaymentStatus = PAID
+		}
+		err = putPartialShipmentsRecord(stub, partialShipmentsKey, partialShipments)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if partialShipment.PaymentStatus == PAID {
+			err = releaseBillOfLading(stub, args[0], args[2])
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+		}
+	} else if tradeAgreement.Payment + tradeAgreement.Penalty >= tradeAgreement.Amount {
+		err = releaseBillOfLading(stub, args[0], "")
+		if err != nil {
+			return shim.Error(err.Error())
+		}
 	}
 
 	// Delete request key from ledger
@@ -1715,8 +2568,10 @@ func (t *TradeWorkflowChaincode) makePayment(stub shim.ChaincodeStubInterface, c
 
 // Update shipment location; we will only allow SOURCE and DESTINATION as valid locations for this contract
 func (t *TradeWorkflowChaincode) updateShipmentLocation(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
-	var shipmentLocationKey, arrivalDateKey string
+	var shipmentLocationKey, arrivalDateKey, deliveryWindowEnd string
 	var shipmentLocationBytes []byte
+	var shipment *Shipment
+	var delayDays int
 	var err error
 
 	// Access control: Only a Carrier Org member can invoke this transaction
@@ -1749,6 +2604,17 @@ func (t *TradeWorkflowChaincode) updateShipmentLocation(stub shim.ChaincodeStubI
 		fmt.Printf("Shipment for trade %s has not been prepared yet\n", args[0])
 		return shim.Error("Shipment not prepared yet")
 	}
+
+	// The location of a shipment with a leg plan is derived from its checkpoints
+	_, shipment, err = getShipmentRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if shipment != nil && len(shipment.Legs) > 0 {
+		fmt.Printf("Shipment for trade %s is tracked by checkpoints\n", args[0])
+		return shim.Error("Shipment has a leg plan; record a checkpoint instead")
+	}
+
 	if string(shipmentLocationBytes) == args[1] {
 		fmt.Printf("Shipment for trade %s is already in location %s\n", args[0], args[1])
 	} else {
@@ -1758,6 +2624,18 @@ func (t *TradeWorkflowChaincode) updateShipmentLocation(stub shim.ChaincodeStubI
 				return shim.Error(err.Error())
 			}
 			fmt.Printf("Shipment ArrivalDate for trade %s recorded\n", args[0])
+
+			// Report a late arrival
+			deliveryWindowEnd, delayDays, err = getDeliveryDelay(stub, args[0], args[2])
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+			if delayDays > 0 {
+				err = emitShipmentDelayed(stub, args[0], deliveryWindowEnd, args[2], false, delayDays)
+				if err != nil {
+					return shim.Error(err.Error())
+				}
+			}
 		}
 	}
 
@@ -1797,17 +2675,22 @@ func (t *TradeWorkflowChaincode) getTradeStatus(stub shim.ChaincodeStubInterface
 	var tradeKey, jsonResp string
 	var tradeAgreement TradeAgreement
 	var tradeAgreementBytes []byte
+	var complianceHold *ComplianceHold
+	var dispute *Dispute
 	var err error
 
-	// Access control: Only an Importer or Exporter or Exporting Entity Org member can invoke this transaction
-	if !t.testMode && !(authenticateImporterOrg(creatorOrg, creatorCertIssuer) || authenticateExporterOrg(creatorOrg, creatorCertIssuer) || authenticateExportingEntityOrg(creatorOrg, creatorCertIssuer)) {
-		return shim.Error("Caller not a member of Importer or Exporter or Exporting Entity Org. Access denied.")
-	}
-
 	if len(args) != 1 {
 		return shim.Error("Incorrect number of arguments. Expecting 1: <trade ID>")
 	}
 
+	// Access control: Only the trade's participants, or a regulator for its jurisdiction, can invoke this transaction
+	if !t.testMode {
+		err = authorizeTradeAccess(stub, creatorOrg, creatorCertIssuer, args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
 	// Get the state from the ledger
 	tradeKey, err = getTradeKey(stub, args[0])
 	if err != nil {
@@ -1830,6 +2713,22 @@ func (t *TradeWorkflowChaincode) getTradeStatus(stub shim.ChaincodeStubInterface
 		return shim.Error(err.Error())
 	}
 
+	// A trade under dispute, or on compliance hold, reports that in place of its own status
+	_, dispute, err = getCurrentDisputeRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if dispute != nil && dispute.Status == OPEN {
+		tradeAgreement.Status = DISPUTED
+	}
+	_, complianceHold, err = getComplianceHoldRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if complianceHold != nil && complianceHold.Status == COMPLIANCE_HOLD {
+		tradeAgreement.Status = COMPLIANCE_HOLD
+	}
+
 	jsonResp = "{\"Status\":\"" + tradeAgreement.Status + "\"}"
 	fmt.Printf("Query Response:%s\n", jsonResp)
 	return shim.Success([]byte(jsonResp))
@@ -1842,15 +2741,18 @@ func (t *TradeWorkflowChaincode) getLCStatus(stub shim.ChaincodeStubInterface, c
 	var letterOfCreditBytes []byte
 	var err error
 
-	// Access control: Only an Importer or Exporter or Lender Org member can invoke this transaction
-	if !t.testMode && !(authenticateImporterOrg(creatorOrg, creatorCertIssuer) || authenticateExporterOrg(creatorOrg, creatorCertIssuer) || authenticateLenderOrg(creatorOrg, creatorCertIssuer)) {
-		return shim.Error("Caller not a member of Importer or Exporter or Exporting Entity Org. Access denied.")
-	}
-
 	if len(args) != 1 {
 		return shim.Error("Incorrect number of arguments. Expecting 1: <trade ID>")
 	}
 
+	// Access control: Only the trade's participants, or a regulator for its jurisdiction, can invoke this transaction
+	if !t.testMode {
+		err = authorizeTradeAccess(stub, creatorOrg, creatorCertIssuer, args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
 	// Get the state from the ledger
 	lcKey, err = getLCKey(stub, args[0])
 	if err != nil {
@@ -1882,18 +2784,22 @@ func (t *TradeWorkflowChaincode) getLCStatus(stub shim.ChaincodeStubInterface, c
 func (t *TradeWorkflowChaincode) getELStatus(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, args []string) pb.Response {
 	var elKey, jsonResp string
 	var exportLicense ExportLicense
+	var license *StandaloneExportLicense
 	var exportLicenseBytes []byte
 	var err error
 
-	// Access control: Only an Exporter or Regulator Org member can invoke this transaction
-	if !t.testMode && !(authenticateExporterOrg(creatorOrg, creatorCertIssuer) || authenticateRegulatorOrg(creatorOrg, creatorCertIssuer)) {
-		return shim.Error("Caller not a member of Exporter or Regulator Org. Access denied.")
-	}
-
 	if len(args) != 1 {
 		return shim.Error("Incorrect number of arguments. Expecting 1: <trade ID>")
 	}
 
+	// Access control: Only the trade's participants, or a regulator for its jurisdiction, can invoke this transaction
+	if !t.testMode {
+		err = authorizeTradeAccess(stub, creatorOrg, creatorCertIssuer, args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
 	// Get the state from the ledger
 	elKey, err = getELKey(stub, args[0])
 	if err != nil {
@@ -1916,6 +2822,15 @@ func (t *TradeWorkflowChaincode) getELStatus(stub shim.ChaincodeStubInterface, c
 		return shim.Error(err.Error())
 	}
 
+	// A trade shipping under a standalone E/L takes its status from that license
+	if exportLicense.Standalone && exportLicense.Status == ISSUED {
+		_, license, err = getStandaloneExportLicenseRecord(stub, exportLicense.Id)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		exportLicense.Status = license.Status
+	}
+
 	jsonResp = "{\"Status\":\"" + exportLicense.Status + "\"}"
 	fmt.Printf("Query Response:%s\n", jsonResp)
 	return shim.Success([]byte(jsonResp))
@@ -1927,15 +2842,18 @@ func (t *TradeWorkflowChaincode) getShipmentLocation(stub shim.ChaincodeStubInte
 	var shipmentLocationBytes []byte
 	var err error
 
-	// Access control: Only an Importer or Exporter or Lender or Carrier Org member can invoke this transaction
-	if !t.testMode && !(authenticateImporterOrg(creatorOrg, creatorCertIssuer) || authenticateExporterOrg(creatorOrg, creatorCertIssuer) || authenticateLenderOrg(creatorOrg, creatorCertIssuer) || authenticateCarrierOrg(creatorOrg, creatorCertIssuer)) {
-		return shim.Error("Caller not a member of Importer or Exporter or Exporting Entity or Carrier Org. Access denied.")
-	}
-
 	if len(args) != 1 {
 		return shim.Error("Incorrect number of arguments. Expecting 1: <trade ID>")
 	}
 
+	// Access control: Only the trade's participants, or a regulator for its jurisdiction, can invoke this transaction
+	if !t.testMode {
+		err = authorizeTradeAccess(stub, creatorOrg, creatorCertIssuer, args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
 	// Get the state from the ledger
 	slKey, err = getShipmentLocationKey(stub, args[0])
 	if err != nil {
@@ -1963,15 +2881,18 @@ func (t *TradeWorkflowChaincode) getArrivalDate(stub shim.ChaincodeStubInterface
 	var arrivalDateBytes []byte
 	var err error
 
-	// Access control: Only an Importer or Exporter or Lender or Carrier Org member can invoke this transaction
-	if !t.testMode && !(authenticateImporterOrg(creatorOrg, creatorCertIssuer) || authenticateExporterOrg(creatorOrg, creatorCertIssuer) || authenticateLenderOrg(creatorOrg, creatorCertIssuer) || authenticateCarrierOrg(creatorOrg, creatorCertIssuer)) {
-		return shim.Error("Caller not a member of Importer or Exporter or Exporting Entity or Carrier Org. Access denied.")
-	}
-
 	if len(args) != 1 {
 		return shim.Error("Incorrect number of arguments. Expecting 1: <trade ID>")
 	}
 
+	// Access control: Only the trade's participants, or a regulator for its jurisdiction, can invoke this transaction
+	if !t.testMode {
+		err = authorizeTradeAccess(stub, creatorOrg, creatorCertIssuer, args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
 	// Get the state from the ledger
 	adKey, err = getArrivalDateKey(stub, args[0])
 	if err != nil {
@@ -1999,17 +2920,24 @@ func (t *TradeWorkflowChaincode) getBillOfLading(stub shim.ChaincodeStubInterfac
 	var billOfLadingBytes []byte
 	var err error
 
-	// Access control: Only an Importer or Exporter or Exporting Entity or Carrier Org member can invoke this transaction
-	if !t.testMode && !(authenticateImporterOrg(creatorOrg, creatorCertIssuer) || authenticateExporterOrg(creatorOrg, creatorCertIssuer) || authenticateExportingEntityOrg(creatorOrg, creatorCertIssuer) || authenticateCarrierOrg(creatorOrg, creatorCertIssuer)) {
-		return shim.Error("Caller not a member of Importer or Exporter or Exporting Entity or Carrier Org. Access denied.")
+	if len(args) != 1 && len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 1: <trade ID>, or 2: <trade ID, shipment ID>")
 	}
 
-	if len(args) != 1 {
-		return shim.Error("Incorrect number of arguments. Expecting 1: <trade ID>")
+	// Access control: Only the trade's participants, or a regulator for its jurisdiction, can invoke this transaction
+	if !t.testMode {
+		err = authorizeTradeAccess(stub, creatorOrg, creatorCertIssuer, args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
 	}
 
 	// Get the state from the ledger
-	blKey, err = getBLKey(stub, args[0])
+	if len(args) == 2 {
+		blKey, err = getPartialBLKey(stub, args[0], args[1])
+	} else {
+		blKey, err = getBLKey(stub, args[0])
+	}
 	if err != nil {
 		return shim.Error(err.Error())
 	}
@@ -2037,6 +2965,15 @@ func (t *TradeWorkflowChaincode) getAccountBalance(stub shim.ChaincodeStubInterf
 		return shim.Error("Incorrect number of arguments. Expecting 2: {Trade ID, Entity}")
 	}
 
+	// Access control: Only the trade's participants, or a regulator for its jurisdiction, can invoke this transaction
+	if !t.testMode {
+		err = authorizeTradeAccess(stub, creatorOrg, creatorCertIssuer, args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	// Within the trade, only the account holder's org can read its balance
 	entity = strings.ToLower(args[1])
 	if entity == "exporter" {
 		// Access control: Only an Exporter or Exporting Entity Org member can invoke this transaction
@@ -2051,18 +2988,30 @@ func (t *TradeWorkflowChaincode) getAccountBalance(stub shim.ChaincodeStubInterf
 		}
 		balanceKey = impBalKey
 	} else if entity == "lender" {
-		// Access control: Only an Lender Org member can invoke this transaction
+		// Access control: Only a Lender Org member can invoke this transaction
 		if !t.testMode && !authenticateLenderOrg(creatorOrg, creatorCertIssuer) {
-			return shim.Error("Caller not a member of Importer Org. Access denied.")
+			return shim.Error("Caller not a member of Lender Org. Access denied.")
 		}
 		balanceKey = lenBalKey
+	} else if entity == "insurer" {
+		// Access control: Only the insurer can invoke this transaction
+		if !t.testMode && !authenticateInsurer(stub, creatorOrg, creatorCertIssuer) {
+			return shim.Error("Caller not an insurer of Lender Org. Access denied.")
+		}
+		balanceKey = insBalKey
+	} else if entity == "customs" {
+		// Access control: Only a customs officer can invoke this transaction
+		if !t.testMode && !authenticateCustomsOfficer(stub, creatorOrg, creatorCertIssuer) {
+			return shim.Error("Caller not a customs officer of Regulator Org. Access denied.")
+		}
+		balanceKey = cusBalKey
 	} else {
-		err = errors.New(fmt.Sprintf("Invalid entity %s; Permissible values: {exporter, importer, lender}", args[1]))
+		err = errors.New(fmt.Sprintf("Invalid entity %s; Permissible values: {exporter, importer, lender, insurer, customs}", args[1]))
 		return shim.Error(err.Error())
 	}
 
 	// Get the account balances from the ledger
-	balanceBytes, err = stub.GetState(balanceKey)
+	balanceBytes, err = getPrivateData(stub, getAccountBalanceCollection(balanceKey), balanceKey)
 	if err != nil {
 		jsonResp = "{\"Error\":\"Failed to get state for " + balanceKey + "\"}"
 		return shim.Error(jsonResp)
@@ -2080,6 +3029,7 @@ func (t *TradeWorkflowChaincode) getAccountBalance(stub shim.ChaincodeStubInterf
 func main() {
 	twc := new(TradeWorkflowChaincode)
 	twc.testMode = false
+	twc.dualAuthorization = true
 	err := shim.Start(twc)
 	if err != nil {
 		fmt.Printf("Error starting Trade Workflow chaincode: %s\n", err)
@@ -17,12 +17,32 @@
 package main
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
-	"testing"
+	"math/big"
+	"sort"
 	"strconv"
-	"encoding/json"
+	"strings"
+	"testing"
+	"time"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric/common/attrmgr"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+	"github.com/hyperledger/fabric/protos/msp"
+	pb "github.com/hyperledger/fabric/protos/peer"
 )
 
 const (
@@ -39,7 +59,200 @@ const (
 	REGAUTH = "ForestryDepartment"
 )
 
-func checkInit(t *testing.T, stub *shim.MockStub, args [][]byte) {
+// MockStub returns no creator identity, no transient map, and no private data, drops events, and stamps transactions with the wall clock; this wrapper supplies them, and a fixed clock
+type extendedMockStub struct {
+	*shim.MockStub
+	cc			shim.Chaincode
+	args		[][]byte
+	creator		[]byte
+	creatorKey	*ecdsa.PrivateKey
+	creatorCert	string
+	transient	map[string][]byte
+	privateData	map[string]map[string][]byte
+	event		*pb.ChaincodeEvent
+	txTime		time.Time
+}
+
+func newExtendedMockStub(name string, cc shim.Chaincode) *extendedMockStub {
+	txTime, _ := time.Parse(time.RFC3339, "2019-01-01T00:00:00Z")
+	return &extendedMockStub{MockStub: shim.NewMockStub(name, cc), cc: cc, privateData: map[string]map[string][]byte{}, txTime: txTime}
+}
+
+func (stub *extendedMockStub) GetArgs() [][]byte {
+	return stub.args
+}
+
+func (stub *extendedMockStub) GetStringArgs() []string {
+	strargs := make([]string, 0, len(stub.args))
+	for _, barg := range stub.args {
+		strargs = append(strargs, string(barg))
+	}
+	return strargs
+}
+
+func (stub *extendedMockStub) GetFunctionAndParameters() (string, []string) {
+	allargs := stub.GetStringArgs()
+	if len(allargs) == 0 {
+		return "", []string{}
+	}
+	return allargs[0], allargs[1:]
+}
+
+func (stub *extendedMockStub) GetCreator() ([]byte, error) {
+	return stub.creator, nil
+}
+
+func (stub *extendedMockStub) GetTransient() (map[string][]byte, error) {
+	return stub.transient, nil
+}
+
+func (stub *extendedMockStub) GetPrivateData(collection string, key string) ([]byte, error) {
+	return stub.privateData[collection][key], nil
+}
+
+func (stub *extendedMockStub) PutPrivateData(collection string, key string, value []byte) error {
+	if stub.privateData[collection] == nil {
+		stub.privateData[collection] = map[string][]byte{}
+	}
+	stub.privateData[collection][key] = value
+	return nil
+}
+
+// Private data keys are returned in key order, as from a range query on the peer
+func (stub *extendedMockStub) GetPrivateDataByPartialCompositeKey(collection string, objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	prefix, err := stub.CreateCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, err
+	}
+	iterator := &privateDataIterator{}
+	for key, value := range stub.privateData[collection] {
+		if strings.HasPrefix(key, prefix) {
+			iterator.results = append(iterator.results, &queryresult.KV{Namespace: collection, Key: key, Value: value})
+		}
+	}
+	sort.Slice(iterator.results, func(i, j int) bool { return iterator.results[i].Key < iterator.results[j].Key })
+	return iterator, nil
+}
+
+type privateDataIterator struct {
+	results	[]*queryresult.KV
+}
+
+func (iterator *privateDataIterator) HasNext() bool {
+	return len(iterator.results) > 0
+}
+
+func (iterator *privateDataIterator) Next() (*queryresult.KV, error) {
+	result := iterator.results[0]
+	iterator.results = iterator.results[1:]
+	return result, nil
+}
+
+func (iterator *privateDataIterator) Close() error {
+	return nil
+}
+
+func (stub *extendedMockStub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	return &timestamp.Timestamp{Seconds: stub.txTime.Unix(), Nanos: int32(stub.txTime.Nanosecond())}, nil
+}
+
+// Set the time at which subsequent transactions are proposed
+func (stub *extendedMockStub) setTxTime(t *testing.T, txTime string) {
+	var err error
+	stub.txTime, err = time.Parse(time.RFC3339, txTime)
+	if err != nil {
+		fmt.Println("Invalid transaction time", txTime)
+		t.FailNow()
+	}
+}
+
+// Fabric keeps a single event per transaction; the last one set wins
+func (stub *extendedMockStub) SetEvent(name string, payload []byte) error {
+	stub.event = &pb.ChaincodeEvent{EventName: name, Payload: payload}
+	return nil
+}
+
+// Set the transient map passed with subsequent transactions
+func (stub *extendedMockStub) setTransient(fields map[string]string) {
+	stub.transient = map[string][]byte{}
+	for name, value := range fields {
+		stub.transient[name] = []byte(value)
+	}
+}
+
+func (stub *extendedMockStub) MockInit(uuid string, args [][]byte) pb.Response {
+	stub.args = args
+	stub.MockTransactionStart(uuid)
+	res := stub.cc.Init(stub)
+	stub.MockTransactionEnd(uuid)
+	return res
+}
+
+func (stub *extendedMockStub) MockInvoke(uuid string, args [][]byte) pb.Response {
+	stub.args = args
+	stub.event = nil
+	stub.MockTransactionStart(uuid)
+	res := stub.cc.Invoke(stub)
+	stub.MockTransactionEnd(uuid)
+	return res
+}
+
+// Issue a certificate for a user from an org's CA, and set it as the creator of subsequent transactions
+func (stub *extendedMockStub) setCreator(t *testing.T, mspID string, caName string, userName string) {
+	stub.setCreatorWithAttributes(t, mspID, caName, userName, nil)
+}
+
+func (stub *extendedMockStub) setCreatorWithAttributes(t *testing.T, mspID string, caName string, userName string, attrs map[string]string) {
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	userKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{CommonName: caName},
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter: time.Now().Add(time.Hour),
+		IsCA: true,
+		BasicConstraintsValid: true,
+		KeyUsage: x509.KeyUsageCertSign,
+	}
+	userTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject: pkix.Name{CommonName: userName, OrganizationalUnit: []string{"client"}},
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter: time.Now().Add(time.Hour),
+		KeyUsage: x509.KeyUsageDigitalSignature,
+	}
+	if attrs != nil {
+		attrsBytes, _ := json.Marshal(&attrmgr.Attributes{Attrs: attrs})
+		userTemplate.ExtraExtensions = []pkix.Extension{{Id: attrmgr.AttrOID, Value: attrsBytes}}
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, userTemplate, caTemplate, &userKey.PublicKey, caKey)
+	if err != nil {
+		fmt.Println("Failed to create certificate", err)
+		t.FailNow()
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	stub.creatorKey = userKey
+	stub.creatorCert = string(certPEM)
+	stub.creator, err = proto.Marshal(&msp.SerializedIdentity{Mspid: mspID, IdBytes: certPEM})
+	if err != nil {
+		fmt.Println("Failed to serialize identity", err)
+		t.FailNow()
+	}
+}
+
+// Sign content with the creator's enrollment key, as the issuer of a document would off-chain
+func (stub *extendedMockStub) sign(t *testing.T, content []byte) []byte {
+	digest := sha256.Sum256(content)
+	r, s, err := ecdsa.Sign(rand.Reader, stub.creatorKey, digest[:])
+	if err != nil {
+		fmt.Println("Failed to sign content", err)
+		t.FailNow()
+	}
+	signature, _ := asn1.Marshal(ecdsaSignature{r, s})
+	return signature
+}
+
+func checkInit(t *testing.T, stub *extendedMockStub, args [][]byte) {
 	res := stub.MockInit("1", args)
 	if res.Status != shim.OK {
 		fmt.Println("Init failed", string(res.Message))
@@ -47,7 +260,7 @@ func checkInit(t *testing.T, stub *shim.MockStub, args [][]byte) {
 	}
 }
 
-func checkNoState(t *testing.T, stub *shim.MockStub, name string) {
+func checkNoState(t *testing.T, stub *extendedMockStub, name string) {
 	bytes := stub.State[name]
 	if bytes != nil {
 		fmt.Println("State", name, "should be absent; found value")
@@ -55,7 +268,15 @@ func checkNoState(t *testing.T, stub *shim.MockStub, name string) {
 	}
 }
 
-func checkState(t *testing.T, stub *shim.MockStub, name string, value string) {
+func checkNoPrivateState(t *testing.T, stub *extendedMockStub, collection string, name string) {
+	bytes := stub.privateData[collection][name]
+	if bytes != nil {
+		fmt.Println("Private state", name, "should be absent; found value")
+		t.FailNow()
+	}
+}
+
+func checkState(t *testing.T, stub *extendedMockStub, name string, value string) {
 	bytes := stub.State[name]
 	if bytes == nil {
 		fmt.Println("State", name, "failed to get value")
@@ -67,7 +288,37 @@ func checkState(t *testing.T, stub *shim.MockStub, name string, value string) {
 	}
 }
 
-func checkBadQuery(t *testing.T, stub *shim.MockStub, function string, name string) {
+func checkPrivateState(t *testing.T, stub *extendedMockStub, collection string, name string, value string) {
+	bytes := stub.privateData[collection][name]
+	if bytes == nil {
+		fmt.Println("Private state", name, "failed to get value")
+		t.FailNow()
+	}
+	if string(bytes) != value {
+		fmt.Println("Private state value", name, "was", string(bytes), "and not", value, "as expected")
+		t.FailNow()
+	}
+}
+
+func checkEvent(t *testing.T, stub *extendedMockStub, name string, payload string) {
+	if stub.event == nil || stub.event.EventName != name {
+		fmt.Println("Event", name, "was not emitted")
+		t.FailNow()
+	}
+	if string(stub.event.Payload) != payload {
+		fmt.Println("Event payload", name, "was", string(stub.event.Payload), "and not", payload, "as expected")
+		t.FailNow()
+	}
+}
+
+func checkNoEvent(t *testing.T, stub *extendedMockStub) {
+	if stub.event != nil {
+		fmt.Println("Event", stub.event.EventName, "was emitted unexpectedly")
+		t.FailNow()
+	}
+}
+
+func checkBadQuery(t *testing.T, stub *extendedMockStub, function string, name string) {
 	res := stub.MockInvoke("1", [][]byte{[]byte(function), []byte(name)})
 	if res.Status == shim.OK {
 		fmt.Println("Query", name, "unexpectedly succeeded")
@@ -75,7 +326,7 @@ func checkBadQuery(t *testing.T, stub *shim.MockStub, function string, name stri
 	}
 }
 
-func checkQuery(t *testing.T, stub *shim.MockStub, function string, name string, value string) {
+func checkQuery(t *testing.T, stub *extendedMockStub, function string, name string, value string) {
 	res := stub.MockInvoke("1", [][]byte{[]byte(function), []byte(name)})
 	if res.Status != shim.OK {
 		fmt.Println("Query", name, "failed", string(res.Message))
@@ -92,7 +343,7 @@ func checkQuery(t *testing.T, stub *shim.MockStub, function string, name string,
 	}
 }
 
-func checkQueryArgs(t *testing.T, stub *shim.MockStub, args [][]byte, value string) {
+func checkQueryArgs(t *testing.T, stub *extendedMockStub, args [][]byte, value string) {
 	res := stub.MockInvoke("1", args)
 	if res.Status != shim.OK {
 		fmt.Println("Query", string(args[1]), "failed", string(res.Message))
@@ -109,7 +360,7 @@ func checkQueryArgs(t *testing.T, stub *shim.MockStub, args [][]byte, value stri
 	}
 }
 
-func checkBadInvoke(t *testing.T, stub *shim.MockStub, args [][]byte) {
+func checkBadInvoke(t *testing.T, stub *extendedMockStub, args [][]byte) {
 	res := stub.MockInvoke("1", args)
 	if res.Status == shim.OK {
 		fmt.Println("Invoke", args, "unexpectedly succeeded")
@@ -117,7 +368,7 @@ func checkBadInvoke(t *testing.T, stub *shim.MockStub, args [][]byte) {
 	}
 }
 
-func checkInvoke(t *testing.T, stub *shim.MockStub, args [][]byte) {
+func checkInvoke(t *testing.T, stub *extendedMockStub, args [][]byte) {
 	res := stub.MockInvoke("1", args)
 	if res.Status != shim.OK {
 		fmt.Println("Invoke", args, "failed", string(res.Message))
@@ -129,34 +380,105 @@ func getInitArguments() [][]byte {
 	return [][]byte{[]byte("init"),
 			[]byte("LumberInc"),
 			[]byte("LumberBank"),
-			[]byte("100000"),
 			[]byte("WoodenToys"),
 			[]byte("ToyBank"),
-			[]byte("200000"),
 			[]byte("LenderInc"),
 			[]byte("LenderBank"),
-			[]byte("300000"),
 			[]byte("UniversalFrieght"),
 			[]byte("ForestryDepartment")}
 }
 
+func getInitTransient() map[string]string {
+	return map[string]string{"exporterBalance": "100000",
+			"importerBalance": "200000",
+			"lenderBalance": "300000"}
+}
+
+// Public records carry the hash of their private terms
+func withTradeTermsHash(tradeAgreement *TradeAgreement) *TradeAgreement {
+	termsBytes, _ := json.Marshal(&TradeTerms{tradeAgreement.Amount, tradeAgreement.Payment, tradeAgreement.DelayPenaltyRate, tradeAgreement.Penalty})
+	tradeAgreement.TermsHash = hashPrivateData(termsBytes)
+	return tradeAgreement
+}
+
+func withLetterOfCreditTermsHash(letterOfCredit *LetterOfCredit) *LetterOfCredit {
+	letterOfCreditTerms := &LetterOfCreditTerms{letterOfCredit.Amount, nil}
+	for _, transfer := range letterOfCredit.Transfers {
+		letterOfCreditTerms.Transfers = append(letterOfCreditTerms.Transfers, LCTransferTerms{transfer.Beneficiary, transfer.Amount, transfer.DiscountRate, transfer.AnnualRate})
+	}
+	termsBytes, _ := json.Marshal(letterOfCreditTerms)
+	letterOfCredit.TermsHash = hashPrivateData(termsBytes)
+	return letterOfCredit
+}
+
+func getStandaloneExportLicenseTermsBytes(license *StandaloneExportLicense) []byte {
+	licenseTerms := &StandaloneExportLicenseTerms{license.ValueDrawn, []QuotaDrawdownTerms{}}
+	for _, drawdown := range license.Drawdowns {
+		licenseTerms.Drawdowns = append(licenseTerms.Drawdowns, QuotaDrawdownTerms{drawdown.TradeId, drawdown.ShipmentId, drawdown.Value})
+	}
+	termsBytes, _ := json.Marshal(licenseTerms)
+	return termsBytes
+}
+
+func withStandaloneExportLicenseTermsHash(license *StandaloneExportLicense) *StandaloneExportLicense {
+	license.TermsHash = hashPrivateData(getStandaloneExportLicenseTermsBytes(license))
+	return license
+}
+
+func getCustomsDeclarationTermsBytes(customsDeclaration *CustomsDeclaration) []byte {
+	customsDeclarationTerms := &CustomsDeclarationTerms{[]CustomsLineItemTerms{}, customsDeclaration.CustomsValue, customsDeclaration.Duty, customsDeclaration.Tax}
+	for _, lineItem := range customsDeclaration.LineItems {
+		customsDeclarationTerms.LineItems = append(customsDeclarationTerms.LineItems, CustomsLineItemTerms{lineItem.Value, lineItem.Duty, lineItem.Tax})
+	}
+	termsBytes, _ := json.Marshal(customsDeclarationTerms)
+	return termsBytes
+}
+
+func withCustomsDeclarationTermsHash(customsDeclaration *CustomsDeclaration) *CustomsDeclaration {
+	customsDeclaration.TermsHash = hashPrivateData(getCustomsDeclarationTermsBytes(customsDeclaration))
+	return customsDeclaration
+}
+
+func withInsurancePolicyTermsHash(insurancePolicy *InsurancePolicy) *InsurancePolicy {
+	termsBytes, _ := json.Marshal(&InsurancePolicyTerms{insurancePolicy.InsuredValue, insurancePolicy.Premium, insurancePolicy.PaidOut})
+	insurancePolicy.TermsHash = hashPrivateData(termsBytes)
+	return insurancePolicy
+}
+
+func withInsuranceClaimTermsHash(insuranceClaim *InsuranceClaim) *InsuranceClaim {
+	termsBytes, _ := json.Marshal(&InsuranceClaimTerms{insuranceClaim.Amount, insuranceClaim.Payout})
+	insuranceClaim.TermsHash = hashPrivateData(termsBytes)
+	return insuranceClaim
+}
+
+// Queries on peers that hold the private terms return them with the public record
+func withPrivateTerms(recordBytes []byte, termsBytes []byte) string {
+	var record, terms interface{}
+
+	json.Unmarshal(recordBytes, &record)
+	json.Unmarshal(termsBytes, &terms)
+	mergedBytes, _ := json.Marshal(mergePrivateTerms(record, terms))
+	return string(mergedBytes)
+}
+
 func TestTradeWorkflow_Init(t *testing.T) {
 	scc := new(TradeWorkflowChaincode)
 	scc.testMode = true
-	stub := shim.NewMockStub("Trade Workflow", scc)
+	stub := newExtendedMockStub("Trade Workflow", scc)
 
 	// Init
+	stub.setTransient(getInitTransient())
 	checkInit(t, stub, getInitArguments())
 
 	checkState(t, stub, "Exporter", EXPORTER)
 	checkState(t, stub, "ExportersBank", EXPBANK)
-	checkState(t, stub, "ExportersAccountBalance", strconv.Itoa(EXPBALANCE))
+	checkPrivateState(t, stub, accountBalancesCollection, "ExportersAccountBalance", strconv.Itoa(EXPBALANCE))
 	checkState(t, stub, "Importer", IMPORTER)
 	checkState(t, stub, "ImportersBank", IMPBANK)
-	checkState(t, stub, "ImportersAccountBalance", strconv.Itoa(IMPBALANCE))
+	checkPrivateState(t, stub, accountBalancesCollection, "ImportersAccountBalance", strconv.Itoa(IMPBALANCE))
 	checkState(t, stub, "Lender", LENDER)
 	checkState(t, stub, "LendersBank", LENBANK)
-	checkState(t, stub, "LendersAccountBalance", strconv.Itoa(LENBALANCE))
+	checkPrivateState(t, stub, accountBalancesCollection, "LendersAccountBalance", strconv.Itoa(LENBALANCE))
 	checkState(t, stub, "Carrier", CARRIER)
 	checkState(t, stub, "RegulatoryAuthority", REGAUTH)
 }
@@ -164,22 +486,36 @@ func TestTradeWorkflow_Init(t *testing.T) {
 func TestTradeWorkflow_Agreement(t *testing.T) {
 	scc := new(TradeWorkflowChaincode)
 	scc.testMode = true
-	stub := shim.NewMockStub("Trade Workflow", scc)
+	stub := newExtendedMockStub("Trade Workflow", scc)
 
 	// Init
+	stub.setTransient(getInitTransient())
 	checkInit(t, stub, getInitArguments())
 
-	// Invoke 'requestTrade'
+	// Invoke 'requestTrade' without the amount in the transient map and verify failure
 	tradeID := "2ks89j9"
 	amount := 50000
 	descGoods := "Wood for Toys"
-	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(strconv.Itoa(amount)), []byte(descGoods)})
+	stub.setTransient(map[string]string{})
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(descGoods)})
+
+	// Invoke 'requestTrade'
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(descGoods)})
 
-	tradeAgreement := &TradeAgreement{amount, descGoods, REQUESTED, 0}
+	tradeAgreement := withTradeTermsHash(&TradeAgreement{amount, descGoods, 0, REQUESTED, 0, "", "", 0.0, 0, nil, ""})
 	tradeAgreementBytes, _ := json.Marshal(tradeAgreement)
 	tradeKey, _ := stub.CreateCompositeKey("Trade", []string{tradeID})
 	checkState(t, stub, tradeKey, string(tradeAgreementBytes))
 
+	// The amount is recorded in the private data collection only
+	tradeTermsBytes, _ := json.Marshal(&TradeTerms{amount, 0, 0.0, 0})
+	checkPrivateState(t, stub, tradeTermsCollection, tradeKey, string(tradeTermsBytes))
+	if strings.Contains(string(stub.State[tradeKey]), strconv.Itoa(amount)) {
+		fmt.Println("State", tradeKey, "should not contain the trade amount")
+		t.FailNow()
+	}
+
 	expectedResp := "{\"Status\":\"REQUESTED\"}"
 	checkQuery(t, stub, "getTradeStatus", tradeID, expectedResp)
 
@@ -203,21 +539,23 @@ func TestTradeWorkflow_Agreement(t *testing.T) {
 func TestTradeWorkflow_LetterOfCredit(t *testing.T) {
 	scc := new(TradeWorkflowChaincode)
 	scc.testMode = true
-	stub := shim.NewMockStub("Trade Workflow", scc)
+	stub := newExtendedMockStub("Trade Workflow", scc)
 
 	// Init
+	stub.setTransient(getInitTransient())
 	checkInit(t, stub, getInitArguments())
 
 	// Invoke 'requestTrade' and 'acceptTrade'
 	tradeID := "2ks89j9"
 	amount := 50000
 	descGoods := "Wood for Toys"
-	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(strconv.Itoa(amount)), []byte(descGoods)})
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(descGoods)})
 	checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeID)})
 
 	// Invoke 'requestLC'
 	checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(tradeID)})
-	letterOfCredit := &LetterOfCredit{"", "", EXPORTER, amount, []string{}, REQUESTED, 0.0, false}
+	letterOfCredit := withLetterOfCreditTermsHash(&LetterOfCredit{"", "", EXPORTER, amount, []DocumentReference{}, REQUESTED, false, nil, ""})
 	letterOfCreditBytes, _ := json.Marshal(letterOfCredit)
 	lcKey, _ := stub.CreateCompositeKey("LetterOfCredit", []string{tradeID})
 	checkState(t, stub, lcKey, string(letterOfCreditBytes))
@@ -242,7 +580,7 @@ func TestTradeWorkflow_LetterOfCredit(t *testing.T) {
 	doc1 := "E/L"
 	doc2 := "B/L"
 	checkInvoke(t, stub, [][]byte{[]byte("issueLC"), []byte(tradeID), []byte(lcID), []byte(expirationDate), []byte(doc1), []byte(doc2)})
-	letterOfCredit = &LetterOfCredit{lcID, expirationDate, EXPORTER, amount, []string{doc1, doc2}, ISSUED, 0.0, false}
+	letterOfCredit = withLetterOfCreditTermsHash(&LetterOfCredit{lcID, expirationDate, EXPORTER, amount, []DocumentReference{{doc1, ""}, {doc2, ""}}, ISSUED, false, nil, ""})
 	letterOfCreditBytes, _ = json.Marshal(letterOfCredit)
 	checkState(t, stub, lcKey, string(letterOfCreditBytes))
 
@@ -251,7 +589,7 @@ func TestTradeWorkflow_LetterOfCredit(t *testing.T) {
 
 	// Invoke 'acceptLC'
 	checkInvoke(t, stub, [][]byte{[]byte("acceptLC"), []byte(tradeID)})
-	letterOfCredit = &LetterOfCredit{lcID, expirationDate, EXPORTER, amount, []string{doc1, doc2}, ACCEPTED, 0.0, false}
+	letterOfCredit = withLetterOfCreditTermsHash(&LetterOfCredit{lcID, expirationDate, EXPORTER, amount, []DocumentReference{{doc1, ""}, {doc2, ""}}, ACCEPTED, false, nil, ""})
 	letterOfCreditBytes, _ = json.Marshal(letterOfCredit)
 	checkState(t, stub, lcKey, string(letterOfCreditBytes))
 
@@ -262,16 +600,18 @@ func TestTradeWorkflow_LetterOfCredit(t *testing.T) {
 func TestTradeWorkflow_ExportLicense(t *testing.T) {
 	scc := new(TradeWorkflowChaincode)
 	scc.testMode = true
-	stub := shim.NewMockStub("Trade Workflow", scc)
+	stub := newExtendedMockStub("Trade Workflow", scc)
 
 	// Init
+	stub.setTransient(getInitTransient())
 	checkInit(t, stub, getInitArguments())
 
 	// Invoke 'requestTrade', 'acceptTrade', 'requestLC', 'issueLC', 'acceptLC'
 	tradeID := "2ks89j9"
 	amount := 50000
 	descGoods := "Wood for Toys"
-	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(strconv.Itoa(amount)), []byte(descGoods)})
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(descGoods)})
 	checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeID)})
 	checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(tradeID)})
 	lcID := "lc8349"
@@ -283,7 +623,7 @@ func TestTradeWorkflow_ExportLicense(t *testing.T) {
 
 	// Issue 'requestEL'
 	checkInvoke(t, stub, [][]byte{[]byte("requestEL"), []byte(tradeID)})
-	exportLicense := &ExportLicense{"", "", EXPORTER, CARRIER, descGoods, REGAUTH, REQUESTED}
+	exportLicense := &ExportLicense{"", "", EXPORTER, CARRIER, descGoods, "", REGAUTH, REQUESTED, nil, "", false, "", ""}
 	exportLicenseBytes, _ := json.Marshal(exportLicense)
 	elKey, _ := stub.CreateCompositeKey("ExportLicense", []string{tradeID})
 	checkState(t, stub, elKey, string(exportLicenseBytes))
@@ -303,7 +643,7 @@ func TestTradeWorkflow_ExportLicense(t *testing.T) {
 
 	// Invoke 'issueEL' and verify state change
 	checkInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(tradeID), []byte(elID), []byte(elExpirationDate)})
-	exportLicense = &ExportLicense{elID, elExpirationDate, EXPORTER, CARRIER, descGoods, REGAUTH, ISSUED}
+	exportLicense = &ExportLicense{elID, elExpirationDate, EXPORTER, CARRIER, descGoods, "", REGAUTH, ISSUED, nil, "", false, "", ""}
 	exportLicenseBytes, _ = json.Marshal(exportLicense)
 	checkState(t, stub, elKey, string(exportLicenseBytes))
 
@@ -314,16 +654,18 @@ func TestTradeWorkflow_ExportLicense(t *testing.T) {
 func TestTradeWorkflow_ShipmentInitiation(t *testing.T) {
 	scc := new(TradeWorkflowChaincode)
 	scc.testMode = true
-	stub := shim.NewMockStub("Trade Workflow", scc)
+	stub := newExtendedMockStub("Trade Workflow", scc)
 
 	// Init
+	stub.setTransient(getInitTransient())
 	checkInit(t, stub, getInitArguments())
 
 	// Invoke 'requestTrade', 'acceptTrade', 'requestLC', 'issueLC', 'acceptLC', 'requestEL', 'issueEL'
 	tradeID := "2ks89j9"
 	amount := 50000
 	descGoods := "Wood for Toys"
-	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(strconv.Itoa(amount)), []byte(descGoods)})
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(descGoods)})
 	checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeID)})
 	checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(tradeID)})
 	lcID := "lc8349"
@@ -359,7 +701,7 @@ func TestTradeWorkflow_ShipmentInitiation(t *testing.T) {
 
 	// Invoke 'acceptShipmentAndIssueBL' and verify state change
 	checkInvoke(t, stub, [][]byte{[]byte("acceptShipmentAndIssueBL"), []byte(tradeID), []byte(blID), []byte(blExpirationDate), []byte(sourcePort), []byte(destinationPort)})
-	billOfLading := &BillOfLading{blID, blExpirationDate, EXPORTER, CARRIER, descGoods, amount, IMPBANK, sourcePort, destinationPort}
+	billOfLading := &BillOfLading{blID, blExpirationDate, EXPORTER, CARRIER, descGoods, 0, IMPBANK, sourcePort, destinationPort, nil, IMPBANK, "ImporterOrgMSP", "", HELD_BY_BANK, []Endorsement{}, "", ""}
 	billOfLadingBytes, _ := json.Marshal(billOfLading)
 	checkState(t, stub, blKey, string(billOfLadingBytes))
 	checkQuery(t, stub, "getBillOfLading", tradeID, string(billOfLadingBytes))
@@ -368,16 +710,18 @@ func TestTradeWorkflow_ShipmentInitiation(t *testing.T) {
 func TestTradeWorkflow_PaymentFulfilment(t *testing.T) {
 	scc := new(TradeWorkflowChaincode)
 	scc.testMode = true
-	stub := shim.NewMockStub("Trade Workflow", scc)
+	stub := newExtendedMockStub("Trade Workflow", scc)
 
 	// Init
+	stub.setTransient(getInitTransient())
 	checkInit(t, stub, getInitArguments())
 
 	// Invoke 'requestTrade', 'acceptTrade', 'requestLC', 'issueLC', 'acceptLC', 'requestEL', 'issueEL', 'prepareShipment', 'acceptShipmentAndIssueBL'
 	tradeID := "2ks89j9"
 	amount := 50000
 	descGoods := "Wood for Toys"
-	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(strconv.Itoa(amount)), []byte(descGoods)})
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(descGoods)})
 	checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeID)})
 	checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(tradeID)})
 	lcID := "lc8349"
@@ -410,9 +754,9 @@ func TestTradeWorkflow_PaymentFulfilment(t *testing.T) {
 	payment := amount / 2
 	expBalanceStr := strconv.Itoa(EXPBALANCE + payment)
 	impBalanceStr := strconv.Itoa(IMPBALANCE - payment)
-	checkState(t, stub, expBalKey, expBalanceStr)
-	checkState(t, stub, impBalKey, impBalanceStr)
-	tradeAgreement := &TradeAgreement{amount, descGoods, ACCEPTED, payment}
+	checkPrivateState(t, stub, accountBalancesCollection, expBalKey, expBalanceStr)
+	checkPrivateState(t, stub, accountBalancesCollection, impBalKey, impBalanceStr)
+	tradeAgreement := withTradeTermsHash(&TradeAgreement{amount, descGoods, 0, ACCEPTED, payment, "", "", 0.0, 0, nil, ""})
 	tradeAgreementBytes, _ := json.Marshal(tradeAgreement)
 	tradeKey, _ := stub.CreateCompositeKey("Trade", []string{tradeID})
 	checkState(t, stub, tradeKey, string(tradeAgreementBytes))
@@ -441,12 +785,20 @@ func TestTradeWorkflow_PaymentFulfilment(t *testing.T) {
 	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeID), []byte("03/01/2019")})
 	checkNoState(t, stub, paymentKey)
 
+	// Verify that the B/L was released to the importer
+	blKey, _ := stub.CreateCompositeKey("BillOfLading", []string{tradeID})
+	billOfLading := &BillOfLading{blID, blExpirationDate, EXPORTER, CARRIER, descGoods, 0, IMPBANK, sourcePort, destinationPort, nil, IMPORTER, "ImporterOrgMSP", "", RELEASED_TO_IMPORTER, []Endorsement{{IMPBANK, "ImporterOrgMSP", IMPORTER, "ImporterOrgMSP", ""}}, "", ""}
+	billOfLadingBytes, _ := json.Marshal(billOfLading)
+	checkState(t, stub, blKey, string(billOfLadingBytes))
+	releasedEventBytes, _ := json.Marshal(&BillOfLadingReleasedEvent{tradeID, blID, IMPORTER, "ImporterOrgMSP"})
+	checkEvent(t, stub, billOfLadingReleasedEvent, string(releasedEventBytes))
+
 	// Verify account and payment balances, and check queries
 	expBalanceStr = strconv.Itoa(EXPBALANCE + amount)
 	impBalanceStr = strconv.Itoa(IMPBALANCE - amount)
-	checkState(t, stub, expBalKey, expBalanceStr)
-	checkState(t, stub, impBalKey, impBalanceStr)
-	tradeAgreement = &TradeAgreement{amount, descGoods, ACCEPTED, amount}
+	checkPrivateState(t, stub, accountBalancesCollection, expBalKey, expBalanceStr)
+	checkPrivateState(t, stub, accountBalancesCollection, impBalKey, impBalanceStr)
+	tradeAgreement = withTradeTermsHash(&TradeAgreement{amount, descGoods, 0, ACCEPTED, amount, "", "", 0.0, 0, nil, ""})
 	tradeAgreementBytes, _ = json.Marshal(tradeAgreement)
 	checkState(t, stub, tradeKey, string(tradeAgreementBytes))
 
@@ -460,16 +812,18 @@ func TestTradeWorkflow_PaymentFulfilment(t *testing.T) {
 func TestTradeWorkflow_LetterOfCreditTransfer(t *testing.T) {
 	scc := new(TradeWorkflowChaincode)
 	scc.testMode = true
-	stub := shim.NewMockStub("Trade Workflow", scc)
+	stub := newExtendedMockStub("Trade Workflow", scc)
 
 	// Init
+	stub.setTransient(getInitTransient())
 	checkInit(t, stub, getInitArguments())
 
 	// Invoke 'requestTrade', 'acceptTrade', 'requestLC', 'issueLC', 'acceptLC', 'requestEL', 'issueEL', 'prepareShipment', 'acceptShipmentAndIssueBL'
 	tradeID := "2ks89j9"
 	amount := 50000
 	descGoods := "Wood for Toys"
-	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(strconv.Itoa(amount)), []byte(descGoods)})
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(descGoods)})
 	checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeID)})
 	checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(tradeID)})
 	lcID := "lc8349"
@@ -491,8 +845,9 @@ func TestTradeWorkflow_LetterOfCreditTransfer(t *testing.T) {
 
 	// Invoke 'requestLCTransfer'
 	discountRate := float32(0.1)
-	checkInvoke(t, stub, [][]byte{[]byte("requestLCTransfer"), []byte(tradeID), []byte(strconv.FormatFloat(float64(discountRate), 'f', 2, 64))})
-	letterOfCredit := &LetterOfCredit{lcID, lcExpirationDate, LENDER, amount, []string{doc1, doc2}, TRANSFER_REQUESTED, discountRate, false}
+	stub.setTransient(map[string]string{"discountRate": strconv.FormatFloat(float64(discountRate), 'f', 2, 64)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestLCTransfer"), []byte(tradeID)})
+	letterOfCredit := withLetterOfCreditTermsHash(&LetterOfCredit{lcID, lcExpirationDate, EXPORTER, amount, []DocumentReference{{doc1, ""}, {doc2, ""}}, TRANSFER_REQUESTED, false, []LCTransfer{{LENDER, REQUESTED, amount, discountRate, 0, "", "", false}}, ""})
 	letterOfCreditBytes, _ := json.Marshal(letterOfCredit)
 	lcKey, _ := stub.CreateCompositeKey("LetterOfCredit", []string{tradeID})
 	checkState(t, stub, lcKey, string(letterOfCreditBytes))
@@ -513,7 +868,7 @@ func TestTradeWorkflow_LetterOfCreditTransfer(t *testing.T) {
 
 	// Invoke 'issueLCTransfer'
 	checkInvoke(t, stub, [][]byte{[]byte("issueLCTransfer"), []byte(tradeID)})
-	letterOfCredit = &LetterOfCredit{lcID, lcExpirationDate, LENDER, amount, []string{doc1, doc2}, TRANSFER_ISSUED, discountRate, false}
+	letterOfCredit = withLetterOfCreditTermsHash(&LetterOfCredit{lcID, lcExpirationDate, EXPORTER, amount, []DocumentReference{{doc1, ""}, {doc2, ""}}, TRANSFER_ISSUED, false, []LCTransfer{{LENDER, ISSUED, amount, discountRate, 0, "", "", false}}, ""})
 	letterOfCreditBytes, _ = json.Marshal(letterOfCredit)
 	checkState(t, stub, lcKey, string(letterOfCreditBytes))
 
@@ -522,7 +877,7 @@ func TestTradeWorkflow_LetterOfCreditTransfer(t *testing.T) {
 
 	// Invoke 'acceptLCTransfer'
 	checkInvoke(t, stub, [][]byte{[]byte("acceptLCTransfer"), []byte(tradeID)})
-	letterOfCredit = &LetterOfCredit{lcID, lcExpirationDate, LENDER, amount, []string{doc1, doc2}, TRANSFER_ACCEPTED, discountRate, false}
+	letterOfCredit = withLetterOfCreditTermsHash(&LetterOfCredit{lcID, lcExpirationDate, EXPORTER, amount, []DocumentReference{{doc1, ""}, {doc2, ""}}, TRANSFER_ACCEPTED, false, []LCTransfer{{LENDER, ACCEPTED, amount, discountRate, 0, "", "", false}}, ""})
 	letterOfCreditBytes, _ = json.Marshal(letterOfCredit)
 	checkState(t, stub, lcKey, string(letterOfCreditBytes))
 
@@ -532,12 +887,12 @@ func TestTradeWorkflow_LetterOfCreditTransfer(t *testing.T) {
 	// Invoke 'requestAdvancePayment'
 	checkInvoke(t, stub, [][]byte{[]byte("requestAdvancePayment"), []byte(tradeID)})
 	advancePaymentKey, _ := stub.CreateCompositeKey("AdvancePayment", []string{tradeID})
-	checkState(t, stub, advancePaymentKey, REQUESTED)
+	checkState(t, stub, advancePaymentKey, LENDER)
 
 	// Invoke 'makeAdvancePayment'
 	checkInvoke(t, stub, [][]byte{[]byte("makeAdvancePayment"), []byte(tradeID)})
 	checkNoState(t, stub, advancePaymentKey)
-	letterOfCredit = &LetterOfCredit{lcID, lcExpirationDate, LENDER, amount, []string{doc1, doc2}, TRANSFER_ACCEPTED, discountRate, true}
+	letterOfCredit = withLetterOfCreditTermsHash(&LetterOfCredit{lcID, lcExpirationDate, EXPORTER, amount, []DocumentReference{{doc1, ""}, {doc2, ""}}, TRANSFER_ACCEPTED, false, []LCTransfer{{LENDER, ACCEPTED, amount, discountRate, 0, "", "", true}}, ""})
 	letterOfCreditBytes, _ = json.Marshal(letterOfCredit)
 	checkState(t, stub, lcKey, string(letterOfCreditBytes))
 
@@ -546,8 +901,8 @@ func TestTradeWorkflow_LetterOfCreditTransfer(t *testing.T) {
 	payment := int((fullRate - discountRate) * float32(amount))
 	expBalanceStr := strconv.Itoa(EXPBALANCE + payment)
 	lenBalanceStr := strconv.Itoa(LENBALANCE - payment)
-	checkState(t, stub, expBalKey, expBalanceStr)
-	checkState(t, stub, lenBalKey, lenBalanceStr)
+	checkPrivateState(t, stub, accountBalancesCollection, expBalKey, expBalanceStr)
+	checkPrivateState(t, stub, accountBalancesCollection, lenBalKey, lenBalanceStr)
 
 	// Check queries
 	expectedResp = "{\"Balance\":\"" + expBalanceStr + "\"}"
@@ -560,16 +915,18 @@ func TestTradeWorkflow_LetterOfCreditTransfer(t *testing.T) {
 func TestTradeWorkflow_PaymentFulfilment_LetterOfCreditTransferBeforePayment(t *testing.T) {
 	scc := new(TradeWorkflowChaincode)
 	scc.testMode = true
-	stub := shim.NewMockStub("Trade Workflow", scc)
+	stub := newExtendedMockStub("Trade Workflow", scc)
 
 	// Init
+	stub.setTransient(getInitTransient())
 	checkInit(t, stub, getInitArguments())
 
 	// Invoke 'requestTrade', 'acceptTrade', 'requestLC', 'issueLC', 'acceptLC', 'requestEL', 'issueEL', 'prepareShipment', 'acceptShipmentAndIssueBL'
 	tradeID := "2ks89j9"
 	amount := 50000
 	descGoods := "Wood for Toys"
-	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(strconv.Itoa(amount)), []byte(descGoods)})
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(descGoods)})
 	checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeID)})
 	checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(tradeID)})
 	lcID := "lc8349"
@@ -589,7 +946,8 @@ func TestTradeWorkflow_PaymentFulfilment_LetterOfCreditTransferBeforePayment(t *
 	destinationPort := "Market Port"
 	checkInvoke(t, stub, [][]byte{[]byte("acceptShipmentAndIssueBL"), []byte(tradeID), []byte(blID), []byte(blExpirationDate), []byte(sourcePort), []byte(destinationPort)})
 	discountRate := float32(0.1)
-	checkInvoke(t, stub, [][]byte{[]byte("requestLCTransfer"), []byte(tradeID), []byte(strconv.FormatFloat(float64(discountRate), 'f', 2, 64))})
+	stub.setTransient(map[string]string{"discountRate": strconv.FormatFloat(float64(discountRate), 'f', 2, 64)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestLCTransfer"), []byte(tradeID)})
 	checkInvoke(t, stub, [][]byte{[]byte("issueLCTransfer"), []byte(tradeID)})
 	checkInvoke(t, stub, [][]byte{[]byte("acceptLCTransfer"), []byte(tradeID)})
 	checkInvoke(t, stub, [][]byte{[]byte("requestAdvancePayment"), []byte(tradeID)})
@@ -607,9 +965,9 @@ func TestTradeWorkflow_PaymentFulfilment_LetterOfCreditTransferBeforePayment(t *
 	payment = amount / 2
 	lenBalanceStr := strconv.Itoa(lenBalance + payment)
 	impBalanceStr := strconv.Itoa(IMPBALANCE - payment)
-	checkState(t, stub, lenBalKey, lenBalanceStr)
-	checkState(t, stub, impBalKey, impBalanceStr)
-	tradeAgreement := &TradeAgreement{amount, descGoods, ACCEPTED, payment}
+	checkPrivateState(t, stub, accountBalancesCollection, lenBalKey, lenBalanceStr)
+	checkPrivateState(t, stub, accountBalancesCollection, impBalKey, impBalanceStr)
+	tradeAgreement := withTradeTermsHash(&TradeAgreement{amount, descGoods, 0, ACCEPTED, payment, "", "", 0.0, 0, nil, ""})
 	tradeAgreementBytes, _ := json.Marshal(tradeAgreement)
 	tradeKey, _ := stub.CreateCompositeKey("Trade", []string{tradeID})
 	checkState(t, stub, tradeKey, string(tradeAgreementBytes))
@@ -631,9 +989,9 @@ func TestTradeWorkflow_PaymentFulfilment_LetterOfCreditTransferBeforePayment(t *
 	// Verify account and payment balances, and check queries
 	lenBalanceStr = strconv.Itoa(lenBalance + amount)
 	impBalanceStr = strconv.Itoa(IMPBALANCE - amount)
-	checkState(t, stub, lenBalKey, lenBalanceStr)
-	checkState(t, stub, impBalKey, impBalanceStr)
-	tradeAgreement = &TradeAgreement{amount, descGoods, ACCEPTED, amount}
+	checkPrivateState(t, stub, accountBalancesCollection, lenBalKey, lenBalanceStr)
+	checkPrivateState(t, stub, accountBalancesCollection, impBalKey, impBalanceStr)
+	tradeAgreement = withTradeTermsHash(&TradeAgreement{amount, descGoods, 0, ACCEPTED, amount, "", "", 0.0, 0, nil, ""})
 	tradeAgreementBytes, _ = json.Marshal(tradeAgreement)
 	checkState(t, stub, tradeKey, string(tradeAgreementBytes))
 
@@ -647,16 +1005,18 @@ func TestTradeWorkflow_PaymentFulfilment_LetterOfCreditTransferBeforePayment(t *
 func TestTradeWorkflow_PaymentFulfilment_LetterOfCreditTransferAfterPartialPayment(t *testing.T) {
 	scc := new(TradeWorkflowChaincode)
 	scc.testMode = true
-	stub := shim.NewMockStub("Trade Workflow", scc)
+	stub := newExtendedMockStub("Trade Workflow", scc)
 
 	// Init
+	stub.setTransient(getInitTransient())
 	checkInit(t, stub, getInitArguments())
 
 	// Invoke 'requestTrade', 'acceptTrade', 'requestLC', 'issueLC', 'acceptLC', 'requestEL', 'issueEL', 'prepareShipment', 'acceptShipmentAndIssueBL'
 	tradeID := "2ks89j9"
 	amount := 50000
 	descGoods := "Wood for Toys"
-	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(strconv.Itoa(amount)), []byte(descGoods)})
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(descGoods)})
 	checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeID)})
 	checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(tradeID)})
 	lcID := "lc8349"
@@ -679,7 +1039,8 @@ func TestTradeWorkflow_PaymentFulfilment_LetterOfCreditTransferAfterPartialPayme
 	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeID), []byte("01/01/2019")})
 
 	discountRate := float32(0.1)
-	checkInvoke(t, stub, [][]byte{[]byte("requestLCTransfer"), []byte(tradeID), []byte(strconv.FormatFloat(float64(discountRate), 'f', 2, 64))})
+	stub.setTransient(map[string]string{"discountRate": strconv.FormatFloat(float64(discountRate), 'f', 2, 64)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestLCTransfer"), []byte(tradeID)})
 	checkInvoke(t, stub, [][]byte{[]byte("issueLCTransfer"), []byte(tradeID)})
 	checkInvoke(t, stub, [][]byte{[]byte("acceptLCTransfer"), []byte(tradeID)})
 	checkInvoke(t, stub, [][]byte{[]byte("requestAdvancePayment"), []byte(tradeID)})
@@ -689,8 +1050,8 @@ func TestTradeWorkflow_PaymentFulfilment_LetterOfCreditTransferAfterPartialPayme
 	payment := int((fullRate - discountRate) * float32(amount / 2))
 	expBalanceStr := strconv.Itoa(EXPBALANCE + amount / 2 + payment)
 	lenBalanceStr := strconv.Itoa(LENBALANCE - payment)
-	checkState(t, stub, expBalKey, expBalanceStr)
-	checkState(t, stub, lenBalKey, lenBalanceStr)
+	checkPrivateState(t, stub, accountBalancesCollection, expBalKey, expBalanceStr)
+	checkPrivateState(t, stub, accountBalancesCollection, lenBalKey, lenBalanceStr)
 
 	// Check queries
 	expectedResp := "{\"Balance\":\"" + expBalanceStr + "\"}"
@@ -706,9 +1067,9 @@ func TestTradeWorkflow_PaymentFulfilment_LetterOfCreditTransferAfterPartialPayme
 	// Verify account and payment balances, and check queries
 	lenBalanceStr = strconv.Itoa(LENBALANCE - payment + amount / 2)
 	impBalanceStr := strconv.Itoa(IMPBALANCE - amount)
-	checkState(t, stub, lenBalKey, lenBalanceStr)
-	checkState(t, stub, impBalKey, impBalanceStr)
-	tradeAgreement := &TradeAgreement{amount, descGoods, ACCEPTED, amount}
+	checkPrivateState(t, stub, accountBalancesCollection, lenBalKey, lenBalanceStr)
+	checkPrivateState(t, stub, accountBalancesCollection, impBalKey, impBalanceStr)
+	tradeAgreement := withTradeTermsHash(&TradeAgreement{amount, descGoods, 0, ACCEPTED, amount, "", "", 0.0, 0, nil, ""})
 	tradeAgreementBytes, _ := json.Marshal(tradeAgreement)
 	tradeKey, _ := stub.CreateCompositeKey("Trade", []string{tradeID})
 	checkState(t, stub, tradeKey, string(tradeAgreementBytes))
@@ -719,3 +1080,2759 @@ func TestTradeWorkflow_PaymentFulfilment_LetterOfCreditTransferAfterPartialPayme
 	expectedResp = "{\"Balance\":\"" + impBalanceStr + "\"}"
 	checkQueryArgs(t, stub, [][]byte{[]byte("getAccountBalance"), []byte(tradeID), []byte("importer")}, expectedResp)
 }
+
+func TestTradeWorkflow_Documents(t *testing.T) {
+	scc := new(TradeWorkflowChaincode)
+	scc.testMode = true
+	stub := newExtendedMockStub("Trade Workflow", scc)
+
+	// Init
+	stub.setTransient(getInitTransient())
+	checkInit(t, stub, getInitArguments())
+
+	// Invoke 'requestTrade', 'acceptTrade', 'requestLC', 'issueLC'
+	tradeID := "2ks89j9"
+	amount := 50000
+	descGoods := "Wood for Toys"
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(descGoods)})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(tradeID)})
+	lcID := "lc8349"
+	expirationDate := "12/31/2018"
+	doc1 := "Commercial Invoice"
+	doc2 := "B/L"
+	checkInvoke(t, stub, [][]byte{[]byte("issueLC"), []byte(tradeID), []byte(lcID), []byte(expirationDate), []byte(doc1), []byte(doc2)})
+
+	// Invoke bad 'uploadDocument' and verify that nothing is recorded
+	docID := "inv-001"
+	hash := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	mediaType := "application/pdf"
+	size := "48213"
+	uri := "https://docs.lumberinc.com/inv-001.pdf"
+	checkBadInvoke(t, stub, [][]byte{[]byte("uploadDocument"), []byte(tradeID), []byte(docID), []byte("Trade"), []byte(doc1), []byte(hash), []byte(mediaType), []byte(size)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("uploadDocument"), []byte(tradeID), []byte(docID), []byte("Trade"), []byte(doc1), []byte("abcd"), []byte(mediaType), []byte(size), []byte(uri)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("uploadDocument"), []byte(tradeID), []byte(docID), []byte("Trade"), []byte(doc1), []byte(hash), []byte(mediaType), []byte("-1"), []byte(uri)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("uploadDocument"), []byte(tradeID), []byte(docID), []byte("Invoice"), []byte(doc1), []byte(hash), []byte(mediaType), []byte(size), []byte(uri)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("uploadDocument"), []byte(tradeID), []byte(docID), []byte("BillOfLading"), []byte(doc1), []byte(hash), []byte(mediaType), []byte(size), []byte(uri)})
+	documentKey, _ := stub.CreateCompositeKey("Document", []string{tradeID, docID})
+	checkNoState(t, stub, documentKey)
+
+	// Invoke 'uploadDocument' and verify state change
+	checkInvoke(t, stub, [][]byte{[]byte("uploadDocument"), []byte(tradeID), []byte(docID), []byte("Trade"), []byte(doc1), []byte(hash), []byte(mediaType), []byte(size), []byte(uri)})
+	document := &Document{docID, tradeID, "", "Trade", doc1, hash, mediaType, 48213, uri, "", ""}
+	documentBytes, _ := json.Marshal(document)
+	checkState(t, stub, documentKey, string(documentBytes))
+	checkQueryArgs(t, stub, [][]byte{[]byte("getDocument"), []byte(tradeID), []byte(docID)}, string(documentBytes))
+
+	// Documents cannot be replaced
+	checkBadInvoke(t, stub, [][]byte{[]byte("uploadDocument"), []byte(tradeID), []byte(docID), []byte("Trade"), []byte(doc1), []byte(hash), []byte(mediaType), []byte(size), []byte(uri)})
+
+	// A B/L copy attached to the L/C itself is not the trade's shipping document, and fulfils no reference
+	checkInvoke(t, stub, [][]byte{[]byte("uploadDocument"), []byte(tradeID), []byte("bl-copy"), []byte("LetterOfCredit"), []byte(doc2), []byte(hash), []byte(mediaType), []byte(size), []byte(uri)})
+
+	// The L/C now references the uploaded document
+	letterOfCredit := withLetterOfCreditTermsHash(&LetterOfCredit{lcID, expirationDate, EXPORTER, amount, []DocumentReference{{doc1, docID}, {doc2, ""}}, ISSUED, false, nil, ""})
+	letterOfCreditBytes, _ := json.Marshal(letterOfCredit)
+	lcKey, _ := stub.CreateCompositeKey("LetterOfCredit", []string{tradeID})
+	checkState(t, stub, lcKey, string(letterOfCreditBytes))
+
+	// Verify document hashes
+	checkQueryArgs(t, stub, [][]byte{[]byte("verifyDocument"), []byte(tradeID), []byte(docID), []byte(strings.ToUpper(hash))}, "{\"Verified\":\"true\"}")
+	otherHash := "60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752"
+	checkQueryArgs(t, stub, [][]byte{[]byte("verifyDocument"), []byte(tradeID), []byte(docID), []byte(otherHash)}, "{\"Verified\":\"false\"}")
+	checkBadInvoke(t, stub, [][]byte{[]byte("verifyDocument"), []byte(tradeID), []byte("inv-002"), []byte(hash)})
+}
+
+func TestTradeWorkflow_Signatures(t *testing.T) {
+	scc := new(TradeWorkflowChaincode)
+	scc.testMode = true
+	stub := newExtendedMockStub("Trade Workflow", scc)
+
+	// Init
+	stub.setTransient(getInitTransient())
+	checkInit(t, stub, getInitArguments())
+
+	// Invoke 'requestTrade', 'acceptTrade', 'requestLC', 'issueLC', 'acceptLC', 'requestEL'
+	tradeID := "2ks89j9"
+	amount := 50000
+	descGoods := "Wood for Toys"
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(descGoods)})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueLC"), []byte(tradeID), []byte("lc8349"), []byte("12/31/2018"), []byte("E/L"), []byte("B/L")})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptLC"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestEL"), []byte(tradeID)})
+
+	// Invoke 'issueEL' with a signature over different content and verify failure
+	elID := "el979"
+	elExpirationDate := "04/30/2019"
+	stub.setCreator(t, "RegulatorOrgMSP", "ca.regulatororg.trade.com", "Admin@regulatororg.trade.com")
+	exportLicense := &ExportLicense{elID, elExpirationDate, EXPORTER, CARRIER, descGoods, "", REGAUTH, ISSUED, nil, "", false, "", ""}
+	elContent, _ := canonicalExportLicense(exportLicense)
+	stub.setTransient(map[string]string{"signature": string(stub.sign(t, []byte("forged")))})
+	checkBadInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(tradeID), []byte(elID), []byte(elExpirationDate)})
+	checkQuery(t, stub, "getELStatus", tradeID, "{\"Status\":\"REQUESTED\"}")
+
+	// Invoke 'issueEL' signed by the regulator and verify that the signature is stored
+	elSignature := stub.sign(t, elContent)
+	stub.setTransient(map[string]string{"signature": string(elSignature)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(tradeID), []byte(elID), []byte(elExpirationDate)})
+	exportLicense.Signature = base64.StdEncoding.EncodeToString(elSignature)
+	exportLicense.SignerCertificate = stub.creatorCert
+	exportLicenseBytes, _ := json.Marshal(exportLicense)
+	elKey, _ := stub.CreateCompositeKey("ExportLicense", []string{tradeID})
+	checkState(t, stub, elKey, string(exportLicenseBytes))
+
+	// Invoke 'prepareShipment' and 'acceptShipmentAndIssueBL' signed by the carrier
+	stub.setTransient(map[string]string{})
+	checkInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(tradeID)})
+	blID := "bl06678"
+	blExpirationDate := "08/31/2018"
+	sourcePort := "Woodlands Port"
+	destinationPort := "Market Port"
+	stub.setCreator(t, "CarrierOrgMSP", "ca.carrierorg.trade.com", "Admin@carrierorg.trade.com")
+	billOfLading := &BillOfLading{blID, blExpirationDate, EXPORTER, CARRIER, descGoods, 0, IMPBANK, sourcePort, destinationPort, nil, IMPBANK, "ImporterOrgMSP", "", HELD_BY_BANK, []Endorsement{}, "", ""}
+	blContent, _ := canonicalBillOfLading(billOfLading)
+	blSignature := stub.sign(t, blContent)
+	stub.setTransient(map[string]string{"signature": string(blSignature)})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptShipmentAndIssueBL"), []byte(tradeID), []byte(blID), []byte(blExpirationDate), []byte(sourcePort), []byte(destinationPort)})
+	billOfLading.Signature = base64.StdEncoding.EncodeToString(blSignature)
+	billOfLading.SignerCertificate = stub.creatorCert
+	billOfLadingBytes, _ := json.Marshal(billOfLading)
+	checkQuery(t, stub, "getBillOfLading", tradeID, string(billOfLadingBytes))
+
+	// Anyone holding the signed content can verify it against the ledger
+	stub.setCreator(t, "ImporterOrgMSP", "ca.importerorg.trade.com", "Auditor@importerorg.trade.com")
+	blHash := sha256.Sum256(blContent)
+	expectedResp := "{\"Verified\":\"true\",\"Signer\":\"CN=Admin@carrierorg.trade.com,OU=client\",\"Issuer\":\"ca.carrierorg.trade.com\"}"
+	checkQueryArgs(t, stub, [][]byte{[]byte("verifySignature"), []byte(tradeID), []byte("BillOfLading"), []byte(hex.EncodeToString(blHash[:]))}, expectedResp)
+	elHash := sha256.Sum256(elContent)
+	expectedResp = "{\"Verified\":\"true\",\"Signer\":\"CN=Admin@regulatororg.trade.com,OU=client\",\"Issuer\":\"ca.regulatororg.trade.com\"}"
+	checkQueryArgs(t, stub, [][]byte{[]byte("verifySignature"), []byte(tradeID), []byte("ExportLicense"), []byte(hex.EncodeToString(elHash[:]))}, expectedResp)
+	expectedResp = "{\"Verified\":\"false\",\"Signer\":\"CN=Admin@regulatororg.trade.com,OU=client\",\"Issuer\":\"ca.regulatororg.trade.com\"}"
+	checkQueryArgs(t, stub, [][]byte{[]byte("verifySignature"), []byte(tradeID), []byte("ExportLicense"), []byte(hex.EncodeToString(blHash[:]))}, expectedResp)
+	checkBadInvoke(t, stub, [][]byte{[]byte("verifySignature"), []byte(tradeID), []byte("LetterOfCredit"), []byte(hex.EncodeToString(blHash[:]))})
+}
+
+func TestTradeWorkflow_BillOfLadingEndorsement(t *testing.T) {
+	scc := new(TradeWorkflowChaincode)
+	scc.testMode = true
+	stub := newExtendedMockStub("Trade Workflow", scc)
+
+	// Init
+	stub.setTransient(getInitTransient())
+	checkInit(t, stub, getInitArguments())
+
+	// Invoke 'requestTrade', 'acceptTrade', 'requestLC', 'issueLC', 'acceptLC', 'requestEL', 'issueEL', 'prepareShipment', 'acceptShipmentAndIssueBL'
+	tradeID := "2ks89j9"
+	amount := 50000
+	descGoods := "Wood for Toys"
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(descGoods)})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(tradeID)})
+	lcID := "lc8349"
+	lcExpirationDate := "12/31/2018"
+	doc1 := "E/L"
+	doc2 := "B/L"
+	checkInvoke(t, stub, [][]byte{[]byte("issueLC"), []byte(tradeID), []byte(lcID), []byte(lcExpirationDate), []byte(doc1), []byte(doc2)})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptLC"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestEL"), []byte(tradeID)})
+	elID := "el979"
+	elExpirationDate := "04/30/2019"
+	checkInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(tradeID), []byte(elID), []byte(elExpirationDate)})
+	checkInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(tradeID)})
+	blID := "bl06678"
+	blExpirationDate := "08/31/2018"
+	sourcePort := "Woodlands Port"
+	destinationPort := "Market Port"
+	checkInvoke(t, stub, [][]byte{[]byte("acceptShipmentAndIssueBL"), []byte(tradeID), []byte(blID), []byte(blExpirationDate), []byte(sourcePort), []byte(destinationPort)})
+	blKey, _ := stub.CreateCompositeKey("BillOfLading", []string{tradeID})
+
+	// The importer's bank holds the B/L until payment; invoke bad 'endorseBL' and 'surrenderBL' and verify unchanged state
+	billOfLading := &BillOfLading{blID, blExpirationDate, EXPORTER, CARRIER, descGoods, 0, IMPBANK, sourcePort, destinationPort, nil, IMPBANK, "ImporterOrgMSP", "", HELD_BY_BANK, []Endorsement{}, "", ""}
+	billOfLadingBytes, _ := json.Marshal(billOfLading)
+	checkBadInvoke(t, stub, [][]byte{[]byte("endorseBL"), []byte(tradeID), []byte(IMPORTER), []byte("ImporterOrgMSP")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("surrenderBL"), []byte(tradeID)})
+	checkState(t, stub, blKey, string(billOfLadingBytes))
+
+	// Pay in full: half at source, the balance at destination
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeID), []byte("01/01/2019")})
+	checkState(t, stub, blKey, string(billOfLadingBytes))
+	checkInvoke(t, stub, [][]byte{[]byte("updateShipmentLocation"), []byte(tradeID), []byte(DESTINATION), []byte("02/01/2019")})
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeID), []byte("03/01/2019")})
+	billOfLading.Holder = IMPORTER
+	billOfLading.Status = RELEASED_TO_IMPORTER
+	billOfLading.Endorsements = []Endorsement{Endorsement{IMPBANK, "ImporterOrgMSP", IMPORTER, "ImporterOrgMSP", ""}}
+	billOfLadingBytes, _ = json.Marshal(billOfLading)
+	checkState(t, stub, blKey, string(billOfLadingBytes))
+
+	// Invoke bad 'endorseBL' and verify unchanged state
+	checkBadInvoke(t, stub, [][]byte{[]byte("endorseBL"), []byte(tradeID), []byte("ToyStore")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("endorseBL"), []byte("abcd"), []byte("ToyStore"), []byte("RetailerOrgMSP")})
+	checkState(t, stub, blKey, string(billOfLadingBytes))
+
+	// No user's subject is recorded for the importer, so the importer's org holds the B/L; invoke 'endorseBL' to a named endorsee,
+	// and the B/L is negotiated
+	scc.testMode = false
+	stub.setCreator(t, "RetailerOrgMSP", "ca.retailerorg.trade.com", "Dan@retailerorg.trade.com")
+	checkBadInvoke(t, stub, [][]byte{[]byte("endorseBL"), []byte(tradeID), []byte("ToyStore"), []byte("RetailerOrgMSP"), []byte("CN=Dan@retailerorg.trade.com,OU=client")})
+	checkState(t, stub, blKey, string(billOfLadingBytes))
+	stub.setCreator(t, "ImporterOrgMSP", "ca.importerorg.trade.com", "Alice@importerorg.trade.com")
+	checkInvoke(t, stub, [][]byte{[]byte("endorseBL"), []byte(tradeID), []byte("ToyStore"), []byte("RetailerOrgMSP"), []byte("CN=Dan@retailerorg.trade.com,OU=client")})
+	scc.testMode = true
+	billOfLading.Holder = "ToyStore"
+	billOfLading.HolderOrg = "RetailerOrgMSP"
+	billOfLading.HolderSubject = "CN=Dan@retailerorg.trade.com,OU=client"
+	billOfLading.Status = NEGOTIATED
+	billOfLading.Endorsements = append(billOfLading.Endorsements, Endorsement{IMPORTER, "ImporterOrgMSP", "ToyStore", "RetailerOrgMSP", ""})
+	billOfLadingBytes, _ = json.Marshal(billOfLading)
+	checkState(t, stub, blKey, string(billOfLadingBytes))
+
+	// Only the endorsee's own user can act as the holder; invoke 'endorseBL' in blank, and the B/L becomes a bearer document
+	bearerToken := "b7c1f0e29a4d4c8e9f3a6d2b5e8c1f47"
+	scc.testMode = false
+	stub.setCreator(t, "RetailerOrgMSP", "ca.retailerorg.trade.com", "Eve@retailerorg.trade.com")
+	stub.setTransient(map[string]string{"bearerToken": bearerToken})
+	checkBadInvoke(t, stub, [][]byte{[]byte("endorseBL"), []byte(tradeID)})
+	checkState(t, stub, blKey, string(billOfLadingBytes))
+	stub.setCreator(t, "RetailerOrgMSP", "ca.retailerorg.trade.com", "Dan@retailerorg.trade.com")
+	stub.setTransient(map[string]string{})
+	checkBadInvoke(t, stub, [][]byte{[]byte("endorseBL"), []byte(tradeID)})
+	stub.setTransient(map[string]string{"bearerToken": "guessable"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("endorseBL"), []byte(tradeID)})
+	checkState(t, stub, blKey, string(billOfLadingBytes))
+	stub.setTransient(map[string]string{"bearerToken": bearerToken})
+	checkInvoke(t, stub, [][]byte{[]byte("endorseBL"), []byte(tradeID)})
+	scc.testMode = true
+	billOfLading.Holder = ""
+	billOfLading.HolderOrg = ""
+	billOfLading.HolderSubject = ""
+	billOfLading.Endorsements = append(billOfLading.Endorsements, Endorsement{"ToyStore", "RetailerOrgMSP", "", "", hashPrivateData([]byte(bearerToken))})
+	billOfLadingBytes, _ = json.Marshal(billOfLading)
+	checkState(t, stub, blKey, string(billOfLadingBytes))
+	checkQuery(t, stub, "getBillOfLading", tradeID, string(billOfLadingBytes))
+
+	// Invoke 'surrenderBL' at destination, once Customs has cleared the goods; only the bearer of the token can present the B/L
+	checkBadInvoke(t, stub, [][]byte{[]byte("surrenderBL"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("setTariffRate"), []byte("4421"), []byte("0"), []byte("0")})
+	stub.setTransient(map[string]string{"values": "[50000]"})
+	checkInvoke(t, stub, [][]byte{[]byte("fileCustomsDeclaration"), []byte(tradeID), []byte("[{\"hsCode\":\"4421.91\",\"description\":\"Wooden toy parts\",\"quantity\":1}]")})
+	checkInvoke(t, stub, [][]byte{[]byte("assessDuty"), []byte(tradeID)})
+	stub.setTransient(map[string]string{})
+	checkBadInvoke(t, stub, [][]byte{[]byte("surrenderBL"), []byte(tradeID)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("endorseBL"), []byte(tradeID), []byte(IMPORTER), []byte("ImporterOrgMSP"), []byte("CN=Alice@importerorg.trade.com,OU=client")})
+	stub.setTransient(map[string]string{"bearerToken": "a7c1f0e29a4d4c8e9f3a6d2b5e8c1f47"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("surrenderBL"), []byte(tradeID)})
+	checkState(t, stub, blKey, string(billOfLadingBytes))
+	stub.setTransient(map[string]string{"bearerToken": bearerToken})
+	checkInvoke(t, stub, [][]byte{[]byte("surrenderBL"), []byte(tradeID)})
+	billOfLading.Status = SURRENDERED
+	billOfLadingBytes, _ = json.Marshal(billOfLading)
+	checkState(t, stub, blKey, string(billOfLadingBytes))
+
+	// A surrendered B/L can be neither endorsed nor surrendered again
+	checkBadInvoke(t, stub, [][]byte{[]byte("endorseBL"), []byte(tradeID), []byte(IMPORTER), []byte("ImporterOrgMSP")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("surrenderBL"), []byte(tradeID)})
+	checkState(t, stub, blKey, string(billOfLadingBytes))
+
+	// The issuer's signature is unaffected by transfers of title
+	blContent, _ := canonicalBillOfLading(billOfLading)
+	billOfLading = &BillOfLading{blID, blExpirationDate, EXPORTER, CARRIER, descGoods, 0, IMPBANK, sourcePort, destinationPort, nil, IMPBANK, "ImporterOrgMSP", "", HELD_BY_BANK, []Endorsement{}, "", ""}
+	issuedContent, _ := canonicalBillOfLading(billOfLading)
+	if string(blContent) != string(issuedContent) {
+		fmt.Println("Signed B/L content changed after endorsement")
+		t.FailNow()
+	}
+
+	// The importer's bank can negotiate a B/L it holds, acting in its role; other users of its org cannot
+	otherTradeID := "7hd62k1"
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(otherTradeID), []byte(descGoods)})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(otherTradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(otherTradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueLC"), []byte(otherTradeID), []byte(lcID), []byte(lcExpirationDate), []byte(doc1), []byte(doc2)})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptLC"), []byte(otherTradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestEL"), []byte(otherTradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(otherTradeID), []byte(elID), []byte(elExpirationDate)})
+	checkInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(otherTradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptShipmentAndIssueBL"), []byte(otherTradeID), []byte(blID), []byte(blExpirationDate), []byte(sourcePort), []byte(destinationPort)})
+	blKey, _ = stub.CreateCompositeKey("BillOfLading", []string{otherTradeID})
+	billOfLadingBytes, _ = json.Marshal(billOfLading)
+	endorseArgs := [][]byte{[]byte("endorseBL"), []byte(otherTradeID), []byte("TradeBank"), []byte("FinancierOrgMSP"), []byte("CN=Grace@financierorg.trade.com,OU=client")}
+	scc.testMode = false
+	stub.setCreator(t, "ImporterOrgMSP", "ca.importerorg.trade.com", "Alice@importerorg.trade.com")
+	checkBadInvoke(t, stub, endorseArgs)
+	checkBadInvoke(t, stub, [][]byte{[]byte("surrenderBL"), []byte(otherTradeID)})
+	checkState(t, stub, blKey, string(billOfLadingBytes))
+	stub.setCreatorWithAttributes(t, "ImporterOrgMSP", "ca.importerorg.trade.com", "Erin@importerorg.trade.com", map[string]string{"tradeRole": "ImportersBank"})
+	checkInvoke(t, stub, endorseArgs)
+	scc.testMode = true
+	billOfLading.Holder = "TradeBank"
+	billOfLading.HolderOrg = "FinancierOrgMSP"
+	billOfLading.HolderSubject = "CN=Grace@financierorg.trade.com,OU=client"
+	billOfLading.Status = NEGOTIATED
+	billOfLading.Endorsements = []Endorsement{{IMPBANK, "ImporterOrgMSP", "TradeBank", "FinancierOrgMSP", ""}}
+	billOfLadingBytes, _ = json.Marshal(billOfLading)
+	checkState(t, stub, blKey, string(billOfLadingBytes))
+}
+
+func TestTradeWorkflow_ShipmentTracking(t *testing.T) {
+	scc := new(TradeWorkflowChaincode)
+	scc.testMode = true
+	stub := newExtendedMockStub("Trade Workflow", scc)
+
+	// Init
+	stub.setTransient(getInitTransient())
+	checkInit(t, stub, getInitArguments())
+
+	// Invoke 'requestTrade', 'acceptTrade', 'requestLC', 'issueLC', 'acceptLC', 'requestEL', 'issueEL'
+	tradeID := "2ks89j9"
+	amount := 50000
+	descGoods := "Wood for Toys"
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(descGoods)})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(tradeID)})
+	lcID := "lc8349"
+	lcExpirationDate := "12/31/2018"
+	doc1 := "E/L"
+	doc2 := "B/L"
+	checkInvoke(t, stub, [][]byte{[]byte("issueLC"), []byte(tradeID), []byte(lcID), []byte(lcExpirationDate), []byte(doc1), []byte(doc2)})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptLC"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestEL"), []byte(tradeID)})
+	elID := "el979"
+	elExpirationDate := "04/30/2019"
+	checkInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(tradeID), []byte(elID), []byte(elExpirationDate)})
+
+	// Invoke bad 'planShipmentLeg' before the shipment is prepared
+	checkBadInvoke(t, stub, [][]byte{[]byte("planShipmentLeg"), []byte(tradeID), []byte("CNSHA"), []byte("SGSIN"), []byte("Ever Given"), []byte("042E")})
+	checkInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(tradeID)})
+
+	// Invoke bad 'planShipmentLeg' and verify unchanged state
+	shipmentKey, _ := stub.CreateCompositeKey("Shipment", []string{"Tracking", tradeID})
+	checkBadInvoke(t, stub, [][]byte{[]byte("planShipmentLeg"), []byte(tradeID), []byte("CNSHA"), []byte("SGSIN"), []byte("Ever Given")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("planShipmentLeg"), []byte(tradeID), []byte("Shanghai"), []byte("SGSIN"), []byte("Ever Given"), []byte("042E")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("planShipmentLeg"), []byte(tradeID), []byte("CNSHA"), []byte("CNSHA"), []byte("Ever Given"), []byte("042E")})
+	checkNoState(t, stub, shipmentKey)
+
+	// Invoke 'planShipmentLeg': port of loading to transshipment port to port of discharge, then by rail to an inland depot
+	checkInvoke(t, stub, [][]byte{[]byte("planShipmentLeg"), []byte(tradeID), []byte("CNSHA"), []byte("SGSIN"), []byte("Ever Given"), []byte("042E")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("planShipmentLeg"), []byte(tradeID), []byte("MYPKG"), []byte("NLRTM"), []byte("Maersk Elba"), []byte("107W")})
+	checkInvoke(t, stub, [][]byte{[]byte("planShipmentLeg"), []byte(tradeID), []byte("SG SIN"), []byte("nlrtm"), []byte("Maersk Elba"), []byte("107W")})
+	checkInvoke(t, stub, [][]byte{[]byte("planShipmentLeg"), []byte(tradeID), []byte("NLRTM"), []byte("DEDUI"), []byte(""), []byte("")})
+	shipment := &Shipment{tradeID, []ShipmentLeg{{"CNSHA", "SGSIN", "Ever Given", "042E"}, {"SGSIN", "NLRTM", "Maersk Elba", "107W"}, {"NLRTM", "DEDUI", "", ""}}, []ShipmentCheckpoint{}, nil, nil, nil, nil, nil}
+	shipmentBytes, _ := json.Marshal(shipment)
+	checkState(t, stub, shipmentKey, string(shipmentBytes))
+
+	// The location is no longer set directly
+	checkBadInvoke(t, stub, [][]byte{[]byte("updateShipmentLocation"), []byte(tradeID), []byte(DESTINATION), []byte("02/01/2019")})
+
+	// Invoke bad 'recordCheckpoint' and verify unchanged state
+	checkBadInvoke(t, stub, [][]byte{[]byte("recordCheckpoint"), []byte(tradeID), []byte("USNYC"), []byte("2019-01-05T08:00:00Z"), []byte("Ever Given"), []byte("042E"), []byte(LOADED)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("recordCheckpoint"), []byte(tradeID), []byte("CNSHA"), []byte("01/05/2019"), []byte("Ever Given"), []byte("042E"), []byte(LOADED)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("recordCheckpoint"), []byte(tradeID), []byte("CNSHA"), []byte("2019-01-05T08:00:00Z"), []byte("Ever Given"), []byte("042E"), []byte("SUNK")})
+	checkState(t, stub, shipmentKey, string(shipmentBytes))
+
+	// Invoke 'recordCheckpoint' along the route
+	checkInvoke(t, stub, [][]byte{[]byte("recordCheckpoint"), []byte(tradeID), []byte("CNSHA"), []byte("2019-01-05T08:00:00Z"), []byte("Ever Given"), []byte("042E"), []byte(LOADED)})
+	checkInvoke(t, stub, [][]byte{[]byte("recordCheckpoint"), []byte(tradeID), []byte("CNSHA"), []byte("2019-01-06T18:30:00Z"), []byte("Ever Given"), []byte("042E"), []byte(DEPARTED)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("recordCheckpoint"), []byte(tradeID), []byte("SGSIN"), []byte("2019-01-06T12:00:00Z"), []byte("Ever Given"), []byte("042E"), []byte(ARRIVED)})
+	checkInvoke(t, stub, [][]byte{[]byte("recordCheckpoint"), []byte(tradeID), []byte("SGSIN"), []byte("2019-01-12T06:00:00Z"), []byte("Ever Given"), []byte("042E"), []byte(ARRIVED)})
+	slKey, _ := stub.CreateCompositeKey("Shipment", []string{"Location", tradeID})
+	checkState(t, stub, slKey, SOURCE)
+	checkInvoke(t, stub, [][]byte{[]byte("recordCheckpoint"), []byte(tradeID), []byte("NLRTM"), []byte("2019-01-30T10:00:00Z"), []byte("Maersk Elba"), []byte("107W"), []byte(DISCHARGED)})
+	checkState(t, stub, slKey, SOURCE)
+
+	// Arrival at the inland depot completes the shipment
+	checkInvoke(t, stub, [][]byte{[]byte("recordCheckpoint"), []byte(tradeID), []byte("DEDUI"), []byte("2019-02-01T15:00:00+01:00"), []byte(""), []byte(""), []byte(ARRIVED)})
+	checkState(t, stub, slKey, DESTINATION)
+	adKey, _ := stub.CreateCompositeKey("Shipment", []string{"ArrivalDate", tradeID})
+	checkState(t, stub, adKey, "02/01/2019")
+	checkBadInvoke(t, stub, [][]byte{[]byte("planShipmentLeg"), []byte(tradeID), []byte("DEDUI"), []byte("DEBER"), []byte(""), []byte("")})
+
+	shipment.Checkpoints = []ShipmentCheckpoint{
+		{"CNSHA", "2019-01-05T08:00:00Z", "Ever Given", "042E", LOADED},
+		{"CNSHA", "2019-01-06T18:30:00Z", "Ever Given", "042E", DEPARTED},
+		{"SGSIN", "2019-01-12T06:00:00Z", "Ever Given", "042E", ARRIVED},
+		{"NLRTM", "2019-01-30T10:00:00Z", "Maersk Elba", "107W", DISCHARGED},
+		{"DEDUI", "2019-02-01T15:00:00+01:00", "", "", ARRIVED},
+	}
+	shipmentBytes, _ = json.Marshal(shipment)
+	checkState(t, stub, shipmentKey, string(shipmentBytes))
+	checkQuery(t, stub, "getShipment", tradeID, string(shipmentBytes))
+	expectedResp := "{\"Location\":\"DESTINATION\"}"
+	checkQuery(t, stub, "getShipmentLocation", tradeID, expectedResp)
+}
+
+func TestTradeWorkflow_DeliveryDelay(t *testing.T) {
+	scc := new(TradeWorkflowChaincode)
+	scc.testMode = true
+	stub := newExtendedMockStub("Trade Workflow", scc)
+
+	// Init
+	stub.setTransient(getInitTransient())
+	checkInit(t, stub, getInitArguments())
+
+	// Invoke bad 'requestTrade' with an invalid delivery window or a penalty without one
+	tradeID := "2ks89j9"
+	amount := 50000
+	descGoods := "Wood for Toys"
+	windowStart := "01/01/2019"
+	windowEnd := "01/31/2019"
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount), "delayPenaltyRate": "0.01"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(descGoods), []byte(windowEnd), []byte(windowStart)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(descGoods), []byte(windowStart), []byte("2019-01-31")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(descGoods)})
+	tradeKey, _ := stub.CreateCompositeKey("Trade", []string{tradeID})
+	checkNoState(t, stub, tradeKey)
+
+	// Invoke 'requestTrade' with a delivery window and a penalty of 1% of the amount per day of delay
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(descGoods), []byte(windowStart), []byte(windowEnd)})
+	tradeAgreement := withTradeTermsHash(&TradeAgreement{amount, descGoods, 0, REQUESTED, 0, windowStart, windowEnd, 0.01, 0, nil, ""})
+	tradeAgreementBytes, _ := json.Marshal(tradeAgreement)
+	checkState(t, stub, tradeKey, string(tradeAgreementBytes))
+	tradeTermsBytes, _ := json.Marshal(&TradeTerms{amount, 0, 0.01, 0})
+	checkPrivateState(t, stub, tradeTermsCollection, tradeKey, string(tradeTermsBytes))
+
+	// Invoke 'acceptTrade', 'requestLC', 'issueLC', 'acceptLC', 'requestEL', 'issueEL', 'prepareShipment', 'acceptShipmentAndIssueBL'
+	checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(tradeID)})
+	lcID := "lc8349"
+	lcExpirationDate := "12/31/2018"
+	doc1 := "E/L"
+	doc2 := "B/L"
+	checkInvoke(t, stub, [][]byte{[]byte("issueLC"), []byte(tradeID), []byte(lcID), []byte(lcExpirationDate), []byte(doc1), []byte(doc2)})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptLC"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestEL"), []byte(tradeID)})
+	elID := "el979"
+	elExpirationDate := "04/30/2019"
+	checkInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(tradeID), []byte(elID), []byte(elExpirationDate)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("updateShipmentETA"), []byte(tradeID), []byte("2019-01-25T12:00:00Z")})
+	checkInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(tradeID)})
+	blID := "bl06678"
+	blExpirationDate := "08/31/2018"
+	sourcePort := "Woodlands Port"
+	destinationPort := "Market Port"
+	checkInvoke(t, stub, [][]byte{[]byte("acceptShipmentAndIssueBL"), []byte(tradeID), []byte(blID), []byte(blExpirationDate), []byte(sourcePort), []byte(destinationPort)})
+
+	// Invoke 'updateShipmentETA' within the delivery window
+	shipmentKey, _ := stub.CreateCompositeKey("Shipment", []string{"Tracking", tradeID})
+	checkBadInvoke(t, stub, [][]byte{[]byte("updateShipmentETA"), []byte(tradeID), []byte("01/25/2019")})
+	checkInvoke(t, stub, [][]byte{[]byte("updateShipmentETA"), []byte(tradeID), []byte("2019-01-25T12:00:00Z")})
+	checkNoEvent(t, stub)
+
+	// Invoke 'updateShipmentETA' past the delivery window; only a change in the delay is reported
+	checkInvoke(t, stub, [][]byte{[]byte("updateShipmentETA"), []byte(tradeID), []byte("2019-02-03T12:00:00Z")})
+	delayedEventBytes, _ := json.Marshal(&ShipmentDelayedEvent{tradeID, windowEnd, "02/03/2019", true, 3})
+	checkEvent(t, stub, shipmentDelayedEvent, string(delayedEventBytes))
+	checkInvoke(t, stub, [][]byte{[]byte("updateShipmentETA"), []byte(tradeID), []byte("2019-02-03T18:00:00Z")})
+	checkNoEvent(t, stub)
+	shipment := &Shipment{tradeID, []ShipmentLeg{}, []ShipmentCheckpoint{}, []EstimatedArrival{{"2019-01-25T12:00:00Z", 0}, {"2019-02-03T12:00:00Z", 3}, {"2019-02-03T18:00:00Z", 3}}, nil, nil, nil, nil}
+	shipmentBytes, _ := json.Marshal(shipment)
+	checkState(t, stub, shipmentKey, string(shipmentBytes))
+	checkQuery(t, stub, "getShipment", tradeID, string(shipmentBytes))
+
+	// Invoke 'requestPayment' and 'makePayment' at source
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeID), []byte("01/20/2019")})
+
+	// Deliver shipment to final location 5 days late
+	checkInvoke(t, stub, [][]byte{[]byte("updateShipmentLocation"), []byte(tradeID), []byte(DESTINATION), []byte("02/05/2019")})
+	delayedEventBytes, _ = json.Marshal(&ShipmentDelayedEvent{tradeID, windowEnd, "02/05/2019", false, 5})
+	checkEvent(t, stub, shipmentDelayedEvent, string(delayedEventBytes))
+	checkBadInvoke(t, stub, [][]byte{[]byte("updateShipmentETA"), []byte(tradeID), []byte("2019-02-06T12:00:00Z")})
+
+	// Invoke 'requestPayment' and 'makePayment'; the penalty is netted against the balance owed
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeID), []byte("02/10/2019")})
+	penalty := amount / 100 * 5
+	payment := amount - penalty
+	expBalanceStr := strconv.Itoa(EXPBALANCE + payment)
+	impBalanceStr := strconv.Itoa(IMPBALANCE - payment)
+	checkPrivateState(t, stub, accountBalancesCollection, expBalKey, expBalanceStr)
+	checkPrivateState(t, stub, accountBalancesCollection, impBalKey, impBalanceStr)
+	tradeAgreement = withTradeTermsHash(&TradeAgreement{amount, descGoods, 0, ACCEPTED, payment, windowStart, windowEnd, 0.01, penalty, nil, ""})
+	tradeAgreementBytes, _ = json.Marshal(tradeAgreement)
+	checkState(t, stub, tradeKey, string(tradeAgreementBytes))
+
+	// The trade is settled net of the penalty: the B/L is released and no further payment can be requested
+	releasedEventBytes, _ := json.Marshal(&BillOfLadingReleasedEvent{tradeID, blID, IMPORTER, "ImporterOrgMSP"})
+	checkEvent(t, stub, billOfLadingReleasedEvent, string(releasedEventBytes))
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeID)})
+}
+
+func TestTradeWorkflow_Telemetry(t *testing.T) {
+	scc := new(TradeWorkflowChaincode)
+	scc.testMode = true
+	stub := newExtendedMockStub("Trade Workflow", scc)
+
+	// Init
+	stub.setTransient(getInitTransient())
+	checkInit(t, stub, getInitArguments())
+
+	// Invoke 'requestTrade' and 'setTelemetryThresholds'
+	tradeID := "2ks89j9"
+	amount := 50000
+	descGoods := "Frozen Berries"
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(descGoods)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("setTelemetryThresholds"), []byte(tradeID), []byte("-18"), []byte("-25"), []byte("20"), []byte("60")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("setTelemetryThresholds"), []byte(tradeID), []byte("-25"), []byte("-18"), []byte("20"), []byte("160")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("setTelemetryThresholds"), []byte(tradeID), []byte("cold"), []byte("-18"), []byte("20"), []byte("60")})
+	checkInvoke(t, stub, [][]byte{[]byte("setTelemetryThresholds"), []byte(tradeID), []byte("-25"), []byte("-18"), []byte("20"), []byte("60")})
+	tradeAgreement := withTradeTermsHash(&TradeAgreement{amount, descGoods, 0, REQUESTED, 0, "", "", 0.0, 0, &TelemetryThresholds{-25, -18, 20, 60}, ""})
+	tradeAgreementBytes, _ := json.Marshal(tradeAgreement)
+	tradeKey, _ := stub.CreateCompositeKey("Trade", []string{tradeID})
+	checkState(t, stub, tradeKey, string(tradeAgreementBytes))
+
+	// Invoke 'acceptTrade'; the thresholds are then fixed
+	checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeID)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("setTelemetryThresholds"), []byte(tradeID), []byte("-30"), []byte("-10"), []byte("20"), []byte("60")})
+
+	// Invoke 'requestLC', 'issueLC', 'acceptLC', 'requestEL', 'issueEL', 'prepareShipment'
+	checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(tradeID)})
+	lcID := "lc8349"
+	lcExpirationDate := "12/31/2018"
+	doc1 := "E/L"
+	doc2 := "B/L"
+	checkInvoke(t, stub, [][]byte{[]byte("issueLC"), []byte(tradeID), []byte(lcID), []byte(lcExpirationDate), []byte(doc1), []byte(doc2)})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptLC"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestEL"), []byte(tradeID)})
+	elID := "el979"
+	elExpirationDate := "04/30/2019"
+	checkInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(tradeID), []byte(elID), []byte(elExpirationDate)})
+	deviceID := "reefer-0042"
+	checkBadInvoke(t, stub, [][]byte{[]byte("registerDevice"), []byte(tradeID), []byte(deviceID)})
+	stub.setTxTime(t, "2019-01-04T12:00:00Z")
+	checkInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(tradeID)})
+	stub.setTxTime(t, "2019-01-05T12:00:00Z")
+
+	// Invoke 'registerDevice'
+	readings := "[{\"timestamp\":\"2019-01-05T08:00:00Z\",\"temperature\":-21.5,\"humidity\":40}]"
+	checkBadInvoke(t, stub, [][]byte{[]byte("submitTelemetry"), []byte(tradeID), []byte(deviceID), []byte(readings)})
+	checkInvoke(t, stub, [][]byte{[]byte("registerDevice"), []byte(tradeID), []byte(deviceID)})
+	checkInvoke(t, stub, [][]byte{[]byte("registerDevice"), []byte(tradeID), []byte(deviceID)})
+
+	// Invoke bad 'submitTelemetry' and verify unchanged state
+	summaryKey, _ := stub.CreateCompositeKey("TelemetrySummary", []string{tradeID})
+	checkBadInvoke(t, stub, [][]byte{[]byte("submitTelemetry"), []byte(tradeID), []byte(deviceID), []byte("[]")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("submitTelemetry"), []byte(tradeID), []byte(deviceID), []byte("-21.5")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("submitTelemetry"), []byte(tradeID), []byte(deviceID), []byte("[{\"timestamp\":\"01/05/2019\",\"temperature\":-21.5,\"humidity\":40}]")})
+	checkNoState(t, stub, summaryKey)
+
+	// Readings must be taken after the shipment was prepared, and not after the transaction
+	checkBadInvoke(t, stub, [][]byte{[]byte("submitTelemetry"), []byte(tradeID), []byte(deviceID), []byte("[{\"timestamp\":\"2019-01-04T11:00:00Z\",\"temperature\":-21.5,\"humidity\":40}]")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("submitTelemetry"), []byte(tradeID), []byte(deviceID), []byte("[" + readings[1:len(readings)-1] + ",{\"timestamp\":\"2019-01-05T13:00:00Z\",\"temperature\":-21.5,\"humidity\":40}]")})
+	checkNoState(t, stub, summaryKey)
+
+	// Invoke 'submitTelemetry' within the thresholds, then with a temperature and a humidity excursion
+	checkInvoke(t, stub, [][]byte{[]byte("submitTelemetry"), []byte(tradeID), []byte(deviceID), []byte(readings)})
+	shipmentKey, _ := stub.CreateCompositeKey("Shipment", []string{"Tracking", tradeID})
+	shipment := &Shipment{tradeID, []ShipmentLeg{}, []ShipmentCheckpoint{}, nil, []string{deviceID}, nil, nil, nil}
+	shipmentBytes, _ := json.Marshal(shipment)
+	checkState(t, stub, shipmentKey, string(shipmentBytes))
+	readings = "[{\"timestamp\":\"2019-01-05T09:00:00Z\",\"temperature\":-16.5,\"humidity\":45}," +
+		"{\"timestamp\":\"2019-01-05T07:00:00Z\",\"temperature\":-22,\"humidity\":65.5}]"
+	checkInvoke(t, stub, [][]byte{[]byte("submitTelemetry"), []byte(tradeID), []byte(deviceID), []byte(readings)})
+	shipment.Incidents = []ShipmentIncident{
+		{TEMPERATURE_EXCURSION, deviceID, "2019-01-05T09:00:00Z", -16.5, -18},
+		{HUMIDITY_EXCURSION, deviceID, "2019-01-05T07:00:00Z", 65.5, 60},
+	}
+	shipmentBytes, _ = json.Marshal(shipment)
+	checkState(t, stub, shipmentKey, string(shipmentBytes))
+
+	// Check the raw readings and the summary
+	batchKey, _ := stub.CreateCompositeKey("TelemetryBatch", []string{tradeID, "1"})
+	batchBytes, _ := json.Marshal(&TelemetryBatch{"1", tradeID, deviceID, []TelemetryReading{{"2019-01-05T09:00:00Z", -16.5, 45}, {"2019-01-05T07:00:00Z", -22, 65.5}}})
+	checkState(t, stub, batchKey, string(batchBytes))
+	summary := &TelemetrySummary{tradeID, 2, 3, "2019-01-05T07:00:00Z", "2019-01-05T09:00:00Z", -22, -16.5, 40, 65.5, 2}
+	summaryBytes, _ := json.Marshal(summary)
+	checkState(t, stub, summaryKey, string(summaryBytes))
+	checkQuery(t, stub, "getTelemetrySummary", tradeID, string(summaryBytes))
+
+	// A resubmitted batch is ignored, and only the new readings of a batch that repeats some are recorded, under their device
+	checkInvoke(t, stub, [][]byte{[]byte("submitTelemetry"), []byte(tradeID), []byte(deviceID), []byte(readings)})
+	checkState(t, stub, summaryKey, string(summaryBytes))
+	checkState(t, stub, shipmentKey, string(shipmentBytes))
+	readings = "[{\"timestamp\":\"2019-01-05T09:00:00+00:00\",\"temperature\":-16.5,\"humidity\":45}," +
+		"{\"timestamp\":\"2019-01-05T09:30:00Z\",\"temperature\":-20,\"humidity\":45}," +
+		"{\"timestamp\":\"2019-01-05T09:30:00Z\",\"temperature\":-20,\"humidity\":45}]"
+	res := stub.MockInvoke("batchTx", [][]byte{[]byte("submitTelemetry"), []byte(tradeID), []byte(deviceID), []byte(readings)})
+	if res.Status != shim.OK {
+		fmt.Println("Invoke submitTelemetry failed", string(res.Message))
+		t.FailNow()
+	}
+	batchKey, _ = stub.CreateCompositeKey("TelemetryBatch", []string{tradeID, "batchTx"})
+	batchBytes, _ = json.Marshal(&TelemetryBatch{"batchTx", tradeID, deviceID, []TelemetryReading{{"2019-01-05T09:30:00Z", -20, 45}}})
+	checkState(t, stub, batchKey, string(batchBytes))
+	summary = &TelemetrySummary{tradeID, 3, 4, "2019-01-05T07:00:00Z", "2019-01-05T09:30:00Z", -22, -16.5, 40, 65.5, 2}
+	summaryBytes, _ = json.Marshal(summary)
+	checkState(t, stub, summaryKey, string(summaryBytes))
+	checkState(t, stub, shipmentKey, string(shipmentBytes))
+
+	// Only a device of the Carrier Org can submit readings, under its own identity
+	scc.testMode = false
+	readings = "[{\"timestamp\":\"2019-01-05T10:00:00Z\",\"temperature\":-20,\"humidity\":40}]"
+	stub.setCreator(t, "CarrierOrgMSP", "ca.carrierorg.trade.com", deviceID)
+	checkBadInvoke(t, stub, [][]byte{[]byte("submitTelemetry"), []byte(tradeID), []byte(deviceID), []byte(readings)})
+	stub.setCreatorWithAttributes(t, "CarrierOrgMSP", "ca.carrierorg.trade.com", "reefer-0043", map[string]string{"device": "true"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("submitTelemetry"), []byte(tradeID), []byte(deviceID), []byte(readings)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("submitTelemetry"), []byte(tradeID), []byte("reefer-0043"), []byte(readings)})
+	stub.setCreatorWithAttributes(t, "CarrierOrgMSP", "ca.carrierorg.trade.com", deviceID, map[string]string{"device": "true"})
+	checkInvoke(t, stub, [][]byte{[]byte("submitTelemetry"), []byte(tradeID), []byte(deviceID), []byte(readings)})
+	summary = &TelemetrySummary{tradeID, 4, 5, "2019-01-05T07:00:00Z", "2019-01-05T10:00:00Z", -22, -16.5, 40, 65.5, 2}
+	summaryBytes, _ = json.Marshal(summary)
+	checkState(t, stub, summaryKey, string(summaryBytes))
+}
+
+func TestTradeWorkflow_Containers(t *testing.T) {
+	scc := new(TradeWorkflowChaincode)
+	scc.testMode = true
+	stub := newExtendedMockStub("Trade Workflow", scc)
+
+	// Init
+	stub.setTransient(getInitTransient())
+	checkInit(t, stub, getInitArguments())
+
+	// Invoke 'requestTrade', 'acceptTrade', 'requestLC', 'issueLC', 'acceptLC', 'requestEL', 'issueEL'
+	tradeID := "2ks89j9"
+	amount := 50000
+	descGoods := "Wood for Toys"
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(descGoods)})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(tradeID)})
+	lcID := "lc8349"
+	lcExpirationDate := "12/31/2018"
+	doc1 := "E/L"
+	doc2 := "B/L"
+	checkInvoke(t, stub, [][]byte{[]byte("issueLC"), []byte(tradeID), []byte(lcID), []byte(lcExpirationDate), []byte(doc1), []byte(doc2)})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptLC"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestEL"), []byte(tradeID)})
+	elID := "el979"
+	elExpirationDate := "04/30/2019"
+	checkInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(tradeID), []byte(elID), []byte(elExpirationDate)})
+
+	// Invoke bad 'prepareShipment' and verify unchanged state
+	shipmentLocationKey, _ := stub.CreateCompositeKey("Shipment", []string{"Location", tradeID})
+	checkBadInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(tradeID), []byte("[{\"number\":\"CSQU3054384\",\"sealNumber\":\"SL-1001\",\"type\":\"22G1\",\"grossWeight\":18000}]")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(tradeID), []byte("[{\"number\":\"CSQU3054383\",\"sealNumber\":\"\",\"type\":\"22G1\",\"grossWeight\":18000}]")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(tradeID), []byte("[{\"number\":\"CSQU3054383\",\"sealNumber\":\"SL-1001\",\"type\":\"22G\",\"grossWeight\":18000}]")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(tradeID), []byte("[{\"number\":\"CSQU3054383\",\"sealNumber\":\"SL-1001\",\"type\":\"22G1\",\"grossWeight\":0}]")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(tradeID), []byte("[{\"number\":\"CSQU3054383\",\"sealNumber\":\"SL-1001\",\"type\":\"22G1\",\"grossWeight\":18000,\"packages\":[{\"type\":\"PALLET\",\"quantity\":0}]}]")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(tradeID), []byte("[{\"number\":\"CSQU3054383\",\"sealNumber\":\"SL-1001\",\"type\":\"22G1\",\"grossWeight\":18000},{\"number\":\"csqu 305438 3\",\"sealNumber\":\"SL-1002\",\"type\":\"22G1\",\"grossWeight\":18000}]")})
+	checkNoState(t, stub, shipmentLocationKey)
+
+	// Invoke 'prepareShipment' with two containers
+	containers := "[{\"number\":\"csqu 305438 3\",\"sealNumber\":\"SL-1001\",\"type\":\"22g1\",\"grossWeight\":18000,\"packages\":[{\"type\":\"PALLET\",\"quantity\":20}]}," +
+		"{\"number\":\"MSKU9070323\",\"sealNumber\":\"SL-1002\",\"type\":\"45G1\",\"grossWeight\":26500}]"
+	checkInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(tradeID), []byte(containers)})
+	checkState(t, stub, shipmentLocationKey, SOURCE)
+	shipmentKey, _ := stub.CreateCompositeKey("Shipment", []string{"Tracking", tradeID})
+	shipment := &Shipment{tradeID, []ShipmentLeg{}, []ShipmentCheckpoint{}, nil, nil, nil,
+		[]Container{{"CSQU3054383", "SL-1001", "22G1", 18000, []Package{{"PALLET", 20}}}, {"MSKU9070323", "SL-1002", "45G1", 26500, nil}}, nil}
+	shipmentBytes, _ := json.Marshal(shipment)
+	checkState(t, stub, shipmentKey, string(shipmentBytes))
+
+	// Invoke bad 'recordSealChange' and verify unchanged state
+	checkBadInvoke(t, stub, [][]byte{[]byte("recordSealChange"), []byte(tradeID), []byte("TGHU1234567"), []byte("SL-2002"), []byte("Customs inspection"), []byte("2019-01-05T08:00:00Z")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("recordSealChange"), []byte(tradeID), []byte("MSKU9070323"), []byte("SL-1002"), []byte("Customs inspection"), []byte("2019-01-05T08:00:00Z")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("recordSealChange"), []byte(tradeID), []byte("MSKU9070323"), []byte("SL-2002"), []byte(""), []byte("2019-01-05T08:00:00Z")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("recordSealChange"), []byte(tradeID), []byte("MSKU9070323"), []byte("SL-2002"), []byte("Customs inspection"), []byte("01/05/2019")})
+	checkState(t, stub, shipmentKey, string(shipmentBytes))
+
+	// Invoke 'recordSealChange' and check the event
+	checkInvoke(t, stub, [][]byte{[]byte("recordSealChange"), []byte(tradeID), []byte("MSKU9070323"), []byte("SL-2002"), []byte("Customs inspection"), []byte("2019-01-05T08:00:00Z")})
+	shipment.Containers[1].SealNumber = "SL-2002"
+	shipment.SealChanges = []SealChange{{"MSKU9070323", "SL-1002", "SL-2002", "Customs inspection", "2019-01-05T08:00:00Z"}}
+	shipmentBytes, _ = json.Marshal(shipment)
+	checkState(t, stub, shipmentKey, string(shipmentBytes))
+	eventBytes, _ := json.Marshal(&SealChangedEvent{tradeID, "MSKU9070323", "SL-1002", "SL-2002", "Customs inspection"})
+	checkEvent(t, stub, "SealChanged", string(eventBytes))
+
+	// Invoke 'acceptShipmentAndIssueBL' with missing, unknown or wrongly sealed containers and verify that no B/L is issued
+	blID := "bl06678"
+	blExpirationDate := "03/03/2019"
+	sourcePort := "Woodlands Port"
+	destinationPort := "Market Port"
+	blKey, _ := stub.CreateCompositeKey("BillOfLading", []string{tradeID})
+	blArgs := [][]byte{[]byte("acceptShipmentAndIssueBL"), []byte(tradeID), []byte(blID), []byte(blExpirationDate), []byte(sourcePort), []byte(destinationPort)}
+	checkBadInvoke(t, stub, blArgs)
+	checkBadInvoke(t, stub, append(blArgs, []byte("[{\"number\":\"CSQU3054383\",\"sealNumber\":\"SL-1001\"}]")))
+	checkBadInvoke(t, stub, append(blArgs, []byte("[{\"number\":\"CSQU3054383\",\"sealNumber\":\"SL-1001\"},{\"number\":\"MSKU9070323\",\"sealNumber\":\"SL-1002\"}]")))
+	checkBadInvoke(t, stub, append(blArgs, []byte("[{\"number\":\"CSQU3054383\",\"sealNumber\":\"SL-1001\"},{\"number\":\"TGHU1234567\",\"sealNumber\":\"SL-2002\"}]")))
+	checkBadInvoke(t, stub, append(blArgs, []byte("[{\"number\":\"CSQU3054383\",\"sealNumber\":\"SL-1001\"},{\"number\":\"CSQU3054383\",\"sealNumber\":\"SL-1001\"}]")))
+	checkNoState(t, stub, blKey)
+
+	// Invoke 'acceptShipmentAndIssueBL' with the prepared containers under their current seals
+	checkInvoke(t, stub, append(blArgs, []byte("[{\"number\":\"MSKU9070323\",\"sealNumber\":\"SL-2002\"},{\"number\":\"CSQU3054383\",\"sealNumber\":\"SL-1001\"}]")))
+	billOfLading := &BillOfLading{blID, blExpirationDate, EXPORTER, CARRIER, descGoods, 0, IMPBANK, sourcePort, destinationPort,
+		[]ContainerReference{{"MSKU9070323", "SL-2002"}, {"CSQU3054383", "SL-1001"}}, IMPBANK, "ImporterOrgMSP", "", HELD_BY_BANK, []Endorsement{}, "", ""}
+	billOfLadingBytes, _ := json.Marshal(billOfLading)
+	checkState(t, stub, blKey, string(billOfLadingBytes))
+}
+
+func TestTradeWorkflow_PartialShipments(t *testing.T) {
+	scc := new(TradeWorkflowChaincode)
+	scc.testMode = true
+	stub := newExtendedMockStub("Trade Workflow", scc)
+
+	// Init
+	stub.setTransient(getInitTransient())
+	checkInit(t, stub, getInitArguments())
+
+	// Invoke 'requestTrade' with a quantity of goods, and 'acceptTrade'
+	tradeID := "2ks89j9"
+	amount := 50000
+	quantity := 3
+	descGoods := "Wood for Toys"
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(descGoods), []byte("0")})
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(descGoods), []byte(strconv.Itoa(quantity))})
+	tradeAgreement := withTradeTermsHash(&TradeAgreement{amount, descGoods, quantity, REQUESTED, 0, "", "", 0.0, 0, nil, ""})
+	tradeAgreementBytes, _ := json.Marshal(tradeAgreement)
+	tradeKey, _ := stub.CreateCompositeKey("Trade", []string{tradeID})
+	checkState(t, stub, tradeKey, string(tradeAgreementBytes))
+	checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeID)})
+
+	// Invoke 'requestLC' allowing partial shipments, 'issueLC', 'acceptLC', 'requestEL', 'issueEL'
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(tradeID), []byte("PARTIAL")})
+	checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(tradeID), []byte(ALLOWED)})
+	letterOfCredit := withLetterOfCreditTermsHash(&LetterOfCredit{"", "", EXPORTER, amount, []DocumentReference{}, REQUESTED, true, nil, ""})
+	letterOfCreditBytes, _ := json.Marshal(letterOfCredit)
+	lcKey, _ := stub.CreateCompositeKey("LetterOfCredit", []string{tradeID})
+	checkState(t, stub, lcKey, string(letterOfCreditBytes))
+	lcID := "lc8349"
+	lcExpirationDate := "12/31/2018"
+	doc1 := "E/L"
+	doc2 := "B/L"
+	checkInvoke(t, stub, [][]byte{[]byte("issueLC"), []byte(tradeID), []byte(lcID), []byte(lcExpirationDate), []byte(doc1), []byte(doc2)})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptLC"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestEL"), []byte(tradeID)})
+	elID := "el979"
+	elExpirationDate := "04/30/2019"
+	checkInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(tradeID), []byte(elID), []byte(elExpirationDate)})
+
+	// Invoke bad 'preparePartialShipment' and verify unchanged state
+	partialShipmentsKey, _ := stub.CreateCompositeKey("Shipment", []string{"Partial", tradeID})
+	checkBadInvoke(t, stub, [][]byte{[]byte("preparePartialShipment"), []byte(tradeID), []byte("lot1"), []byte("0")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("preparePartialShipment"), []byte(tradeID), []byte("lot1"), []byte("4")})
+	checkNoState(t, stub, partialShipmentsKey)
+
+	// Invoke 'preparePartialShipment' for two lots; the trade can then no longer be shipped in full
+	checkInvoke(t, stub, [][]byte{[]byte("preparePartialShipment"), []byte(tradeID), []byte("lot1"), []byte("1")})
+	checkInvoke(t, stub, [][]byte{[]byte("preparePartialShipment"), []byte(tradeID), []byte("lot2"), []byte("2")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("preparePartialShipment"), []byte(tradeID), []byte("lot3"), []byte("1")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(tradeID)})
+	partialShipments := &PartialShipments{tradeID, []PartialShipment{{"lot1", 1, SOURCE, "", "", ""}, {"lot2", 2, SOURCE, "", "", ""}}}
+	partialShipmentsBytes, _ := json.Marshal(partialShipments)
+	checkState(t, stub, partialShipmentsKey, string(partialShipmentsBytes))
+
+	// Invoke 'acceptPartialShipmentAndIssueBL' for the first lot; payment cannot be requested before
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeID), []byte("lot1")})
+	blExpirationDate := "08/31/2018"
+	sourcePort := "Woodlands Port"
+	destinationPort := "Market Port"
+	checkBadInvoke(t, stub, [][]byte{[]byte("acceptPartialShipmentAndIssueBL"), []byte(tradeID), []byte("lot3"), []byte("bl06678"), []byte(blExpirationDate), []byte(sourcePort), []byte(destinationPort)})
+	stub.setCreator(t, "CarrierOrgMSP", "ca.carrierorg.trade.com", "Admin@carrierorg.trade.com")
+	billOfLading := &BillOfLading{"bl06678", blExpirationDate, EXPORTER, CARRIER, descGoods, 1, IMPBANK, sourcePort, destinationPort, nil, IMPBANK, "ImporterOrgMSP", "", HELD_BY_BANK, []Endorsement{}, "", ""}
+	blContent, _ := canonicalBillOfLading(billOfLading)
+	blSignature := stub.sign(t, blContent)
+	stub.setTransient(map[string]string{"signature": string(blSignature)})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptPartialShipmentAndIssueBL"), []byte(tradeID), []byte("lot1"), []byte("bl06678"), []byte(blExpirationDate), []byte(sourcePort), []byte(destinationPort)})
+	stub.setTransient(map[string]string{})
+	checkBadInvoke(t, stub, [][]byte{[]byte("acceptPartialShipmentAndIssueBL"), []byte(tradeID), []byte("lot1"), []byte("bl06679"), []byte(blExpirationDate), []byte(sourcePort), []byte(destinationPort)})
+	blKey1, _ := stub.CreateCompositeKey("BillOfLading", []string{tradeID, "lot1"})
+	billOfLading.Signature = base64.StdEncoding.EncodeToString(blSignature)
+	billOfLading.SignerCertificate = stub.creatorCert
+	billOfLadingBytes, _ := json.Marshal(billOfLading)
+	checkState(t, stub, blKey1, string(billOfLadingBytes))
+	checkQueryArgs(t, stub, [][]byte{[]byte("getBillOfLading"), []byte(tradeID), []byte("lot1")}, string(billOfLadingBytes))
+
+	// The signature on the first lot's B/L is verified with its Shipment ID; the trade has no B/L of its own
+	blHash := sha256.Sum256(blContent)
+	expectedResp := "{\"Verified\":\"true\",\"Signer\":\"CN=Admin@carrierorg.trade.com,OU=client\",\"Issuer\":\"ca.carrierorg.trade.com\"}"
+	checkQueryArgs(t, stub, [][]byte{[]byte("verifySignature"), []byte(tradeID), []byte("lot1"), []byte("BillOfLading"), []byte(hex.EncodeToString(blHash[:]))}, expectedResp)
+	checkBadInvoke(t, stub, [][]byte{[]byte("verifySignature"), []byte(tradeID), []byte("BillOfLading"), []byte(hex.EncodeToString(blHash[:]))})
+	checkBadInvoke(t, stub, [][]byte{[]byte("verifySignature"), []byte(tradeID), []byte("lot2"), []byte("BillOfLading"), []byte(hex.EncodeToString(blHash[:]))})
+
+	// Invoke 'uploadDocument' for the B/L of the first lot; it fulfils the L/C's B/L reference
+	hash := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	uri := "https://docs.carrier.com/bl06678.pdf"
+	checkBadInvoke(t, stub, [][]byte{[]byte("uploadDocument"), []byte(tradeID), []byte("bl-lot1"), []byte("BillOfLading"), []byte(doc2), []byte(hash), []byte("application/pdf"), []byte("1024"), []byte(uri)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("uploadDocument"), []byte(tradeID), []byte("lot2"), []byte("bl-lot1"), []byte("BillOfLading"), []byte(doc2), []byte(hash), []byte("application/pdf"), []byte("1024"), []byte(uri)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("uploadDocument"), []byte(tradeID), []byte("lot1"), []byte("bl-lot1"), []byte("Trade"), []byte(doc2), []byte(hash), []byte("application/pdf"), []byte("1024"), []byte(uri)})
+	checkInvoke(t, stub, [][]byte{[]byte("uploadDocument"), []byte(tradeID), []byte("lot1"), []byte("bl-lot1"), []byte("BillOfLading"), []byte(doc2), []byte(hash), []byte("application/pdf"), []byte("1024"), []byte(uri)})
+	document := &Document{"bl-lot1", tradeID, "lot1", "BillOfLading", doc2, hash, "application/pdf", 1024, uri, "", ""}
+	documentBytes, _ := json.Marshal(document)
+	checkQueryArgs(t, stub, [][]byte{[]byte("getDocument"), []byte(tradeID), []byte("bl-lot1")}, string(documentBytes))
+	letterOfCredit = withLetterOfCreditTermsHash(&LetterOfCredit{lcID, lcExpirationDate, EXPORTER, amount, []DocumentReference{{doc1, ""}, {doc2, "bl-lot1"}}, ACCEPTED, true, nil, ""})
+	letterOfCreditBytes, _ = json.Marshal(letterOfCredit)
+	checkState(t, stub, lcKey, string(letterOfCreditBytes))
+
+	// Invoke 'requestPayment' and 'makePayment' for the first lot at source; half of its share (1 of 3 units) is paid
+	share1 := amount / 3
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeID), []byte("lot1")})
+	paymentKey1, _ := stub.CreateCompositeKey("Payment", []string{tradeID, "lot1"})
+	checkState(t, stub, paymentKey1, REQUESTED)
+	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeID), []byte("01/01/2019"), []byte("lot1")})
+	checkNoState(t, stub, paymentKey1)
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeID), []byte("lot1")})
+	checkPrivateState(t, stub, accountBalancesCollection, expBalKey, strconv.Itoa(EXPBALANCE + share1 / 2))
+	checkPrivateState(t, stub, accountBalancesCollection, impBalKey, strconv.Itoa(IMPBALANCE - share1 / 2))
+
+	// Deliver the first lot and pay the rest of its share; its B/L is released to the importer
+	checkBadInvoke(t, stub, [][]byte{[]byte("updatePartialShipmentLocation"), []byte(tradeID), []byte("lot2"), []byte(DESTINATION), []byte("02/01/2019")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("updatePartialShipmentLocation"), []byte(tradeID), []byte("lot1"), []byte(SOURCE), []byte("02/01/2019")})
+	checkInvoke(t, stub, [][]byte{[]byte("updatePartialShipmentLocation"), []byte(tradeID), []byte("lot1"), []byte(DESTINATION), []byte("02/01/2019")})
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeID), []byte("lot1")})
+	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeID), []byte("03/01/2019"), []byte("lot1")})
+	checkPrivateState(t, stub, accountBalancesCollection, expBalKey, strconv.Itoa(EXPBALANCE + share1))
+	checkPrivateState(t, stub, accountBalancesCollection, impBalKey, strconv.Itoa(IMPBALANCE - share1))
+	billOfLading.Holder = IMPORTER
+	billOfLading.Status = RELEASED_TO_IMPORTER
+	billOfLading.Endorsements = []Endorsement{{IMPBANK, "ImporterOrgMSP", IMPORTER, "ImporterOrgMSP", ""}}
+	billOfLadingBytes, _ = json.Marshal(billOfLading)
+	checkState(t, stub, blKey1, string(billOfLadingBytes))
+	releasedEventBytes, _ := json.Marshal(&BillOfLadingReleasedEvent{tradeID, "bl06678", IMPORTER, "ImporterOrgMSP"})
+	checkEvent(t, stub, billOfLadingReleasedEvent, string(releasedEventBytes))
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeID), []byte("lot1")})
+
+	// Title to the first lot has passed to the importer, so what was paid for it can no longer be refunded
+	stub.setTransient(map[string]string{})
+	checkBadInvoke(t, stub, [][]byte{[]byte("refundPayment"), []byte(tradeID), []byte("1")})
+	checkPrivateState(t, stub, accountBalancesCollection, impBalKey, strconv.Itoa(IMPBALANCE - share1))
+
+	// Ship, deliver and pay for the second lot in one payment; the trade is then paid in full
+	checkInvoke(t, stub, [][]byte{[]byte("acceptPartialShipmentAndIssueBL"), []byte(tradeID), []byte("lot2"), []byte("bl06679"), []byte(blExpirationDate), []byte(sourcePort), []byte(destinationPort)})
+	checkInvoke(t, stub, [][]byte{[]byte("updatePartialShipmentLocation"), []byte(tradeID), []byte("lot2"), []byte(DESTINATION), []byte("02/15/2019")})
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeID), []byte("lot2")})
+	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeID), []byte("03/01/2019"), []byte("lot2")})
+	checkPrivateState(t, stub, accountBalancesCollection, expBalKey, strconv.Itoa(EXPBALANCE + amount))
+	checkPrivateState(t, stub, accountBalancesCollection, impBalKey, strconv.Itoa(IMPBALANCE - amount))
+	tradeAgreement = withTradeTermsHash(&TradeAgreement{amount, descGoods, quantity, ACCEPTED, amount, "", "", 0.0, 0, nil, ""})
+	tradeAgreementBytes, _ = json.Marshal(tradeAgreement)
+	checkState(t, stub, tradeKey, string(tradeAgreementBytes))
+	partialShipments = &PartialShipments{tradeID, []PartialShipment{{"lot1", 1, DESTINATION, "02/01/2019", "bl06678", PAID}, {"lot2", 2, DESTINATION, "02/15/2019", "bl06679", PAID}}}
+	partialShipmentsBytes, _ = json.Marshal(partialShipments)
+	checkQuery(t, stub, "getPartialShipments", tradeID, string(partialShipmentsBytes))
+
+	// Invoke 'surrenderBL' for the second lot, once Customs has cleared its goods
+	checkInvoke(t, stub, [][]byte{[]byte("setTariffRate"), []byte("4421"), []byte("0"), []byte("0")})
+	stub.setTransient(map[string]string{"values": "[20000]"})
+	checkInvoke(t, stub, [][]byte{[]byte("fileCustomsDeclaration"), []byte(tradeID), []byte("lot2"), []byte("[{\"hsCode\":\"4421.91\",\"description\":\"Wooden toy parts\",\"quantity\":2}]")})
+	checkInvoke(t, stub, [][]byte{[]byte("assessDuty"), []byte(tradeID), []byte("lot2")})
+	checkInvoke(t, stub, [][]byte{[]byte("surrenderBL"), []byte(tradeID), []byte("lot2")})
+	blKey2, _ := stub.CreateCompositeKey("BillOfLading", []string{tradeID, "lot2"})
+	billOfLading = &BillOfLading{"bl06679", blExpirationDate, EXPORTER, CARRIER, descGoods, 2, IMPBANK, sourcePort, destinationPort, nil, IMPORTER, "ImporterOrgMSP", "", SURRENDERED,
+		[]Endorsement{{IMPBANK, "ImporterOrgMSP", IMPORTER, "ImporterOrgMSP", ""}}, "", ""}
+	billOfLadingBytes, _ = json.Marshal(billOfLading)
+	checkState(t, stub, blKey2, string(billOfLadingBytes))
+
+	// A trade whose L/C does not allow partial shipments must be shipped in full
+	tradeID = "3lt90k0"
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(descGoods), []byte(strconv.Itoa(quantity))})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(tradeID), []byte(NOT_ALLOWED)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueLC"), []byte(tradeID), []byte(lcID), []byte(lcExpirationDate), []byte(doc1), []byte(doc2)})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptLC"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestEL"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(tradeID), []byte(elID), []byte(elExpirationDate)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("preparePartialShipment"), []byte(tradeID), []byte("lot1"), []byte("1")})
+	checkInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(tradeID)})
+}
+
+func TestTradeWorkflow_ExportLicenseConditions(t *testing.T) {
+	scc := new(TradeWorkflowChaincode)
+	scc.testMode = true
+	stub := newExtendedMockStub("Trade Workflow", scc)
+
+	// Init
+	stub.setTransient(getInitTransient())
+	checkInit(t, stub, getInitArguments())
+
+	// Invoke 'requestTrade', 'acceptTrade', 'requestLC', 'issueLC', 'acceptLC', 'requestEL' for two trades of 100 units
+	tradeID := "2ks89j9"
+	rejectedTradeID := "7hd62k1"
+	amount := 50000
+	descGoods := "Wood for Toys"
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	for _, id := range []string{tradeID, rejectedTradeID} {
+		checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(id), []byte(descGoods), []byte("100")})
+		checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(id)})
+		checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(id)})
+		checkInvoke(t, stub, [][]byte{[]byte("issueLC"), []byte(id), []byte("lc8349"), []byte("12/31/2018"), []byte("E/L"), []byte("B/L")})
+		checkInvoke(t, stub, [][]byte{[]byte("acceptLC"), []byte(id)})
+		checkInvoke(t, stub, [][]byte{[]byte("requestEL"), []byte(id)})
+	}
+
+	// Invoke bad 'rejectEL', then 'rejectEL', and verify that the E/L can no longer be issued
+	elKey, _ := stub.CreateCompositeKey("ExportLicense", []string{rejectedTradeID})
+	checkBadInvoke(t, stub, [][]byte{[]byte("rejectEL"), []byte(rejectedTradeID), []byte("")})
+	checkInvoke(t, stub, [][]byte{[]byte("rejectEL"), []byte(rejectedTradeID), []byte("Protected species")})
+	exportLicense := &ExportLicense{"", "", EXPORTER, CARRIER, descGoods, "", REGAUTH, REJECTED, nil, "Protected species", false, "", ""}
+	exportLicenseBytes, _ := json.Marshal(exportLicense)
+	checkState(t, stub, elKey, string(exportLicenseBytes))
+	checkQuery(t, stub, "getELStatus", rejectedTradeID, "{\"Status\":\"REJECTED\"}")
+	checkBadInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(rejectedTradeID), []byte("el979"), []byte("04/30/2019")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("revokeEL"), []byte(rejectedTradeID), []byte("Protected species")})
+	checkState(t, stub, elKey, string(exportLicenseBytes))
+
+	// Invoke bad 'issueEL' with invalid conditions and verify unchanged state
+	elID := "el979"
+	elExpirationDate := "04/30/2019"
+	elKey, _ = stub.CreateCompositeKey("ExportLicense", []string{tradeID})
+	checkBadInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(tradeID), []byte(elID), []byte("2019-04-30")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(tradeID), []byte(elID), []byte(elExpirationDate), []byte("[]")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(tradeID), []byte(elID), []byte(elExpirationDate), []byte("{\"validFrom\":\"05/01/2019\"}")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(tradeID), []byte(elID), []byte(elExpirationDate), []byte("{\"maxQuantity\":-1}")})
+	checkQuery(t, stub, "getELStatus", tradeID, "{\"Status\":\"REQUESTED\"}")
+
+	// Invoke 'issueEL' with conditions and verify state change
+	conditions := "{\"validFrom\":\"01/15/2019\",\"maxQuantity\":80,\"destinations\":[\"Market Port\"]}"
+	checkInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(tradeID), []byte(elID), []byte(elExpirationDate), []byte(conditions)})
+	exportLicense = &ExportLicense{elID, elExpirationDate, EXPORTER, CARRIER, descGoods, "", REGAUTH, ISSUED, &ExportLicenseConditions{"01/15/2019", 80, []string{"Market Port"}}, "", false, "", ""}
+	exportLicenseBytes, _ = json.Marshal(exportLicense)
+	checkState(t, stub, elKey, string(exportLicenseBytes))
+
+	// Invoke 'prepareShipment' before the E/L is valid, and for more goods than it allows
+	slKey, _ := stub.CreateCompositeKey("Shipment", []string{"Location", tradeID})
+	checkBadInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(tradeID)})
+	stub.setTxTime(t, "2019-02-01T00:00:00Z")
+	checkBadInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(tradeID)})
+	checkNoState(t, stub, slKey)
+
+	// Invoke 'preparePartialShipment' within the quantity allowed, and beyond it
+	checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(tradeID), []byte(ALLOWED)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueLC"), []byte(tradeID), []byte("lc8349"), []byte("12/31/2019"), []byte("E/L"), []byte("B/L")})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptLC"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("preparePartialShipment"), []byte(tradeID), []byte("s1"), []byte("60")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("preparePartialShipment"), []byte(tradeID), []byte("s2"), []byte("40")})
+	checkInvoke(t, stub, [][]byte{[]byte("preparePartialShipment"), []byte(tradeID), []byte("s2"), []byte("20")})
+
+	// Invoke 'acceptPartialShipmentAndIssueBL' to a destination not allowed by the E/L, then to an allowed one
+	blKey, _ := stub.CreateCompositeKey("BillOfLading", []string{tradeID, "s1"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("acceptPartialShipmentAndIssueBL"), []byte(tradeID), []byte("s1"), []byte("bl06678"), []byte("03/03/2019"), []byte("Woodlands Port"), []byte("Other Port")})
+	checkNoState(t, stub, blKey)
+	checkInvoke(t, stub, [][]byte{[]byte("acceptPartialShipmentAndIssueBL"), []byte(tradeID), []byte("s1"), []byte("bl06678"), []byte("03/03/2019"), []byte("Woodlands Port"), []byte("market port")})
+
+	// Invoke bad 'revokeEL', then 'revokeEL', and verify that no further B/L can be issued
+	checkBadInvoke(t, stub, [][]byte{[]byte("revokeEL"), []byte(tradeID), []byte("")})
+	checkInvoke(t, stub, [][]byte{[]byte("revokeEL"), []byte(tradeID), []byte("Sanctions imposed")})
+	exportLicense.Status = REVOKED
+	exportLicense.Reason = "Sanctions imposed"
+	exportLicenseBytes, _ = json.Marshal(exportLicense)
+	checkState(t, stub, elKey, string(exportLicenseBytes))
+	blKey, _ = stub.CreateCompositeKey("BillOfLading", []string{tradeID, "s2"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("acceptPartialShipmentAndIssueBL"), []byte(tradeID), []byte("s2"), []byte("bl06679"), []byte("03/03/2019"), []byte("Woodlands Port"), []byte("Market Port")})
+	checkNoState(t, stub, blKey)
+
+	// The E/L lapses the day after it expires
+	stub.setTxTime(t, "2019-05-01T00:00:00Z")
+	checkInvoke(t, stub, [][]byte{[]byte("requestEL"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(tradeID), []byte(elID), []byte(elExpirationDate)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("acceptPartialShipmentAndIssueBL"), []byte(tradeID), []byte("s2"), []byte("bl06679"), []byte("03/03/2019"), []byte("Woodlands Port"), []byte("Market Port")})
+	checkNoState(t, stub, blKey)
+}
+
+func TestTradeWorkflow_StandaloneExportLicense(t *testing.T) {
+	scc := new(TradeWorkflowChaincode)
+	scc.testMode = true
+	stub := newExtendedMockStub("Trade Workflow", scc)
+
+	// Init
+	stub.setTransient(getInitTransient())
+	checkInit(t, stub, getInitArguments())
+
+	// Invoke bad 'issueStandaloneEL' and verify that no license is recorded
+	licenseID := "sel100"
+	descGoods := "Wood for Toys"
+	elExpirationDate := "12/31/2019"
+	licenseKey, _ := stub.CreateCompositeKey("StandaloneExportLicense", []string{licenseID})
+	checkBadInvoke(t, stub, [][]byte{[]byte("issueStandaloneEL"), []byte(licenseID), []byte(descGoods), []byte("2019-12-31"), []byte("150"), []byte("80000")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("issueStandaloneEL"), []byte(licenseID), []byte(descGoods), []byte(elExpirationDate), []byte("0"), []byte("80000")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("issueStandaloneEL"), []byte(licenseID), []byte(descGoods), []byte(elExpirationDate), []byte("150"), []byte("lots")})
+	checkNoState(t, stub, licenseKey)
+
+	// Invoke 'issueStandaloneEL' for 150 units worth 80000, and verify that it cannot be issued twice
+	stub.setCreator(t, "RegulatorOrgMSP", "ca.regulatororg.trade.com", "Admin@regulatororg.trade.com")
+	license := &StandaloneExportLicense{licenseID, elExpirationDate, EXPORTER, descGoods, REGAUTH, ISSUED, nil, "", 150, 80000, 0, 0, []QuotaDrawdown{}, "", "", ""}
+	licenseContent, _ := canonicalStandaloneExportLicense(license)
+	licenseSignature := stub.sign(t, licenseContent)
+	stub.setTransient(map[string]string{"signature": string(licenseSignature)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueStandaloneEL"), []byte(licenseID), []byte(descGoods), []byte(elExpirationDate), []byte("150"), []byte("80000")})
+	license.Signature = base64.StdEncoding.EncodeToString(licenseSignature)
+	license.SignerCertificate = stub.creatorCert
+	licenseBytes, _ := json.Marshal(withStandaloneExportLicenseTermsHash(license))
+	checkState(t, stub, licenseKey, string(licenseBytes))
+	checkPrivateState(t, stub, exportQuotaCollection, licenseKey, "{\"valueDrawn\":0,\"drawdowns\":[]}")
+	checkBadInvoke(t, stub, [][]byte{[]byte("issueStandaloneEL"), []byte(licenseID), []byte(descGoods), []byte(elExpirationDate), []byte("300"), []byte("80000")})
+	checkQuery(t, stub, "getStandaloneEL", licenseID, withPrivateTerms(licenseBytes, getStandaloneExportLicenseTermsBytes(license)))
+
+	// Invoke 'requestTrade', 'acceptTrade', 'requestLC', 'issueLC', 'acceptLC' for four trades
+	tradeA := "2ks89j9"
+	tradeB := "7hd62k1"
+	tradeC := "9fj27d4"
+	tradeD := "3mc81x5"
+	trades := []struct {
+		id, goods, quantity, amount, partialShipments string
+	}{
+		{tradeA, descGoods, "100", "50000", NOT_ALLOWED},
+		{tradeB, descGoods, "60", "30000", ALLOWED},
+		{tradeC, descGoods, "5", "20000", NOT_ALLOWED},
+		{tradeD, "Steel Beams", "10", "10000", NOT_ALLOWED},
+	}
+	for _, trade := range trades {
+		stub.setTransient(map[string]string{"amount": trade.amount})
+		checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(trade.id), []byte(trade.goods), []byte(trade.quantity)})
+		checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(trade.id)})
+		checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(trade.id), []byte(trade.partialShipments)})
+		checkInvoke(t, stub, [][]byte{[]byte("issueLC"), []byte(trade.id), []byte("lc8349"), []byte("12/31/2019"), []byte("E/L"), []byte("B/L")})
+		checkInvoke(t, stub, [][]byte{[]byte("acceptLC"), []byte(trade.id)})
+	}
+
+	// Invoke bad 'attachEL': unknown license, goods not covered, and a trade with its own E/L requested
+	checkBadInvoke(t, stub, [][]byte{[]byte("attachEL"), []byte(tradeA), []byte("sel999")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("attachEL"), []byte(tradeD), []byte(licenseID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestEL"), []byte(tradeC)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("attachEL"), []byte(tradeC), []byte(licenseID)})
+
+	// Invoke 'attachEL' and verify the trade's E/L
+	checkInvoke(t, stub, [][]byte{[]byte("attachEL"), []byte(tradeA), []byte(licenseID)})
+	elKey, _ := stub.CreateCompositeKey("ExportLicense", []string{tradeA})
+	exportLicense := &ExportLicense{licenseID, elExpirationDate, EXPORTER, CARRIER, descGoods, "", REGAUTH, ISSUED, nil, "", true, "", ""}
+	exportLicenseBytes, _ := json.Marshal(exportLicense)
+	checkState(t, stub, elKey, string(exportLicenseBytes))
+
+	// Invoke 'prepareShipment' and verify the drawdown, which is not repeated if the shipment is prepared again
+	checkInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(tradeA)})
+	license.QuantityDrawn = 100
+	license.ValueDrawn = 50000
+	license.Drawdowns = []QuotaDrawdown{{tradeA, "", 100, 50000, "2019-01-01T00:00:00Z"}}
+	licenseBytes, _ = json.Marshal(withStandaloneExportLicenseTermsHash(license))
+	checkState(t, stub, licenseKey, string(licenseBytes))
+	checkPrivateState(t, stub, exportQuotaCollection, licenseKey, "{\"valueDrawn\":50000,\"drawdowns\":[{\"tradeId\":\"" + tradeA + "\",\"value\":50000}]}")
+	checkInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(tradeA)})
+	checkState(t, stub, licenseKey, string(licenseBytes))
+
+	// The signature on the standalone E/L is verified with its License ID, through a trade that ships under it; drawdowns leave it valid
+	licenseHash := sha256.Sum256(licenseContent)
+	expectedResp := "{\"Verified\":\"true\",\"Signer\":\"CN=Admin@regulatororg.trade.com,OU=client\",\"Issuer\":\"ca.regulatororg.trade.com\"}"
+	checkQueryArgs(t, stub, [][]byte{[]byte("verifySignature"), []byte(tradeA), []byte(licenseID), []byte("ExportLicense"), []byte(hex.EncodeToString(licenseHash[:]))}, expectedResp)
+	checkBadInvoke(t, stub, [][]byte{[]byte("verifySignature"), []byte(tradeD), []byte(licenseID), []byte("ExportLicense"), []byte(hex.EncodeToString(licenseHash[:]))})
+	checkBadInvoke(t, stub, [][]byte{[]byte("verifySignature"), []byte(tradeA), []byte(licenseID), []byte("LetterOfCredit"), []byte(hex.EncodeToString(licenseHash[:]))})
+
+	// Invoke 'preparePartialShipment' within the remaining quota, then beyond its quantity
+	checkInvoke(t, stub, [][]byte{[]byte("attachEL"), []byte(tradeB), []byte(licenseID)})
+	checkInvoke(t, stub, [][]byte{[]byte("preparePartialShipment"), []byte(tradeB), []byte("s1"), []byte("40")})
+	license.QuantityDrawn = 140
+	license.ValueDrawn = 70000
+	license.Drawdowns = append(license.Drawdowns, QuotaDrawdown{tradeB, "s1", 40, 20000, "2019-01-01T00:00:00Z"})
+	licenseBytes, _ = json.Marshal(withStandaloneExportLicenseTermsHash(license))
+	checkState(t, stub, licenseKey, string(licenseBytes))
+	checkPrivateState(t, stub, exportQuotaCollection, licenseKey, string(getStandaloneExportLicenseTermsBytes(license)))
+	checkQuery(t, stub, "getStandaloneEL", licenseID, withPrivateTerms(licenseBytes, getStandaloneExportLicenseTermsBytes(license)))
+	checkBadInvoke(t, stub, [][]byte{[]byte("preparePartialShipment"), []byte(tradeB), []byte("s2"), []byte("20")})
+	checkState(t, stub, licenseKey, string(licenseBytes))
+
+	// Invoke 'prepareShipment' beyond the remaining value, once the trade's own E/L is rejected in favour of the standalone one
+	checkInvoke(t, stub, [][]byte{[]byte("rejectEL"), []byte(tradeC), []byte("Covered by standalone license")})
+	checkInvoke(t, stub, [][]byte{[]byte("attachEL"), []byte(tradeC), []byte(licenseID)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(tradeC)})
+	slKey, _ := stub.CreateCompositeKey("Shipment", []string{"Location", tradeC})
+	checkNoState(t, stub, slKey)
+	checkState(t, stub, licenseKey, string(licenseBytes))
+
+	// Invoke bad 'revokeStandaloneEL', then 'revokeStandaloneEL', and verify that no B/L can be issued under it
+	checkBadInvoke(t, stub, [][]byte{[]byte("revokeStandaloneEL"), []byte(licenseID), []byte("")})
+	checkInvoke(t, stub, [][]byte{[]byte("revokeStandaloneEL"), []byte(licenseID), []byte("Quota withdrawn")})
+	checkQuery(t, stub, "getELStatus", tradeA, "{\"Status\":\"REVOKED\"}")
+	blKey, _ := stub.CreateCompositeKey("BillOfLading", []string{tradeA})
+	checkBadInvoke(t, stub, [][]byte{[]byte("acceptShipmentAndIssueBL"), []byte(tradeA), []byte("bl06678"), []byte("03/03/2019"), []byte("Woodlands Port"), []byte("Market Port")})
+	checkNoState(t, stub, blKey)
+	checkBadInvoke(t, stub, [][]byte{[]byte("attachEL"), []byte(tradeD), []byte(licenseID)})
+}
+
+func TestTradeWorkflow_CustomsClearance(t *testing.T) {
+	scc := new(TradeWorkflowChaincode)
+	scc.testMode = true
+	stub := newExtendedMockStub("Trade Workflow", scc)
+
+	// Init
+	stub.setTransient(getInitTransient())
+	checkInit(t, stub, getInitArguments())
+
+	// Invoke 'requestTrade', 'acceptTrade', 'requestLC', 'issueLC', 'acceptLC', 'requestEL', 'issueEL', 'prepareShipment', 'acceptShipmentAndIssueBL'
+	tradeID := "2ks89j9"
+	amount := 50000
+	descGoods := "Wood for Toys"
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(descGoods), []byte("100")})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueLC"), []byte(tradeID), []byte("lc8349"), []byte("12/31/2019"), []byte("E/L"), []byte("B/L")})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptLC"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestEL"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(tradeID), []byte("el979"), []byte("04/30/2019")})
+	checkInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(tradeID)})
+	blID := "bl06678"
+	sourcePort := "Woodlands Port"
+	destinationPort := "Market Port"
+	checkInvoke(t, stub, [][]byte{[]byte("acceptShipmentAndIssueBL"), []byte(tradeID), []byte(blID), []byte("03/03/2019"), []byte(sourcePort), []byte(destinationPort)})
+
+	// Invoke bad 'setTariffRate' and verify that no rate is recorded
+	tariffKey, _ := stub.CreateCompositeKey("Tariff", []string{"4421"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("setTariffRate"), []byte("44"), []byte("0.05"), []byte("0.1")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("setTariffRate"), []byte("4421"), []byte("-0.05"), []byte("0.1")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("setTariffRate"), []byte("4421"), []byte("5%"), []byte("0.1")})
+	checkNoState(t, stub, tariffKey)
+
+	// Invoke 'setTariffRate' for a heading and for one of its subheadings; the most specific rate applies
+	checkInvoke(t, stub, [][]byte{[]byte("setTariffRate"), []byte("4421"), []byte("0.05"), []byte("0.1")})
+	checkInvoke(t, stub, [][]byte{[]byte("setTariffRate"), []byte("4421.91"), []byte("0.02"), []byte("0.1")})
+	tariffBytes, _ := json.Marshal(&TariffRate{"442191", 0.02, 0.1})
+	checkQuery(t, stub, "getTariff", "4421.91.10", string(tariffBytes))
+	tariffBytes, _ = json.Marshal(&TariffRate{"4421", 0.05, 0.1})
+	checkQuery(t, stub, "getTariff", "442199", string(tariffBytes))
+	checkBadQuery(t, stub, "getTariff", "9503.00")
+
+	// Invoke bad 'fileCustomsDeclaration' and verify that no declaration is recorded
+	declarationKey, _ := stub.CreateCompositeKey("CustomsDeclaration", []string{tradeID})
+	checkBadInvoke(t, stub, [][]byte{[]byte("assessDuty"), []byte(tradeID)})
+	stub.setTransient(map[string]string{"values": "[50000]"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("fileCustomsDeclaration"), []byte(tradeID), []byte("{}")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("fileCustomsDeclaration"), []byte(tradeID), []byte("[{\"hsCode\":\"4421\",\"description\":\"Toy parts\",\"quantity\":100}]")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("fileCustomsDeclaration"), []byte(tradeID), []byte("[{\"hsCode\":\"4421.91\",\"description\":\"Toy parts\",\"quantity\":90}]")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("fileCustomsDeclaration"), []byte("abcd"), []byte("[{\"hsCode\":\"4421.91\",\"description\":\"Toy parts\",\"quantity\":100}]")})
+	stub.setTransient(map[string]string{"values": "[-1]"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("fileCustomsDeclaration"), []byte(tradeID), []byte("[{\"hsCode\":\"4421.91\",\"description\":\"Toy parts\",\"quantity\":100}]")})
+	stub.setTransient(map[string]string{"values": "[30000,20000]"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("fileCustomsDeclaration"), []byte(tradeID), []byte("[{\"hsCode\":\"4421.91\",\"description\":\"Toy parts\",\"quantity\":100}]")})
+	stub.setTransient(map[string]string{})
+	checkBadInvoke(t, stub, [][]byte{[]byte("fileCustomsDeclaration"), []byte(tradeID), []byte("[{\"hsCode\":\"4421.91\",\"description\":\"Toy parts\",\"quantity\":100}]")})
+	checkNoState(t, stub, declarationKey)
+
+	// Invoke 'fileCustomsDeclaration' with a line that has no tariff rate; assessment fails until the declaration is amended
+	stub.setTransient(map[string]string{"values": "[50000]"})
+	checkInvoke(t, stub, [][]byte{[]byte("fileCustomsDeclaration"), []byte(tradeID), []byte("[{\"hsCode\":\"9503.00\",\"description\":\"Toys\",\"quantity\":100}]")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("assessDuty"), []byte(tradeID)})
+	lineItems := "[{\"hsCode\":\"4421.91\",\"description\":\"Toy parts\",\"quantity\":60},{\"hsCode\":\"4421.99\",\"description\":\"Toy frames\",\"quantity\":40}]"
+	stub.setTransient(map[string]string{"values": "[30000,20000]"})
+	checkInvoke(t, stub, [][]byte{[]byte("fileCustomsDeclaration"), []byte(tradeID), []byte(lineItems)})
+	declaration := &CustomsDeclaration{tradeID, "", blID, IMPORTER, descGoods, sourcePort, destinationPort,
+		[]CustomsLineItem{{"442191", "Toy parts", 60, 30000, 0, 0, 0, 0}, {"442199", "Toy frames", 40, 20000, 0, 0, 0, 0}}, 50000, 0, 0, FILED, ""}
+	declarationBytes, _ := json.Marshal(withCustomsDeclarationTermsHash(declaration))
+	checkState(t, stub, declarationKey, string(declarationBytes))
+	checkPrivateState(t, stub, customsCollection, declarationKey, "{\"lineItems\":[{\"value\":30000},{\"value\":20000}],\"customsValue\":50000,\"duty\":0,\"tax\":0}")
+	checkNoPrivateState(t, stub, tradeTermsCollection, declarationKey)
+	checkBadInvoke(t, stub, [][]byte{[]byte("payDuty"), []byte(tradeID)})
+
+	// Invoke 'assessDuty' and verify duty and tax on each line; tax is levied on the value plus duty
+	checkInvoke(t, stub, [][]byte{[]byte("assessDuty"), []byte(tradeID)})
+	declaration.LineItems = []CustomsLineItem{{"442191", "Toy parts", 60, 30000, 0.02, 0.1, 600, 3060}, {"442199", "Toy frames", 40, 20000, 0.05, 0.1, 1000, 2100}}
+	declaration.Duty = 1600
+	declaration.Tax = 5160
+	declaration.Status = ASSESSED
+	declarationBytes, _ = json.Marshal(withCustomsDeclarationTermsHash(declaration))
+	checkState(t, stub, declarationKey, string(declarationBytes))
+	checkPrivateState(t, stub, customsCollection, declarationKey, string(getCustomsDeclarationTermsBytes(declaration)))
+	checkBadInvoke(t, stub, [][]byte{[]byte("assessDuty"), []byte(tradeID)})
+
+	// Pay in full and deliver; the B/L cannot be surrendered before the goods are cleared
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeID), []byte("01/01/2019")})
+	checkInvoke(t, stub, [][]byte{[]byte("updateShipmentLocation"), []byte(tradeID), []byte(DESTINATION), []byte("02/01/2019")})
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeID), []byte("03/01/2019")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("surrenderBL"), []byte(tradeID)})
+
+	// Invoke 'payDuty' and verify that the duty is paid from the importer's account into Customs revenue, and the goods cleared
+	checkInvoke(t, stub, [][]byte{[]byte("payDuty"), []byte(tradeID)})
+	checkPrivateState(t, stub, accountBalancesCollection, impBalKey, strconv.Itoa(IMPBALANCE - amount - 6760))
+	checkPrivateState(t, stub, customsCollection, cusBalKey, "6760")
+	checkNoPrivateState(t, stub, accountBalancesCollection, cusBalKey)
+	checkQueryArgs(t, stub, [][]byte{[]byte("getAccountBalance"), []byte(tradeID), []byte("customs")}, "{\"Balance\":\"6760\"}")
+	paymentKey, _ := stub.CreateCompositeKey("PaymentRecord", []string{tradeID, "3"})
+	dutyRecord := PaymentRecord{"3", tradeID, "", DUTY, "payDuty", IMPORTER, cusKey, 6760, 0, 0, "2019-01-01T00:00:00Z", "1", ""}
+	paymentBytes, _ := json.Marshal(&dutyRecord)
+	checkPrivateState(t, stub, tradeTermsCollection, paymentKey, string(paymentBytes))
+	checkPrivateState(t, stub, customsCollection, paymentKey, string(paymentBytes))
+	paymentKey, _ = stub.CreateCompositeKey("PaymentRecord", []string{tradeID, "2"})
+	checkNoPrivateState(t, stub, customsCollection, paymentKey)
+	statementBytes, _ := json.Marshal(&Statement{cusKey, "01/01/2019", "01/31/2019", []PaymentRecord{dutyRecord}, 6760, 0, 6760})
+	checkQueryArgs(t, stub, [][]byte{[]byte("getStatement"), []byte("customs"), []byte("01/01/2019"), []byte("01/31/2019")}, string(statementBytes))
+	declaration.Status = CLEARED
+	declarationBytes, _ = json.Marshal(declaration)
+	checkState(t, stub, declarationKey, string(declarationBytes))
+	checkQuery(t, stub, "getCustomsDeclaration", tradeID, withPrivateTerms(declarationBytes, getCustomsDeclarationTermsBytes(declaration)))
+	checkBadInvoke(t, stub, [][]byte{[]byte("payDuty"), []byte(tradeID)})
+	stub.setTransient(map[string]string{"values": "[30000,20000]"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("fileCustomsDeclaration"), []byte(tradeID), []byte(lineItems)})
+
+	// Invoke 'surrenderBL'
+	checkInvoke(t, stub, [][]byte{[]byte("surrenderBL"), []byte(tradeID)})
+
+	// Only a Regulator Org member enrolled as a customs officer can set tariffs
+	scc.testMode = false
+	stub.setCreator(t, "RegulatorOrgMSP", "ca.regulatororg.trade.com", "User1@regulatororg.trade.com")
+	checkBadInvoke(t, stub, [][]byte{[]byte("setTariffRate"), []byte("4421.91"), []byte("0.05"), []byte("0.1")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("getAccountBalance"), []byte(tradeID), []byte("customs")})
+	stub.setCreatorWithAttributes(t, "RegulatorOrgMSP", "ca.regulatororg.trade.com", "Officer@regulatororg.trade.com", map[string]string{"customs": "true"})
+	checkInvoke(t, stub, [][]byte{[]byte("setTariffRate"), []byte("4421.91"), []byte("0.05"), []byte("0.1")})
+	checkQueryArgs(t, stub, [][]byte{[]byte("getCustomsDeclaration"), []byte(tradeID)}, withPrivateTerms(declarationBytes, getCustomsDeclarationTermsBytes(declaration)))
+}
+
+func TestTradeWorkflow_SanctionsScreening(t *testing.T) {
+	scc := new(TradeWorkflowChaincode)
+	scc.testMode = true
+	stub := newExtendedMockStub("Trade Workflow", scc)
+
+	// Init
+	stub.setTransient(getInitTransient())
+	checkInit(t, stub, getInitArguments())
+
+	// Invoke bad 'addScreeningEntry' and verify that nothing is listed
+	checkBadInvoke(t, stub, [][]byte{[]byte("addScreeningEntry"), []byte("NAME"), []byte("Wooden Toys"), []byte(BLOCK)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("addScreeningEntry"), []byte(PARTY), []byte("Wooden Toys"), []byte("WARN")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("addScreeningEntry"), []byte(PARTY), []byte("The Co., Ltd."), []byte(BLOCK)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("addScreeningEntry"), []byte(COUNTRY), []byte("Korea"), []byte(BLOCK)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("addScreeningEntry"), []byte(HS_CODE), []byte("44a"), []byte(HOLD)})
+	checkNoState(t, stub, screeningListKey)
+
+	// Invoke 'addScreeningEntry' to block the importer under another spelling, and verify that the trade cannot be requested
+	tradeID := "2ks89j9"
+	amount := 50000
+	descGoods := "Wood for Toys"
+	tradeKey, _ := stub.CreateCompositeKey("Trade", []string{tradeID})
+	checkInvoke(t, stub, [][]byte{[]byte("addScreeningEntry"), []byte(PARTY), []byte("Wooden Toys Ltd."), []byte(BLOCK)})
+	screeningListBytes, _ := json.Marshal(&ScreeningList{[]ScreeningEntry{{PARTY, "Wooden Toys Ltd.", BLOCK}}})
+	checkState(t, stub, screeningListKey, string(screeningListBytes))
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(descGoods)})
+	checkNoState(t, stub, tradeKey)
+
+	// Invoke 'removeScreeningEntry' under yet another spelling, then list the exporter's bank for review
+	checkBadInvoke(t, stub, [][]byte{[]byte("removeScreeningEntry"), []byte(PARTY), []byte("Toy Bank")})
+	checkInvoke(t, stub, [][]byte{[]byte("removeScreeningEntry"), []byte(PARTY), []byte("WOODEN TOYS LIMITED")})
+	checkInvoke(t, stub, [][]byte{[]byte("addScreeningEntry"), []byte(PARTY), []byte("Lumber Bank Corp"), []byte(HOLD)})
+	screeningListBytes, _ = json.Marshal(&ScreeningList{[]ScreeningEntry{{PARTY, "Lumber Bank Corp", HOLD}}})
+	checkState(t, stub, screeningListKey, string(screeningListBytes))
+
+	// Invoke 'requestTrade' and verify that the trade is recorded, but on compliance hold
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(descGoods)})
+	holdKey, _ := stub.CreateCompositeKey("ComplianceHold", []string{tradeID})
+	complianceHold := &ComplianceHold{tradeID, COMPLIANCE_HOLD, []ScreeningMatch{{PARTY, "Lumber Bank Corp", EXPBANK, "requestTrade"}}, ""}
+	complianceHoldBytes, _ := json.Marshal(complianceHold)
+	checkState(t, stub, holdKey, string(complianceHoldBytes))
+	checkQuery(t, stub, "getComplianceHold", tradeID, string(complianceHoldBytes))
+	checkQuery(t, stub, "getTradeStatus", tradeID, "{\"Status\":\"COMPLIANCE_HOLD\"}")
+	checkBadInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeID)})
+
+	// Invoke bad 'clearComplianceHold', then 'clearComplianceHold', and verify that the trade can proceed
+	checkBadInvoke(t, stub, [][]byte{[]byte("clearComplianceHold"), []byte(tradeID), []byte("")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("clearComplianceHold"), []byte("abcd"), []byte("False positive")})
+	checkInvoke(t, stub, [][]byte{[]byte("clearComplianceHold"), []byte(tradeID), []byte("Different legal entity")})
+	complianceHold.Status = CLEARED
+	complianceHold.Reason = "Different legal entity"
+	complianceHoldBytes, _ = json.Marshal(complianceHold)
+	checkState(t, stub, holdKey, string(complianceHoldBytes))
+	checkBadInvoke(t, stub, [][]byte{[]byte("clearComplianceHold"), []byte(tradeID), []byte("Different legal entity")})
+	checkQuery(t, stub, "getTradeStatus", tradeID, "{\"Status\":\"REQUESTED\"}")
+
+	// A cleared match does not hold the trade again
+	checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueLC"), []byte(tradeID), []byte("lc8349"), []byte("12/31/2019"), []byte("E/L"), []byte("B/L")})
+	checkState(t, stub, holdKey, string(complianceHoldBytes))
+	checkInvoke(t, stub, [][]byte{[]byte("acceptLC"), []byte(tradeID)})
+
+	// Invoke 'requestEL' for goods under a listed HS heading, and verify the hold
+	checkInvoke(t, stub, [][]byte{[]byte("addScreeningEntry"), []byte(HS_CODE), []byte("44.03"), []byte(HOLD)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestEL"), []byte(tradeID), []byte("4403")})
+	checkInvoke(t, stub, [][]byte{[]byte("requestEL"), []byte(tradeID), []byte("4403.11")})
+	elKey, _ := stub.CreateCompositeKey("ExportLicense", []string{tradeID})
+	exportLicenseBytes, _ := json.Marshal(&ExportLicense{"", "", EXPORTER, CARRIER, descGoods, "440311", REGAUTH, REQUESTED, nil, "", false, "", ""})
+	checkState(t, stub, elKey, string(exportLicenseBytes))
+	complianceHold.Matches = append(complianceHold.Matches, ScreeningMatch{HS_CODE, "4403", "440311", "requestEL"})
+	complianceHold.Status = COMPLIANCE_HOLD
+	complianceHold.Reason = ""
+	complianceHoldBytes, _ = json.Marshal(complianceHold)
+	checkState(t, stub, holdKey, string(complianceHoldBytes))
+	checkBadInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(tradeID), []byte("el979"), []byte("04/30/2019")})
+	checkInvoke(t, stub, [][]byte{[]byte("clearComplianceHold"), []byte(tradeID), []byte("Plantation timber")})
+	checkInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(tradeID), []byte("el979"), []byte("04/30/2019")})
+	checkInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(tradeID)})
+
+	// Invoke 'acceptShipmentAndIssueBL' to a port in a blocked country, and verify that no B/L is issued
+	blKey, _ := stub.CreateCompositeKey("BillOfLading", []string{tradeID})
+	checkInvoke(t, stub, [][]byte{[]byte("addScreeningEntry"), []byte(COUNTRY), []byte("kp"), []byte(BLOCK)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("acceptShipmentAndIssueBL"), []byte(tradeID), []byte("bl06678"), []byte("03/03/2019"), []byte("Woodlands Port"), []byte("KP NAM")})
+	checkNoState(t, stub, blKey)
+
+	// Invoke 'acceptShipmentAndIssueBL' to a misspelt listed port, and verify that the B/L is issued and the trade held
+	checkInvoke(t, stub, [][]byte{[]byte("addScreeningEntry"), []byte(PORT), []byte("Market Port"), []byte(HOLD)})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptShipmentAndIssueBL"), []byte(tradeID), []byte("bl06678"), []byte("03/03/2019"), []byte("Woodlands Port"), []byte("Markett Port")})
+	complianceHold.Matches = append(complianceHold.Matches, ScreeningMatch{PORT, "Market Port", "Markett Port", "acceptShipmentAndIssueBL"})
+	complianceHold.Status = COMPLIANCE_HOLD
+	complianceHold.Reason = ""
+	complianceHoldBytes, _ = json.Marshal(complianceHold)
+	checkState(t, stub, holdKey, string(complianceHoldBytes))
+	checkQuery(t, stub, "getTradeStatus", tradeID, "{\"Status\":\"COMPLIANCE_HOLD\"}")
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeID)})
+}
+
+func TestTradeWorkflow_AMLMonitoring(t *testing.T) {
+	scc := new(TradeWorkflowChaincode)
+	scc.testMode = true
+	stub := newExtendedMockStub("Trade Workflow", scc)
+
+	// Init
+	stub.setTransient(getInitTransient())
+	checkInit(t, stub, getInitArguments())
+
+	// Invoke bad 'setAMLRules' and verify that no rules are recorded
+	checkBadInvoke(t, stub, [][]byte{[]byte("setAMLRules"), []byte("[]")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("setAMLRules"), []byte("{\"paymentThreshold\":-1}")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("setAMLRules"), []byte("{\"tradeCountThreshold\":2}")})
+	checkNoState(t, stub, amlRulesKey)
+
+	// Invoke 'setAMLRules'
+	rules := "{\"paymentThreshold\":40000,\"tradeCountThreshold\":2,\"tradeCountWindowDays\":30,\"transferWindowDays\":10}"
+	checkInvoke(t, stub, [][]byte{[]byte("setAMLRules"), []byte(rules)})
+	checkState(t, stub, amlRulesKey, rules)
+
+	// Take three trades through to the issuance of their B/Ls
+	tradeA := "2ks89j9"
+	tradeB := "7hd62k1"
+	tradeC := "9fj27d4"
+	amounts := map[string]int{tradeA: 50000, tradeB: 100000, tradeC: 50000}
+	for _, id := range []string{tradeA, tradeB, tradeC} {
+		stub.setTransient(map[string]string{"amount": strconv.Itoa(amounts[id])})
+		checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(id), []byte("Wood for Toys")})
+		checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(id)})
+		checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(id)})
+		checkInvoke(t, stub, [][]byte{[]byte("issueLC"), []byte(id), []byte("lc8349"), []byte("12/31/2019"), []byte("E/L"), []byte("B/L")})
+		checkInvoke(t, stub, [][]byte{[]byte("acceptLC"), []byte(id)})
+		checkInvoke(t, stub, [][]byte{[]byte("requestEL"), []byte(id)})
+		checkInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(id), []byte("el979"), []byte("04/30/2019")})
+		checkInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(id)})
+		checkInvoke(t, stub, [][]byte{[]byte("acceptShipmentAndIssueBL"), []byte(id), []byte("bl06678"), []byte("03/03/2019"), []byte("Woodlands Port"), []byte("Market Port")})
+	}
+
+	// Invoke 'makePayment' below the threshold, on the only trade paid so far, and verify that nothing is flagged
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeA)})
+	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeA), []byte("01/01/2019")})
+	if len(stub.privateData[amlFlagsCollection]) != 0 {
+		fmt.Println("Payment below the thresholds was flagged")
+		t.FailNow()
+	}
+
+	// Invoke 'makePayment' over the threshold, on the second trade paid between the same parties within the window
+	stub.setTxTime(t, "2019-01-10T00:00:00Z")
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeB)})
+	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeB), []byte("01/10/2019")})
+	largePaymentFlag := AMLFlag{LARGE_PAYMENT, tradeB, "makePayment", IMPORTER, EXPORTER, 50000, "2019-01-10T00:00:00Z", "1", "Payment of 50000 exceeds the threshold of 40000"}
+	frequentTradesFlag := AMLFlag{FREQUENT_TRADES, tradeB, "makePayment", IMPORTER, EXPORTER, 50000, "2019-01-10T00:00:00Z", "1", "2 trades paid by WoodenToys to LumberInc within 30 days"}
+	flagKey, _ := stub.CreateCompositeKey("AMLFlag", []string{"2019-01-10T00:00:00Z", "1", LARGE_PAYMENT, EXPORTER})
+	flagBytes, _ := json.Marshal(&largePaymentFlag)
+	checkPrivateState(t, stub, amlFlagsCollection, flagKey, string(flagBytes))
+	checkNoState(t, stub, flagKey)
+
+	// Payment activity is kept under the pair of parties, payee first
+	activityKey, _ := stub.CreateCompositeKey("AMLActivity", []string{EXPORTER, IMPORTER, "2019-01-10T00:00:00Z", "1", PAYMENT, "0"})
+	activityBytes, _ := json.Marshal(&PaymentActivity{PAYMENT, tradeB, IMPORTER, EXPORTER, 50000, "2019-01-10T00:00:00Z"})
+	checkPrivateState(t, stub, tradeTermsCollection, activityKey, string(activityBytes))
+
+	// Transfer the third trade's L/C, and invoke 'makeAdvancePayment' over the threshold
+	stub.setTxTime(t, "2019-02-20T00:00:00Z")
+	discountRate := float32(0.1)
+	stub.setTransient(map[string]string{"discountRate": strconv.FormatFloat(float64(discountRate), 'f', 2, 64)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestLCTransfer"), []byte(tradeC)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueLCTransfer"), []byte(tradeC)})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptLCTransfer"), []byte(tradeC)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestAdvancePayment"), []byte(tradeC)})
+	checkInvoke(t, stub, [][]byte{[]byte("makeAdvancePayment"), []byte(tradeC)})
+	advance := int((float32(1.0) - discountRate) * float32(amounts[tradeC]))
+	advanceFlag := AMLFlag{LARGE_PAYMENT, tradeC, "makeAdvancePayment", LENDER, EXPORTER, advance, "2019-02-20T00:00:00Z", "1", fmt.Sprintf("Payment of %d exceeds the threshold of 40000", advance)}
+
+	// Invoke 'makePayment' to the lender within the window after the transfer; payments older than the windows no longer count
+	stub.setTxTime(t, "2019-02-25T00:00:00Z")
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeC)})
+	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeC), []byte("02/25/2019")})
+	transferFlag := AMLFlag{TRANSFER_PAYMENT, tradeC, "makePayment", IMPORTER, LENDER, 25000, "2019-02-25T00:00:00Z", "1", "Payment to LenderInc 5 days after the L/C was transferred from LumberInc"}
+
+	// Invoke bad 'listFlags', then 'listFlags' unfiltered, by party and by date
+	checkBadInvoke(t, stub, [][]byte{[]byte("listFlags"), []byte(""), []byte("2019-02-01")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("listFlags"), []byte(""), []byte("02/25/2019"), []byte("02/01/2019")})
+	flagsBytes, _ := json.Marshal([]AMLFlag{frequentTradesFlag, largePaymentFlag, advanceFlag, transferFlag})
+	checkQueryArgs(t, stub, [][]byte{[]byte("listFlags"), []byte("")}, string(flagsBytes))
+	flagsBytes, _ = json.Marshal([]AMLFlag{advanceFlag, transferFlag})
+	checkQueryArgs(t, stub, [][]byte{[]byte("listFlags"), []byte("Lender Inc.")}, string(flagsBytes))
+	flagsBytes, _ = json.Marshal([]AMLFlag{advanceFlag})
+	checkQueryArgs(t, stub, [][]byte{[]byte("listFlags"), []byte("Lumber Inc"), []byte("02/01/2019"), []byte("02/20/2019")}, string(flagsBytes))
+	flagsBytes, _ = json.Marshal([]AMLFlag{})
+	checkQueryArgs(t, stub, [][]byte{[]byte("listFlags"), []byte("Toy Bank")}, string(flagsBytes))
+}
+
+func TestTradeWorkflow_CargoInsurance(t *testing.T) {
+	scc := new(TradeWorkflowChaincode)
+	scc.testMode = true
+	stub := newExtendedMockStub("Trade Workflow", scc)
+
+	// Init, with an Insurer
+	insurer := "CargoInsure"
+	insBalance := 50000
+	transient := getInitTransient()
+	transient["insurerBalance"] = strconv.Itoa(insBalance)
+	stub.setTransient(transient)
+	checkInit(t, stub, append(getInitArguments(), []byte(insurer)))
+	checkState(t, stub, "Insurer", insurer)
+	checkPrivateState(t, stub, accountBalancesCollection, "InsurersAccountBalance", strconv.Itoa(insBalance))
+
+	// Invoke 'requestTrade', 'setTelemetryThresholds'
+	tradeID := "2ks89j9"
+	amount := 50000
+	descGoods := "Frozen Berries"
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(descGoods)})
+	checkInvoke(t, stub, [][]byte{[]byte("setTelemetryThresholds"), []byte(tradeID), []byte("-25"), []byte("-18"), []byte("20"), []byte("60")})
+
+	// Invoke bad 'issueInsurancePolicy' and verify unchanged state
+	policyID := "pol-3381"
+	expirationDate := "06/30/2019"
+	policyKey, _ := stub.CreateCompositeKey("InsurancePolicy", []string{tradeID})
+	stub.setTransient(map[string]string{"insuredValue": "55000", "premium": "550"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("issueInsurancePolicy"), []byte(tradeID), []byte(policyID), []byte(ICC_A), []byte(BUYER), []byte(expirationDate)})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeID)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("issueInsurancePolicy"), []byte(tradeID), []byte(policyID), []byte("ICC_D"), []byte(BUYER), []byte(expirationDate)})
+	stub.setTransient(map[string]string{"insuredValue": "55000", "premium": "55000"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("issueInsurancePolicy"), []byte(tradeID), []byte(policyID), []byte(ICC_A), []byte(BUYER), []byte(expirationDate)})
+	stub.setTransient(map[string]string{"insuredValue": "55000", "premium": "550"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("issueInsurancePolicy"), []byte(tradeID), []byte(policyID), []byte(ICC_A), []byte("CARRIER"), []byte(expirationDate)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("issueInsurancePolicy"), []byte(tradeID), []byte(policyID), []byte(ICC_A), []byte(BUYER), []byte("12/31/2018")})
+	stub.setTransient(map[string]string{"insuredValue": "55000", "premium": "250000"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("issueInsurancePolicy"), []byte(tradeID), []byte(policyID), []byte(ICC_A), []byte(BUYER), []byte(expirationDate)})
+	stub.setTransient(map[string]string{"insuredValue": "55000"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("issueInsurancePolicy"), []byte(tradeID), []byte(policyID), []byte(ICC_A), []byte(BUYER), []byte(expirationDate)})
+	checkNoState(t, stub, policyKey)
+
+	// Invoke 'issueInsurancePolicy'; the buyer pays the premium
+	insuredValue := 55000
+	premium := 550
+	stub.setTransient(map[string]string{"insuredValue": strconv.Itoa(insuredValue), "premium": strconv.Itoa(premium)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueInsurancePolicy"), []byte(tradeID), []byte(policyID), []byte("icc_a"), []byte("buyer"), []byte(expirationDate)})
+	policy := &InsurancePolicy{policyID, tradeID, insurer, insuredValue, ICC_A, premium, BUYER, expirationDate, ISSUED, []string{}, 0, ""}
+	policyBytes, _ := json.Marshal(withInsurancePolicyTermsHash(policy))
+	checkState(t, stub, policyKey, string(policyBytes))
+	termsBytes, _ := json.Marshal(&InsurancePolicyTerms{insuredValue, premium, 0})
+	checkPrivateState(t, stub, tradeTermsCollection, policyKey, string(termsBytes))
+	checkQuery(t, stub, "getInsurancePolicy", tradeID, withPrivateTerms(policyBytes, termsBytes))
+	checkPrivateState(t, stub, accountBalancesCollection, "ImportersAccountBalance", strconv.Itoa(IMPBALANCE - premium))
+	checkPrivateState(t, stub, accountBalancesCollection, "InsurersAccountBalance", strconv.Itoa(insBalance + premium))
+	expectedResp := "{\"Balance\":\"" + strconv.Itoa(insBalance + premium) + "\"}"
+	checkQueryArgs(t, stub, [][]byte{[]byte("getAccountBalance"), []byte(tradeID), []byte("insurer")}, expectedResp)
+	stub.setTransient(map[string]string{"insuredValue": "55000", "premium": "300"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("issueInsurancePolicy"), []byte(tradeID), []byte("pol-3382"), []byte(ICC_B), []byte(SELLER), []byte(expirationDate)})
+
+	// Take the trade through to shipment, and record two excursions
+	checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueLC"), []byte(tradeID), []byte("lc8349"), []byte("12/31/2018"), []byte("E/L"), []byte("B/L")})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptLC"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestEL"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(tradeID), []byte("el979"), []byte("04/30/2019")})
+	checkInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(tradeID)})
+	deviceID := "reefer-0042"
+	checkInvoke(t, stub, [][]byte{[]byte("registerDevice"), []byte(tradeID), []byte(deviceID)})
+	stub.setTxTime(t, "2019-01-05T12:00:00Z")
+	readings := "[{\"timestamp\":\"2019-01-05T09:00:00Z\",\"temperature\":-16.5,\"humidity\":45}," +
+		"{\"timestamp\":\"2019-01-05T07:00:00Z\",\"temperature\":-22,\"humidity\":65.5}]"
+	checkInvoke(t, stub, [][]byte{[]byte("submitTelemetry"), []byte(tradeID), []byte(deviceID), []byte(readings)})
+	temperatureIncident := ShipmentIncident{TEMPERATURE_EXCURSION, deviceID, "2019-01-05T09:00:00Z", -16.5, -18}
+	humidityIncident := ShipmentIncident{HUMIDITY_EXCURSION, deviceID, "2019-01-05T07:00:00Z", 65.5, 60}
+
+	// Invoke bad 'fileClaim' and verify unchanged state
+	claimID := "clm-1"
+	claimKey, _ := stub.CreateCompositeKey("InsuranceClaim", []string{tradeID, claimID})
+	description := "Berries thawed in transit"
+	stub.setTransient(map[string]string{"amount": "20000"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("fileClaim"), []byte(tradeID), []byte(claimID), []byte(BUYER), []byte("[]"), []byte(description)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("fileClaim"), []byte(tradeID), []byte(claimID), []byte(BUYER), []byte("[2]"), []byte(description)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("fileClaim"), []byte(tradeID), []byte(claimID), []byte(BUYER), []byte("[0,0]"), []byte(description)})
+	stub.setTransient(map[string]string{"amount": "60000"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("fileClaim"), []byte(tradeID), []byte(claimID), []byte(BUYER), []byte("[0]"), []byte(description)})
+	stub.setTransient(map[string]string{"amount": "20000"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("fileClaim"), []byte(tradeID), []byte(claimID), []byte("CARRIER"), []byte("[0]"), []byte(description)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("fileClaim"), []byte(tradeID), []byte(claimID), []byte(BUYER), []byte("[0]"), []byte("")})
+	stub.setTransient(map[string]string{})
+	checkBadInvoke(t, stub, [][]byte{[]byte("fileClaim"), []byte(tradeID), []byte(claimID), []byte(BUYER), []byte("[0]"), []byte(description)})
+	checkNoState(t, stub, claimKey)
+
+	// Invoke 'fileClaim' on the temperature excursion; it cannot be claimed for again
+	stub.setTransient(map[string]string{"amount": "20000"})
+	checkInvoke(t, stub, [][]byte{[]byte("fileClaim"), []byte(tradeID), []byte(claimID), []byte(BUYER), []byte("[0]"), []byte(description)})
+	claim := &InsuranceClaim{claimID, tradeID, policyID, IMPORTER, BUYER, 20000, []ShipmentIncident{temperatureIncident}, description, FILED, 0, "", ""}
+	claimBytes, _ := json.Marshal(withInsuranceClaimTermsHash(claim))
+	checkState(t, stub, claimKey, string(claimBytes))
+	termsBytes, _ = json.Marshal(&InsuranceClaimTerms{20000, 0})
+	checkPrivateState(t, stub, tradeTermsCollection, claimKey, string(termsBytes))
+	checkQueryArgs(t, stub, [][]byte{[]byte("getInsuranceClaim"), []byte(tradeID), []byte(claimID)}, withPrivateTerms(claimBytes, termsBytes))
+	policy.Claims = []string{claimID}
+	policyBytes, _ = json.Marshal(withInsurancePolicyTermsHash(policy))
+	checkState(t, stub, policyKey, string(policyBytes))
+	stub.setTransient(map[string]string{"amount": "5000"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("fileClaim"), []byte(tradeID), []byte("clm-2"), []byte(SELLER), []byte("[1,0]"), []byte(description)})
+
+	// Invoke 'adjudicateClaim', approving part of the claim, then 'payClaim'
+	checkBadInvoke(t, stub, [][]byte{[]byte("payClaim"), []byte(tradeID), []byte(claimID)})
+	stub.setTransient(map[string]string{"payout": "25000"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("adjudicateClaim"), []byte(tradeID), []byte(claimID), []byte(APPROVED)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("adjudicateClaim"), []byte(tradeID), []byte(claimID), []byte(REJECTED), []byte("")})
+	stub.setTransient(map[string]string{"payout": "15000"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("adjudicateClaim"), []byte(tradeID), []byte(claimID), []byte("PENDING")})
+	checkInvoke(t, stub, [][]byte{[]byte("adjudicateClaim"), []byte(tradeID), []byte(claimID), []byte(APPROVED)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("adjudicateClaim"), []byte(tradeID), []byte(claimID), []byte(REJECTED), []byte("Late notice")})
+	checkInvoke(t, stub, [][]byte{[]byte("payClaim"), []byte(tradeID), []byte(claimID)})
+	claim.Status = PAID
+	claim.Payout = 15000
+	claimBytes, _ = json.Marshal(withInsuranceClaimTermsHash(claim))
+	checkState(t, stub, claimKey, string(claimBytes))
+	policy.PaidOut = 15000
+	policyBytes, _ = json.Marshal(withInsurancePolicyTermsHash(policy))
+	checkState(t, stub, policyKey, string(policyBytes))
+	termsBytes, _ = json.Marshal(&InsurancePolicyTerms{insuredValue, premium, 15000})
+	checkPrivateState(t, stub, tradeTermsCollection, policyKey, string(termsBytes))
+	checkPrivateState(t, stub, accountBalancesCollection, "ImportersAccountBalance", strconv.Itoa(IMPBALANCE - premium + 15000))
+	checkPrivateState(t, stub, accountBalancesCollection, "InsurersAccountBalance", strconv.Itoa(insBalance + premium - 15000))
+	checkBadInvoke(t, stub, [][]byte{[]byte("payClaim"), []byte(tradeID), []byte(claimID)})
+
+	// The premium and the payout are recorded as payments, and appear on the Insurer's statement
+	premiumRecord := PaymentRecord{"1", tradeID, "", PREMIUM, "issueInsurancePolicy", IMPORTER, insurer, premium, 0, 0, "2019-01-01T00:00:00Z", "1", ""}
+	claimRecord := PaymentRecord{"2", tradeID, "", CLAIM, "payClaim", insurer, IMPORTER, 15000, 0, 0, "2019-01-05T12:00:00Z", "1", ""}
+	statementBytes, _ := json.Marshal(&Statement{insurer, "01/01/2019", "01/31/2019", []PaymentRecord{premiumRecord, claimRecord}, premium, 15000, premium - 15000})
+	checkQueryArgs(t, stub, [][]byte{[]byte("getStatement"), []byte("insurer"), []byte("01/01/2019"), []byte("01/31/2019")}, string(statementBytes))
+
+	// A rejected claim releases its incidents; claims may not exceed the remaining insured value, nor be filed after the policy expires
+	stub.setTransient(map[string]string{"amount": "5000"})
+	checkInvoke(t, stub, [][]byte{[]byte("fileClaim"), []byte(tradeID), []byte("clm-2"), []byte(SELLER), []byte("[1]"), []byte("Mould on cartons")})
+	checkInvoke(t, stub, [][]byte{[]byte("adjudicateClaim"), []byte(tradeID), []byte("clm-2"), []byte(REJECTED), []byte("Excluded under packing clause")})
+	claim = &InsuranceClaim{"clm-2", tradeID, policyID, EXPORTER, SELLER, 5000, []ShipmentIncident{humidityIncident}, "Mould on cartons", REJECTED, 0, "Excluded under packing clause", ""}
+	claimBytes, _ = json.Marshal(withInsuranceClaimTermsHash(claim))
+	claimKey, _ = stub.CreateCompositeKey("InsuranceClaim", []string{tradeID, "clm-2"})
+	checkState(t, stub, claimKey, string(claimBytes))
+	stub.setTransient(map[string]string{"amount": "40001"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("fileClaim"), []byte(tradeID), []byte("clm-3"), []byte(SELLER), []byte("[1]"), []byte("Mould on cartons")})
+	stub.setTxTime(t, "2019-07-01T00:00:00Z")
+	stub.setTransient(map[string]string{"amount": "40000"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("fileClaim"), []byte(tradeID), []byte("clm-3"), []byte(SELLER), []byte("[1]"), []byte("Mould on cartons")})
+	stub.setTxTime(t, "2019-06-30T23:59:59Z")
+	checkInvoke(t, stub, [][]byte{[]byte("fileClaim"), []byte(tradeID), []byte("clm-3"), []byte(SELLER), []byte("[1]"), []byte("Mould on cartons")})
+
+	// The Insurer's account must cover the payout
+	stub.setTransient(map[string]string{"payout": "40000"})
+	checkInvoke(t, stub, [][]byte{[]byte("adjudicateClaim"), []byte(tradeID), []byte("clm-3"), []byte(APPROVED)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("payClaim"), []byte(tradeID), []byte("clm-3")})
+	checkPrivateState(t, stub, accountBalancesCollection, "ExportersAccountBalance", strconv.Itoa(EXPBALANCE))
+
+	// Only the insurer can issue policies and adjudicate or pay claims; claimants claim on their own behalf
+	scc.testMode = false
+	stub.setCreator(t, "ImporterOrgMSP", "ca.importerorg.trade.com", "User1@importerorg.trade.com")
+	checkBadInvoke(t, stub, [][]byte{[]byte("adjudicateClaim"), []byte(tradeID), []byte("clm-3"), []byte(REJECTED), []byte("Late notice")})
+	stub.setTransient(map[string]string{"amount": "1000"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("fileClaim"), []byte(tradeID), []byte("clm-4"), []byte(SELLER), []byte("[1]"), []byte(description)})
+
+	// The insurer is a Lender Org member enrolled with the attribute 'insurer=true'
+	stub.setCreator(t, "LenderOrgMSP", "ca.lenderorg.trade.com", "User1@lenderorg.trade.com")
+	checkBadInvoke(t, stub, [][]byte{[]byte("adjudicateClaim"), []byte(tradeID), []byte("clm-3"), []byte(REJECTED), []byte("Late notice")})
+	checkBadQuery(t, stub, "getInsurancePolicy", tradeID)
+	stub.setCreatorWithAttributes(t, "LenderOrgMSP", "ca.lenderorg.trade.com", "Underwriter@lenderorg.trade.com", map[string]string{"insurer": "true"})
+	checkQuery(t, stub, "getInsurancePolicy", tradeID, withPrivateTerms(stub.State[policyKey], stub.privateData[tradeTermsCollection][policyKey]))
+}
+
+func TestTradeWorkflow_Disputes(t *testing.T) {
+	scc := new(TradeWorkflowChaincode)
+	scc.testMode = true
+	stub := newExtendedMockStub("Trade Workflow", scc)
+
+	// Init
+	stub.setTransient(getInitTransient())
+	checkInit(t, stub, getInitArguments())
+
+	// Take three trades through to the issuance of their B/Ls; the second is paid half at source
+	tradeA := "2ks89j9"
+	tradeB := "7hd62k1"
+	tradeC := "9fj27d4"
+	amount := 50000
+	for _, id := range []string{tradeA, tradeB, tradeC} {
+		stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+		checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(id), []byte("Wood for Toys")})
+		checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(id)})
+		checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(id)})
+		checkInvoke(t, stub, [][]byte{[]byte("issueLC"), []byte(id), []byte("lc8349"), []byte("12/31/2019"), []byte("E/L"), []byte("B/L")})
+		checkInvoke(t, stub, [][]byte{[]byte("acceptLC"), []byte(id)})
+		checkInvoke(t, stub, [][]byte{[]byte("requestEL"), []byte(id)})
+		checkInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(id), []byte("el979"), []byte("04/30/2019")})
+		checkInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(id)})
+		checkInvoke(t, stub, [][]byte{[]byte("acceptShipmentAndIssueBL"), []byte(id), []byte("bl06678"), []byte("03/03/2019"), []byte("Woodlands Port"), []byte("Market Port")})
+	}
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeB)})
+	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeB), []byte("01/01/2019")})
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeA)})
+
+	// Invoke bad 'openDispute' and verify that nothing is recorded
+	reason := "Lumber received is not kiln-dried"
+	disputeKey, _ := stub.CreateCompositeKey("Dispute", []string{tradeA, "dsp-1"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("openDispute"), []byte(tradeA), []byte("dsp-1")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("openDispute"), []byte(tradeA), []byte("dsp-1"), []byte("")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("openDispute"), []byte("abcd"), []byte("dsp-1"), []byte(reason)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("openDispute"), []byte(tradeA), []byte(""), []byte(reason)})
+	checkNoState(t, stub, disputeKey)
+
+	// Invoke 'openDispute'; payments on the trade are frozen
+	checkInvoke(t, stub, [][]byte{[]byte("openDispute"), []byte(tradeA), []byte("dsp-1"), []byte(reason)})
+	dispute := &Dispute{"dsp-1", tradeA, "", "", reason, []string{}, OPEN, "2019-01-01T00:00:00Z", "", "", 0, "", "", ""}
+	disputeBytes, _ := json.Marshal(dispute)
+	checkState(t, stub, disputeKey, string(disputeBytes))
+	checkQuery(t, stub, "getDispute", tradeA, string(disputeBytes))
+	checkQuery(t, stub, "getTradeStatus", tradeA, "{\"Status\":\"DISPUTED\"}")
+	checkBadInvoke(t, stub, [][]byte{[]byte("openDispute"), []byte(tradeA), []byte("dsp-2"), []byte(reason)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeA), []byte("01/01/2019")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestAdvancePayment"), []byte(tradeA)})
+
+	// Invoke 'uploadDocument' to attach evidence to the dispute
+	hash := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	checkBadInvoke(t, stub, [][]byte{[]byte("uploadDocument"), []byte(tradeC), []byte("photo-1"), []byte("Dispute"), []byte("Photograph"), []byte(hash), []byte("image/jpeg"), []byte("1024"), []byte("https://docs.woodentoys.com/photo-1.jpg")})
+	checkInvoke(t, stub, [][]byte{[]byte("uploadDocument"), []byte(tradeA), []byte("photo-1"), []byte("Dispute"), []byte("Photograph"), []byte(hash), []byte("image/jpeg"), []byte("1024"), []byte("https://docs.woodentoys.com/photo-1.jpg")})
+	dispute.Evidence = []string{"photo-1"}
+	disputeBytes, _ = json.Marshal(dispute)
+	checkState(t, stub, disputeKey, string(disputeBytes))
+
+	// Invoke bad 'resolveDispute' and verify unchanged state
+	ruling := "Goods accepted at a reduced price"
+	checkBadInvoke(t, stub, [][]byte{[]byte("resolveDispute"), []byte(tradeA), []byte("SETTLE"), []byte(ruling)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("resolveDispute"), []byte(tradeA), []byte(PARTIAL_PAY), []byte("")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("resolveDispute"), []byte(tradeC), []byte(CANCEL), []byte(ruling)})
+	stub.setTransient(map[string]string{})
+	checkBadInvoke(t, stub, [][]byte{[]byte("resolveDispute"), []byte(tradeA), []byte(PARTIAL_PAY), []byte(ruling)})
+	stub.setTransient(map[string]string{"amount": "50001"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("resolveDispute"), []byte(tradeA), []byte(PARTIAL_PAY), []byte(ruling)})
+	checkState(t, stub, disputeKey, string(disputeBytes))
+
+	// Invoke 'resolveDispute' with a partial payment; the trade goes on, and the B/L stays with the bank until it is paid in full
+	stub.setTransient(map[string]string{"amount": "30000"})
+	checkInvoke(t, stub, [][]byte{[]byte("resolveDispute"), []byte(tradeA), []byte("partial_pay"), []byte(ruling)})
+	termsBytes, _ := json.Marshal(&DisputeTerms{30000})
+	dispute = &Dispute{"dsp-1", tradeA, "", "", reason, []string{"photo-1"}, RESOLVED, "2019-01-01T00:00:00Z", "", PARTIAL_PAY, 0, ruling, "2019-01-01T00:00:00Z", hashPrivateData(termsBytes)}
+	disputeBytes, _ = json.Marshal(dispute)
+	checkState(t, stub, disputeKey, string(disputeBytes))
+	checkPrivateState(t, stub, tradeTermsCollection, disputeKey, string(termsBytes))
+	checkPrivateState(t, stub, accountBalancesCollection, expBalKey, strconv.Itoa(EXPBALANCE + 25000 + 30000))
+	checkPrivateState(t, stub, accountBalancesCollection, impBalKey, strconv.Itoa(IMPBALANCE - 25000 - 30000))
+	tradeKey, _ := stub.CreateCompositeKey("Trade", []string{tradeA})
+	tradeAgreementBytes, _ := json.Marshal(withTradeTermsHash(&TradeAgreement{amount, "Wood for Toys", 0, ACCEPTED, 30000, "", "", 0.0, 0, nil, ""}))
+	checkState(t, stub, tradeKey, string(tradeAgreementBytes))
+	paymentKey, _ := stub.CreateCompositeKey("Payment", []string{tradeA})
+	checkNoState(t, stub, paymentKey)
+	blKey, _ := stub.CreateCompositeKey("BillOfLading", []string{tradeA})
+	billOfLading := &BillOfLading{"bl06678", "03/03/2019", EXPORTER, CARRIER, "Wood for Toys", 0, IMPBANK, "Woodlands Port", "Market Port", nil, IMPBANK, "ImporterOrgMSP", "", HELD_BY_BANK, []Endorsement{}, "", ""}
+	billOfLadingBytes, _ := json.Marshal(billOfLading)
+	checkState(t, stub, blKey, string(billOfLadingBytes))
+	checkQuery(t, stub, "getTradeStatus", tradeA, "{\"Status\":\"ACCEPTED\"}")
+	checkBadInvoke(t, stub, [][]byte{[]byte("resolveDispute"), []byte(tradeA), []byte(CANCEL), []byte(ruling)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("uploadDocument"), []byte(tradeA), []byte("photo-2"), []byte("Dispute"), []byte("Photograph"), []byte(hash), []byte("image/jpeg"), []byte("1024"), []byte("https://docs.woodentoys.com/photo-2.jpg")})
+
+	// Once the dispute is resolved, a new one can be opened, and the rest of the trade paid after its resolution
+	// The resolved dispute keeps its ruling and evidence, and its ID cannot be reused
+	checkBadInvoke(t, stub, [][]byte{[]byte("openDispute"), []byte(tradeA), []byte("dsp-1"), []byte("Late delivery")})
+	checkInvoke(t, stub, [][]byte{[]byte("openDispute"), []byte(tradeA), []byte("dsp-4"), []byte("Late delivery")})
+	checkState(t, stub, disputeKey, string(disputeBytes))
+	checkQueryArgs(t, stub, [][]byte{[]byte("getDispute"), []byte(tradeA), []byte("dsp-1")}, string(disputeBytes))
+	tradeDisputesKey, _ := stub.CreateCompositeKey("TradeDisputes", []string{tradeA})
+	tradeDisputesBytes, _ := json.Marshal(&TradeDisputes{tradeA, []string{"dsp-1", "dsp-4"}})
+	checkState(t, stub, tradeDisputesKey, string(tradeDisputesBytes))
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeA)})
+	stub.setTransient(map[string]string{"amount": "20000"})
+	checkInvoke(t, stub, [][]byte{[]byte("resolveDispute"), []byte(tradeA), []byte(PARTIAL_PAY), []byte("Balance due")})
+	checkPrivateState(t, stub, accountBalancesCollection, expBalKey, strconv.Itoa(EXPBALANCE + 25000 + 50000))
+	billOfLading = &BillOfLading{"bl06678", "03/03/2019", EXPORTER, CARRIER, "Wood for Toys", 0, IMPBANK, "Woodlands Port", "Market Port", nil, IMPORTER, "ImporterOrgMSP", "", RELEASED_TO_IMPORTER, []Endorsement{{IMPBANK, "ImporterOrgMSP", IMPORTER, "ImporterOrgMSP", ""}}, "", ""}
+	billOfLadingBytes, _ = json.Marshal(billOfLading)
+	checkState(t, stub, blKey, string(billOfLadingBytes))
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeA)})
+
+	// Invoke 'resolveDispute' with a refund of what was paid; the L/C amount is restored and the B/L stays with the bank
+	checkInvoke(t, stub, [][]byte{[]byte("openDispute"), []byte(tradeB), []byte("dsp-2"), []byte("Goods never left the port")})
+	stub.setTransient(map[string]string{"amount": "25001"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("resolveDispute"), []byte(tradeB), []byte(REFUND), []byte("Exporter in breach"), []byte("1")})
+	stub.setTransient(map[string]string{"amount": "25000"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("resolveDispute"), []byte(tradeB), []byte(REFUND), []byte("Exporter in breach")})
+	checkInvoke(t, stub, [][]byte{[]byte("resolveDispute"), []byte(tradeB), []byte(REFUND), []byte("Exporter in breach"), []byte("1")})
+	checkPrivateState(t, stub, accountBalancesCollection, expBalKey, strconv.Itoa(EXPBALANCE + 50000))
+	checkPrivateState(t, stub, accountBalancesCollection, impBalKey, strconv.Itoa(IMPBALANCE - 50000))
+	lcKey, _ := stub.CreateCompositeKey("LetterOfCredit", []string{tradeB})
+	lcTermsBytes, _ := json.Marshal(&LetterOfCreditTerms{amount, nil})
+	checkPrivateState(t, stub, tradeTermsCollection, lcKey, string(lcTermsBytes))
+	blKey, _ = stub.CreateCompositeKey("BillOfLading", []string{tradeB})
+	billOfLadingBytes, _ = json.Marshal(&BillOfLading{"bl06678", "03/03/2019", EXPORTER, CARRIER, "Wood for Toys", 0, IMPBANK, "Woodlands Port", "Market Port", nil, IMPBANK, "ImporterOrgMSP", "", HELD_BY_BANK, []Endorsement{}, "", ""})
+	checkState(t, stub, blKey, string(billOfLadingBytes))
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeB)})
+	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeB), []byte("01/01/2019")})
+	checkPrivateState(t, stub, accountBalancesCollection, impBalKey, strconv.Itoa(IMPBALANCE - 50000 - 25000))
+
+	// Invoke 'resolveDispute' to cancel a paid trade; what has not been refunded already is returned to the importer
+	checkInvoke(t, stub, [][]byte{[]byte("openDispute"), []byte(tradeB), []byte("dsp-6"), []byte("Goods seized at port")})
+	checkInvoke(t, stub, [][]byte{[]byte("resolveDispute"), []byte(tradeB), []byte(CANCEL), []byte("Trade frustrated")})
+	checkPrivateState(t, stub, accountBalancesCollection, expBalKey, strconv.Itoa(EXPBALANCE + 50000))
+	checkPrivateState(t, stub, accountBalancesCollection, impBalKey, strconv.Itoa(IMPBALANCE - 50000))
+	paymentKey, _ = stub.CreateCompositeKey("PaymentRecord", []string{tradeB, "4"})
+	paymentBytes, _ := json.Marshal(&PaymentRecord{"4", tradeB, "", REFUND, "resolveDispute", EXPORTER, IMPORTER, 25000, 0, 0, "2019-01-01T00:00:00Z", "1", "3"})
+	checkPrivateState(t, stub, tradeTermsCollection, paymentKey, string(paymentBytes))
+	paymentKey, _ = stub.CreateCompositeKey("PaymentRecord", []string{tradeB, "5"})
+	checkNoPrivateState(t, stub, tradeTermsCollection, paymentKey)
+	tradeKey, _ = stub.CreateCompositeKey("Trade", []string{tradeB})
+	tradeAgreementBytes, _ = json.Marshal(withTradeTermsHash(&TradeAgreement{amount, "Wood for Toys", 0, CANCELLED, 0, "", "", 0.0, 0, nil, ""}))
+	checkState(t, stub, tradeKey, string(tradeAgreementBytes))
+	disputeKey, _ = stub.CreateCompositeKey("Dispute", []string{tradeB, "dsp-6"})
+	termsBytes, _ = json.Marshal(&DisputeTerms{25000})
+	checkPrivateState(t, stub, tradeTermsCollection, disputeKey, string(termsBytes))
+	disputeKey, _ = stub.CreateCompositeKey("Dispute", []string{tradeA, "dsp-4"})
+
+	// A payment approved while the dispute is open is refused; invoke 'resolveDispute' to cancel the trade
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeC)})
+	scc.testMode = false
+	scc.dualAuthorization = true
+	stub.setCreator(t, "ImporterOrgMSP", "ca.importerorg.trade.com", "Maker@importerorg.trade.com")
+	res := stub.MockInvoke("payTx", [][]byte{[]byte("makePayment"), []byte(tradeC), []byte("01/01/2019")})
+	if res.Status != shim.OK {
+		fmt.Println("Invoke makePayment failed", string(res.Message))
+		t.FailNow()
+	}
+	scc.testMode = true
+	checkInvoke(t, stub, [][]byte{[]byte("openDispute"), []byte(tradeC), []byte("dsp-3"), []byte("Order placed in error")})
+	scc.testMode = false
+	stub.setCreator(t, "ImporterOrgMSP", "ca.importerorg.trade.com", "Checker@importerorg.trade.com")
+	checkBadInvoke(t, stub, [][]byte{[]byte("approveAction"), []byte("payTx")})
+	scc.testMode = true
+	scc.dualAuthorization = false
+	checkInvoke(t, stub, [][]byte{[]byte("resolveDispute"), []byte(tradeC), []byte(CANCEL), []byte("Cancelled by agreement")})
+	checkQuery(t, stub, "getTradeStatus", tradeC, "{\"Status\":\"CANCELLED\"}")
+
+	// A cancelled trade is closed to every transaction
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeC)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeC)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(tradeC)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("updateShipmentLocation"), []byte(tradeC), []byte(DESTINATION), []byte("03/01/2019")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("openDispute"), []byte(tradeC), []byte("dsp-5"), []byte("Reopen")})
+	checkQuery(t, stub, "getTradeStatus", tradeC, "{\"Status\":\"CANCELLED\"}")
+
+	// Only a Regulator Org member enrolled as an arbitrator can resolve a dispute
+	scc.testMode = false
+	stub.setCreator(t, "ImporterOrgMSP", "ca.importerorg.trade.com", "User1@importerorg.trade.com")
+	checkBadInvoke(t, stub, [][]byte{[]byte("resolveDispute"), []byte(tradeC), []byte(PAY_IN_FULL), []byte(ruling)})
+	stub.setCreator(t, "RegulatorOrgMSP", "ca.regulatororg.trade.com", "User1@regulatororg.trade.com")
+	checkBadInvoke(t, stub, [][]byte{[]byte("resolveDispute"), []byte(tradeC), []byte(PAY_IN_FULL), []byte(ruling)})
+	checkBadQuery(t, stub, "getDispute", tradeA)
+	stub.setCreatorWithAttributes(t, "RegulatorOrgMSP", "ca.regulatororg.trade.com", "Arbiter@regulatororg.trade.com", map[string]string{"arbitrator": "true"})
+	checkQuery(t, stub, "getDispute", tradeA, string(stub.State[disputeKey]))
+}
+
+func TestTradeWorkflow_Refunds(t *testing.T) {
+	scc := new(TradeWorkflowChaincode)
+	scc.testMode = true
+	stub := newExtendedMockStub("Trade Workflow", scc)
+
+	// Init
+	stub.setTransient(getInitTransient())
+	checkInit(t, stub, getInitArguments())
+
+	// Take a trade through to its first payment, of half the amount
+	tradeID := "2ks89j9"
+	amount := 50000
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte("Wood for Toys")})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueLC"), []byte(tradeID), []byte("lc8349"), []byte("12/31/2019"), []byte("E/L"), []byte("B/L")})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptLC"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestEL"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(tradeID), []byte("el979"), []byte("04/30/2019")})
+	checkInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptShipmentAndIssueBL"), []byte(tradeID), []byte("bl06678"), []byte("03/03/2019"), []byte("Woodlands Port"), []byte("Market Port")})
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeID), []byte("01/01/2019")})
+
+	// The payment is recorded
+	paymentKey, _ := stub.CreateCompositeKey("PaymentRecord", []string{tradeID, "1"})
+	paymentBytes, _ := json.Marshal(&PaymentRecord{"1", tradeID, "", PAYMENT, "makePayment", IMPORTER, EXPORTER, 25000, 0, 1, "2019-01-01T00:00:00Z", "1", ""})
+	checkPrivateState(t, stub, tradeTermsCollection, paymentKey, string(paymentBytes))
+	checkQueryArgs(t, stub, [][]byte{[]byte("getPayment"), []byte(tradeID), []byte("1")}, string(paymentBytes))
+	checkBadQuery(t, stub, "getPayment", tradeID)
+
+	// Invoke bad 'refundPayment' and verify unchanged balances
+	stub.setTransient(map[string]string{"amount": "25001"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("refundPayment"), []byte(tradeID), []byte("1")})
+	stub.setTransient(map[string]string{"amount": "0"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("refundPayment"), []byte(tradeID), []byte("1")})
+	stub.setTransient(map[string]string{"amount": "10000"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("refundPayment"), []byte(tradeID), []byte("2")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("refundPayment"), []byte(tradeID)})
+	checkPrivateState(t, stub, accountBalancesCollection, expBalKey, strconv.Itoa(EXPBALANCE + 25000))
+	checkPrivateState(t, stub, accountBalancesCollection, impBalKey, strconv.Itoa(IMPBALANCE - 25000))
+
+	// Invoke 'refundPayment' for part of the payment; the refund is recorded against it
+	checkInvoke(t, stub, [][]byte{[]byte("refundPayment"), []byte(tradeID), []byte("1")})
+	refundKey, _ := stub.CreateCompositeKey("PaymentRecord", []string{tradeID, "2"})
+	refundBytes, _ := json.Marshal(&PaymentRecord{"2", tradeID, "", REFUND, "refundPayment", EXPORTER, IMPORTER, 10000, 0, 0, "2019-01-01T00:00:00Z", "1", "1"})
+	checkPrivateState(t, stub, tradeTermsCollection, refundKey, string(refundBytes))
+	checkPrivateState(t, stub, tradeTermsCollection, paymentKey, string(paymentBytes))
+	checkPrivateState(t, stub, accountBalancesCollection, expBalKey, strconv.Itoa(EXPBALANCE + 15000))
+	checkPrivateState(t, stub, accountBalancesCollection, impBalKey, strconv.Itoa(IMPBALANCE - 15000))
+	tradeKey, _ := stub.CreateCompositeKey("Trade", []string{tradeID})
+	tradeTermsBytes, _ := json.Marshal(&TradeTerms{amount, 15000, 0.0, 0})
+	checkPrivateState(t, stub, tradeTermsCollection, tradeKey, string(tradeTermsBytes))
+	lcKey, _ := stub.CreateCompositeKey("LetterOfCredit", []string{tradeID})
+	lcTermsBytes, _ := json.Marshal(&LetterOfCreditTerms{amount - 15000, nil})
+	checkPrivateState(t, stub, tradeTermsCollection, lcKey, string(lcTermsBytes))
+
+	// A refund is not itself refundable, and no more than what remains of the payment can be refunded
+	checkBadInvoke(t, stub, [][]byte{[]byte("refundPayment"), []byte(tradeID), []byte("2")})
+	stub.setTransient(map[string]string{"amount": "15001"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("refundPayment"), []byte(tradeID), []byte("1")})
+
+	// Only the beneficiary that received the payment can refund it
+	scc.testMode = false
+	stub.setTransient(map[string]string{})
+	stub.setCreator(t, "ImporterOrgMSP", "ca.importerorg.trade.com", "User1@importerorg.trade.com")
+	checkBadInvoke(t, stub, [][]byte{[]byte("refundPayment"), []byte(tradeID), []byte("1")})
+	stub.setCreator(t, "LenderOrgMSP", "ca.lenderorg.trade.com", "User1@lenderorg.trade.com")
+	checkBadInvoke(t, stub, [][]byte{[]byte("refundPayment"), []byte(tradeID), []byte("1")})
+	checkPrivateState(t, stub, accountBalancesCollection, expBalKey, strconv.Itoa(EXPBALANCE + 15000))
+
+	// Invoke 'refundPayment' without an amount to refund the remainder of the payment
+	stub.setCreator(t, "ExporterOrgMSP", "ca.exporterorg.trade.com", "User1@exporterorg.trade.com")
+	checkInvoke(t, stub, [][]byte{[]byte("refundPayment"), []byte(tradeID), []byte("1")})
+	scc.testMode = true
+	refundKey, _ = stub.CreateCompositeKey("PaymentRecord", []string{tradeID, "3"})
+	refundBytes, _ = json.Marshal(&PaymentRecord{"3", tradeID, "", REFUND, "refundPayment", EXPORTER, IMPORTER, 15000, 0, 0, "2019-01-01T00:00:00Z", "1", "1"})
+	checkPrivateState(t, stub, tradeTermsCollection, refundKey, string(refundBytes))
+	checkPrivateState(t, stub, accountBalancesCollection, expBalKey, strconv.Itoa(EXPBALANCE))
+	checkPrivateState(t, stub, accountBalancesCollection, impBalKey, strconv.Itoa(IMPBALANCE))
+	tradeTermsBytes, _ = json.Marshal(&TradeTerms{amount, 0, 0.0, 0})
+	checkPrivateState(t, stub, tradeTermsCollection, tradeKey, string(tradeTermsBytes))
+	lcTermsBytes, _ = json.Marshal(&LetterOfCreditTerms{amount, nil})
+	checkPrivateState(t, stub, tradeTermsCollection, lcKey, string(lcTermsBytes))
+	checkBadInvoke(t, stub, [][]byte{[]byte("refundPayment"), []byte(tradeID), []byte("1")})
+
+	// Pay the trade in full; once its B/L is released to the importer, neither a refund nor a cancellation can return the funds
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeID), []byte("01/01/2019")})
+	checkInvoke(t, stub, [][]byte{[]byte("updateShipmentLocation"), []byte(tradeID), []byte(DESTINATION), []byte("01/01/2019")})
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeID), []byte("01/01/2019")})
+	blKey, _ := stub.CreateCompositeKey("BillOfLading", []string{tradeID})
+	billOfLadingBytes, _ := json.Marshal(&BillOfLading{"bl06678", "03/03/2019", EXPORTER, CARRIER, "Wood for Toys", 0, IMPBANK, "Woodlands Port", "Market Port", nil, IMPORTER, "ImporterOrgMSP", "", RELEASED_TO_IMPORTER, []Endorsement{{IMPBANK, "ImporterOrgMSP", IMPORTER, "ImporterOrgMSP", ""}}, "", ""})
+	checkState(t, stub, blKey, string(billOfLadingBytes))
+	checkBadInvoke(t, stub, [][]byte{[]byte("refundPayment"), []byte(tradeID), []byte("4")})
+	checkInvoke(t, stub, [][]byte{[]byte("openDispute"), []byte(tradeID), []byte("dsp-1"), []byte("Goods not as described")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("resolveDispute"), []byte(tradeID), []byte(CANCEL), []byte("Trade frustrated")})
+	checkPrivateState(t, stub, accountBalancesCollection, expBalKey, strconv.Itoa(EXPBALANCE + amount))
+	checkPrivateState(t, stub, accountBalancesCollection, impBalKey, strconv.Itoa(IMPBALANCE - amount))
+	tradeTermsBytes, _ = json.Marshal(&TradeTerms{amount, amount, 0.0, 0})
+	checkPrivateState(t, stub, tradeTermsCollection, tradeKey, string(tradeTermsBytes))
+}
+
+func TestTradeWorkflow_PaymentRecords(t *testing.T) {
+	scc := new(TradeWorkflowChaincode)
+	scc.testMode = true
+	stub := newExtendedMockStub("Trade Workflow", scc)
+
+	// Init
+	stub.setTransient(getInitTransient())
+	checkInit(t, stub, getInitArguments())
+
+	// Take two trades through to the issuance of their B/Ls
+	tradeA := "2ks89j9"
+	tradeB := "7hd62k1"
+	amount := 50000
+	for _, id := range []string{tradeA, tradeB} {
+		stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+		checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(id), []byte("Wood for Toys")})
+		checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(id)})
+		checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(id)})
+		checkInvoke(t, stub, [][]byte{[]byte("issueLC"), []byte(id), []byte("lc8349"), []byte("12/31/2019"), []byte("E/L"), []byte("B/L")})
+		checkInvoke(t, stub, [][]byte{[]byte("acceptLC"), []byte(id)})
+		checkInvoke(t, stub, [][]byte{[]byte("requestEL"), []byte(id)})
+		checkInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(id), []byte("el979"), []byte("04/30/2019")})
+		checkInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(id)})
+		checkInvoke(t, stub, [][]byte{[]byte("acceptShipmentAndIssueBL"), []byte(id), []byte("bl06678"), []byte("03/03/2019"), []byte("Woodlands Port"), []byte("Market Port")})
+	}
+
+	// Pay the first installments at source, a month apart
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeA)})
+	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeA), []byte("01/01/2019")})
+	stub.setTxTime(t, "2019-02-10T00:00:00Z")
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeB)})
+	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeB), []byte("02/10/2019")})
+
+	// Pay the balance of the first trade late; the record carries the surcharge
+	checkInvoke(t, stub, [][]byte{[]byte("updateShipmentLocation"), []byte(tradeA), []byte(DESTINATION), []byte("02/01/2019")})
+	stub.setTxTime(t, "2019-04-16T00:00:00Z")
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeA)})
+	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeA), []byte("04/16/2019")})
+	surcharge := 583
+	paymentA1 := PaymentRecord{"1", tradeA, "", PAYMENT, "makePayment", IMPORTER, EXPORTER, 25000, 0, 1, "2019-01-01T00:00:00Z", "1", ""}
+	paymentB1 := PaymentRecord{"1", tradeB, "", PAYMENT, "makePayment", IMPORTER, EXPORTER, 25000, 0, 1, "2019-02-10T00:00:00Z", "1", ""}
+	paymentA2 := PaymentRecord{"2", tradeA, "", PAYMENT, "makePayment", IMPORTER, EXPORTER, 25000 + surcharge, surcharge, 2, "2019-04-16T00:00:00Z", "1", ""}
+	paymentKey, _ := stub.CreateCompositeKey("PaymentRecord", []string{tradeA, "2"})
+	paymentBytes, _ := json.Marshal(&paymentA2)
+	checkPrivateState(t, stub, tradeTermsCollection, paymentKey, string(paymentBytes))
+
+	// Invoke 'listPayments' for a trade and for an account
+	paymentsBytes, _ := json.Marshal([]PaymentRecord{paymentA1, paymentA2})
+	checkQueryArgs(t, stub, [][]byte{[]byte("listPayments"), []byte("trade"), []byte(tradeA)}, string(paymentsBytes))
+	paymentsBytes, _ = json.Marshal([]PaymentRecord{paymentA1, paymentB1, paymentA2})
+	checkQueryArgs(t, stub, [][]byte{[]byte("listPayments"), []byte("account"), []byte("exporter")}, string(paymentsBytes))
+	checkQueryArgs(t, stub, [][]byte{[]byte("listPayments"), []byte("account"), []byte("lender")}, "[]")
+	checkQueryArgs(t, stub, [][]byte{[]byte("listPayments"), []byte("trade"), []byte("unknown")}, "[]")
+	checkBadQuery(t, stub, "listPayments", tradeA)
+	checkBadInvoke(t, stub, [][]byte{[]byte("listPayments"), []byte("shipment"), []byte(tradeA)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("listPayments"), []byte("account"), []byte("carrier")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("getStatement"), []byte("exporter"), []byte("04/16/2019"), []byte("02/01/2019")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("getStatement"), []byte("exporter"), []byte("2019-02-01"), []byte("04/16/2019")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("getStatement"), []byte("exporter"), []byte("02/01/2019")})
+
+	// Invoke 'getStatement'; both dates are inclusive
+	statementBytes, _ := json.Marshal(&Statement{EXPORTER, "02/01/2019", "04/16/2019", []PaymentRecord{paymentB1, paymentA2}, 50000 + surcharge, 0, 50000 + surcharge})
+	checkQueryArgs(t, stub, [][]byte{[]byte("getStatement"), []byte("exporter"), []byte("02/01/2019"), []byte("04/16/2019")}, string(statementBytes))
+	statementBytes, _ = json.Marshal(&Statement{IMPORTER, "01/01/2019", "02/09/2019", []PaymentRecord{paymentA1}, 0, 25000, -25000})
+	checkQueryArgs(t, stub, [][]byte{[]byte("getStatement"), []byte("importer"), []byte("01/01/2019"), []byte("02/09/2019")}, string(statementBytes))
+	statementBytes, _ = json.Marshal(&Statement{IMPORTER, "05/01/2019", "05/31/2019", []PaymentRecord{}, 0, 0, 0})
+	checkQueryArgs(t, stub, [][]byte{[]byte("getStatement"), []byte("importer"), []byte("05/01/2019"), []byte("05/31/2019")}, string(statementBytes))
+
+	// Only the account holder's org can list its payments or get its statement
+	scc.testMode = false
+	stub.setCreator(t, "ImporterOrgMSP", "ca.importerorg.trade.com", "User1@importerorg.trade.com")
+	statementBytes, _ = json.Marshal(&Statement{IMPORTER, "01/01/2019", "02/09/2019", []PaymentRecord{paymentA1}, 0, 25000, -25000})
+	checkQueryArgs(t, stub, [][]byte{[]byte("getStatement"), []byte("importer"), []byte("01/01/2019"), []byte("02/09/2019")}, string(statementBytes))
+	checkBadInvoke(t, stub, [][]byte{[]byte("listPayments"), []byte("account"), []byte("exporter")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("getStatement"), []byte("exporter"), []byte("01/01/2019"), []byte("02/09/2019")})
+	scc.testMode = true
+}
+
+func TestTradeWorkflow_FinancingMarketplace(t *testing.T) {
+	scc := new(TradeWorkflowChaincode)
+	scc.testMode = true
+	stub := newExtendedMockStub("Trade Workflow", scc)
+
+	// Init
+	stub.setTransient(getInitTransient())
+	checkInit(t, stub, getInitArguments())
+
+	// Take a trade through to the preparation of its shipment
+	tradeID := "2ks89j9"
+	amount := 50000
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte("Wood for Toys")})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueLC"), []byte(tradeID), []byte("lc8349"), []byte("12/31/2019"), []byte("E/L"), []byte("B/L")})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptLC"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestEL"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(tradeID), []byte("el979"), []byte("04/30/2019")})
+	checkInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(tradeID)})
+
+	// Invoke bad 'registerLender' and verify that nothing is recorded
+	northBank := "NorthBank"
+	southBank := "SouthBank"
+	stub.setTransient(map[string]string{})
+	checkBadInvoke(t, stub, [][]byte{[]byte("registerLender"), []byte(northBank)})
+	stub.setTransient(map[string]string{"balance": "-1"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("registerLender"), []byte(northBank)})
+	stub.setTransient(map[string]string{"balance": "150000"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("registerLender"), []byte(LENDER)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("registerLender"), []byte(EXPORTER)})
+	northKey, _ := stub.CreateCompositeKey("Lender", []string{northBank})
+	checkNoState(t, stub, northKey)
+
+	// Only the regulator registers a lender and funds its account; a lender cannot set its own balance
+	scc.testMode = false
+	stub.setCreatorWithAttributes(t, "LenderOrgMSP", "ca.lenderorg.trade.com", "User1@lenderorg.trade.com", map[string]string{"lender": northBank})
+	checkBadInvoke(t, stub, [][]byte{[]byte("registerLender"), []byte(northBank)})
+	checkNoState(t, stub, northKey)
+
+	// Invoke 'registerLender' for two lenders with accounts of their own
+	stub.setCreator(t, "RegulatorOrgMSP", "ca.regulatororg.trade.com", "Admin@regulatororg.trade.com")
+	checkInvoke(t, stub, [][]byte{[]byte("registerLender"), []byte(northBank)})
+	scc.testMode = true
+	stub.setTransient(map[string]string{"balance": "80000"})
+	checkInvoke(t, stub, [][]byte{[]byte("registerLender"), []byte(southBank)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("registerLender"), []byte(southBank)})
+	lenderBytes, _ := json.Marshal(&Lender{northBank, "2019-01-01T00:00:00Z"})
+	checkState(t, stub, northKey, string(lenderBytes))
+	checkQuery(t, stub, "getLender", northBank, string(lenderBytes))
+	southBalKey, _ := stub.CreateCompositeKey("LenderBalance", []string{southBank})
+	checkPrivateState(t, stub, accountBalancesCollection, southBalKey, "80000")
+	checkQuery(t, stub, "getLenderBalance", southBank, "{\"Balance\":\"80000\"}")
+
+	// Invoke bad 'postReceivable' and verify that nothing is recorded
+	checkBadInvoke(t, stub, [][]byte{[]byte("postReceivable"), []byte(tradeID), []byte("12/31/2018")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("postReceivable"), []byte(tradeID), []byte("2019-01-15")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("postReceivable"), []byte("unknown"), []byte("01/15/2019")})
+	receivableKey, _ := stub.CreateCompositeKey("Receivable", []string{tradeID})
+	checkNoState(t, stub, receivableKey)
+
+	// Invoke 'postReceivable'; the L/C cannot be transferred to the configured lender while bidding is open
+	checkInvoke(t, stub, [][]byte{[]byte("postReceivable"), []byte(tradeID), []byte("01/15/2019")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("postReceivable"), []byte(tradeID), []byte("01/15/2019")})
+	receivable := &Receivable{tradeID, EXPORTER, "01/15/2019", OPEN, []string{}, "", "2019-01-01T00:00:00Z", 0, ""}
+	receivableTermsBytes, _ := json.Marshal(&ReceivableTerms{0})
+	receivable.TermsHash = hashPrivateData(receivableTermsBytes)
+	receivableBytes, _ := json.Marshal(receivable)
+	checkState(t, stub, receivableKey, string(receivableBytes))
+	stub.setTransient(map[string]string{"discountRate": "0.1"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestLCTransfer"), []byte(tradeID)})
+
+	// Invoke bad 'submitBid' and verify that nothing is recorded
+	northSalt := "9f86d081884c7d65"
+	southSalt := "2c26b46b68ffc68f"
+	stub.setTransient(map[string]string{"discountRate": "0.25", "advanceRate": "0.5", "salt": northSalt})
+	checkBadInvoke(t, stub, [][]byte{[]byte("submitBid"), []byte(tradeID), []byte("EastBank")})
+	stub.setTransient(map[string]string{"discountRate": "1", "advanceRate": "0.5", "salt": northSalt})
+	checkBadInvoke(t, stub, [][]byte{[]byte("submitBid"), []byte(tradeID), []byte(northBank)})
+	stub.setTransient(map[string]string{"discountRate": "0.25", "advanceRate": "1.5", "salt": northSalt})
+	checkBadInvoke(t, stub, [][]byte{[]byte("submitBid"), []byte(tradeID), []byte(northBank)})
+	stub.setTransient(map[string]string{"discountRate": "0.25", "salt": northSalt})
+	checkBadInvoke(t, stub, [][]byte{[]byte("submitBid"), []byte(tradeID), []byte(northBank)})
+	stub.setTransient(map[string]string{"discountRate": "0.25", "advanceRate": "0.5"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("submitBid"), []byte(tradeID), []byte(northBank)})
+	stub.setTransient(map[string]string{"discountRate": "0.25", "advanceRate": "0.5", "salt": "short"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("submitBid"), []byte(tradeID), []byte(northBank)})
+	checkState(t, stub, receivableKey, string(receivableBytes))
+
+	// Invoke 'submitBid' for both lenders; a lender may revise its bid until the deadline, and only a commitment to its
+	// rates is recorded
+	stub.setTransient(map[string]string{"discountRate": "0.25", "advanceRate": "0.5", "salt": northSalt})
+	checkInvoke(t, stub, [][]byte{[]byte("submitBid"), []byte(tradeID), []byte(northBank)})
+	stub.setTxTime(t, "2019-01-15T23:59:59Z")
+	stub.setTransient(map[string]string{"discountRate": "0.125", "advanceRate": "0.75", "salt": southSalt})
+	checkInvoke(t, stub, [][]byte{[]byte("submitBid"), []byte(tradeID), []byte(southBank)})
+	stub.setTransient(map[string]string{"discountRate": "0.125", "advanceRate": "0.5", "salt": northSalt})
+	checkInvoke(t, stub, [][]byte{[]byte("submitBid"), []byte(tradeID), []byte(northBank)})
+	receivable.Bidders = []string{northBank, southBank}
+	receivableBytes, _ = json.Marshal(receivable)
+	checkState(t, stub, receivableKey, string(receivableBytes))
+	northBidKey, _ := stub.CreateCompositeKey("FinancingBid", []string{tradeID, northBank})
+	northCommitment, _ := sealBidTerms(0.125, 0.5, northSalt)
+	northBidBytes, _ := json.Marshal(&FinancingBid{tradeID, northBank, SUBMITTED, "2019-01-15T23:59:59Z", northCommitment, 0, 0, ""})
+	checkState(t, stub, northBidKey, string(northBidBytes))
+	checkNoPrivateState(t, stub, financingBidsCollection, northBidKey)
+
+	// Bids are sealed until the deadline, and then readable only by the exporter
+	checkBadInvoke(t, stub, [][]byte{[]byte("getBid"), []byte(tradeID), []byte(northBank)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("revealBid"), []byte(tradeID), []byte(northBank)})
+
+	// A bid cannot be accepted until bidding has closed and it has been revealed, and none can be submitted after
+	checkBadInvoke(t, stub, [][]byte{[]byte("acceptBid"), []byte(tradeID), []byte(southBank)})
+	stub.setTxTime(t, "2019-01-16T00:00:00Z")
+	checkBadInvoke(t, stub, [][]byte{[]byte("submitBid"), []byte(tradeID), []byte(northBank)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("acceptBid"), []byte(tradeID), []byte("EastBank")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("acceptBid"), []byte(tradeID), []byte(southBank)})
+	checkQueryArgs(t, stub, [][]byte{[]byte("getBid"), []byte(tradeID), []byte(northBank)}, string(northBidBytes))
+
+	// Invoke bad 'revealBid': the rates and salt must match the commitment
+	stub.setTransient(map[string]string{"discountRate": "0.25", "advanceRate": "0.5", "salt": northSalt})
+	checkBadInvoke(t, stub, [][]byte{[]byte("revealBid"), []byte(tradeID), []byte(northBank)})
+	stub.setTransient(map[string]string{"discountRate": "0.125", "advanceRate": "0.5", "salt": southSalt})
+	checkBadInvoke(t, stub, [][]byte{[]byte("revealBid"), []byte(tradeID), []byte(northBank)})
+	checkState(t, stub, northBidKey, string(northBidBytes))
+
+	// Invoke 'revealBid' for both lenders
+	stub.setTransient(map[string]string{"discountRate": "0.125", "advanceRate": "0.5", "salt": northSalt})
+	checkInvoke(t, stub, [][]byte{[]byte("revealBid"), []byte(tradeID), []byte(northBank)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("revealBid"), []byte(tradeID), []byte(northBank)})
+	stub.setTransient(map[string]string{"discountRate": "0.125", "advanceRate": "0.75", "salt": southSalt})
+	checkInvoke(t, stub, [][]byte{[]byte("revealBid"), []byte(tradeID), []byte(southBank)})
+	bidTermsBytes, _ := json.Marshal(&BidTerms{0.125, 0.5})
+	checkPrivateState(t, stub, financingBidsCollection, northBidKey, string(bidTermsBytes))
+	northBidBytes, _ = json.Marshal(&FinancingBid{tradeID, northBank, REVEALED, "2019-01-15T23:59:59Z", northCommitment, 0.125, 0.5, hashPrivateData(bidTermsBytes)})
+	checkState(t, stub, northBidKey, string(northBidBytes))
+	checkQueryArgs(t, stub, [][]byte{[]byte("getBid"), []byte(tradeID), []byte(northBank)}, "{\"advanceRate\":0.5,\"commitment\":\"" + northCommitment + "\",\"discountRate\":0.125,\"lender\":\"NorthBank\",\"status\":\"REVEALED\",\"submittedAt\":\"2019-01-15T23:59:59Z\",\"termsHash\":\"" + hashPrivateData(bidTermsBytes) + "\",\"tradeId\":\"2ks89j9\"}")
+
+	// Lenders cannot read bids, not even their own
+	scc.testMode = false
+	stub.setCreatorWithAttributes(t, "LenderOrgMSP", "ca.lenderorg.trade.com", "User1@lenderorg.trade.com", map[string]string{"lender": southBank})
+	checkBadInvoke(t, stub, [][]byte{[]byte("getBid"), []byte(tradeID), []byte(northBank)})
+	stub.setCreatorWithAttributes(t, "LenderOrgMSP", "ca.lenderorg.trade.com", "User1@lenderorg.trade.com", map[string]string{"lender": northBank})
+	checkBadInvoke(t, stub, [][]byte{[]byte("getBid"), []byte(tradeID), []byte(northBank)})
+	stub.setCreator(t, "ExporterOrgMSP", "ca.exporterorg.trade.com", "User1@exporterorg.trade.com")
+	checkQueryArgs(t, stub, [][]byte{[]byte("getBid"), []byte(tradeID), []byte(northBank)}, "{\"advanceRate\":0.5,\"commitment\":\"" + northCommitment + "\",\"discountRate\":0.125,\"lender\":\"NorthBank\",\"status\":\"REVEALED\",\"submittedAt\":\"2019-01-15T23:59:59Z\",\"termsHash\":\"" + hashPrivateData(bidTermsBytes) + "\",\"tradeId\":\"2ks89j9\"}")
+	scc.testMode = true
+
+	// Invoke 'acceptBid'; the L/C transfer to the winner is requested at its discount rate
+	checkInvoke(t, stub, [][]byte{[]byte("acceptBid"), []byte(tradeID), []byte(southBank)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("acceptBid"), []byte(tradeID), []byte(northBank)})
+	lcKey, _ := stub.CreateCompositeKey("LetterOfCredit", []string{tradeID})
+	letterOfCreditBytes, _ := json.Marshal(withLetterOfCreditTermsHash(&LetterOfCredit{"lc8349", "12/31/2019", EXPORTER, amount, []DocumentReference{{"E/L", ""}, {"B/L", ""}}, TRANSFER_REQUESTED, false, []LCTransfer{{southBank, REQUESTED, amount, 0.125, 0, "", "", false}}, ""}))
+	checkState(t, stub, lcKey, string(letterOfCreditBytes))
+	receivable.Status = AWARDED
+	receivable.WinningBidder = southBank
+	receivableBytes, _ = json.Marshal(receivable)
+	checkState(t, stub, receivableKey, string(receivableBytes))
+	northBidBytes, _ = json.Marshal(&FinancingBid{tradeID, northBank, REJECTED, "2019-01-15T23:59:59Z", northCommitment, 0.125, 0.5, hashPrivateData(bidTermsBytes)})
+	checkState(t, stub, northBidKey, string(northBidBytes))
+	southBidKey, _ := stub.CreateCompositeKey("FinancingBid", []string{tradeID, southBank})
+	bidTermsBytes, _ = json.Marshal(&BidTerms{0.125, 0.75})
+	southCommitment, _ := sealBidTerms(0.125, 0.75, southSalt)
+	southBidBytes, _ := json.Marshal(&FinancingBid{tradeID, southBank, ACCEPTED, "2019-01-15T23:59:59Z", southCommitment, 0.125, 0.75, hashPrivateData(bidTermsBytes)})
+	checkState(t, stub, southBidKey, string(southBidBytes))
+
+	// The transfer is accepted only by the winning lender
+	checkInvoke(t, stub, [][]byte{[]byte("issueLCTransfer"), []byte(tradeID)})
+	scc.testMode = false
+	stub.setCreatorWithAttributes(t, "LenderOrgMSP", "ca.lenderorg.trade.com", "User1@lenderorg.trade.com", map[string]string{"lender": northBank})
+	checkBadInvoke(t, stub, [][]byte{[]byte("acceptLCTransfer"), []byte(tradeID)})
+	stub.setCreator(t, "LenderOrgMSP", "ca.lenderorg.trade.com", "User1@lenderorg.trade.com")
+	checkBadInvoke(t, stub, [][]byte{[]byte("acceptLCTransfer"), []byte(tradeID)})
+	stub.setCreatorWithAttributes(t, "LenderOrgMSP", "ca.lenderorg.trade.com", "User1@lenderorg.trade.com", map[string]string{"lender": southBank})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptLCTransfer"), []byte(tradeID)})
+	scc.testMode = true
+
+	// Invoke 'makeAdvancePayment'; the winner advances its share of the discounted amount and holds back the rest
+	checkInvoke(t, stub, [][]byte{[]byte("requestAdvancePayment"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("makeAdvancePayment"), []byte(tradeID)})
+	advance := 32812
+	reserve := 43750 - advance
+	checkPrivateState(t, stub, accountBalancesCollection, expBalKey, strconv.Itoa(EXPBALANCE + advance))
+	checkPrivateState(t, stub, accountBalancesCollection, southBalKey, strconv.Itoa(80000 - advance))
+	checkPrivateState(t, stub, accountBalancesCollection, lenBalKey, strconv.Itoa(LENBALANCE))
+	receivableTermsBytes, _ = json.Marshal(&ReceivableTerms{reserve})
+	checkPrivateState(t, stub, tradeTermsCollection, receivableKey, string(receivableTermsBytes))
+	paymentKey, _ := stub.CreateCompositeKey("PaymentRecord", []string{tradeID, "1"})
+	paymentBytes, _ := json.Marshal(&PaymentRecord{"1", tradeID, "", ADVANCE_PAYMENT, "makeAdvancePayment", southBank, EXPORTER, advance, 0, 0, "2019-01-16T00:00:00Z", "1", ""})
+	checkPrivateState(t, stub, tradeTermsCollection, paymentKey, string(paymentBytes))
+
+	// The importer's payments go to the winner; the reserve is released to the exporter once the trade is paid in full
+	checkInvoke(t, stub, [][]byte{[]byte("acceptShipmentAndIssueBL"), []byte(tradeID), []byte("bl06678"), []byte("03/03/2019"), []byte("Woodlands Port"), []byte("Market Port")})
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeID), []byte("01/16/2019")})
+	checkPrivateState(t, stub, accountBalancesCollection, southBalKey, strconv.Itoa(80000 - advance + 25000))
+	checkPrivateState(t, stub, accountBalancesCollection, expBalKey, strconv.Itoa(EXPBALANCE + advance))
+	checkInvoke(t, stub, [][]byte{[]byte("updateShipmentLocation"), []byte(tradeID), []byte(DESTINATION), []byte("02/01/2019")})
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeID), []byte("02/10/2019")})
+	checkPrivateState(t, stub, accountBalancesCollection, southBalKey, strconv.Itoa(80000 - advance + amount - reserve))
+	checkPrivateState(t, stub, accountBalancesCollection, expBalKey, strconv.Itoa(EXPBALANCE + advance + reserve))
+	checkPrivateState(t, stub, accountBalancesCollection, impBalKey, strconv.Itoa(IMPBALANCE - amount))
+	paymentKey, _ = stub.CreateCompositeKey("PaymentRecord", []string{tradeID, "4"})
+	paymentBytes, _ = json.Marshal(&PaymentRecord{"4", tradeID, "", RESERVE_RELEASE, "makePayment", southBank, EXPORTER, reserve, 0, 0, "2019-01-16T00:00:00Z", "1", ""})
+	checkPrivateState(t, stub, tradeTermsCollection, paymentKey, string(paymentBytes))
+	receivableTermsBytes, _ = json.Marshal(&ReceivableTerms{0})
+	checkPrivateState(t, stub, tradeTermsCollection, receivableKey, string(receivableTermsBytes))
+
+	// The winner's financing position is settled, having advanced the reserve as well
+	financingPositionKey, _ := stub.CreateCompositeKey("FinancingPosition", []string{tradeID, southBank})
+	financingPositionTermsBytes, _ := json.Marshal(&FinancingPositionTerms{43750, amount, amount, 0, 0, 0, 0, 0})
+	checkPrivateState(t, stub, tradeTermsCollection, financingPositionKey, string(financingPositionTermsBytes))
+	financingPositionBytes, _ := json.Marshal(&FinancingPosition{tradeID, southBank, SETTLED, "12/31/2019", "", "", "2019-01-16T00:00:00Z", "2019-01-16T00:00:00Z", 0, 0, 0, 0, 0, 0, 0, 0, hashPrivateData(financingPositionTermsBytes)})
+	checkState(t, stub, financingPositionKey, string(financingPositionBytes))
+	checkBadInvoke(t, stub, [][]byte{[]byte("exerciseRecourse"), []byte(tradeID), []byte(southBank)})
+
+	// A registered lender lists the payments on its own account only
+	scc.testMode = false
+	stub.setCreatorWithAttributes(t, "LenderOrgMSP", "ca.lenderorg.trade.com", "User1@lenderorg.trade.com", map[string]string{"lender": northBank})
+	checkBadInvoke(t, stub, [][]byte{[]byte("listPayments"), []byte("account"), []byte("lender"), []byte(southBank)})
+	stub.setCreatorWithAttributes(t, "LenderOrgMSP", "ca.lenderorg.trade.com", "User1@lenderorg.trade.com", map[string]string{"lender": southBank})
+	checkBadInvoke(t, stub, [][]byte{[]byte("listPayments"), []byte("account"), []byte("lender")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("listPayments"), []byte("account"), []byte("lender"), []byte("EastBank")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("listPayments"), []byte("account"), []byte("importer"), []byte(southBank)})
+	southPayments := []PaymentRecord{
+		{"1", tradeID, "", ADVANCE_PAYMENT, "makeAdvancePayment", southBank, EXPORTER, advance, 0, 0, "2019-01-16T00:00:00Z", "1", ""},
+		{"2", tradeID, "", PAYMENT, "makePayment", IMPORTER, southBank, 25000, 0, 1, "2019-01-16T00:00:00Z", "1", ""},
+		{"3", tradeID, "", PAYMENT, "makePayment", IMPORTER, southBank, 25000, 0, 2, "2019-01-16T00:00:00Z", "1", ""},
+		{"4", tradeID, "", RESERVE_RELEASE, "makePayment", southBank, EXPORTER, reserve, 0, 0, "2019-01-16T00:00:00Z", "1", ""}}
+	statementBytes, _ := json.Marshal(&Statement{southBank, "01/16/2019", "01/16/2019", southPayments, amount, advance + reserve, amount - advance - reserve})
+	checkQueryArgs(t, stub, [][]byte{[]byte("getStatement"), []byte("lender"), []byte("01/16/2019"), []byte("01/16/2019"), []byte(southBank)}, string(statementBytes))
+	scc.testMode = true
+}
+
+func TestTradeWorkflow_PartialLCTransfers(t *testing.T) {
+	scc := new(TradeWorkflowChaincode)
+	scc.testMode = true
+	stub := newExtendedMockStub("Trade Workflow", scc)
+
+	// Init
+	stub.setTransient(getInitTransient())
+	checkInit(t, stub, getInitArguments())
+
+	// Take a trade through to the shipment, and register a second lender
+	tradeID := "2ks89j9"
+	amount := 50000
+	lcID := "lc8349"
+	lcExpirationDate := "12/31/2019"
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte("Wood for Toys")})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueLC"), []byte(tradeID), []byte(lcID), []byte(lcExpirationDate), []byte("E/L"), []byte("B/L")})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptLC"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestEL"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(tradeID), []byte("el979"), []byte("04/30/2019")})
+	checkInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptShipmentAndIssueBL"), []byte(tradeID), []byte("bl06678"), []byte("03/03/2019"), []byte("Woodlands Port"), []byte("Market Port")})
+	northBank := "NorthBank"
+	stub.setTransient(map[string]string{"balance": "150000"})
+	checkInvoke(t, stub, [][]byte{[]byte("registerLender"), []byte(northBank)})
+	documents := []DocumentReference{{"E/L", ""}, {"B/L", ""}}
+	lcKey, _ := stub.CreateCompositeKey("LetterOfCredit", []string{tradeID})
+
+	// Invoke bad 'requestLCTransfer' and verify unchanged state
+	letterOfCreditBytes, _ := json.Marshal(withLetterOfCreditTermsHash(&LetterOfCredit{lcID, lcExpirationDate, EXPORTER, amount, documents, ACCEPTED, false, nil, ""}))
+	stub.setTransient(map[string]string{"discountRate": "0.1", "amount": "0"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestLCTransfer"), []byte(tradeID)})
+	stub.setTransient(map[string]string{"discountRate": "0.1", "amount": strconv.Itoa(amount + 1)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestLCTransfer"), []byte(tradeID)})
+	stub.setTransient(map[string]string{"discountRate": "0.1", "amount": "20000"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestLCTransfer"), []byte(tradeID), []byte("EastBank")})
+	checkState(t, stub, lcKey, string(letterOfCreditBytes))
+
+	// Transfer a part of the L/C amount to the configured lender; no other transfer can start until it is accepted
+	checkInvoke(t, stub, [][]byte{[]byte("requestLCTransfer"), []byte(tradeID)})
+	stub.setTransient(map[string]string{"discountRate": "0.2", "amount": "15000"})
+	checkInvoke(t, stub, [][]byte{[]byte("requestLCTransfer"), []byte(tradeID), []byte(northBank)})
+	letterOfCreditBytes, _ = json.Marshal(withLetterOfCreditTermsHash(&LetterOfCredit{lcID, lcExpirationDate, EXPORTER, amount, documents, TRANSFER_REQUESTED, false, []LCTransfer{{LENDER, REQUESTED, 20000, 0.1, 0, "", "", false}}, ""}))
+	checkState(t, stub, lcKey, string(letterOfCreditBytes))
+	checkInvoke(t, stub, [][]byte{[]byte("issueLCTransfer"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptLCTransfer"), []byte(tradeID)})
+
+	// Transfer a further part to the second lender; the L/C cannot be transferred twice to the same lender, nor beyond
+	// the amount the exporter retains
+	stub.setTransient(map[string]string{"discountRate": "0.2", "amount": "15000"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestLCTransfer"), []byte(tradeID)})
+	stub.setTransient(map[string]string{"discountRate": "0.2", "amount": "30001"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestLCTransfer"), []byte(tradeID), []byte(northBank)})
+	stub.setTransient(map[string]string{"discountRate": "0.2", "amount": "15000"})
+	checkInvoke(t, stub, [][]byte{[]byte("requestLCTransfer"), []byte(tradeID), []byte(northBank)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueLCTransfer"), []byte(tradeID)})
+	scc.testMode = false
+	stub.setCreatorWithAttributes(t, "LenderOrgMSP", "ca.lenderorg.trade.com", "User1@lenderorg.trade.com", map[string]string{"lender": LENDER})
+	checkBadInvoke(t, stub, [][]byte{[]byte("acceptLCTransfer"), []byte(tradeID)})
+	stub.setCreatorWithAttributes(t, "LenderOrgMSP", "ca.lenderorg.trade.com", "User1@lenderorg.trade.com", map[string]string{"lender": northBank})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptLCTransfer"), []byte(tradeID)})
+	scc.testMode = true
+	transfers := []LCTransfer{{LENDER, ACCEPTED, 20000, 0.1, 0, "", "", false}, {northBank, ACCEPTED, 15000, 0.2, 0, "", "", false}}
+	letterOfCreditBytes, _ = json.Marshal(withLetterOfCreditTermsHash(&LetterOfCredit{lcID, lcExpirationDate, EXPORTER, amount, documents, TRANSFER_ACCEPTED, false, transfers, ""}))
+	checkState(t, stub, lcKey, string(letterOfCreditBytes))
+	letterOfCreditTermsBytes, _ := json.Marshal(&LetterOfCreditTerms{amount, []LCTransferTerms{{LENDER, 20000, 0.1, 0}, {northBank, 15000, 0.2, 0}}})
+	checkPrivateState(t, stub, tradeTermsCollection, lcKey, string(letterOfCreditTermsBytes))
+
+	// Each lender advances the discounted value of its own part
+	checkInvoke(t, stub, [][]byte{[]byte("requestAdvancePayment"), []byte(tradeID), []byte(LENDER)})
+	checkInvoke(t, stub, [][]byte{[]byte("makeAdvancePayment"), []byte(tradeID)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestAdvancePayment"), []byte(tradeID), []byte(LENDER)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestAdvancePayment"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("makeAdvancePayment"), []byte(tradeID)})
+	northBalKey, _ := stub.CreateCompositeKey("LenderBalance", []string{northBank})
+	checkPrivateState(t, stub, accountBalancesCollection, expBalKey, strconv.Itoa(EXPBALANCE + 18000 + 12000))
+	checkPrivateState(t, stub, accountBalancesCollection, lenBalKey, strconv.Itoa(LENBALANCE - 18000))
+	checkPrivateState(t, stub, accountBalancesCollection, northBalKey, strconv.Itoa(150000 - 12000))
+
+	// A second beneficiary can request payment for its part, a lender without a transfer cannot
+	scc.testMode = false
+	stub.setCreatorWithAttributes(t, "LenderOrgMSP", "ca.lenderorg.trade.com", "User1@lenderorg.trade.com", map[string]string{"lender": "EastBank"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeID)})
+	stub.setCreatorWithAttributes(t, "LenderOrgMSP", "ca.lenderorg.trade.com", "User1@lenderorg.trade.com", map[string]string{"lender": northBank})
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeID)})
+	scc.testMode = true
+
+	// Invoke 'makePayment'; the payment for the shipped goods is split pro rata between the beneficiaries
+	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeID), []byte("01/16/2019")})
+	checkPrivateState(t, stub, accountBalancesCollection, lenBalKey, strconv.Itoa(LENBALANCE - 18000 + 10000))
+	checkPrivateState(t, stub, accountBalancesCollection, northBalKey, strconv.Itoa(150000 - 12000 + 7500))
+	checkPrivateState(t, stub, accountBalancesCollection, expBalKey, strconv.Itoa(EXPBALANCE + 30000 + 7500))
+	checkPrivateState(t, stub, accountBalancesCollection, impBalKey, strconv.Itoa(IMPBALANCE - 25000))
+	transfers = []LCTransfer{{LENDER, ACCEPTED, 10000, 0.1, 0, "", "", true}, {northBank, ACCEPTED, 7500, 0.2, 0, "", "", true}}
+	letterOfCreditBytes, _ = json.Marshal(withLetterOfCreditTermsHash(&LetterOfCredit{lcID, lcExpirationDate, EXPORTER, 25000, documents, TRANSFER_ACCEPTED, false, transfers, ""}))
+	checkState(t, stub, lcKey, string(letterOfCreditBytes))
+
+	// The payment is recorded per beneficiary under one installment
+	for i, payee := range []string{LENDER, northBank, EXPORTER} {
+		id := strconv.Itoa(i + 3)
+		paymentKey, _ := stub.CreateCompositeKey("PaymentRecord", []string{tradeID, id})
+		paymentBytes, _ := json.Marshal(&PaymentRecord{id, tradeID, "", PAYMENT, "makePayment", IMPORTER, payee, []int{10000, 7500, 7500}[i], 0, 1, "2019-01-01T00:00:00Z", "1", ""})
+		checkPrivateState(t, stub, tradeTermsCollection, paymentKey, string(paymentBytes))
+	}
+
+	// Invoke 'makePayment' for the balance on delivery; every beneficiary is paid out in full
+	checkInvoke(t, stub, [][]byte{[]byte("updateShipmentLocation"), []byte(tradeID), []byte(DESTINATION), []byte("02/01/2019")})
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeID), []byte("02/10/2019")})
+	checkPrivateState(t, stub, accountBalancesCollection, lenBalKey, strconv.Itoa(LENBALANCE - 18000 + 20000))
+	checkPrivateState(t, stub, accountBalancesCollection, northBalKey, strconv.Itoa(150000 - 12000 + 15000))
+	checkPrivateState(t, stub, accountBalancesCollection, expBalKey, strconv.Itoa(EXPBALANCE + 30000 + 15000))
+	checkPrivateState(t, stub, accountBalancesCollection, impBalKey, strconv.Itoa(IMPBALANCE - amount))
+	transfers = []LCTransfer{{LENDER, ACCEPTED, 0, 0.1, 0, "", "", true}, {northBank, ACCEPTED, 0, 0.2, 0, "", "", true}}
+	letterOfCreditBytes, _ = json.Marshal(withLetterOfCreditTermsHash(&LetterOfCredit{lcID, lcExpirationDate, EXPORTER, 0, documents, TRANSFER_ACCEPTED, false, transfers, ""}))
+	checkState(t, stub, lcKey, string(letterOfCreditBytes))
+	paymentKey, _ := stub.CreateCompositeKey("PaymentRecord", []string{tradeID, "7"})
+	paymentBytes, _ := json.Marshal(&PaymentRecord{"7", tradeID, "", PAYMENT, "makePayment", IMPORTER, northBank, 7500, 0, 2, "2019-01-01T00:00:00Z", "1", ""})
+	checkPrivateState(t, stub, tradeTermsCollection, paymentKey, string(paymentBytes))
+}
+
+func TestTradeWorkflow_LenderRecourse(t *testing.T) {
+	scc := new(TradeWorkflowChaincode)
+	scc.testMode = true
+	stub := newExtendedMockStub("Trade Workflow", scc)
+
+	// Init
+	stub.setTransient(getInitTransient())
+	checkInit(t, stub, getInitArguments())
+
+	// Take a trade through to the shipment, and transfer a part of its L/C to the lender
+	tradeID := "2ks89j9"
+	amount := 50000
+	lcExpirationDate := "03/31/2019"
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte("Wood for Toys")})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueLC"), []byte(tradeID), []byte("lc8349"), []byte(lcExpirationDate), []byte("E/L"), []byte("B/L")})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptLC"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestEL"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(tradeID), []byte("el979"), []byte("04/30/2019")})
+	checkInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptShipmentAndIssueBL"), []byte(tradeID), []byte("bl06678"), []byte("03/03/2019"), []byte("Woodlands Port"), []byte("Market Port")})
+	stub.setTransient(map[string]string{"discountRate": "0.1", "amount": "20000"})
+	checkInvoke(t, stub, [][]byte{[]byte("requestLCTransfer"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueLCTransfer"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptLCTransfer"), []byte(tradeID)})
+
+	// No position is opened until the lender advances
+	checkBadInvoke(t, stub, [][]byte{[]byte("getFinancingPosition"), []byte(tradeID), []byte(LENDER)})
+
+	// Invoke 'makeAdvancePayment'; the lender's position expects its part of the L/C
+	checkInvoke(t, stub, [][]byte{[]byte("requestAdvancePayment"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("makeAdvancePayment"), []byte(tradeID)})
+	financingPositionKey, _ := stub.CreateCompositeKey("FinancingPosition", []string{tradeID, LENDER})
+	financingPositionTermsBytes, _ := json.Marshal(&FinancingPositionTerms{18000, 20000, 0, 20000, 0, 0, 0, 0})
+	checkPrivateState(t, stub, tradeTermsCollection, financingPositionKey, string(financingPositionTermsBytes))
+	financingPositionBytes, _ := json.Marshal(&FinancingPosition{tradeID, LENDER, OPEN, lcExpirationDate, "", "", "2019-01-01T00:00:00Z", "", 0, 0, 0, 0, 0, 0, 0, 0, hashPrivateData(financingPositionTermsBytes)})
+	checkState(t, stub, financingPositionKey, string(financingPositionBytes))
+
+	// Invoke 'makePayment' for the shipped goods; the lender's share is collected against its position
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeID), []byte("01/16/2019")})
+	financingPositionTermsBytes, _ = json.Marshal(&FinancingPositionTerms{18000, 20000, 10000, 10000, 0, 0, 0, 0})
+	checkQueryArgs(t, stub, [][]byte{[]byte("getFinancingPosition"), []byte(tradeID), []byte(LENDER)}, "{\"advanced\":18000,\"collected\":10000,\"expected\":20000,\"expirationDate\":\"03/31/2019\",\"lender\":\"LenderInc\",\"openedAt\":\"2019-01-01T00:00:00Z\",\"shortfall\":10000,\"status\":\"OPEN\",\"termsHash\":\"" + hashPrivateData(financingPositionTermsBytes) + "\",\"tradeId\":\"2ks89j9\"}")
+
+	// Invoke bad 'exerciseRecourse': the L/C is payable until the end of its expiration date, and only the lender
+	// that advanced has a position
+	stub.setTxTime(t, "2019-03-31T23:59:59Z")
+	checkBadInvoke(t, stub, [][]byte{[]byte("exerciseRecourse"), []byte(tradeID)})
+	stub.setTxTime(t, "2019-04-01T00:00:00Z")
+	stub.setTransient(map[string]string{"balance": "150000"})
+	checkInvoke(t, stub, [][]byte{[]byte("registerLender"), []byte("NorthBank")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("exerciseRecourse"), []byte(tradeID), []byte("NorthBank")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("exerciseRecourse"), []byte(tradeID), []byte("EastBank")})
+	scc.testMode = false
+	stub.setCreatorWithAttributes(t, "LenderOrgMSP", "ca.lenderorg.trade.com", "User1@lenderorg.trade.com", map[string]string{"lender": "NorthBank"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("exerciseRecourse"), []byte(tradeID), []byte(LENDER)})
+	scc.testMode = true
+	checkPrivateState(t, stub, accountBalancesCollection, expBalKey, strconv.Itoa(EXPBALANCE + 18000 + 15000))
+
+	// Invoke 'exerciseRecourse' once the L/C has expired unpaid; the exporter is debited for the shortfall
+	checkInvoke(t, stub, [][]byte{[]byte("exerciseRecourse"), []byte(tradeID)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("exerciseRecourse"), []byte(tradeID)})
+	checkPrivateState(t, stub, accountBalancesCollection, expBalKey, strconv.Itoa(EXPBALANCE + 18000 + 15000 - 10000))
+	checkPrivateState(t, stub, accountBalancesCollection, lenBalKey, strconv.Itoa(LENBALANCE - 18000 + 10000 + 10000))
+	financingPositionBytes, _ = json.Marshal(&FinancingPosition{tradeID, LENDER, RECOURSED, lcExpirationDate, "", "", "2019-01-01T00:00:00Z", "2019-04-01T00:00:00Z", 0, 0, 0, 0, 0, 0, 0, 0, hashPrivateData(financingPositionTermsBytes)})
+	checkState(t, stub, financingPositionKey, string(financingPositionBytes))
+	paymentKey, _ := stub.CreateCompositeKey("PaymentRecord", []string{tradeID, "4"})
+	paymentBytes, _ := json.Marshal(&PaymentRecord{"4", tradeID, "", RECOURSE, "exerciseRecourse", EXPORTER, LENDER, 10000, 0, 0, "2019-04-01T00:00:00Z", "1", ""})
+	checkPrivateState(t, stub, tradeTermsCollection, paymentKey, string(paymentBytes))
+
+	// Cancelling a financed trade refunds its payments and reopens the lender's position, on which recourse is open at once
+	tradeID = "7hd62k1"
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte("Wood for Toys")})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueLC"), []byte(tradeID), []byte("lc8350"), []byte("12/31/2019"), []byte("E/L"), []byte("B/L")})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptLC"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestEL"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(tradeID), []byte("el980"), []byte("12/31/2019")})
+	checkInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptShipmentAndIssueBL"), []byte(tradeID), []byte("bl06679"), []byte("12/31/2019"), []byte("Woodlands Port"), []byte("Market Port")})
+	stub.setTransient(map[string]string{"discountRate": "0.1", "amount": "20000"})
+	checkInvoke(t, stub, [][]byte{[]byte("requestLCTransfer"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueLCTransfer"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptLCTransfer"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestAdvancePayment"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("makeAdvancePayment"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeID), []byte("04/16/2019")})
+	checkPrivateState(t, stub, accountBalancesCollection, impBalKey, strconv.Itoa(IMPBALANCE - 25000 - 25000))
+	checkInvoke(t, stub, [][]byte{[]byte("openDispute"), []byte(tradeID), []byte("dsp-1"), []byte("Order placed in error")})
+	checkInvoke(t, stub, [][]byte{[]byte("resolveDispute"), []byte(tradeID), []byte(CANCEL), []byte("Cancelled by agreement")})
+	checkPrivateState(t, stub, accountBalancesCollection, impBalKey, strconv.Itoa(IMPBALANCE - 25000))
+	financingPositionKey, _ = stub.CreateCompositeKey("FinancingPosition", []string{tradeID, LENDER})
+	financingPositionTermsBytes, _ = json.Marshal(&FinancingPositionTerms{18000, 20000, 0, 20000, 0, 0, 0, 0})
+	checkPrivateState(t, stub, tradeTermsCollection, financingPositionKey, string(financingPositionTermsBytes))
+	checkInvoke(t, stub, [][]byte{[]byte("exerciseRecourse"), []byte(tradeID)})
+	financingPositionBytes, _ = json.Marshal(&FinancingPosition{tradeID, LENDER, RECOURSED, "12/31/2019", "", "", "2019-04-01T00:00:00Z", "2019-04-01T00:00:00Z", 0, 0, 0, 0, 0, 0, 0, 0, hashPrivateData(financingPositionTermsBytes)})
+	checkState(t, stub, financingPositionKey, string(financingPositionBytes))
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeID)})
+}
+
+func TestTradeWorkflow_InterestAccrual(t *testing.T) {
+	scc := new(TradeWorkflowChaincode)
+	scc.testMode = true
+	stub := newExtendedMockStub("Trade Workflow", scc)
+
+	// Init
+	stub.setTransient(getInitTransient())
+	checkInit(t, stub, getInitArguments())
+
+	// Take a trade through to the shipment
+	tradeID := "2ks89j9"
+	amount := 50000
+	lcID := "lc8349"
+	lcExpirationDate := "06/30/2019"
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte("Wood for Toys")})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueLC"), []byte(tradeID), []byte(lcID), []byte(lcExpirationDate), []byte("E/L"), []byte("B/L")})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptLC"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestEL"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueEL"), []byte(tradeID), []byte("el979"), []byte("04/30/2019")})
+	checkInvoke(t, stub, [][]byte{[]byte("prepareShipment"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptShipmentAndIssueBL"), []byte(tradeID), []byte("bl06678"), []byte("08/31/2019"), []byte("Woodlands Port"), []byte("Market Port")})
+	documents := []DocumentReference{{"E/L", ""}, {"B/L", ""}}
+	lcKey, _ := stub.CreateCompositeKey("LetterOfCredit", []string{tradeID})
+
+	// Invoke bad 'requestLCTransfer' and verify unchanged state
+	letterOfCreditBytes, _ := json.Marshal(withLetterOfCreditTermsHash(&LetterOfCredit{lcID, lcExpirationDate, EXPORTER, amount, documents, ACCEPTED, false, nil, ""}))
+	stub.setTransient(map[string]string{})
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestLCTransfer"), []byte(tradeID)})
+	stub.setTransient(map[string]string{"discountRate": "0.1", "annualRate": "0.06", "expectedPaymentDate": "03/02/2019"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestLCTransfer"), []byte(tradeID)})
+	stub.setTransient(map[string]string{"discountRate": "1"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestLCTransfer"), []byte(tradeID)})
+	stub.setTransient(map[string]string{"discountRate": "-0.1"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestLCTransfer"), []byte(tradeID)})
+	stub.setTransient(map[string]string{"annualRate": "0.06"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestLCTransfer"), []byte(tradeID)})
+	stub.setTransient(map[string]string{"annualRate": "1.5", "expectedPaymentDate": "03/02/2019"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestLCTransfer"), []byte(tradeID)})
+	stub.setTransient(map[string]string{"annualRate": "0.06", "dayCount": "ACT/ACT", "expectedPaymentDate": "03/02/2019"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestLCTransfer"), []byte(tradeID)})
+	stub.setTransient(map[string]string{"annualRate": "0.06", "expectedPaymentDate": "07/01/2019"})
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestLCTransfer"), []byte(tradeID)})
+	checkState(t, stub, lcKey, string(letterOfCreditBytes))
+
+	// Invoke 'requestLCTransfer' at an annual rate, under ACT/360 by default
+	stub.setTransient(map[string]string{"annualRate": "0.06", "expectedPaymentDate": "03/02/2019"})
+	checkInvoke(t, stub, [][]byte{[]byte("requestLCTransfer"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("issueLCTransfer"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("acceptLCTransfer"), []byte(tradeID)})
+	transfers := []LCTransfer{{LENDER, ACCEPTED, amount, 0, 0.06, ACT_360, "03/02/2019", false}}
+	letterOfCreditBytes, _ = json.Marshal(withLetterOfCreditTermsHash(&LetterOfCredit{lcID, lcExpirationDate, EXPORTER, amount, documents, TRANSFER_ACCEPTED, false, transfers, ""}))
+	checkState(t, stub, lcKey, string(letterOfCreditBytes))
+	letterOfCreditTermsBytes, _ := json.Marshal(&LetterOfCreditTerms{amount, []LCTransferTerms{{LENDER, amount, 0, 0.06}}})
+	checkPrivateState(t, stub, tradeTermsCollection, lcKey, string(letterOfCreditTermsBytes))
+
+	// Invoke 'makeAdvancePayment'; 60 days of interest to the expected payment date are charged up front
+	interest := 500
+	checkInvoke(t, stub, [][]byte{[]byte("requestAdvancePayment"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("makeAdvancePayment"), []byte(tradeID)})
+	checkPrivateState(t, stub, accountBalancesCollection, expBalKey, strconv.Itoa(EXPBALANCE + amount - interest))
+	checkPrivateState(t, stub, accountBalancesCollection, lenBalKey, strconv.Itoa(LENBALANCE - amount + interest))
+	financingPositionKey, _ := stub.CreateCompositeKey("FinancingPosition", []string{tradeID, LENDER})
+	financingPositionTermsBytes, _ := json.Marshal(&FinancingPositionTerms{amount - interest, amount, 0, amount, 0.06, interest, 0, 0})
+	checkPrivateState(t, stub, tradeTermsCollection, financingPositionKey, string(financingPositionTermsBytes))
+	financingPositionBytes, _ := json.Marshal(&FinancingPosition{tradeID, LENDER, OPEN, lcExpirationDate, ACT_360, "03/02/2019", "2019-01-01T00:00:00Z", "", 0, 0, 0, 0, 0, 0, 0, 0, hashPrivateData(financingPositionTermsBytes)})
+	checkState(t, stub, financingPositionKey, string(financingPositionBytes))
+
+	// Invoke 'makePayment' after 45 days; interest accrues on the amount collected
+	stub.setTxTime(t, "2019-02-15T10:00:00Z")
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeID), []byte("02/15/2019")})
+	financingPositionTermsBytes, _ = json.Marshal(&FinancingPositionTerms{amount - interest, amount, 25000, 25000, 0.06, interest, 187, 0})
+	checkPrivateState(t, stub, tradeTermsCollection, financingPositionKey, string(financingPositionTermsBytes))
+	checkQueryArgs(t, stub, [][]byte{[]byte("getFinancingPosition"), []byte(tradeID), []byte(LENDER)}, "{\"accrued\":187,\"advanced\":49500,\"annualRate\":0.06,\"collected\":25000,\"dayCount\":\"ACT/360\",\"expected\":50000,\"expectedPaymentDate\":\"03/02/2019\",\"expirationDate\":\"06/30/2019\",\"interest\":500,\"lender\":\"LenderInc\",\"openedAt\":\"2019-01-01T00:00:00Z\",\"shortfall\":25000,\"status\":\"OPEN\",\"termsHash\":\"" + hashPrivateData(financingPositionTermsBytes) + "\",\"tradeId\":\"2ks89j9\",\"trueUp\":0}")
+
+	// A refund reverses the interest accrued on the amount refunded
+	stub.setTransient(map[string]string{"amount": "10000"})
+	checkInvoke(t, stub, [][]byte{[]byte("refundPayment"), []byte(tradeID), []byte("2")})
+	financingPositionTermsBytes, _ = json.Marshal(&FinancingPositionTerms{amount - interest, amount, 15000, 35000, 0.06, interest, 187 - 75, 0})
+	checkPrivateState(t, stub, tradeTermsCollection, financingPositionKey, string(financingPositionTermsBytes))
+
+	// Invoke 'makePayment' for the balance after 90 days, later than expected; the exporter pays the interest accrued
+	// beyond that charged up front
+	stub.setTxTime(t, "2019-04-01T10:00:00Z")
+	checkInvoke(t, stub, [][]byte{[]byte("updateShipmentLocation"), []byte(tradeID), []byte(DESTINATION), []byte("03/25/2019")})
+	checkInvoke(t, stub, [][]byte{[]byte("requestPayment"), []byte(tradeID)})
+	checkInvoke(t, stub, [][]byte{[]byte("makePayment"), []byte(tradeID), []byte("04/01/2019")})
+	trueUp := 112 + 525 - interest
+	financingPositionTermsBytes, _ = json.Marshal(&FinancingPositionTerms{amount - interest, amount, amount, 0, 0.06, interest, 637, trueUp})
+	checkPrivateState(t, stub, tradeTermsCollection, financingPositionKey, string(financingPositionTermsBytes))
+	financingPositionBytes, _ = json.Marshal(&FinancingPosition{tradeID, LENDER, SETTLED, lcExpirationDate, ACT_360, "03/02/2019", "2019-01-01T00:00:00Z", "2019-04-01T10:00:00Z", 0, 0, 0, 0, 0, 0, 0, 0, hashPrivateData(financingPositionTermsBytes)})
+	checkState(t, stub, financingPositionKey, string(financingPositionBytes))
+	checkPrivateState(t, stub, accountBalancesCollection, expBalKey, strconv.Itoa(EXPBALANCE + amount - interest - trueUp))
+	checkPrivateState(t, stub, accountBalancesCollection, lenBalKey, strconv.Itoa(LENBALANCE + interest + trueUp))
+	checkPrivateState(t, stub, accountBalancesCollection, impBalKey, strconv.Itoa(IMPBALANCE - amount))
+	paymentKey, _ := stub.CreateCompositeKey("PaymentRecord", []string{tradeID, "5"})
+	paymentBytes, _ := json.Marshal(&PaymentRecord{"5", tradeID, "", TRUE_UP, "makePayment", EXPORTER, LENDER, trueUp, 0, 0, "2019-04-01T10:00:00Z", "1", ""})
+	checkPrivateState(t, stub, tradeTermsCollection, paymentKey, string(paymentBytes))
+
+	// Once the B/L is released to the importer, title has passed, and the payment can no longer be refunded
+	stub.setTransient(map[string]string{})
+	checkBadInvoke(t, stub, [][]byte{[]byte("refundPayment"), []byte(tradeID), []byte("4")})
+	checkPrivateState(t, stub, tradeTermsCollection, financingPositionKey, string(financingPositionTermsBytes))
+	checkPrivateState(t, stub, accountBalancesCollection, impBalKey, strconv.Itoa(IMPBALANCE - amount))
+}
+
+func TestTradeWorkflow_DualAuthorization(t *testing.T) {
+	scc := new(TradeWorkflowChaincode)
+	scc.testMode = false
+	scc.dualAuthorization = true
+	stub := newExtendedMockStub("Trade Workflow", scc)
+
+	// Init
+	stub.setTransient(getInitTransient())
+	checkInit(t, stub, getInitArguments())
+
+	// Invoke 'requestTrade', 'acceptTrade', 'requestLC'
+	tradeID := "2ks89j9"
+	amount := 50000
+	descGoods := "Wood for Toys"
+	stub.setCreator(t, "ImporterOrgMSP", "ca.importerorg.trade.com", "Maker@importerorg.trade.com")
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(descGoods)})
+	stub.setCreator(t, "ExporterOrgMSP", "ca.exporterorg.trade.com", "Maker@exporterorg.trade.com")
+	checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeID)})
+	stub.setCreator(t, "ImporterOrgMSP", "ca.importerorg.trade.com", "Maker@importerorg.trade.com")
+	checkInvoke(t, stub, [][]byte{[]byte("requestLC"), []byte(tradeID)})
+	letterOfCredit := withLetterOfCreditTermsHash(&LetterOfCredit{"", "", EXPORTER, amount, []DocumentReference{}, REQUESTED, false, nil, ""})
+	letterOfCreditBytes, _ := json.Marshal(letterOfCredit)
+	lcKey, _ := stub.CreateCompositeKey("LetterOfCredit", []string{tradeID})
+
+	// Invoke 'issueLC' as maker and verify that only a pending action is recorded
+	lcID := "lc8349"
+	expirationDate := "12/31/2018"
+	doc1 := "E/L"
+	doc2 := "B/L"
+	issueArgs := [][]byte{[]byte("issueLC"), []byte(tradeID), []byte(lcID), []byte(expirationDate), []byte(doc1), []byte(doc2)}
+	res := stub.MockInvoke("issueTx", issueArgs)
+	if res.Status != shim.OK || string(res.Payload) != "{\"ActionID\":\"issueTx\"}" {
+		fmt.Println("Invoke issueLC did not record a pending action", string(res.Message))
+		t.FailNow()
+	}
+	checkState(t, stub, lcKey, string(letterOfCreditBytes))
+	pendingActionKey, _ := stub.CreateCompositeKey("PendingAction", []string{"issueTx"})
+	inputsBytes := stub.privateData[tradeTermsCollection][pendingActionKey]
+	var inputs PendingActionInputs
+	json.Unmarshal(inputsBytes, &inputs)
+	if string(inputs.Transient["amount"]) != strconv.Itoa(amount) || string(inputs.Creator) != string(stub.creator) {
+		fmt.Println("Pending action did not capture the maker's identity and transient map")
+		t.FailNow()
+	}
+	pendingAction := &PendingAction{"issueTx", "issueLC", []string{tradeID, lcID, expirationDate, doc1, doc2}, "ImporterOrgMSP",
+		"CN=Maker@importerorg.trade.com,OU=client", "", PENDING_APPROVAL, "", "2019-01-01T00:00:00Z", "2019-01-04T00:00:00Z",
+		hashPrivateData(inputsBytes)}
+	pendingActionBytes, _ := json.Marshal(pendingAction)
+	checkState(t, stub, pendingActionKey, string(pendingActionBytes))
+	checkQuery(t, stub, "getPendingAction", "issueTx", string(pendingActionBytes))
+
+	// Submitting makes no one a participant, and an action on an unknown trade is refused
+	tradeParticipantsKey, _ := stub.CreateCompositeKey("TradeParticipants", []string{tradeID})
+	tradeParticipants := &TradeParticipants{REGAUTH, []TradeParticipant{
+		TradeParticipant{"ImporterOrgMSP", "", "ImportersBank", IMPBANK},
+		TradeParticipant{"ExporterOrgMSP", "", "Exporter", EXPORTER},
+		TradeParticipant{"ExporterOrgMSP", "", "ExportersBank", EXPBANK},
+		TradeParticipant{"CarrierOrgMSP", "", "Carrier", CARRIER},
+		TradeParticipant{"ImporterOrgMSP", "CN=Maker@importerorg.trade.com,OU=client", "", ""},
+		TradeParticipant{"ExporterOrgMSP", "CN=Maker@exporterorg.trade.com,OU=client", "", ""}}}
+	tradeParticipantsBytes, _ := json.Marshal(tradeParticipants)
+	checkState(t, stub, tradeParticipantsKey, string(tradeParticipantsBytes))
+	stub.setCreator(t, "ImporterOrgMSP", "ca.importerorg.trade.com", "Mallory@importerorg.trade.com")
+	checkBadInvoke(t, stub, [][]byte{[]byte("issueLC"), []byte("unknown"), []byte(lcID), []byte(expirationDate)})
+	res = stub.MockInvoke("otherTx", [][]byte{[]byte("makePayment"), []byte(tradeID)})
+	if res.Status != shim.OK {
+		fmt.Println("Invoke makePayment failed", string(res.Message))
+		t.FailNow()
+	}
+	checkState(t, stub, tradeParticipantsKey, string(tradeParticipantsBytes))
+	checkBadQuery(t, stub, "getTradeStatus", tradeID)
+
+	// Maker cannot approve their own action, nor can another org
+	stub.setCreator(t, "ImporterOrgMSP", "ca.importerorg.trade.com", "Maker@importerorg.trade.com")
+	checkBadInvoke(t, stub, [][]byte{[]byte("approveAction"), []byte("issueTx")})
+	stub.setCreator(t, "ExporterOrgMSP", "ca.exporterorg.trade.com", "Checker@exporterorg.trade.com")
+	checkBadInvoke(t, stub, [][]byte{[]byte("approveAction"), []byte("issueTx")})
+	checkBadQuery(t, stub, "getPendingAction", "issueTx")
+
+	// Nor can a user of the maker's MSP enrolled by another CA
+	stub.setCreator(t, "ImporterOrgMSP", "ca.otherorg.trade.com", "Checker@importerorg.trade.com")
+	checkBadInvoke(t, stub, [][]byte{[]byte("approveAction"), []byte("issueTx")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("rejectAction"), []byte("issueTx"), []byte("Not authorized")})
+	checkState(t, stub, lcKey, string(letterOfCreditBytes))
+
+	// Checker of the same org approves and the L/C gets issued
+	stub.setCreator(t, "ImporterOrgMSP", "ca.importerorg.trade.com", "Checker@importerorg.trade.com")
+	checkInvoke(t, stub, [][]byte{[]byte("approveAction"), []byte("issueTx")})
+	letterOfCredit = withLetterOfCreditTermsHash(&LetterOfCredit{lcID, expirationDate, EXPORTER, amount, []DocumentReference{{doc1, ""}, {doc2, ""}}, ISSUED, false, nil, ""})
+	letterOfCreditBytes, _ = json.Marshal(letterOfCredit)
+	checkState(t, stub, lcKey, string(letterOfCreditBytes))
+	pendingAction.Checker = "CN=Checker@importerorg.trade.com,OU=client"
+	pendingAction.Status = APPROVED
+	pendingActionBytes, _ = json.Marshal(pendingAction)
+	checkState(t, stub, pendingActionKey, string(pendingActionBytes))
+	tradeParticipants.Members = append(tradeParticipants.Members, TradeParticipant{"ImporterOrgMSP", "CN=Checker@importerorg.trade.com,OU=client", "", ""})
+	tradeParticipantsBytes, _ = json.Marshal(tradeParticipants)
+	checkState(t, stub, tradeParticipantsKey, string(tradeParticipantsBytes))
+	checkBadInvoke(t, stub, [][]byte{[]byte("approveAction"), []byte("issueTx")})
+
+	// Invoke 'acceptLC' as maker and have it rejected by a checker
+	stub.setCreator(t, "ExporterOrgMSP", "ca.exporterorg.trade.com", "Maker@exporterorg.trade.com")
+	res = stub.MockInvoke("acceptTx", [][]byte{[]byte("acceptLC"), []byte(tradeID)})
+	if res.Status != shim.OK {
+		fmt.Println("Invoke acceptLC failed", string(res.Message))
+		t.FailNow()
+	}
+	stub.setCreator(t, "ExporterOrgMSP", "ca.exporterorg.trade.com", "Checker@exporterorg.trade.com")
+	checkBadInvoke(t, stub, [][]byte{[]byte("rejectAction"), []byte("acceptTx")})
+	checkInvoke(t, stub, [][]byte{[]byte("rejectAction"), []byte("acceptTx"), []byte("Documents incomplete")})
+	pendingActionKey, _ = stub.CreateCompositeKey("PendingAction", []string{"acceptTx"})
+	pendingAction = &PendingAction{"acceptTx", "acceptLC", []string{tradeID}, "ExporterOrgMSP", "CN=Maker@exporterorg.trade.com,OU=client",
+		"CN=Checker@exporterorg.trade.com,OU=client", REJECTED, "Documents incomplete", "2019-01-01T00:00:00Z", "2019-01-04T00:00:00Z",
+		hashPrivateData(stub.privateData[tradeTermsCollection][pendingActionKey])}
+	pendingActionBytes, _ = json.Marshal(pendingAction)
+	checkState(t, stub, pendingActionKey, string(pendingActionBytes))
+	checkBadInvoke(t, stub, [][]byte{[]byte("approveAction"), []byte("acceptTx")})
+	checkState(t, stub, lcKey, string(letterOfCreditBytes))
+
+	// An action left unapproved past its expiry cannot be approved
+	stub.setCreator(t, "ExporterOrgMSP", "ca.exporterorg.trade.com", "Maker@exporterorg.trade.com")
+	res = stub.MockInvoke("lateTx", [][]byte{[]byte("acceptLC"), []byte(tradeID)})
+	if res.Status != shim.OK {
+		fmt.Println("Invoke acceptLC failed", string(res.Message))
+		t.FailNow()
+	}
+	stub.setCreator(t, "ExporterOrgMSP", "ca.exporterorg.trade.com", "Checker@exporterorg.trade.com")
+	stub.setTxTime(t, "2019-01-04T00:00:01Z")
+	checkBadInvoke(t, stub, [][]byte{[]byte("approveAction"), []byte("lateTx")})
+	checkState(t, stub, lcKey, string(letterOfCreditBytes))
+
+	// Approved in time, the action runs as its maker
+	stub.setTxTime(t, "2019-01-04T00:00:00Z")
+	checkInvoke(t, stub, [][]byte{[]byte("approveAction"), []byte("lateTx")})
+	letterOfCredit.Status = ACCEPTED
+	letterOfCreditBytes, _ = json.Marshal(letterOfCredit)
+	checkState(t, stub, lcKey, string(letterOfCreditBytes))
+}
+
+func TestTradeWorkflow_TradeReadAccess(t *testing.T) {
+	scc := new(TradeWorkflowChaincode)
+	scc.testMode = false
+	stub := newExtendedMockStub("Trade Workflow", scc)
+
+	// Init
+	stub.setTransient(getInitTransient())
+	checkInit(t, stub, getInitArguments())
+
+	// Two importers of the same org each request a trade, and the exporter accepts the first one
+	tradeID := "2ks89j9"
+	otherTradeID := "7hd62k1"
+	amount := 50000
+	descGoods := "Wood for Toys"
+	stub.setCreator(t, "ImporterOrgMSP", "ca.importerorg.trade.com", "Alice@importerorg.trade.com")
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(descGoods)})
+	stub.setCreator(t, "ImporterOrgMSP", "ca.importerorg.trade.com", "Bob@importerorg.trade.com")
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(otherTradeID), []byte(descGoods)})
+	stub.setCreator(t, "ExporterOrgMSP", "ca.exporterorg.trade.com", "Carol@exporterorg.trade.com")
+	checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeID)})
+
+	tradeParticipants := &TradeParticipants{REGAUTH, []TradeParticipant{
+		TradeParticipant{"ImporterOrgMSP", "", "ImportersBank", IMPBANK},
+		TradeParticipant{"ExporterOrgMSP", "", "Exporter", EXPORTER},
+		TradeParticipant{"ExporterOrgMSP", "", "ExportersBank", EXPBANK},
+		TradeParticipant{"CarrierOrgMSP", "", "Carrier", CARRIER},
+		TradeParticipant{"ImporterOrgMSP", "CN=Alice@importerorg.trade.com,OU=client", "", ""},
+		TradeParticipant{"ExporterOrgMSP", "CN=Carol@exporterorg.trade.com,OU=client", "", ""}}}
+	tradeParticipantsBytes, _ := json.Marshal(tradeParticipants)
+	tradeParticipantsKey, _ := stub.CreateCompositeKey("TradeParticipants", []string{tradeID})
+	checkState(t, stub, tradeParticipantsKey, string(tradeParticipantsBytes))
+
+	// A trade ID cannot be requested again
+	stub.setCreator(t, "ImporterOrgMSP", "ca.importerorg.trade.com", "Bob@importerorg.trade.com")
+	stub.setTransient(map[string]string{"amount": strconv.Itoa(amount)})
+	checkBadInvoke(t, stub, [][]byte{[]byte("requestTrade"), []byte(tradeID), []byte(descGoods)})
+
+	// Transactions that leave the trade as it was make no one a participant
+	stub.setCreator(t, "ExporterOrgMSP", "ca.exporterorg.trade.com", "Mallory@exporterorg.trade.com")
+	checkInvoke(t, stub, [][]byte{[]byte("acceptTrade"), []byte(tradeID)})
+	checkState(t, stub, tradeParticipantsKey, string(tradeParticipantsBytes))
+	checkBadQuery(t, stub, "getTradeStatus", tradeID)
+
+	// Participants can read their trade but not the other one
+	stub.setCreator(t, "ExporterOrgMSP", "ca.exporterorg.trade.com", "Carol@exporterorg.trade.com")
+	expectedResp := "{\"Status\":\"ACCEPTED\"}"
+	checkQuery(t, stub, "getTradeStatus", tradeID, expectedResp)
+	checkBadQuery(t, stub, "getTradeStatus", otherTradeID)
+	stub.setCreator(t, "ImporterOrgMSP", "ca.importerorg.trade.com", "Alice@importerorg.trade.com")
+	checkQuery(t, stub, "getTradeStatus", tradeID, expectedResp)
+	checkBadQuery(t, stub, "getTradeStatus", otherTradeID)
+
+	// Account balances are read through a trade the caller takes part in, and only by the holder's org
+	expectedResp = "{\"Balance\":\"" + strconv.Itoa(IMPBALANCE) + "\"}"
+	checkQueryArgs(t, stub, [][]byte{[]byte("getAccountBalance"), []byte(tradeID), []byte("importer")}, expectedResp)
+	checkBadInvoke(t, stub, [][]byte{[]byte("getAccountBalance"), []byte(otherTradeID), []byte("importer")})
+	checkBadInvoke(t, stub, [][]byte{[]byte("getAccountBalance"), []byte(tradeID), []byte("exporter")})
+	stub.setCreator(t, "ExporterOrgMSP", "ca.exporterorg.trade.com", "Carol@exporterorg.trade.com")
+	checkBadInvoke(t, stub, [][]byte{[]byte("getAccountBalance"), []byte(tradeID), []byte("importer")})
+
+	// Another member of the importer org cannot read the first trade
+	stub.setCreator(t, "ImporterOrgMSP", "ca.importerorg.trade.com", "Bob@importerorg.trade.com")
+	checkBadQuery(t, stub, "getTradeStatus", tradeID)
+	checkQuery(t, stub, "getTradeStatus", otherTradeID, "{\"Status\":\"REQUESTED\"}")
+
+	// The importer's bank and the carrier can read the trade before acting on it, but only in their own org's role, for the trade's party
+	stub.setCreatorWithAttributes(t, "ImporterOrgMSP", "ca.importerorg.trade.com", "Erin@importerorg.trade.com", map[string]string{"tradeRole": "ImportersBank", "tradeParty": IMPBANK})
+	checkQuery(t, stub, "getTradeStatus", tradeID, "{\"Status\":\"ACCEPTED\"}")
+	stub.setCreatorWithAttributes(t, "CarrierOrgMSP", "ca.carrierorg.trade.com", "Frank@carrierorg.trade.com", map[string]string{"tradeRole": "Carrier", "tradeParty": CARRIER})
+	checkQuery(t, stub, "getTradeStatus", tradeID, "{\"Status\":\"ACCEPTED\"}")
+	stub.setCreatorWithAttributes(t, "CarrierOrgMSP", "ca.carrierorg.trade.com", "Frank@carrierorg.trade.com", map[string]string{"tradeRole": "Carrier", "tradeParty": "OtherCarrier"})
+	checkBadQuery(t, stub, "getTradeStatus", tradeID)
+	stub.setCreatorWithAttributes(t, "CarrierOrgMSP", "ca.carrierorg.trade.com", "Frank@carrierorg.trade.com", map[string]string{"tradeRole": "ImportersBank", "tradeParty": IMPBANK})
+	checkBadQuery(t, stub, "getTradeStatus", tradeID)
+	stub.setCreator(t, "CarrierOrgMSP", "ca.carrierorg.trade.com", "Frank@carrierorg.trade.com")
+	checkBadQuery(t, stub, "getTradeStatus", tradeID)
+
+	// Regulators can read trades of their own jurisdiction only
+	stub.setCreator(t, "RegulatorOrgMSP", "ca.regulatororg.trade.com", "Dave@regulatororg.trade.com")
+	checkBadQuery(t, stub, "getTradeStatus", tradeID)
+	stub.setCreatorWithAttributes(t, "RegulatorOrgMSP", "ca.regulatororg.trade.com", "Dave@regulatororg.trade.com", map[string]string{"jurisdiction": "CustomsDepartment"})
+	checkBadQuery(t, stub, "getTradeStatus", tradeID)
+	stub.setCreatorWithAttributes(t, "RegulatorOrgMSP", "ca.regulatororg.trade.com", "Dave@regulatororg.trade.com", map[string]string{"jurisdiction": REGAUTH})
+	checkQuery(t, stub, "getTradeStatus", tradeID, "{\"Status\":\"ACCEPTED\"}")
+
+	// Only participants can attach documents, and the uploader is recorded
+	hash := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	uploadArgs := [][]byte{[]byte("uploadDocument"), []byte(tradeID), []byte("inv-001"), []byte("Trade"), []byte("Commercial Invoice"), []byte(hash),
+		[]byte("application/pdf"), []byte("48213"), []byte("https://docs.lumberinc.com/inv-001.pdf")}
+	stub.setCreator(t, "ImporterOrgMSP", "ca.importerorg.trade.com", "Bob@importerorg.trade.com")
+	checkBadInvoke(t, stub, uploadArgs)
+	stub.setCreator(t, "ExporterOrgMSP", "ca.exporterorg.trade.com", "Carol@exporterorg.trade.com")
+	checkInvoke(t, stub, uploadArgs)
+	document := &Document{"inv-001", tradeID, "", "Trade", "Commercial Invoice", hash, "application/pdf", 48213, "https://docs.lumberinc.com/inv-001.pdf",
+		"ExporterOrgMSP", "CN=Carol@exporterorg.trade.com,OU=client"}
+	documentBytes, _ := json.Marshal(document)
+	checkQueryArgs(t, stub, [][]byte{[]byte("getDocument"), []byte(tradeID), []byte("inv-001")}, string(documentBytes))
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright 2018 IBM All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the 'License');
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an 'AS IS' BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Returns nil if the L/C has not been transferred to the beneficiary
+func getLCTransfer(letterOfCredit *LetterOfCredit, beneficiary string) *LCTransfer {
+	for i := range letterOfCredit.Transfers {
+		if letterOfCredit.Transfers[i].Beneficiary == beneficiary {
+			return &letterOfCredit.Transfers[i]
+		}
+	}
+	return nil
+}
+
+// Returns nil if the L/C has not been transferred; only the latest transfer can be in progress
+func getLatestLCTransfer(letterOfCredit *LetterOfCredit) *LCTransfer {
+	if len(letterOfCredit.Transfers) == 0 {
+		return nil
+	}
+	return &letterOfCredit.Transfers[len(letterOfCredit.Transfers) - 1]
+}
+
+// The part of the L/C amount the first beneficiary retains, which is available for transfer
+func getLCRetainedAmount(letterOfCredit *LetterOfCredit) int {
+	var retained int
+
+	retained = letterOfCredit.Amount
+	for _, transfer := range letterOfCredit.Transfers {
+		retained -= transfer.Amount
+	}
+	return retained
+}
+
+// Split an amount between the second beneficiaries of an L/C and, last, its first beneficiary, pro rata to their parts
+// of the L/C amount; shares are rounded cumulatively so that they add up to the amount
+func splitLCAmount(letterOfCredit *LetterOfCredit, amount int) []int {
+	var shares []int
+	var total, allocated, previous, next int
+
+	shares = make([]int, len(letterOfCredit.Transfers) + 1)
+	total = letterOfCredit.Amount
+	for _, transfer := range letterOfCredit.Transfers {
+		allocated += transfer.Amount
+	}
+	if allocated > total {
+		total = allocated
+	}
+	if total <= 0 {
+		shares[len(letterOfCredit.Transfers)] = amount
+		return shares
+	}
+
+	allocated = 0
+	for i, transfer := range letterOfCredit.Transfers {
+		allocated += transfer.Amount
+		next = int(int64(amount) * int64(allocated) / int64(total))
+		shares[i] = next - previous
+		previous = next
+	}
+	shares[len(letterOfCredit.Transfers)] = amount - previous
+	return shares
+}
+
+// Whether the caller acts for a beneficiary with a part of the L/C amount: the exporter for the part it retains, or a
+// second beneficiary of an accepted transfer
+func authorizeLCBeneficiary(stub shim.ChaincodeStubInterface, creatorOrg string, creatorCertIssuer string, letterOfCredit *LetterOfCredit) bool {
+	if authenticateExporterOrg(creatorOrg, creatorCertIssuer) && getLCRetainedAmount(letterOfCredit) > 0 {
+		return true
+	}
+	for _, transfer := range letterOfCredit.Transfers {
+		if transfer.Status == ACCEPTED && transfer.Amount > 0 && authorizeLender(stub, creatorOrg, creatorCertIssuer, transfer.Beneficiary) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Pay an amount from the importer to the beneficiaries of an L/C, pro rata to their parts of the L/C amount, on the
+// balances given; the caller debits the importer
+// The L/C amount, and the parts of it allocated to second beneficiaries, are reduced by the amount settled, which
+// includes any penalty netted against the payment; a late surcharge is split with the payment
+// Returns the payment records to make, one per beneficiary paid
+func payLCBeneficiaries(stub shim.ChaincodeStubInterface, tradeID string, shipmentID string, function string, letterOfCredit *LetterOfCredit, amount int, settled int, surcharge int, balances map[string]int) ([]PaymentRecord, error) {
+	var payees []string
+	var amounts, shares, surcharges, reductions []int
+	var paymentRecords []PaymentRecord
+	var importerBytes []byte
+	var payee, payeeBalKey string
+	var remaining int
+	var err error
+
+	importerBytes, err = stub.GetState(impKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// The payment can only settle what is left of the L/C amount
+	shares = splitLCAmount(letterOfCredit, amount)
+	surcharges = splitLCAmount(letterOfCredit, surcharge)
+	remaining = settled
+	if remaining > letterOfCredit.Amount {
+		remaining = letterOfCredit.Amount
+	}
+	if remaining < 0 {
+		remaining = 0
+	}
+	reductions = splitLCAmount(letterOfCredit, remaining)
+
+	for i, share := range shares {
+		if i < len(letterOfCredit.Transfers) {
+			letterOfCredit.Transfers[i].Amount -= reductions[i]
+			payee = letterOfCredit.Transfers[i].Beneficiary
+		} else {
+			payee = letterOfCredit.Beneficiary
+		}
+		if share <= 0 {
+			continue
+		}
+
+		payeeBalKey, err = getBeneficiaryAccount(stub, payee)
+		if err != nil {
+			fmt.Printf("L/C for trade %s does not have vaild beneficiary\n", tradeID)
+			return nil, err
+		}
+		err = loadAccountBalance(stub, balances, payeeBalKey)
+		if err != nil {
+			return nil, err
+		}
+		balances[payeeBalKey] += share
+		payees = append(payees, payee)
+		amounts = append(amounts, share)
+		paymentRecords = append(paymentRecords, PaymentRecord{"", tradeID, shipmentID, PAYMENT, function, string(importerBytes), payee, share, surcharges[i], 0, "", "", ""})
+	}
+	letterOfCredit.Amount -= settled
+
+	// Evaluate the AML rules on the payment to each beneficiary
+	err = monitorPayments(stub, function, tradeID, string(importerBytes), payees, amounts)
+	if err != nil {
+		return nil, err
+	}
+	return paymentRecords, nil
+}
@@ -101,6 +101,14 @@ var ALL_FIVE_ORG_MEMBERS = {
 	}
 };
 
+// The orgs whose peers hold the trade terms and account balances collections (see collections_config.json)
+var ALL_TRADE_TERMS_ORG_MEMBERS = {
+	identities: FIVE_ORG_MEMBERS_AND_ADMIN,
+	policy: {
+		'3-of': [{ 'signed-by': 0 }, { 'signed-by': 1 }, { 'signed-by': 2 }]
+	}
+};
+
 var ALL_ORGS_EXCEPT_REGULATOR = {
 	identities: FOUR_ORG_MEMBERS_AND_ADMIN,
 	policy: {
@@ -131,12 +139,17 @@ var LENDER_ORG = 'lenderorg';
 var CARRIER_ORG = 'carrierorg';
 var REGULATOR_ORG = 'regulatororg';
 
+// Orgs whose peers endorse transactions; all orgs when not set
+var TRADE_TERMS_ORGS = [EXPORTER_ORG, LENDER_ORG, IMPORTER_ORG];
+var ENDORSING_ORGS = null;
+
 var CHANNEL_NAME = 'tradechannel';
 var CHAINCODE_PATH = 'github.com/trade_workflow';
 var CHAINCODE_ID = 'tradecc';
 var CHAINCODE_VERSION = 'v0';
 var CHAINCODE_UPGRADE_PATH = 'github.com/trade_workflow_v1';
 var CHAINCODE_UPGRADE_VERSION = 'v1';
+var CHAINCODE_UPGRADE_COLLECTIONS_CONFIG = 'collections_config.json';
 
 var TRANSACTION_ENDORSEMENT_POLICY = ALL_FOUR_ORG_MEMBERS;
 
@@ -152,13 +165,17 @@ module.exports = {
 	LENDER_ORG: LENDER_ORG,
 	CARRIER_ORG: CARRIER_ORG,
 	REGULATOR_ORG: REGULATOR_ORG,
+	TRADE_TERMS_ORGS: TRADE_TERMS_ORGS,
+	ENDORSING_ORGS: ENDORSING_ORGS,
 	CHANNEL_NAME: CHANNEL_NAME,
 	CHAINCODE_PATH: CHAINCODE_PATH,
 	CHAINCODE_ID: CHAINCODE_ID,
 	CHAINCODE_VERSION: CHAINCODE_VERSION,
 	CHAINCODE_UPGRADE_PATH: CHAINCODE_UPGRADE_PATH,
 	CHAINCODE_UPGRADE_VERSION: CHAINCODE_UPGRADE_VERSION,
+	CHAINCODE_UPGRADE_COLLECTIONS_CONFIG: CHAINCODE_UPGRADE_COLLECTIONS_CONFIG,
 	ALL_FOUR_ORG_MEMBERS: ALL_FOUR_ORG_MEMBERS,
 	ALL_FIVE_ORG_MEMBERS: ALL_FIVE_ORG_MEMBERS,
+	ALL_TRADE_TERMS_ORG_MEMBERS: ALL_TRADE_TERMS_ORG_MEMBERS,
 	TRANSACTION_ENDORSEMENT_POLICY: TRANSACTION_ENDORSEMENT_POLICY
 };
@@ -28,7 +28,8 @@ var queryCC = require('./query-chaincode.js');
 var tradeID = 'h87hfj4';
 
 Constants.networkConfig = './config_upgrade.json';	// Use the augmented configuration
-Constants.TRANSACTION_ENDORSEMENT_POLICY = Constants.ALL_FIVE_ORG_MEMBERS;	// Use the updated endorsement policy
+Constants.TRANSACTION_ENDORSEMENT_POLICY = Constants.ALL_TRADE_TERMS_ORG_MEMBERS;	// Use the updated endorsement policy
+Constants.ENDORSING_ORGS = Constants.TRADE_TERMS_ORGS;	// Only peers holding the private data collections endorse
 
 /////////////////////////////////
 // INVOKE AND QUERY OPERATIONS //
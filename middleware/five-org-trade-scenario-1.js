@@ -28,14 +28,15 @@ var queryCC = require('./query-chaincode.js');
 var tradeID = 'h87hi94';
 
 Constants.networkConfig = './config_upgrade.json';	// Use the augmented configuration
-Constants.TRANSACTION_ENDORSEMENT_POLICY = Constants.ALL_FIVE_ORG_MEMBERS;	// Use the updated endorsement policy
+Constants.TRANSACTION_ENDORSEMENT_POLICY = Constants.ALL_TRADE_TERMS_ORG_MEMBERS;	// Use the updated endorsement policy
+Constants.ENDORSING_ORGS = Constants.TRADE_TERMS_ORGS;	// Only peers holding the private data collections endorse
 
 /////////////////////////////////
 // INVOKE AND QUERY OPERATIONS //
 /////////////////////////////////
 
 // INVOKE: requestTrade (Importer)
-invokeCC.invokeChaincode(Constants.IMPORTER_ORG, Constants.CHAINCODE_VERSION, 'requestTrade', [tradeID, '50000','Wood for Toys'], 'Importer', Constants)
+invokeCC.invokeChaincode(Constants.IMPORTER_ORG, Constants.CHAINCODE_VERSION, 'requestTrade', [tradeID, 'Wood for Toys'], 'Importer', Constants, { amount: Buffer.from('50000') })
 .then(() => {
 	console.log('\n');
 	console.log('------------------------------');
@@ -140,7 +141,7 @@ invokeCC.invokeChaincode(Constants.IMPORTER_ORG, Constants.CHAINCODE_VERSION, 'r
 	console.log('\n');
 
 	// INVOKE: issueLC (Importer's Bank)
-	return invokeCC.invokeChaincode(Constants.IMPORTER_ORG, Constants.CHAINCODE_VERSION, 'issueLC', [tradeID, 'lc8349', '12/31/2018', 'E/L', 'B/L'], 'ImportersBank', Constants);
+	return invokeCC.invokeChaincodeWithApproval(Constants.IMPORTER_ORG, Constants.CHAINCODE_VERSION, 'issueLC', [tradeID, 'lc8349', '12/31/2018', 'E/L', 'B/L'], 'ImportersBank', 'ImportersBankChecker', Constants);
 }, (err) => {
 	console.log('\n');
 	console.log('------------------------');
@@ -178,7 +179,7 @@ invokeCC.invokeChaincode(Constants.IMPORTER_ORG, Constants.CHAINCODE_VERSION, 'r
 	console.log('\n');
 
 	// INVOKE: acceptLC (Exporter's Bank)
-	return invokeCC.invokeChaincode(Constants.EXPORTER_ORG, Constants.CHAINCODE_VERSION, 'acceptLC', [tradeID], 'ExportersBank', Constants);
+	return invokeCC.invokeChaincodeWithApproval(Constants.EXPORTER_ORG, Constants.CHAINCODE_VERSION, 'acceptLC', [tradeID], 'ExportersBank', 'ExportersBankChecker', Constants);
 }, (err) => {
 	console.log('\n');
 	console.log('------------------------');
@@ -387,7 +388,7 @@ invokeCC.invokeChaincode(Constants.IMPORTER_ORG, Constants.CHAINCODE_VERSION, 'r
 	console.log('\n');
 
 	// INVOKE: makePayment (Importer)
-	return invokeCC.invokeChaincode(Constants.IMPORTER_ORG, Constants.CHAINCODE_VERSION, 'makePayment', [tradeID, '01/01/2019'], 'Importer', Constants);
+	return invokeCC.invokeChaincodeWithApproval(Constants.IMPORTER_ORG, Constants.CHAINCODE_VERSION, 'makePayment', [tradeID, '01/01/2019'], 'Importer', 'ImporterChecker', Constants);
 }, (err) => {
 	console.log('\n');
 	console.log('-----------------------------');
@@ -520,7 +521,7 @@ invokeCC.invokeChaincode(Constants.IMPORTER_ORG, Constants.CHAINCODE_VERSION, 'r
 	console.log('\n');
 
 	// INVOKE: makePayment (Importer)
-	return invokeCC.invokeChaincode(Constants.IMPORTER_ORG, Constants.CHAINCODE_VERSION, 'makePayment', [tradeID, '03/01/2019'], 'Importer', Constants);
+	return invokeCC.invokeChaincodeWithApproval(Constants.IMPORTER_ORG, Constants.CHAINCODE_VERSION, 'makePayment', [tradeID, '03/01/2019'], 'Importer', 'ImporterChecker', Constants);
 }, (err) => {
 	console.log('\n');
 	console.log('-----------------------------');
@@ -30,7 +30,7 @@ var ClientUtils = require('./clientUtils.js');
 //
 // Construct instantiation or upgrade proposal
 //
-function buildChaincodeProposal(client, user_handle, chaincode_path, version, funcName, argList) {
+function buildChaincodeProposal(client, user_handle, chaincode_path, version, funcName, argList, transientMap, collectionsConfig) {
 	var tx_id = client.newTransactionID();
 
 	// send proposal to endorser
@@ -43,6 +43,12 @@ function buildChaincodeProposal(client, user_handle, chaincode_path, version, fu
 		txId: tx_id,
 		'endorsement-policy': Constants.TRANSACTION_ENDORSEMENT_POLICY
 	};
+	if (transientMap) {
+		request.transientMap = transientMap;
+	}
+	if (collectionsConfig) {
+		request['collections-config'] = path.join(__dirname, collectionsConfig);
+	}
 
 	return request;
 }
@@ -50,7 +56,7 @@ function buildChaincodeProposal(client, user_handle, chaincode_path, version, fu
 //
 // Send request for chaincode instantiation on the channel to the orderer
 //
-function instantiateOrUpgradeChaincode(userOrg, chaincode_path, version, funcName, argList, upgrade, constants) {
+function instantiateOrUpgradeChaincode(userOrg, chaincode_path, version, funcName, argList, upgrade, constants, transientMap, collectionsConfig) {
 	if (constants) {
 		Constants = constants;
 	}
@@ -140,7 +146,7 @@ function instantiateOrUpgradeChaincode(userOrg, chaincode_path, version, funcNam
 
 	}).then(() => {
 		logger.debug(' orglist:: ', channel.getOrganizations());
-		let request = buildChaincodeProposal(client, user_handle, chaincode_path, version, funcName, argList);
+		let request = buildChaincodeProposal(client, user_handle, chaincode_path, version, funcName, argList, transientMap, collectionsConfig);
 		tx_id = request.txId;
 		if (upgrade) {
 			logger.debug(util.format(
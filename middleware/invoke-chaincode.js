@@ -31,7 +31,7 @@ var ClientUtils = require('./clientUtils.js');
 // Send chaincode invocation request to the orderer
 //
 // If 'userName' is not specified, we will default to 'admin' for the org 'userOrg'
-function invokeChaincode(userOrg, version, funcName, argList, userName, constants) {
+function invokeChaincode(userOrg, version, funcName, argList, userName, constants, transientMap) {
 	if (constants) {
 		Constants = constants;
 	}
@@ -99,6 +99,10 @@ function invokeChaincode(userOrg, version, funcName, argList, userName, constant
 				if (key == userOrg) {
 					userPeer = peer;
 				}
+				// peers outside the private data collections cannot endorse; the user's own peer still delivers events
+				if (Constants.ENDORSING_ORGS && Constants.ENDORSING_ORGS.indexOf(key) < 0) {
+					continue;
+				}
 				channel.addPeer(peer);
 				targets.push(peer);	// Just for logging purposes
 			}
@@ -126,6 +130,9 @@ function invokeChaincode(userOrg, version, funcName, argList, userName, constant
 			args: argList,
 			txId: tx_id,
 		};
+		if (transientMap) {
+			request.transientMap = transientMap;
+		}
 		return channel.sendTransactionProposal(request);
 
 	}, (err) => {
@@ -239,7 +246,8 @@ function invokeChaincode(userOrg, version, funcName, argList, userName, constant
 		if (response.status === 'SUCCESS') {
 			console.log('Successfully sent transaction to the orderer.');
 			logger.debug('invokeChaincode end');
-			return true;
+			// the transaction ID identifies the pending action of a bank action needing approval
+			return tx_id.getTransactionID();
 		} else {
 			console.log('Failed to order the transaction. Error code: ' + response.status);
 			throw new Error('Failed to order the transaction. Error code: ' + response.status);
@@ -263,4 +271,14 @@ function invokeChaincode(userOrg, version, funcName, argList, userName, constant
 	});
 };
 
+// Bank actions need a maker and a checker: the maker's invocation is approved by a second user of the same org
+function invokeChaincodeWithApproval(userOrg, version, funcName, argList, userName, checkerName, constants, transientMap) {
+	return invokeChaincode(userOrg, version, funcName, argList, userName, constants, transientMap)
+	.then((actionID) => {
+		console.log('Action ' + actionID + ' (' + funcName + ') pending approval by', checkerName);
+		return invokeChaincode(userOrg, version, 'approveAction', [actionID], checkerName, constants);
+	});
+}
+
 module.exports.invokeChaincode = invokeChaincode;
+module.exports.invokeChaincodeWithApproval = invokeChaincodeWithApproval;
@@ -53,6 +53,10 @@ function queryChaincode(userOrg, version, funcName, argList, userName, constants
 	// set up the channel to use each org's 'peer1' for
 	// both requests and events
 	for (let key in ORGS) {
+		// with private data, only the user's own peer holds the collections of its org
+		if (Constants.ENDORSING_ORGS && key !== userOrg) {
+			continue;
+		}
 		if (ORGS.hasOwnProperty(key) && typeof ORGS[key].peer1 !== 'undefined') {
 			let data = fs.readFileSync(path.join(__dirname, ORGS[key].peer1['tls_cacerts']));
 			let peer = client.newPeer(
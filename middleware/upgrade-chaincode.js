@@ -25,7 +25,8 @@ var installCC = require('./install-chaincode.js');
 var instantiateCC = require('./instantiate-chaincode.js');
 
 Constants.networkConfig = './config_upgrade.json';	// Use the augmented configuration
-Constants.TRANSACTION_ENDORSEMENT_POLICY = Constants.ALL_FIVE_ORG_MEMBERS;	// Use the updated endorsement policy
+Constants.TRANSACTION_ENDORSEMENT_POLICY = Constants.ALL_TRADE_TERMS_ORG_MEMBERS;	// Use the updated endorsement policy
+Constants.ENDORSING_ORGS = Constants.TRADE_TERMS_ORGS;	// Only peers holding the private data collections endorse
 
 // Install a chaincode, and upon success, attempt to upgrade it on the channel
 installCC.installChaincode(Constants.CHAINCODE_UPGRADE_PATH, Constants.CHAINCODE_UPGRADE_VERSION, Constants).then(() => {
@@ -40,9 +41,12 @@ installCC.installChaincode(Constants.CHAINCODE_UPGRADE_PATH, Constants.CHAINCODE
 		Constants.CHAINCODE_UPGRADE_PATH,
 		Constants.CHAINCODE_UPGRADE_VERSION,
 		'init',
-		['LumberInc', 'LumberBank', '100000', 'WoodenToys', 'ToyBank', '300000', 'LenderInc', 'LenderBank', '100000', 'UniversalFrieght', 'ForestryDepartment'],
+		['LumberInc', 'LumberBank', 'WoodenToys', 'ToyBank', 'LenderInc', 'LenderBank', 'UniversalFrieght', 'ForestryDepartment'],
 		true,
-		Constants
+		Constants,
+		// Account balances are private to the trading parties and their banks
+		{ exporterBalance: Buffer.from('100000'), importerBalance: Buffer.from('300000'), lenderBalance: Buffer.from('100000') },
+		Constants.CHAINCODE_UPGRADE_COLLECTIONS_CONFIG
 	);
 }, (err) => {
 	console.log('\n');